@@ -3,10 +3,12 @@ package main
 import (
 	"context"
 	"log/slog"
+	"time"
 
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/go-chi/chi/v5"
 	"github.com/ponrove/configura"
+	"github.com/ponrove/ponrove-backend/internal/shutdown"
 	"github.com/ponrove/ponrove-backend/pkg/config"
 	"github.com/ponrove/ponrunner"
 )
@@ -30,4 +32,11 @@ func main() {
 	if err != nil {
 		slog.ErrorContext(ctx, "Failed to start runtime", slog.Any("error", err))
 	}
+
+	// Drain buffers and stop background work of the API bundles, once the HTTP server has stopped accepting requests.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Int64(ponrunner.SERVER_SHUTDOWN_TIMEOUT))*time.Second)
+	defer cancel()
+	if err := shutdown.Run(shutdownCtx); err != nil {
+		slog.ErrorContext(ctx, "Failed to shut down gracefully", slog.Any("error", err))
+	}
 }
@@ -0,0 +1,276 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ponrove/octobe/driver/clickhouse"
+	"github.com/ponrove/ponrove-backend/internal/filter"
+)
+
+// PropertyDimensionPrefix prefixes the key of a custom property to break down by, e.g. "property:plan".
+const PropertyDimensionPrefix = "property:"
+
+// maxPropertyKeyLength is the longest custom property key a breakdown groups by.
+const maxPropertyKeyLength = 128
+
+var (
+	ErrInvalidDimension = errors.New("invalid dimension")
+	ErrInvalidMetric    = errors.New("invalid metric")
+)
+
+// dimensionColumns maps the dimensions of raw_events to break down by to their expression. User input never ends up in
+// a query, only the expressions of this whitelist do. Absent UTM parameters are grouped as an empty value.
+var dimensionColumns = map[string]string{
+	"url_path":      "url_path",
+	"url_host":      "url_host",
+	"referrer_host": "referrer_host",
+	"utm_source":    "ifNull(utm_source, '')",
+	"utm_medium":    "ifNull(utm_medium, '')",
+	"utm_campaign":  "ifNull(utm_campaign, '')",
+	"utm_term":      "ifNull(utm_term, '')",
+	"utm_content":   "ifNull(utm_content, '')",
+	"country_code":  "country_code",
+	"region_name":   "region_name",
+	"city_name":     "city_name",
+	"browser_name":  "browser_name",
+	"os_name":       "os_name",
+	"device_type":   "device_type",
+	"event_name":    "event_name",
+}
+
+// Dimensions returns the dimensions of raw_events to break down by, sorted, custom properties aside.
+func Dimensions() []string {
+	names := make([]string, 0, len(dimensionColumns))
+	for name := range dimensionColumns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Dimension is what a breakdown groups events by, a column of raw_events or a custom property.
+type Dimension struct {
+	name        string
+	expression  string
+	propertyKey string
+}
+
+// ParseDimension parses the name of a dimension, one of Dimensions or a custom property key prefixed by
+// PropertyDimensionPrefix. ErrInvalidDimension is returned for anything else.
+func ParseDimension(name string) (Dimension, error) {
+	if key, ok := strings.CutPrefix(name, PropertyDimensionPrefix); ok {
+		if key == "" || len(key) > maxPropertyKeyLength {
+			return Dimension{}, ErrInvalidDimension
+		}
+		// The key is bound as an argument, never formatted into the query.
+		return Dimension{name: name, expression: "custom_properties[?]", propertyKey: key}, nil
+	}
+
+	expression, ok := dimensionColumns[name]
+	if !ok {
+		return Dimension{}, ErrInvalidDimension
+	}
+	return Dimension{name: name, expression: expression}, nil
+}
+
+// String returns the name of the dimension.
+func (d Dimension) String() string {
+	return d.name
+}
+
+// Metric is what the groups of a breakdown are sorted by.
+type Metric string
+
+const (
+	MetricVisitors  Metric = "visitors"
+	MetricPageviews Metric = "pageviews"
+	MetricEvents    Metric = "events"
+	MetricSessions  Metric = "sessions"
+)
+
+// metricColumns maps the metrics to their alias in the breakdown query.
+var metricColumns = map[Metric]string{
+	MetricVisitors:  "visitors",
+	MetricPageviews: "pageviews",
+	MetricEvents:    "events",
+	MetricSessions:  "sessions",
+}
+
+// BreakdownQuery selects the events of a breakdown and how its groups are paginated.
+type BreakdownQuery struct {
+	ProjectID string
+	From, To  time.Time
+	Filter    filter.Filter
+	Dimension Dimension
+	// SortBy is the metric the groups are sorted by, descending unless Ascending. Ties are sorted by value.
+	SortBy    Metric
+	Ascending bool
+	Limit     int
+	Offset    int
+	// Compare selects the window the range is compared to, none by default.
+	Compare Comparison
+	// Goals selects the events completing the goals whose conversions are counted, at most MaxGoals.
+	Goals []filter.Filter
+}
+
+// BreakdownMetrics holds the metrics of the events sharing a value of the dimension.
+type BreakdownMetrics struct {
+	Visitors  uint64
+	Pageviews uint64
+	Events    uint64
+	Sessions  uint64
+	// Conversions holds the conversions of every goal of the query, in order.
+	Conversions []GoalConversions
+}
+
+// BreakdownGroup holds the metrics of the events sharing a value of the dimension.
+type BreakdownGroup struct {
+	Value string
+	BreakdownMetrics
+	// Compare holds the metrics of the events of the value in the window the range is compared to, nil without
+	// comparison.
+	Compare *BreakdownMetrics
+}
+
+// Breakdown groups the events of the project in [q.From, q.To) matching the filter by the dimension, returning a page
+// of the groups and whether more follow. Events without the custom property of a property dimension are left out. Bot
+// traffic is excluded unless the filter compares is_bot. When compared, the groups are those of the range, with the
+// metrics of the same value in the window compared to, from the same query.
+func Breakdown(ctx context.Context, driver clickhouse.Driver, q BreakdownQuery) ([]BreakdownGroup, bool, error) {
+	if q.Dimension.expression == "" {
+		return nil, false, ErrInvalidDimension
+	}
+	sortColumn, ok := metricColumns[q.SortBy]
+	if !ok {
+		return nil, false, ErrInvalidMetric
+	}
+	direction := "DESC"
+	if q.Ascending {
+		direction = "ASC"
+	}
+	if len(q.Goals) > MaxGoals {
+		return nil, false, ErrTooManyGoals
+	}
+	windows, err := queryWindows(q.From, q.To, q.Compare)
+	if err != nil {
+		return nil, false, err
+	}
+
+	conditions, filterArgs := eventConditions(q.Filter)
+	goals, goalArgs := goalConditions(q.Goals)
+	var args []any
+	if q.Dimension.propertyKey != "" {
+		args = append(args, q.Dimension.propertyKey)
+	}
+	if len(windows) > 1 {
+		args = append(args, windows[0].from, windows[0].to, windows[1].from, windows[1].to)
+		for _, a := range goalArgs {
+			args = append(args, a...)
+		}
+	} else {
+		args = append(args, goalColumnArgs(goalArgs)...)
+	}
+	args = append(args, q.ProjectID)
+	for _, win := range windows {
+		args = append(args, win.from, win.to)
+	}
+	args = append(args, filterArgs...)
+	if q.Dimension.propertyKey != "" {
+		conditions += " AND mapContains(custom_properties, ?)"
+		args = append(args, q.Dimension.propertyKey)
+	}
+	// One more group than requested tells whether another page follows.
+	args = append(args, q.Limit+1, q.Offset)
+
+	var query string
+	if len(windows) == 1 {
+		query = fmt.Sprintf(`
+		SELECT
+			%s AS value,
+			uniqExactIf(visitor_fingerprint, visitor_fingerprint != '') AS visitors,
+			countIf(event_name = 'page_view') AS pageviews,
+			count() AS events,
+			uniqExactIf(session_id, session_id != '') AS sessions%s
+		FROM raw_events
+		WHERE project_id = ? AND event_timestamp >= ? AND event_timestamp < ?%s
+		GROUP BY value
+		ORDER BY %s %s, value
+		LIMIT ? OFFSET ?`, q.Dimension.expression, columnList(goalColumns(goals, "")), conditions, sortColumn, direction)
+	} else {
+		// The windows overlap when comparing a range longer than a year to the previous year, an event then counts in
+		// both. Goals are evaluated once per event, as goal_1, goal_2 and so on.
+		goalNames := make([]string, len(goals))
+		goalAliases := make([]string, len(goals))
+		for n, goal := range goals {
+			goalNames[n] = fmt.Sprintf("goal_%d", n+1)
+			goalAliases[n] = fmt.Sprintf("(%s) AS %s", goal, goalNames[n])
+		}
+		query = fmt.Sprintf(`
+		SELECT
+			value,
+			uniqExactIf(visitor_fingerprint, visitor_fingerprint != '' AND in_range) AS visitors,
+			countIf(event_name = 'page_view' AND in_range) AS pageviews,
+			countIf(in_range) AS events,
+			uniqExactIf(session_id, session_id != '' AND in_range) AS sessions%s,
+			uniqExactIf(visitor_fingerprint, visitor_fingerprint != '' AND in_comparison),
+			countIf(event_name = 'page_view' AND in_comparison),
+			countIf(in_comparison),
+			uniqExactIf(session_id, session_id != '' AND in_comparison)%s
+		FROM
+		(
+			SELECT
+				%s AS value,
+				visitor_fingerprint,
+				session_id,
+				event_name,
+				(event_timestamp >= ? AND event_timestamp < ?) AS in_range,
+				(event_timestamp >= ? AND event_timestamp < ?) AS in_comparison%s
+			FROM raw_events
+			WHERE project_id = ?
+				AND ((event_timestamp >= ? AND event_timestamp < ?) OR (event_timestamp >= ? AND event_timestamp < ?))%s
+		)
+		GROUP BY value
+		HAVING events > 0
+		ORDER BY %s %s, value
+		LIMIT ? OFFSET ?`,
+			columnList(goalColumns(goalNames, "in_range")), columnList(goalColumns(goalNames, "in_comparison")),
+			q.Dimension.expression, columnList(goalAliases), conditions, sortColumn, direction)
+	}
+
+	session, err := driver.Begin(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to begin ClickHouse session: %w", err)
+	}
+
+	var groups []BreakdownGroup
+	err = session.Builder()(query).Arguments(args...).Query(func(rows clickhouse.Rows) error {
+		for rows.Next() {
+			g := BreakdownGroup{BreakdownMetrics: BreakdownMetrics{Conversions: make([]GoalConversions, len(goals))}}
+			dest := []any{&g.Value, &g.Visitors, &g.Pageviews, &g.Events, &g.Sessions}
+			dest = append(dest, goalDestinations(g.Conversions)...)
+			if len(windows) > 1 {
+				g.Compare = &BreakdownMetrics{Conversions: make([]GoalConversions, len(goals))}
+				dest = append(dest, &g.Compare.Visitors, &g.Compare.Pageviews, &g.Compare.Events, &g.Compare.Sessions)
+				dest = append(dest, goalDestinations(g.Compare.Conversions)...)
+			}
+			if err := rows.Scan(dest...); err != nil {
+				return err
+			}
+			groups = append(groups, g)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to query breakdown: %w", err)
+	}
+
+	if len(groups) > q.Limit {
+		return groups[:q.Limit], true, nil
+	}
+	return groups, false, nil
+}
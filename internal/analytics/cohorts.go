@@ -0,0 +1,151 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ponrove/octobe/driver/clickhouse"
+	"github.com/ponrove/ponrove-backend/internal/filter"
+)
+
+// MaxCohorts is the most cohorts, and so periods, a retention query spans.
+const MaxCohorts = 366
+
+// MaxCohortLookback is the furthest before the range actors are looked for, bounding the events scanned to tell
+// actors first seen in the range from those seen before.
+const MaxCohortLookback = 365 * 24 * time.Hour
+
+var (
+	ErrInvalidIdentity = errors.New("invalid identity property")
+	ErrTooManyCohorts  = fmt.Errorf("retention spans more than %d cohorts", MaxCohorts)
+	ErrInvalidLookback = errors.New("invalid cohort lookback")
+)
+
+// Cohort holds how many of the actors first seen in a period came back in each following period.
+type Cohort struct {
+	// Start is the start of the period the actors of the cohort were first seen in.
+	Start time.Time
+	// Actors is the number of actors first seen in the period.
+	Actors uint64
+	// Returned holds the number of actors of the cohort active again in each following period, up to the end of the
+	// range, the first period after the cohort first.
+	Returned []uint64
+}
+
+// CohortQuery selects the events of a retention analysis and how its actors are grouped into cohorts.
+type CohortQuery struct {
+	ProjectID string
+	From, To  time.Time
+	Filter    filter.Filter
+	// Interval is the width of the periods, a day, a week or a month.
+	Interval Interval
+	Location *time.Location
+	// IdentityKey is the custom property identifying the actors across periods, e.g. a user ID. Visitor fingerprints
+	// rotate daily, so they never come back in a later period.
+	IdentityKey string
+	// Returning selects the events counting as a return, every event when empty.
+	Returning filter.Filter
+	// Lookback is how long before the range actors are looked for, at most MaxCohortLookback. Actors last seen before
+	// the lookback count as first seen when they come back in the range.
+	Lookback time.Duration
+}
+
+// Cohorts groups the actors of the project first seen in [q.From, q.To) by the period they were first seen in, and
+// counts those active again in every following period of the range, with the events matching the filter. Only the
+// events since q.Lookback before the range are scanned, actors seen within the lookback are left out. Bot traffic is
+// excluded unless the filter compares is_bot.
+func Cohorts(ctx context.Context, driver clickhouse.Driver, q CohortQuery) ([]Cohort, error) {
+	if q.Interval == IntervalHour {
+		return nil, ErrInvalidInterval
+	}
+	if q.IdentityKey == "" || len(q.IdentityKey) > maxPropertyKeyLength {
+		return nil, ErrInvalidIdentity
+	}
+	if q.Lookback < 0 || q.Lookback > MaxCohortLookback {
+		return nil, ErrInvalidLookback
+	}
+	buckets, err := q.Interval.Buckets(q.From, q.To, q.Location)
+	if err != nil {
+		return nil, err
+	}
+	if len(buckets) > MaxCohorts {
+		return nil, ErrTooManyCohorts
+	}
+
+	cohorts := make([]Cohort, len(buckets))
+	index := make(map[int64]int, len(buckets))
+	for n, b := range buckets {
+		cohorts[n] = Cohort{Start: b, Returned: make([]uint64, len(buckets)-n-1)}
+		index[b.Unix()] = n
+	}
+
+	expression := bucketExpressions[q.Interval]
+	periods := fmt.Sprintf("groupUniqArray(%s)", fmt.Sprintf(expression, "event_timestamp", q.Location.String()))
+	returning, returningArgs := q.Returning.SQL()
+	if returning != "" {
+		periods = fmt.Sprintf("groupUniqArrayIf(%s, %s)", fmt.Sprintf(expression, "event_timestamp", q.Location.String()), returning)
+	}
+	conditions, filterArgs := eventConditions(q.Filter)
+
+	// Arguments follow the order of their placeholders.
+	args := []any{q.IdentityKey}
+	args = append(args, returningArgs...)
+	args = append(args, q.ProjectID, q.From.Add(-q.Lookback), q.To, q.IdentityKey)
+	args = append(args, filterArgs...)
+	args = append(args, q.From)
+
+	session, err := driver.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin ClickHouse session: %w", err)
+	}
+
+	err = session.Builder()(fmt.Sprintf(`
+		SELECT
+			cohort,
+			period_start,
+			count()
+		FROM
+		(
+			SELECT
+				custom_properties[?] AS actor,
+				%s AS cohort,
+				%s AS periods
+			FROM raw_events
+			WHERE project_id = ? AND event_timestamp >= ? AND event_timestamp < ? AND custom_properties[?] != ''%s
+			GROUP BY actor
+			HAVING min(event_timestamp) >= ?
+		)
+		ARRAY JOIN arrayPushFront(arrayFilter(p -> p > cohort, periods), cohort) AS period_start
+		GROUP BY cohort, period_start`,
+		fmt.Sprintf(expression, "min(event_timestamp)", q.Location.String()), periods, conditions,
+	)).Arguments(args...).Query(func(rows clickhouse.Rows) error {
+		for rows.Next() {
+			var (
+				cohort, period time.Time
+				actors         uint64
+			)
+			if err := rows.Scan(&cohort, &period, &actors); err != nil {
+				return err
+			}
+
+			c, ok := index[cohort.Unix()]
+			p, found := index[period.Unix()]
+			if !ok || !found || p < c {
+				continue
+			}
+			if p == c {
+				cohorts[c].Actors = actors
+			} else {
+				cohorts[c].Returned[p-c-1] = actors
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cohorts: %w", err)
+	}
+
+	return cohorts, nil
+}
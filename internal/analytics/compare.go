@@ -0,0 +1,49 @@
+package analytics
+
+import (
+	"errors"
+	"time"
+)
+
+// Comparison is the window a query compares its range to.
+type Comparison string
+
+const (
+	// CompareNone doesn't compare the range.
+	CompareNone Comparison = ""
+	// ComparePreviousPeriod compares the range to the range of the same length right before it.
+	ComparePreviousPeriod Comparison = "previous_period"
+	// ComparePreviousYear compares the range to the same range a year earlier.
+	ComparePreviousYear Comparison = "previous_year"
+)
+
+var ErrInvalidComparison = errors.New("invalid comparison")
+
+// Window returns the window [from, to) is compared to.
+func (c Comparison) Window(from, to time.Time) (time.Time, time.Time, error) {
+	switch c {
+	case ComparePreviousPeriod:
+		return from.Add(-to.Sub(from)), from, nil
+	case ComparePreviousYear:
+		return from.AddDate(-1, 0, 0), to.AddDate(-1, 0, 0), nil
+	default:
+		return time.Time{}, time.Time{}, ErrInvalidComparison
+	}
+}
+
+// window is a range of events a query selects, [from, to).
+type window struct {
+	from, to time.Time
+}
+
+// queryWindows returns the range of a query, followed by the window it is compared to, if any.
+func queryWindows(from, to time.Time, c Comparison) ([]window, error) {
+	if c == CompareNone {
+		return []window{{from, to}}, nil
+	}
+	compareFrom, compareTo, err := c.Window(from, to)
+	if err != nil {
+		return nil, err
+	}
+	return []window{{from, to}, {compareFrom, compareTo}}, nil
+}
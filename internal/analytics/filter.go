@@ -0,0 +1,22 @@
+package analytics
+
+import (
+	"strings"
+
+	"github.com/ponrove/ponrove-backend/internal/filter"
+)
+
+// eventConditions returns the conditions, each prefixed by AND, selecting the events of raw_events matching the filter
+// and their arguments. Bot traffic is excluded unless the filter compares is_bot.
+func eventConditions(f filter.Filter) (string, []any) {
+	var b strings.Builder
+	if !f.References("is_bot") {
+		b.WriteString(" AND is_bot = 0")
+	}
+	condition, args := f.SQL()
+	if condition != "" {
+		b.WriteString(" AND ")
+		b.WriteString(condition)
+	}
+	return b.String(), args
+}
@@ -0,0 +1,200 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/ponrove/octobe/driver/clickhouse"
+	"github.com/ponrove/ponrove-backend/internal/filter"
+)
+
+const (
+	// MinFunnelSteps and MaxFunnelSteps bound the number of steps of a funnel.
+	MinFunnelSteps = 2
+	MaxFunnelSteps = 10
+	// MaxFunnelWindow is the longest conversion window of a funnel.
+	MaxFunnelWindow = 90 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidFunnel = errors.New("invalid funnel")
+	ErrInvalidActor  = errors.New("invalid funnel actor")
+)
+
+// FunnelActor is who goes through the steps of a funnel.
+type FunnelActor string
+
+const (
+	FunnelByVisitor FunnelActor = "visitor"
+	FunnelBySession FunnelActor = "session"
+)
+
+// actorColumns maps the actors of a funnel to the column identifying them.
+var actorColumns = map[FunnelActor]string{
+	FunnelByVisitor: "visitor_fingerprint",
+	FunnelBySession: "session_id",
+}
+
+// FunnelStep is a step of a funnel, the events matching its filter.
+type FunnelStep struct {
+	Filter filter.Filter
+}
+
+// FunnelQuery selects the events of a funnel, its steps and how its actors are grouped.
+type FunnelQuery struct {
+	ProjectID string
+	From, To  time.Time
+	Filter    filter.Filter
+	Steps     []FunnelStep
+	// Window is how long after their first step actors have to complete the following steps, in whole seconds.
+	Window time.Duration
+	By     FunnelActor
+	// Breakdown groups the actors by the value of the dimension at their first event of the first step, not grouped
+	// when nil.
+	Breakdown *Dimension
+	// Limit is the most groups of a breakdown, those with the most actors entering the funnel first.
+	Limit int
+}
+
+// FunnelGroup holds how far the actors sharing a value of the dimension went through the funnel.
+type FunnelGroup struct {
+	// Value is the value of the dimension, empty without breakdown.
+	Value string
+	// Reached holds the number of actors reaching each step, within the window of their first step.
+	Reached []uint64
+	// MedianTimes holds the median time between the first event of each step and the first event of the previous
+	// step, from the second step on, for the actors reaching the step whose first events of both steps are in order.
+	// windowFunnel only reports how far actors went, so these are the first events rather than those of the matched
+	// sequence. Nil when no actor reached the step.
+	MedianTimes []*time.Duration
+}
+
+// Funnel computes how far the actors of the project go through the steps of the funnel in order, with the events in
+// [q.From, q.To) matching the filter, using windowFunnel. Bot traffic is excluded unless the filter compares is_bot.
+func Funnel(ctx context.Context, driver clickhouse.Driver, q FunnelQuery) ([]FunnelGroup, error) {
+	if len(q.Steps) < MinFunnelSteps || len(q.Steps) > MaxFunnelSteps || q.Window < time.Second || q.Window > MaxFunnelWindow {
+		return nil, ErrInvalidFunnel
+	}
+	actor, ok := actorColumns[q.By]
+	if !ok {
+		return nil, ErrInvalidActor
+	}
+
+	steps := make([]string, len(q.Steps))
+	stepArgs := make([][]any, len(q.Steps))
+	for n, step := range q.Steps {
+		if step.Filter.Empty() {
+			return nil, ErrInvalidFunnel
+		}
+		steps[n], stepArgs[n] = step.Filter.SQL()
+	}
+
+	// Columns are listed in the order of their placeholders, the arguments follow the same order.
+	var (
+		reached, medians, firstEvents []string
+		args                          []any
+	)
+	for n := range q.Steps {
+		reached = append(reached, fmt.Sprintf("countIf(level >= %d)", n+1))
+		if n > 0 {
+			medians = append(medians, fmt.Sprintf("quantileIf(0.5)(toUnixTimestamp64Milli(step_%[1]d_at) - toUnixTimestamp64Milli(step_%[2]d_at), level >= %[1]d AND step_%[1]d_at >= step_%[2]d_at)", n+1, n))
+		}
+		firstEvents = append(firstEvents, fmt.Sprintf("minIf(event_timestamp, %s) AS step_%d_at", steps[n], n+1))
+	}
+
+	value := "''"
+	if q.Breakdown != nil {
+		value = fmt.Sprintf("argMinIf(%s, event_timestamp, %s)", q.Breakdown.expression, steps[0])
+		if q.Breakdown.propertyKey != "" {
+			args = append(args, q.Breakdown.propertyKey)
+		}
+		args = append(args, stepArgs[0]...)
+	}
+	for _, a := range stepArgs {
+		args = append(args, a...)
+	}
+	for _, a := range stepArgs {
+		args = append(args, a...)
+	}
+	conditions, filterArgs := eventConditions(q.Filter)
+	args = append(args, q.ProjectID, q.From, q.To)
+	args = append(args, filterArgs...)
+	for _, a := range stepArgs {
+		args = append(args, a...)
+	}
+	args = append(args, q.Limit)
+
+	query := fmt.Sprintf(`
+		SELECT
+			value,
+			%s,
+			%s
+		FROM
+		(
+			SELECT
+				%s AS actor,
+				%s AS value,
+				windowFunnel(%d)(toDateTime(event_timestamp), %s) AS level,
+				%s
+			FROM raw_events
+			WHERE project_id = ? AND event_timestamp >= ? AND event_timestamp < ? AND %s != ''%s
+				AND (%s)
+			GROUP BY actor
+		)
+		GROUP BY value
+		ORDER BY countIf(level >= 1) DESC, value
+		LIMIT ?`,
+		strings.Join(reached, ",\n\t\t\t"),
+		strings.Join(medians, ",\n\t\t\t"),
+		actor,
+		value,
+		int64(q.Window/time.Second), strings.Join(steps, ", "),
+		strings.Join(firstEvents, ",\n\t\t\t\t"),
+		actor, conditions,
+		strings.Join(steps, " OR "),
+	)
+
+	session, err := driver.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin ClickHouse session: %w", err)
+	}
+
+	var groups []FunnelGroup
+	err = session.Builder()(query).Arguments(args...).Query(func(rows clickhouse.Rows) error {
+		for rows.Next() {
+			g := FunnelGroup{Reached: make([]uint64, len(q.Steps))}
+			medians := make([]float64, len(q.Steps)-1)
+			dest := []any{&g.Value}
+			for n := range g.Reached {
+				dest = append(dest, &g.Reached[n])
+			}
+			for n := range medians {
+				dest = append(dest, &medians[n])
+			}
+			if err := rows.Scan(dest...); err != nil {
+				return err
+			}
+
+			g.MedianTimes = make([]*time.Duration, len(medians))
+			for n, median := range medians {
+				// ClickHouse returns NaN quantiles without samples.
+				if g.Reached[n+1] == 0 || math.IsNaN(median) {
+					continue
+				}
+				d := time.Duration(median * float64(time.Millisecond))
+				g.MedianTimes[n] = &d
+			}
+			groups = append(groups, g)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query funnel: %w", err)
+	}
+
+	return groups, nil
+}
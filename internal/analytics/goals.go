@@ -0,0 +1,79 @@
+package analytics
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ponrove/ponrove-backend/internal/filter"
+)
+
+// MaxGoals is the most goals a single query reports conversions for.
+const MaxGoals = 10
+
+var ErrTooManyGoals = fmt.Errorf("more than %d goals", MaxGoals)
+
+// GoalConversions holds how often a goal was completed.
+type GoalConversions struct {
+	// Visitors is the number of unique visitors completing the goal.
+	Visitors uint64
+	// Completions is the number of events completing the goal.
+	Completions uint64
+}
+
+// goalConditions returns the condition selecting the events completing every goal, and the arguments of each.
+func goalConditions(goals []filter.Filter) ([]string, [][]any) {
+	conditions := make([]string, len(goals))
+	args := make([][]any, len(goals))
+	for n, g := range goals {
+		conditions[n], args[n] = g.SQL()
+		if conditions[n] == "" {
+			conditions[n] = "1"
+		}
+	}
+	return conditions, args
+}
+
+// goalColumns returns the columns counting the conversions of the goals of the conditions, restricted to the rows of
+// the window unless empty. Every goal has two columns, its visitors then its completions, binding the arguments of its
+// condition in turn. Events without a visitor, server-side events sent without one, complete a goal without converting
+// a visitor.
+func goalColumns(conditions []string, window string) []string {
+	columns := make([]string, 0, 2*len(conditions))
+	for _, condition := range conditions {
+		if window != "" {
+			condition = window + " AND (" + condition + ")"
+		}
+		columns = append(columns, fmt.Sprintf("uniqExactIf(visitor_fingerprint, visitor_fingerprint != '' AND (%s))", condition), fmt.Sprintf("countIf(%s)", condition))
+	}
+	return columns
+}
+
+// columnList joins the columns of a SELECT, each preceded by a comma so the list follows other columns, empty
+// without columns.
+func columnList(columns []string) string {
+	var b strings.Builder
+	for _, c := range columns {
+		b.WriteString(",\n\t\t\t")
+		b.WriteString(c)
+	}
+	return b.String()
+}
+
+// goalColumnArgs returns the arguments of the columns of goalColumns, in order.
+func goalColumnArgs(args [][]any) []any {
+	var all []any
+	for _, a := range args {
+		all = append(all, a...)
+		all = append(all, a...)
+	}
+	return all
+}
+
+// goalDestinations returns the scan destinations of the columns of goalColumns, into conversions.
+func goalDestinations(conversions []GoalConversions) []any {
+	dest := make([]any, 0, 2*len(conversions))
+	for n := range conversions {
+		dest = append(dest, &conversions[n].Visitors, &conversions[n].Completions)
+	}
+	return dest
+}
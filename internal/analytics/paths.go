@@ -0,0 +1,149 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ponrove/octobe/driver/clickhouse"
+	"github.com/ponrove/ponrove-backend/internal/filter"
+)
+
+const (
+	// MinPathDepth and MaxPathDepth bound the number of steps of a path.
+	MinPathDepth = 2
+	MaxPathDepth = 10
+)
+
+var (
+	ErrInvalidPathStep = errors.New("invalid path step")
+	ErrInvalidPath     = errors.New("invalid path")
+)
+
+// PathStep is what the steps of a path are, the pages viewed or the events sent.
+type PathStep string
+
+const (
+	PathStepURLPath   PathStep = "url_path"
+	PathStepEventName PathStep = "event_name"
+)
+
+// pathSteps maps the steps of a path to the expression of a step and the condition on the events making up steps.
+var pathSteps = map[PathStep]struct{ expression, condition string }{
+	PathStepURLPath:   {"url_path", " AND event_name = 'page_view'"},
+	PathStepEventName: {"event_name", ""},
+}
+
+// PathQuery selects the events of the sessions whose paths are explored, and which part of the paths.
+type PathQuery struct {
+	ProjectID string
+	From, To  time.Time
+	Filter    filter.Filter
+	Step      PathStep
+	// Start selects the paths starting at the first occurrence of the step in a session, End those ending at it. Paths
+	// start at the first step of the session when both are empty, and only one of them can be set.
+	Start, End string
+	// Depth is the most steps of a path.
+	Depth int
+	// Limit is the most paths returned, those of the most sessions first.
+	Limit int
+	// MinShare leaves out the paths of a smaller share of the sessions going through the step, between 0 and 1.
+	MinShare float64
+}
+
+// Path is a sequence of steps and the number of sessions going through it.
+type Path struct {
+	Steps    []string
+	Sessions uint64
+}
+
+// PathsResult holds the most common paths, and the sessions they are a share of.
+type PathsResult struct {
+	Paths []Path
+	// Sessions is the number of sessions going through the start or end step, every session with steps without them.
+	Sessions uint64
+}
+
+// Paths returns the most common sequences of steps of the sessions of the project, with the events in [q.From, q.To)
+// matching the filter. Repeated steps, such as reloads of a page, count as a single step. Bot traffic is excluded
+// unless the filter compares is_bot.
+func Paths(ctx context.Context, driver clickhouse.Driver, q PathQuery) (PathsResult, error) {
+	step, ok := pathSteps[q.Step]
+	if !ok {
+		return PathsResult{}, ErrInvalidPathStep
+	}
+	if q.Depth < MinPathDepth || q.Depth > MaxPathDepth || (q.Start != "" && q.End != "") {
+		return PathsResult{}, ErrInvalidPath
+	}
+
+	// anchor is the position of the start or end step in the steps of a session, zero when absent.
+	var (
+		anchor = "1"
+		path   = fmt.Sprintf("arraySlice(steps, 1, %d)", q.Depth)
+		args   []any
+	)
+	switch {
+	case q.Start != "":
+		anchor = "indexOf(steps, ?)"
+		path = fmt.Sprintf("arraySlice(steps, anchor, %d)", q.Depth)
+		args = append(args, q.Start)
+	case q.End != "":
+		anchor = "indexOf(steps, ?)"
+		path = fmt.Sprintf("arraySlice(steps, greatest(1, anchor - %d), least(anchor, %d))", q.Depth-1, q.Depth)
+		args = append(args, q.End)
+	}
+	conditions, filterArgs := eventConditions(q.Filter)
+	args = append(args, q.ProjectID, q.From, q.To)
+	args = append(args, filterArgs...)
+	args = append(args, q.Limit)
+
+	session, err := driver.Begin(ctx)
+	if err != nil {
+		return PathsResult{}, fmt.Errorf("failed to begin ClickHouse session: %w", err)
+	}
+
+	var result PathsResult
+	err = session.Builder()(fmt.Sprintf(`
+		SELECT
+			%s AS path,
+			count() AS sessions,
+			sum(count()) OVER () AS total
+		FROM
+		(
+			SELECT
+				steps,
+				%s AS anchor
+			FROM
+			(
+				SELECT arrayCompact(arrayMap(e -> e.2, arraySort(e -> e.1, groupArray((event_timestamp, %s))))) AS steps
+				FROM raw_events
+				WHERE project_id = ? AND event_timestamp >= ? AND event_timestamp < ? AND session_id != ''%s%s
+				GROUP BY session_id
+			)
+		)
+		WHERE anchor > 0 AND length(steps) > 0
+		GROUP BY path
+		ORDER BY sessions DESC, path
+		LIMIT ?`,
+		path, anchor, step.expression, step.condition, conditions,
+	)).Arguments(args...).Query(func(rows clickhouse.Rows) error {
+		for rows.Next() {
+			var p Path
+			if err := rows.Scan(&p.Steps, &p.Sessions, &result.Sessions); err != nil {
+				return err
+			}
+			// Paths are sorted by sessions, so the following ones are below the share as well.
+			if float64(p.Sessions) < q.MinShare*float64(result.Sessions) {
+				break
+			}
+			result.Paths = append(result.Paths, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return PathsResult{}, fmt.Errorf("failed to query paths: %w", err)
+	}
+
+	return result, nil
+}
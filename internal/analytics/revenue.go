@@ -0,0 +1,83 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ponrove/octobe/driver/clickhouse"
+	"github.com/ponrove/ponrove-backend/internal/filter"
+	"github.com/shopspring/decimal"
+)
+
+// RevenueQuery selects the events whose revenue is reported.
+type RevenueQuery struct {
+	ProjectID string
+	From, To  time.Time
+	Filter    filter.Filter
+}
+
+// CurrencyRevenue is the revenue reported in a currency.
+type CurrencyRevenue struct {
+	// Currency is the ISO 4217 code of the currency.
+	Currency string
+	// Amount is the total revenue, in the currency.
+	Amount decimal.Decimal
+	// Orders is the number of events with revenue.
+	Orders uint64
+}
+
+// RevenueResult holds the revenue of the events, per currency, and the visitors it is a share of.
+type RevenueResult struct {
+	// Visitors is the number of unique visitors, with or without revenue.
+	Visitors uint64
+	// Currencies holds the revenue of every currency reported, ordered by code.
+	Currencies []CurrencyRevenue
+}
+
+// Revenue returns the revenue of the events of the project in [q.From, q.To) matching the filter, in the currencies it
+// was reported in. Bot traffic is excluded unless the filter compares is_bot.
+func Revenue(ctx context.Context, driver clickhouse.Driver, q RevenueQuery) (RevenueResult, error) {
+	conditions, filterArgs := eventConditions(q.Filter)
+	args := append([]any{q.ProjectID, q.From, q.To}, filterArgs...)
+
+	session, err := driver.Begin(ctx)
+	if err != nil {
+		return RevenueResult{}, fmt.Errorf("failed to begin ClickHouse session: %w", err)
+	}
+
+	var result RevenueResult
+	err = session.Builder()(`
+		SELECT uniqExactIf(visitor_fingerprint, visitor_fingerprint != '') AS visitors
+		FROM raw_events
+		WHERE project_id = ? AND event_timestamp >= ? AND event_timestamp < ?` + conditions,
+	).Arguments(args...).QueryRow(&result.Visitors)
+	if err != nil {
+		return RevenueResult{}, fmt.Errorf("failed to query visitors: %w", err)
+	}
+
+	err = session.Builder()(`
+		SELECT
+			revenue_currency,
+			sum(revenue_amount) AS revenue,
+			count() AS orders
+		FROM raw_events
+		WHERE project_id = ? AND event_timestamp >= ? AND event_timestamp < ? AND revenue_currency != ''` + conditions + `
+		GROUP BY revenue_currency
+		ORDER BY revenue_currency`,
+	).Arguments(args...).Query(func(rows clickhouse.Rows) error {
+		for rows.Next() {
+			var c CurrencyRevenue
+			if err := rows.Scan(&c.Currency, &c.Amount, &c.Orders); err != nil {
+				return err
+			}
+			result.Currencies = append(result.Currencies, c)
+		}
+		return nil
+	})
+	if err != nil {
+		return RevenueResult{}, fmt.Errorf("failed to query revenue: %w", err)
+	}
+
+	return result, nil
+}
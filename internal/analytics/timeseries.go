@@ -0,0 +1,287 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ponrove/octobe/driver/clickhouse"
+	"github.com/ponrove/ponrove-backend/internal/filter"
+)
+
+// Interval is the width of the buckets of a time series.
+type Interval string
+
+const (
+	IntervalHour  Interval = "hour"
+	IntervalDay   Interval = "day"
+	IntervalWeek  Interval = "week"
+	IntervalMonth Interval = "month"
+)
+
+// MaxBuckets is the most buckets a single time series spans.
+const MaxBuckets = 2000
+
+var (
+	ErrInvalidInterval = errors.New("invalid interval")
+	ErrInvalidTimezone = errors.New("invalid timezone")
+	ErrTooManyBuckets  = fmt.Errorf("time series spans more than %d buckets", MaxBuckets)
+)
+
+// timezonePattern matches IANA timezone names, which are embedded in queries as ClickHouse only accepts constant
+// timezones.
+var timezonePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_+\-]*(/[A-Za-z0-9_+\-]+)*$`)
+
+// bucketExpressions truncate a timestamp column (%[1]s) to the start of its bucket in a timezone (%[2]s), as a DateTime
+// in that timezone. Weeks start on Monday.
+var bucketExpressions = map[Interval]string{
+	IntervalHour:  `toStartOfHour(%[1]s, '%[2]s')`,
+	IntervalDay:   `toStartOfDay(%[1]s, '%[2]s')`,
+	IntervalWeek:  `toDateTime(toStartOfWeek(%[1]s, 1, '%[2]s'), '%[2]s')`,
+	IntervalMonth: `toDateTime(toStartOfMonth(%[1]s, '%[2]s'), '%[2]s')`,
+}
+
+// LoadTimezone returns the location of an IANA timezone name, ErrInvalidTimezone when unknown.
+func LoadTimezone(name string) (*time.Location, error) {
+	if !timezonePattern.MatchString(name) || name == "Local" {
+		return nil, ErrInvalidTimezone
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, ErrInvalidTimezone
+	}
+	return loc, nil
+}
+
+// Buckets returns the start of every bucket of the interval overlapping [from, to), in the location.
+func (i Interval) Buckets(from, to time.Time, loc *time.Location) ([]time.Time, error) {
+	if _, ok := bucketExpressions[i]; !ok {
+		return nil, ErrInvalidInterval
+	}
+
+	var buckets []time.Time
+	for b := i.start(from.In(loc)); b.Before(to); b = i.next(b) {
+		if len(buckets) == MaxBuckets {
+			return nil, ErrTooManyBuckets
+		}
+		buckets = append(buckets, b)
+	}
+	return buckets, nil
+}
+
+// start truncates t to the start of its bucket, in the location of t.
+func (i Interval) start(t time.Time) time.Time {
+	y, m, d := t.Date()
+	switch i {
+	case IntervalHour:
+		// Truncated in absolute time, as wall clock hours repeat when daylight saving time ends.
+		return t.Add(-time.Duration(t.Minute())*time.Minute - time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+	case IntervalWeek:
+		return time.Date(y, m, d-(int(t.Weekday())+6)%7, 0, 0, 0, 0, t.Location())
+	case IntervalMonth:
+		return time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+	default:
+		return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+	}
+}
+
+// next returns the start of the bucket following the bucket starting at b.
+func (i Interval) next(b time.Time) time.Time {
+	y, m, d := b.Date()
+	switch i {
+	case IntervalHour:
+		return i.start(b.Add(time.Hour))
+	case IntervalWeek:
+		return time.Date(y, m, d+7, 0, 0, 0, 0, b.Location())
+	case IntervalMonth:
+		return time.Date(y, m+1, 1, 0, 0, 0, 0, b.Location())
+	default:
+		return time.Date(y, m, d+1, 0, 0, 0, 0, b.Location())
+	}
+}
+
+// TimeseriesPoint holds the traffic of a single bucket.
+type TimeseriesPoint struct {
+	Bucket    time.Time
+	Pageviews uint64
+	// Visitors counts unique visitor fingerprints, which rotate daily. Visitors returning on another day of a week or
+	// month bucket are counted again.
+	Visitors uint64
+	// Sessions, BounceRate and AvgVisitDuration are attributed to the bucket the session started in, computed from the
+	// events of the session matching the filter. A bounce is a session with a single pageview, out of the sessions
+	// with pageviews.
+	Sessions         uint64
+	BounceRate       float64
+	AvgVisitDuration time.Duration
+	// Conversions holds the conversions of every goal of the query in the bucket, in order.
+	Conversions []GoalConversions
+}
+
+// TimeseriesQuery selects the events of a time series and how they are bucketed.
+type TimeseriesQuery struct {
+	ProjectID string
+	From, To  time.Time
+	Filter    filter.Filter
+	Interval  Interval
+	Location  *time.Location
+	// Compare selects the window the range is compared to, none by default.
+	Compare Comparison
+	// Goals selects the events completing the goals whose conversions are counted, at most MaxGoals.
+	Goals []filter.Filter
+}
+
+// TimeseriesResult holds the buckets of a time series, and those of the window it is compared to.
+type TimeseriesResult struct {
+	Points []TimeseriesPoint
+	// CompareFrom, CompareTo and ComparePoints describe the window the range is compared to, zero without comparison.
+	// ComparePoints are bucketed the same way as Points, but their number can differ when the windows align
+	// differently with the buckets, e.g. months of different lengths.
+	CompareFrom, CompareTo time.Time
+	ComparePoints          []TimeseriesPoint
+}
+
+// Timeseries returns the traffic of the events of the project in [q.From, q.To) matching the filter, per bucket of the
+// interval in the location, empty buckets included. Bot traffic is excluded unless the filter compares is_bot. The
+// windows compared are queried together, in a single query per metric.
+func Timeseries(ctx context.Context, driver clickhouse.Driver, q TimeseriesQuery) (TimeseriesResult, error) {
+	if len(q.Goals) > MaxGoals {
+		return TimeseriesResult{}, ErrTooManyGoals
+	}
+	windows, err := queryWindows(q.From, q.To, q.Compare)
+	if err != nil {
+		return TimeseriesResult{}, err
+	}
+
+	series := make([][]TimeseriesPoint, len(windows))
+	index := make([]map[int64]int, len(windows))
+	for w, win := range windows {
+		buckets, err := q.Interval.Buckets(win.from, win.to, q.Location)
+		if err != nil {
+			return TimeseriesResult{}, err
+		}
+		series[w] = make([]TimeseriesPoint, len(buckets))
+		index[w] = make(map[int64]int, len(buckets))
+		for n, b := range buckets {
+			series[w][n].Bucket = b
+			series[w][n].Conversions = make([]GoalConversions, len(q.Goals))
+			index[w][b.Unix()] = n
+		}
+	}
+	point := func(w uint8, bucket time.Time) *TimeseriesPoint {
+		if int(w) >= len(series) {
+			return nil
+		}
+		n, ok := index[w][bucket.Unix()]
+		if !ok {
+			return nil
+		}
+		return &series[w][n]
+	}
+
+	session, err := driver.Begin(ctx)
+	if err != nil {
+		return TimeseriesResult{}, fmt.Errorf("failed to begin ClickHouse session: %w", err)
+	}
+
+	expression := bucketExpressions[q.Interval]
+	conditions, filterArgs := eventConditions(q.Filter)
+	goals, goalArgs := goalConditions(q.Goals)
+	var (
+		eventQueries, sessionQueries []string
+		eventArgs, args              []any
+	)
+	for w, win := range windows {
+		eventQueries = append(eventQueries, fmt.Sprintf(`
+			SELECT
+				toUInt8(%d) AS series,
+				%s AS bucket,
+				countIf(event_name = 'page_view'),
+				uniqExactIf(visitor_fingerprint, visitor_fingerprint != '')%s
+			FROM raw_events
+			WHERE project_id = ? AND event_timestamp >= ? AND event_timestamp < ?%s
+			GROUP BY bucket`, w, fmt.Sprintf(expression, "event_timestamp", q.Location.String()), columnList(goalColumns(goals, "")), conditions))
+		sessionQueries = append(sessionQueries, fmt.Sprintf(`
+			SELECT
+				toUInt8(%d) AS series,
+				%s AS bucket,
+				count(),
+				countIf(pageviews = 1),
+				countIf(pageviews > 0),
+				avg(duration)
+			FROM
+			(
+				SELECT
+					min(event_timestamp) AS session_start,
+					dateDiff('second', min(event_timestamp), max(event_timestamp)) AS duration,
+					countIf(event_name = 'page_view') AS pageviews
+				FROM raw_events
+				WHERE project_id = ? AND event_timestamp >= ? AND event_timestamp < ? AND session_id != ''%s
+				GROUP BY session_id
+			)
+			GROUP BY bucket`, w, fmt.Sprintf(expression, "session_start", q.Location.String()), conditions))
+		eventArgs = append(eventArgs, goalColumnArgs(goalArgs)...)
+		eventArgs = append(eventArgs, q.ProjectID, win.from, win.to)
+		eventArgs = append(eventArgs, filterArgs...)
+		args = append(args, q.ProjectID, win.from, win.to)
+		args = append(args, filterArgs...)
+	}
+
+	err = session.Builder()(strings.Join(eventQueries, "\n\t\tUNION ALL")).Arguments(eventArgs...).Query(func(rows clickhouse.Rows) error {
+		for rows.Next() {
+			var (
+				w                   uint8
+				bucket              time.Time
+				pageviews, visitors uint64
+				conversions         = make([]GoalConversions, len(goals))
+			)
+			dest := append([]any{&w, &bucket, &pageviews, &visitors}, goalDestinations(conversions)...)
+			if err := rows.Scan(dest...); err != nil {
+				return err
+			}
+			if p := point(w, bucket); p != nil {
+				p.Pageviews = pageviews
+				p.Visitors = visitors
+				p.Conversions = conversions
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return TimeseriesResult{}, fmt.Errorf("failed to query pageviews: %w", err)
+	}
+
+	err = session.Builder()(strings.Join(sessionQueries, "\n\t\tUNION ALL")).Arguments(args...).Query(func(rows clickhouse.Rows) error {
+		for rows.Next() {
+			var (
+				w                                    uint8
+				bucket                               time.Time
+				sessions, bounces, sessionsWithViews uint64
+				duration                             float64
+			)
+			if err := rows.Scan(&w, &bucket, &sessions, &bounces, &sessionsWithViews, &duration); err != nil {
+				return err
+			}
+			if p := point(w, bucket); p != nil {
+				p.Sessions = sessions
+				if sessionsWithViews > 0 {
+					p.BounceRate = float64(bounces) / float64(sessionsWithViews)
+				}
+				p.AvgVisitDuration = time.Duration(duration * float64(time.Second))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return TimeseriesResult{}, fmt.Errorf("failed to query sessions: %w", err)
+	}
+
+	result := TimeseriesResult{Points: series[0]}
+	if len(windows) > 1 {
+		result.CompareFrom, result.CompareTo = windows[1].from, windows[1].to
+		result.ComparePoints = series[1]
+	}
+	return result, nil
+}
@@ -0,0 +1,87 @@
+package analytics_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ponrove/ponrove-backend/internal/analytics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuckets(t *testing.T) {
+	t.Parallel()
+
+	stockholm, err := analytics.LoadTimezone("Europe/Stockholm")
+	require.NoError(t, err)
+	kolkata, err := analytics.LoadTimezone("Asia/Kolkata")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		interval analytics.Interval
+		loc      *time.Location
+		from, to time.Time
+		expected []string
+	}{
+		{
+			name:     "hours across the end of daylight saving time",
+			interval: analytics.IntervalHour,
+			loc:      stockholm,
+			from:     time.Date(2025, 10, 26, 0, 30, 0, 0, time.UTC),
+			to:       time.Date(2025, 10, 26, 2, 0, 0, 0, time.UTC),
+			expected: []string{"2025-10-26T02:00:00+02:00", "2025-10-26T02:00:00+01:00"},
+		},
+		{
+			name:     "hours with a half hour offset",
+			interval: analytics.IntervalHour,
+			loc:      kolkata,
+			from:     time.Date(2025, 6, 16, 0, 0, 0, 0, time.UTC),
+			to:       time.Date(2025, 6, 16, 1, 0, 0, 0, time.UTC),
+			expected: []string{"2025-06-16T05:00:00+05:30", "2025-06-16T06:00:00+05:30"},
+		},
+		{
+			name:     "weeks start on Monday",
+			interval: analytics.IntervalWeek,
+			loc:      time.UTC,
+			from:     time.Date(2025, 6, 18, 12, 0, 0, 0, time.UTC),
+			to:       time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC),
+			expected: []string{"2025-06-16T00:00:00Z", "2025-06-23T00:00:00Z"},
+		},
+		{
+			name:     "months",
+			interval: analytics.IntervalMonth,
+			loc:      stockholm,
+			from:     time.Date(2025, 1, 31, 23, 30, 0, 0, time.UTC),
+			to:       time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+			expected: []string{"2025-02-01T00:00:00+01:00", "2025-03-01T00:00:00+01:00"},
+		},
+	}
+
+	for _, tc := range testCases {
+		buckets, err := tc.interval.Buckets(tc.from, tc.to, tc.loc)
+		require.NoError(t, err, tc.name)
+
+		formatted := make([]string, 0, len(buckets))
+		for _, b := range buckets {
+			formatted = append(formatted, b.Format(time.RFC3339))
+		}
+		assert.Equal(t, tc.expected, formatted, tc.name)
+	}
+}
+
+func TestBucketsLimits(t *testing.T) {
+	t.Parallel()
+
+	from := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, err := analytics.IntervalHour.Buckets(from, from.AddDate(1, 0, 0), time.UTC)
+	assert.ErrorIs(t, err, analytics.ErrTooManyBuckets)
+
+	_, err = analytics.Interval("minute").Buckets(from, from.AddDate(0, 0, 1), time.UTC)
+	assert.ErrorIs(t, err, analytics.ErrInvalidInterval)
+
+	for _, name := range []string{"Local", "", "../etc/passwd", "UTC'", "Mars/Olympus"} {
+		_, err := analytics.LoadTimezone(name)
+		assert.ErrorIs(t, err, analytics.ErrInvalidTimezone, name)
+	}
+}
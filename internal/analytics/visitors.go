@@ -0,0 +1,102 @@
+package analytics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ponrove/octobe/driver/clickhouse"
+)
+
+var ErrNotFound = errors.New("not found")
+
+// Visitor is the directory entry of a visitor, aggregated from their events as they are ingested.
+type Visitor struct {
+	VisitorFingerprint   string
+	FirstSeen            time.Time
+	LastSeen             time.Time
+	TotalSessions        uint32
+	TotalEvents          uint64
+	InitialReferrerHost  string
+	InitialUTMCampaign   string
+	LastKnownCountryCode string
+	LastKnownDeviceType  string
+}
+
+// GetVisitor returns the directory entry of the visitor, ErrNotFound when the visitor is unknown.
+func GetVisitor(ctx context.Context, driver clickhouse.Driver, projectID, visitorFingerprint string) (Visitor, error) {
+	session, err := driver.Begin(ctx)
+	if err != nil {
+		return Visitor{}, fmt.Errorf("failed to begin ClickHouse session: %w", err)
+	}
+
+	var v Visitor
+	err = session.Builder()(`
+		SELECT
+			visitor_fingerprint,
+			first_seen_timestamp,
+			last_seen_timestamp,
+			total_sessions,
+			total_events,
+			initial_referrer_host,
+			initial_utm_campaign,
+			last_known_country_code,
+			last_known_device_type
+		FROM visitor_directory
+		WHERE project_id = ? AND visitor_fingerprint = ?`,
+	).Arguments(projectID, visitorFingerprint).QueryRow(
+		&v.VisitorFingerprint,
+		&v.FirstSeen,
+		&v.LastSeen,
+		&v.TotalSessions,
+		&v.TotalEvents,
+		&v.InitialReferrerHost,
+		&v.InitialUTMCampaign,
+		&v.LastKnownCountryCode,
+		&v.LastKnownDeviceType,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Visitor{}, ErrNotFound
+	}
+	if err != nil {
+		return Visitor{}, fmt.Errorf("failed to query visitor: %w", err)
+	}
+
+	return v, nil
+}
+
+// VisitorSummary counts the visitors active in a time range.
+type VisitorSummary struct {
+	// Visitors is the number of visitors with activity in the range.
+	Visitors uint64
+	// NewVisitors is the number of visitors first seen in the range, with a single session.
+	NewVisitors uint64
+	// ReturningVisitors is the number of visitors seen before the range, or who came back for another session.
+	ReturningVisitors uint64
+}
+
+// SummarizeVisitors counts the new and returning visitors of the project active in [from, to), from the visitor
+// directory rather than the raw events.
+func SummarizeVisitors(ctx context.Context, driver clickhouse.Driver, projectID string, from, to time.Time) (VisitorSummary, error) {
+	session, err := driver.Begin(ctx)
+	if err != nil {
+		return VisitorSummary{}, fmt.Errorf("failed to begin ClickHouse session: %w", err)
+	}
+
+	var summary VisitorSummary
+	err = session.Builder()(`
+		SELECT
+			count(),
+			countIf(first_seen_timestamp < ? OR total_sessions > 1)
+		FROM visitor_directory
+		WHERE project_id = ? AND last_seen_timestamp >= ? AND first_seen_timestamp < ?`,
+	).Arguments(from, projectID, from, to).QueryRow(&summary.Visitors, &summary.ReturningVisitors)
+	if err != nil {
+		return VisitorSummary{}, fmt.Errorf("failed to query visitor summary: %w", err)
+	}
+
+	summary.NewVisitors = summary.Visitors - summary.ReturningVisitors
+	return summary, nil
+}
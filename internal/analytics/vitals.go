@@ -0,0 +1,99 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ponrove/octobe/driver/clickhouse"
+	"github.com/ponrove/ponrove-backend/internal/filter"
+)
+
+// Percentiles summarizes the samples of a single web vital, in milliseconds.
+type Percentiles struct {
+	Samples uint64
+	P50     float64
+	P75     float64
+	P95     float64
+}
+
+// VitalsGroup holds the web vitals percentiles of the page loads of a path, on a device type, from a country.
+type VitalsGroup struct {
+	URLPath     string
+	DeviceType  string
+	CountryCode string
+	// Samples is the number of page loads with at least one web vital.
+	Samples uint64
+	// The percentiles of each web vital are nil when none of the page loads reported it.
+	PageLoadTime           *Percentiles
+	FirstContentfulPaint   *Percentiles
+	LargestContentfulPaint *Percentiles
+}
+
+// VitalsPercentiles computes the p50, p75 and p95 of the web vitals of the project in [from, to) matching the filter,
+// grouped by URL path, device type and country. Both web_vitals events and pageviews carrying web vitals are sampled,
+// bot traffic is not unless the filter compares is_bot. At most limit groups are returned, those with the most samples
+// first.
+func VitalsPercentiles(ctx context.Context, driver clickhouse.Driver, projectID string, from, to time.Time, f filter.Filter, limit int) ([]VitalsGroup, error) {
+	conditions, filterArgs := eventConditions(f)
+	args := append([]any{projectID, from, to}, filterArgs...)
+	args = append(args, limit)
+
+	session, err := driver.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin ClickHouse session: %w", err)
+	}
+
+	var groups []VitalsGroup
+	err = session.Builder()(fmt.Sprintf(`
+		SELECT
+			url_path,
+			device_type,
+			country_code,
+			count(),
+			countIf(page_load_time_ms > 0),
+			quantilesIf(0.5, 0.75, 0.95)(page_load_time_ms, page_load_time_ms > 0),
+			countIf(first_contentful_paint_ms > 0),
+			quantilesIf(0.5, 0.75, 0.95)(first_contentful_paint_ms, first_contentful_paint_ms > 0),
+			countIf(largest_contentful_paint_ms > 0),
+			quantilesIf(0.5, 0.75, 0.95)(largest_contentful_paint_ms, largest_contentful_paint_ms > 0)
+		FROM raw_events
+		WHERE project_id = ?
+			AND event_timestamp >= ? AND event_timestamp < ?
+			AND event_name IN ('web_vitals', 'page_view')
+			AND (page_load_time_ms > 0 OR first_contentful_paint_ms > 0 OR largest_contentful_paint_ms > 0)%s
+		GROUP BY url_path, device_type, country_code
+		ORDER BY count() DESC, url_path, device_type, country_code
+		LIMIT ?`, conditions),
+	).Arguments(args...).Query(func(rows clickhouse.Rows) error {
+		for rows.Next() {
+			var (
+				g                                   VitalsGroup
+				loadSamples, fcpSamples, lcpSamples uint64
+				load, fcp, lcp                      []float64
+			)
+			err := rows.Scan(&g.URLPath, &g.DeviceType, &g.CountryCode, &g.Samples, &loadSamples, &load, &fcpSamples, &fcp, &lcpSamples, &lcp)
+			if err != nil {
+				return err
+			}
+			g.PageLoadTime = percentiles(loadSamples, load)
+			g.FirstContentfulPaint = percentiles(fcpSamples, fcp)
+			g.LargestContentfulPaint = percentiles(lcpSamples, lcp)
+			groups = append(groups, g)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query web vitals: %w", err)
+	}
+
+	return groups, nil
+}
+
+// percentiles converts the quantiles of a web vital, nil without samples, as ClickHouse returns NaN quantiles then.
+func percentiles(samples uint64, quantiles []float64) *Percentiles {
+	if samples == 0 || len(quantiles) != 3 {
+		return nil
+	}
+	return &Percentiles{Samples: samples, P50: quantiles[0], P75: quantiles[1], P95: quantiles[2]}
+}
@@ -0,0 +1,138 @@
+package bots
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Names reported for traffic classified by signals other than the user agent patterns.
+const (
+	NameEmptyUserAgent  = "Empty user agent"
+	NameHeadlessBrowser = "Headless browser"
+)
+
+// maxUserAgentLength bounds the part of a user agent matched against the patterns, so oversized headers can't make
+// classification expensive.
+const maxUserAgentLength = 1024
+
+// defaultPatterns are the known crawler, monitoring and automation user agents. The list is ordered, the first match
+// names the bot, so specific patterns come before the generic ones.
+//
+//go:embed bots.json
+var defaultPatterns []byte
+
+type pattern struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+	regexp  *regexp.Regexp
+}
+
+var compiledPatterns = sync.OnceValue(func() []pattern {
+	var patterns []pattern
+	err := json.NewDecoder(bytes.NewReader(defaultPatterns)).Decode(&patterns)
+	if err != nil {
+		panic(fmt.Sprintf("embedded bot patterns are invalid: %v", err))
+	}
+
+	for i := range patterns {
+		patterns[i].regexp = regexp.MustCompile(patterns[i].Pattern)
+	}
+	return patterns
+})
+
+// Signals are the properties of a request used to tell bots from visitors.
+type Signals struct {
+	// UserAgent is the user agent of the client.
+	UserAgent string
+	// SecCHUA is the Sec-CH-UA client hint, listing the brands of Chromium based browsers.
+	SecCHUA string
+	// AcceptLanguage is the Accept-Language header. Browsers always send it, while many headless browsers and scripts
+	// pretending to be a browser don't.
+	AcceptLanguage string
+	// Headers is set when the headers of the request describe the client. It is unset for events reported by a server on
+	// behalf of a visitor, as only the user agent of the visitor is known then.
+	Headers bool
+}
+
+// Option is a function that modifies the classifier configuration.
+type Option func(*Classifier)
+
+// WithDenyList adds user agent fragments classified as bots, on top of the built-in patterns. Fragments are matched
+// case-insensitively, and reported as the name of the bot.
+func WithDenyList(fragments ...string) Option {
+	return func(c *Classifier) {
+		for _, fragment := range fragments {
+			fragment = strings.TrimSpace(fragment)
+			if fragment != "" {
+				c.denyList = append(c.denyList, fragment)
+			}
+		}
+	}
+}
+
+// Classifier tells bots, crawlers and uptime checkers apart from human visitors.
+type Classifier struct {
+	patterns []pattern
+	denyList []string
+}
+
+// New returns a classifier using the built-in patterns.
+func New(opts ...Option) *Classifier {
+	c := &Classifier{patterns: compiledPatterns()}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// ParseDenyList splits a comma separated deny list, as found in the configuration, into its fragments.
+func ParseDenyList(list string) []string {
+	if strings.TrimSpace(list) == "" {
+		return nil
+	}
+	return strings.Split(list, ",")
+}
+
+// Classify reports whether the request was made by a bot, and the name of the bot if so. The deny list is checked
+// first, then the known bot patterns and finally the headless browser signals.
+func (c *Classifier) Classify(s Signals) (string, bool) {
+	userAgent := strings.TrimSpace(s.UserAgent)
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+
+	if userAgent == "" {
+		return NameEmptyUserAgent, true
+	}
+
+	lowerUserAgent := strings.ToLower(userAgent)
+	for _, fragment := range c.denyList {
+		if strings.Contains(lowerUserAgent, strings.ToLower(fragment)) {
+			return fragment, true
+		}
+	}
+
+	for _, p := range c.patterns {
+		if p.regexp.MatchString(userAgent) {
+			return p.Name, true
+		}
+	}
+
+	if s.Headers {
+		if strings.Contains(s.SecCHUA, "HeadlessChrome") {
+			return NameHeadlessBrowser, true
+		}
+
+		// Browsers send the Accept-Language header with every request, a browser user agent without it is automation.
+		if strings.HasPrefix(userAgent, "Mozilla/") && strings.TrimSpace(s.AcceptLanguage) == "" {
+			return NameHeadlessBrowser, true
+		}
+	}
+
+	return "", false
+}
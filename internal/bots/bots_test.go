@@ -0,0 +1,153 @@
+package bots_test
+
+import (
+	"testing"
+
+	"github.com/ponrove/ponrove-backend/internal/bots"
+	"github.com/stretchr/testify/assert"
+)
+
+const chromeUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/125.0.0.0 Safari/537.36"
+
+func TestClassify(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		signals bots.Signals
+		bot     string
+	}{
+		{
+			name:    "Browser",
+			signals: bots.Signals{UserAgent: chromeUserAgent, AcceptLanguage: "en-US,en;q=0.9", Headers: true},
+		},
+		{
+			name:    "Googlebot",
+			signals: bots.Signals{UserAgent: "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", Headers: true},
+			bot:     "Googlebot",
+		},
+		{
+			name:    "Googlebot smartphone",
+			signals: bots.Signals{UserAgent: "Mozilla/5.0 (Linux; Android 6.0.1; Nexus 5X Build/MMB29P) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/125.0.6422.175 Mobile Safari/537.36 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"},
+			bot:     "Googlebot",
+		},
+		{
+			name:    "Bingbot",
+			signals: bots.Signals{UserAgent: "Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)"},
+			bot:     "Bingbot",
+		},
+		{
+			name:    "Link preview",
+			signals: bots.Signals{UserAgent: "facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)"},
+			bot:     "Facebook",
+		},
+		{
+			name:    "Uptime checker",
+			signals: bots.Signals{UserAgent: "Mozilla/5.0+(compatible; UptimeRobot/2.0; http://www.uptimerobot.com/)"},
+			bot:     "UptimeRobot",
+		},
+		{
+			name:    "Uptime monitor",
+			signals: bots.Signals{UserAgent: "Uptime-Kuma/1.23.13"},
+			bot:     "Uptime Kuma",
+		},
+		{
+			name:    "Chat link preview",
+			signals: bots.Signals{UserAgent: "Mozilla/5.0 (Windows NT 6.1; WOW64) SkypeUriPreview Preview/0.5 skype-url-preview@microsoft.com"},
+			bot:     "Skype",
+		},
+		{
+			name:    "Command line client",
+			signals: bots.Signals{UserAgent: "curl/8.7.1"},
+			bot:     "curl",
+		},
+		{
+			name:    "Unknown crawler",
+			signals: bots.Signals{UserAgent: "Mozilla/5.0 (compatible; ExampleCrawler/1.0)"},
+			bot:     "Generic bot",
+		},
+		{
+			name:    "Unknown bot",
+			signals: bots.Signals{UserAgent: "Mozilla/5.0 (compatible; ExampleBot/2.0; +https://example.com/bot)"},
+			bot:     "Generic bot",
+		},
+		{
+			name:    "Cubot phone",
+			signals: bots.Signals{UserAgent: "Mozilla/5.0 (Linux; Android 11; CUBOT P50 Build/RP1A.201005.001) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/125.0.6422.165 Mobile Safari/537.36", AcceptLanguage: "en-GB", Headers: true},
+		},
+		{
+			name:    "iPhone",
+			signals: bots.Signals{UserAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Mobile/15E148 Safari/604.1", AcceptLanguage: "sv-SE", Headers: true},
+		},
+		{
+			name:    "Samsung Internet",
+			signals: bots.Signals{UserAgent: "Mozilla/5.0 (Linux; Android 14; SM-S918B) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/25.0 Chrome/121.0.0.0 Mobile Safari/537.36", AcceptLanguage: "de-DE", Headers: true},
+		},
+		{
+			name:    "Firefox on macOS",
+			signals: bots.Signals{UserAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 14.5; rv:127.0) Gecko/20100101 Firefox/127.0", AcceptLanguage: "en-US", Headers: true},
+		},
+		{
+			name:    "Safari Technology Preview",
+			signals: bots.Signals{UserAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Safari/605.1.15 Preview", AcceptLanguage: "en-US", Headers: true},
+		},
+		{
+			name:    "Empty user agent",
+			signals: bots.Signals{UserAgent: " "},
+			bot:     bots.NameEmptyUserAgent,
+		},
+		{
+			name:    "Headless Chrome user agent",
+			signals: bots.Signals{UserAgent: "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) HeadlessChrome/125.0.6422.60 Safari/537.36", AcceptLanguage: "en-US", Headers: true},
+			bot:     "HeadlessChrome",
+		},
+		{
+			name: "Headless Chrome client hint",
+			signals: bots.Signals{
+				UserAgent:      chromeUserAgent,
+				SecCHUA:        `"HeadlessChrome";v="125", "Chromium";v="125", "Not.A/Brand";v="24"`,
+				AcceptLanguage: "en-US",
+				Headers:        true,
+			},
+			bot: bots.NameHeadlessBrowser,
+		},
+		{
+			name:    "Browser without Accept-Language",
+			signals: bots.Signals{UserAgent: chromeUserAgent, Headers: true},
+			bot:     bots.NameHeadlessBrowser,
+		},
+		{
+			name:    "Server-side report without headers",
+			signals: bots.Signals{UserAgent: chromeUserAgent},
+		},
+	}
+
+	classifier := bots.New()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			name, isBot := classifier.Classify(tc.signals)
+			assert.Equal(t, tc.bot != "", isBot)
+			assert.Equal(t, tc.bot, name)
+		})
+	}
+}
+
+func TestClassifyDenyList(t *testing.T) {
+	t.Parallel()
+
+	classifier := bots.New(bots.WithDenyList(bots.ParseDenyList(" InternalLoadTester , ,acme-monitor")...))
+
+	name, isBot := classifier.Classify(bots.Signals{UserAgent: chromeUserAgent + " internalloadtester/2.1", AcceptLanguage: "en"})
+	assert.True(t, isBot)
+	assert.Equal(t, "InternalLoadTester", name)
+
+	name, isBot = classifier.Classify(bots.Signals{UserAgent: "Mozilla/5.0 (compatible; ACME-Monitor/1.0; bot)"})
+	assert.True(t, isBot)
+	assert.Equal(t, "acme-monitor", name, "the deny list should take precedence over the built-in patterns")
+
+	_, isBot = classifier.Classify(bots.Signals{UserAgent: chromeUserAgent, AcceptLanguage: "en"})
+	assert.False(t, isBot)
+
+	assert.Empty(t, bots.ParseDenyList(" "))
+}
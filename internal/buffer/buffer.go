@@ -0,0 +1,238 @@
+package buffer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/ponrove/ponrove-backend/internal/events"
+)
+
+var (
+	// ErrFull is returned when adding events would exceed the maximum number of buffered events.
+	ErrFull = errors.New("ingestion buffer is full")
+	// ErrClosed is returned when adding events to a buffer that is draining or drained.
+	ErrClosed = errors.New("ingestion buffer is closed")
+	// ErrDropped is returned by Close when the buffer could not be drained before the context was done.
+	ErrDropped = errors.New("ingestion buffer dropped events")
+)
+
+const (
+	DefaultMaxBatchSize  = 1000
+	DefaultMaxEvents     = 100000
+	DefaultFlushInterval = time.Second
+
+	// flushTimeout bounds a single background flush, so a hanging ClickHouse connection can't stall the buffer forever.
+	flushTimeout = 30 * time.Second
+)
+
+// Flusher writes a batch of events to its destination, usually as one native batch insert into ClickHouse.
+type Flusher func(ctx context.Context, batch []events.Event) error
+
+// Option is a function that modifies the buffer configuration.
+type Option func(*Buffer)
+
+// WithMaxBatchSize sets the number of events that triggers a flush, and the maximum size of a flushed batch.
+func WithMaxBatchSize(size int) Option {
+	return func(b *Buffer) {
+		if size > 0 {
+			b.maxBatchSize = size
+		}
+	}
+}
+
+// WithMaxEvents sets the maximum number of events held in memory, pending and in flight, before Add returns ErrFull.
+func WithMaxEvents(max int) Option {
+	return func(b *Buffer) {
+		if max > 0 {
+			b.maxEvents = max
+		}
+	}
+}
+
+// WithFlushInterval sets the interval at which pending events are flushed, regardless of the batch size.
+func WithFlushInterval(interval time.Duration) Option {
+	return func(b *Buffer) {
+		if interval > 0 {
+			b.flushInterval = interval
+		}
+	}
+}
+
+// Buffer collects events in memory and flushes them in batches, either when enough events are pending or when the
+// flush interval elapses. Memory is bounded by the maximum number of events, once reached Add returns ErrFull so the
+// caller can apply backpressure. Batches that fail to flush are kept and retried on the next flush.
+type Buffer struct {
+	flush         Flusher
+	maxBatchSize  int
+	maxEvents     int
+	flushInterval time.Duration
+
+	mu       sync.Mutex
+	pending  []events.Event
+	inFlight int
+	closed   bool
+
+	trigger chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// New creates a buffer flushing through flush, and starts its background flush loop. Close must be called to stop the
+// loop and drain the pending events.
+func New(flush Flusher, opts ...Option) *Buffer {
+	b := &Buffer{
+		flush:         flush,
+		maxBatchSize:  DefaultMaxBatchSize,
+		maxEvents:     DefaultMaxEvents,
+		flushInterval: DefaultFlushInterval,
+		trigger:       make(chan struct{}, 1),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	go b.loop()
+	return b
+}
+
+// Add appends the events to the buffer. Either all events are added, or none and ErrFull or ErrClosed is returned.
+func (b *Buffer) Add(batch ...events.Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrClosed
+	}
+
+	if len(b.pending)+b.inFlight+len(batch) > b.maxEvents {
+		return ErrFull
+	}
+
+	b.pending = append(b.pending, batch...)
+	if len(b.pending) >= b.maxBatchSize {
+		select {
+		case b.trigger <- struct{}{}:
+		default: // A flush is already triggered.
+		}
+	}
+
+	return nil
+}
+
+// Len returns the number of events held by the buffer, pending and in flight.
+func (b *Buffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.pending) + b.inFlight
+}
+
+// Close stops accepting events and drains the buffer, flushing until no events are left or ctx is done. If events
+// remain once ctx is done, or a flush fails, an error wrapping ErrDropped is returned.
+func (b *Buffer) Close(ctx context.Context) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	b.mu.Unlock()
+
+	close(b.stop)
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+	}
+
+	var flushErr error
+	for ctx.Err() == nil {
+		flushed, err := b.flushBatch(ctx)
+		if err != nil {
+			flushErr = err
+			break
+		}
+		if flushed == 0 {
+			break
+		}
+	}
+
+	if remaining := b.Len(); remaining > 0 {
+		return errors.Join(fmt.Errorf("%w: %d events", ErrDropped, remaining), flushErr, ctx.Err())
+	}
+
+	return nil
+}
+
+// loop flushes the buffer on every tick, and whenever a full batch is pending, until the buffer is closed.
+func (b *Buffer) loop() {
+	defer close(b.done)
+
+	ticker := time.NewTicker(b.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+			b.flushAll(false)
+		case <-b.trigger:
+			b.flushAll(true)
+		}
+	}
+}
+
+// flushAll flushes pending batches until the buffer is empty, or only a partial batch is left when fullOnly is set. It
+// stops at the first failure, leaving the remaining events for the next attempt.
+func (b *Buffer) flushAll(fullOnly bool) {
+	for {
+		b.mu.Lock()
+		pending := len(b.pending)
+		b.mu.Unlock()
+
+		if pending == 0 || (fullOnly && pending < b.maxBatchSize) {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+		_, err := b.flushBatch(ctx)
+		cancel()
+		if err != nil {
+			slog.Error("Failed to flush ingestion buffer, retrying on next flush", slog.Int("buffered", b.Len()), slog.Any("error", err))
+			return
+		}
+	}
+}
+
+// flushBatch flushes up to maxBatchSize pending events, returning the number of flushed events. On failure the batch is
+// put back at the front of the buffer, preserving the order of events.
+func (b *Buffer) flushBatch(ctx context.Context) (int, error) {
+	b.mu.Lock()
+	size := min(len(b.pending), b.maxBatchSize)
+	if size == 0 {
+		b.mu.Unlock()
+		return 0, nil
+	}
+	batch := make([]events.Event, size)
+	copy(batch, b.pending)
+	b.pending = b.pending[size:]
+	b.inFlight += size
+	b.mu.Unlock()
+
+	err := b.flush(ctx, batch)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.inFlight -= size
+	if err != nil {
+		b.pending = append(batch, b.pending...)
+		return 0, err
+	}
+
+	return size, nil
+}
@@ -0,0 +1,146 @@
+package buffer_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ponrove/ponrove-backend/internal/buffer"
+	"github.com/ponrove/ponrove-backend/internal/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder is a flusher recording every flushed batch, optionally failing while err is set.
+type recorder struct {
+	mu      sync.Mutex
+	batches [][]events.Event
+	err     error
+}
+
+func (r *recorder) flush(ctx context.Context, batch []events.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	r.batches = append(r.batches, batch)
+	return nil
+}
+
+func (r *recorder) setErr(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.err = err
+}
+
+func (r *recorder) sizes() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sizes := make([]int, len(r.batches))
+	for i, batch := range r.batches {
+		sizes[i] = len(batch)
+	}
+	return sizes
+}
+
+func eventsNamed(names ...string) []events.Event {
+	batch := make([]events.Event, len(names))
+	for i, name := range names {
+		batch[i] = events.Event{EventName: name}
+	}
+	return batch
+}
+
+func TestFlushOnBatchSize(t *testing.T) {
+	t.Parallel()
+
+	rec := &recorder{}
+	buf := buffer.New(rec.flush, buffer.WithMaxBatchSize(2), buffer.WithFlushInterval(time.Hour))
+	defer buf.Close(context.Background())
+
+	require.NoError(t, buf.Add(eventsNamed("a")...))
+	require.NoError(t, buf.Add(eventsNamed("b", "c", "d", "e")...))
+
+	assert.Eventually(t, func() bool { return assert.ObjectsAreEqual([]int{2, 2}, rec.sizes()) }, time.Second, time.Millisecond)
+	assert.Equal(t, 1, buf.Len(), "a partial batch should wait for the flush interval")
+}
+
+func TestFlushOnInterval(t *testing.T) {
+	t.Parallel()
+
+	rec := &recorder{}
+	buf := buffer.New(rec.flush, buffer.WithMaxBatchSize(100), buffer.WithFlushInterval(10*time.Millisecond))
+	defer buf.Close(context.Background())
+
+	require.NoError(t, buf.Add(eventsNamed("a", "b", "c")...))
+
+	assert.Eventually(t, func() bool { return assert.ObjectsAreEqual([]int{3}, rec.sizes()) }, time.Second, time.Millisecond)
+	assert.Equal(t, 0, buf.Len())
+}
+
+func TestBackpressure(t *testing.T) {
+	t.Parallel()
+
+	rec := &recorder{}
+	buf := buffer.New(rec.flush, buffer.WithMaxEvents(3), buffer.WithFlushInterval(time.Hour))
+	defer buf.Close(context.Background())
+
+	require.NoError(t, buf.Add(eventsNamed("a", "b")...))
+	assert.ErrorIs(t, buf.Add(eventsNamed("c", "d")...), buffer.ErrFull, "events should be rejected as a whole")
+	assert.NoError(t, buf.Add(eventsNamed("c")...))
+	assert.ErrorIs(t, buf.Add(eventsNamed("d")...), buffer.ErrFull)
+	assert.Equal(t, 3, buf.Len())
+}
+
+func TestRetryAfterFailedFlush(t *testing.T) {
+	t.Parallel()
+
+	rec := &recorder{err: errors.New("connection refused")}
+	buf := buffer.New(rec.flush, buffer.WithFlushInterval(5*time.Millisecond))
+	defer buf.Close(context.Background())
+
+	require.NoError(t, buf.Add(eventsNamed("a", "b")...))
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, 2, buf.Len(), "events should be kept while flushing fails")
+
+	rec.setErr(nil)
+	assert.Eventually(t, func() bool { return buf.Len() == 0 }, time.Second, time.Millisecond)
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	require.Len(t, rec.batches, 1)
+	assert.Equal(t, eventsNamed("a", "b"), rec.batches[0], "order should be preserved across retries")
+}
+
+func TestCloseDrains(t *testing.T) {
+	t.Parallel()
+
+	rec := &recorder{}
+	buf := buffer.New(rec.flush, buffer.WithMaxBatchSize(2), buffer.WithFlushInterval(time.Hour))
+
+	require.NoError(t, buf.Add(eventsNamed("a")...))
+	require.NoError(t, buf.Close(context.Background()))
+	assert.Equal(t, []int{1}, rec.sizes())
+	assert.ErrorIs(t, buf.Add(eventsNamed("b")...), buffer.ErrClosed)
+	assert.NoError(t, buf.Close(context.Background()), "closing twice should be a no-op")
+}
+
+func TestCloseReportsDroppedEvents(t *testing.T) {
+	t.Parallel()
+
+	failure := errors.New("connection refused")
+	rec := &recorder{err: failure}
+	buf := buffer.New(rec.flush, buffer.WithFlushInterval(time.Hour))
+
+	require.NoError(t, buf.Add(eventsNamed("a", "b")...))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := buf.Close(ctx)
+	assert.ErrorIs(t, err, buffer.ErrDropped)
+	assert.ErrorIs(t, err, failure)
+	assert.Equal(t, 2, buf.Len())
+}
@@ -0,0 +1,111 @@
+package clientip
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+var ErrInvalidProxy = errors.New("invalid trusted proxy")
+
+// Resolver resolves the IP address of the client that sent a request. X-Forwarded-For is only honoured for requests
+// sent by a trusted proxy, as anyone can set the header otherwise.
+type Resolver struct {
+	trusted []netip.Prefix
+}
+
+// New returns a resolver trusting the given proxy networks. Without trusted proxies, the remote address of the
+// connection is always the client.
+func New(trusted ...netip.Prefix) *Resolver {
+	return &Resolver{trusted: trusted}
+}
+
+// ParseTrustedProxies parses a comma separated list of IP addresses and CIDR networks, as found in the configuration.
+func ParseTrustedProxies(list string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidProxy, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidProxy, err)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+
+	return prefixes, nil
+}
+
+// Resolve returns the client IP address of a request, given the remote address of the connection and the values of
+// its X-Forwarded-For headers. Forwarded addresses are walked from the right, the closest hop, skipping trusted
+// proxies, so the first untrusted address is the client. The boolean is false when no valid address is found.
+func (r *Resolver) Resolve(remoteAddr string, forwardedFor ...string) (netip.Addr, bool) {
+	client, ok := parseAddr(remoteAddr)
+	if !ok {
+		return netip.Addr{}, false
+	}
+
+	if !r.isTrusted(client) {
+		return client, true
+	}
+
+	hops := strings.Split(strings.Join(forwardedFor, ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+
+		addr, ok := parseAddr(hop)
+		if !ok {
+			// The proxy chain is broken, the last trusted hop is the best known client.
+			break
+		}
+
+		client = addr
+		if !r.isTrusted(addr) {
+			break
+		}
+	}
+
+	return client, true
+}
+
+// isTrusted reports whether the address belongs to a trusted proxy.
+func (r *Resolver) isTrusted(addr netip.Addr) bool {
+	for _, prefix := range r.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseAddr parses an IP address, with or without port, as found in remote addresses and forwarding headers.
+func parseAddr(s string) (netip.Addr, bool) {
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+
+	addr, err := netip.ParseAddr(strings.Trim(s, "[]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+
+	return addr.Unmap(), true
+}
@@ -0,0 +1,104 @@
+package clientip_test
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/ponrove/ponrove-backend/internal/clientip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolve(t *testing.T) {
+	t.Parallel()
+
+	trusted, err := clientip.ParseTrustedProxies("10.0.0.0/8, 192.0.2.1 ,, 2001:db8::/32")
+	require.NoError(t, err)
+	resolver := clientip.New(trusted...)
+
+	testCases := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		expected     string
+	}{
+		{
+			name:       "Direct connection",
+			remoteAddr: "203.0.113.7:51234",
+			expected:   "203.0.113.7",
+		},
+		{
+			name:         "Untrusted proxy",
+			remoteAddr:   "203.0.113.7:51234",
+			forwardedFor: []string{"198.51.100.1"},
+			expected:     "203.0.113.7",
+		},
+		{
+			name:         "Trusted proxy",
+			remoteAddr:   "10.1.2.3:443",
+			forwardedFor: []string{"198.51.100.1"},
+			expected:     "198.51.100.1",
+		},
+		{
+			name:         "Chain of trusted proxies",
+			remoteAddr:   "10.1.2.3:443",
+			forwardedFor: []string{"198.51.100.9, 198.51.100.1, 192.0.2.1", "10.0.0.5"},
+			expected:     "198.51.100.1",
+		},
+		{
+			name:         "Spoofed header behind trusted proxy",
+			remoteAddr:   "10.1.2.3:443",
+			forwardedFor: []string{"garbage, 198.51.100.1"},
+			expected:     "198.51.100.1",
+		},
+		{
+			name:         "Broken chain",
+			remoteAddr:   "10.1.2.3:443",
+			forwardedFor: []string{"198.51.100.1, not-an-ip, 10.0.0.5"},
+			expected:     "10.0.0.5",
+		},
+		{
+			name:         "Only trusted hops",
+			remoteAddr:   "10.1.2.3:443",
+			forwardedFor: []string{"10.0.0.5"},
+			expected:     "10.0.0.5",
+		},
+		{
+			name:         "IPv6",
+			remoteAddr:   "[2001:db8::1]:443",
+			forwardedFor: []string{"[2a00:1450:4001::1]:1234"},
+			expected:     "2a00:1450:4001::1",
+		},
+		{
+			name:       "IPv4-mapped IPv6",
+			remoteAddr: "[::ffff:203.0.113.7]:51234",
+			expected:   "203.0.113.7",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			addr, ok := resolver.Resolve(tc.remoteAddr, tc.forwardedFor...)
+			assert.True(t, ok)
+			assert.Equal(t, netip.MustParseAddr(tc.expected), addr)
+		})
+	}
+
+	_, ok := resolver.Resolve("pipe")
+	assert.False(t, ok)
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	t.Parallel()
+
+	prefixes, err := clientip.ParseTrustedProxies("")
+	assert.NoError(t, err)
+	assert.Empty(t, prefixes)
+
+	_, err = clientip.ParseTrustedProxies("10.0.0.0/33")
+	assert.ErrorIs(t, err, clientip.ErrInvalidProxy)
+
+	_, err = clientip.ParseTrustedProxies("proxy.internal")
+	assert.ErrorIs(t, err, clientip.ErrInvalidProxy)
+}
@@ -0,0 +1,72 @@
+package currency
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInvalidCurrency = errors.New("invalid currency code")
+	ErrInvalidRate     = errors.New("invalid exchange rate")
+)
+
+// codePattern matches ISO 4217 currency codes.
+var codePattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// Rates converts amounts into a reporting currency, from a static table of the value of one unit of other currencies
+// in the reporting currency.
+type Rates struct {
+	currency string
+	rates    map[string]decimal.Decimal
+}
+
+// ParseRates returns the rates converting into the reporting currency, from a table of comma separated CODE=RATE
+// entries, e.g. EUR=1.08,SEK=0.094. The reporting currency converts at a rate of 1, and can be left out of the table.
+func ParseRates(currency, table string) (Rates, error) {
+	if !codePattern.MatchString(currency) {
+		return Rates{}, fmt.Errorf("%w: %q", ErrInvalidCurrency, currency)
+	}
+
+	r := Rates{currency: currency, rates: map[string]decimal.Decimal{currency: decimal.NewFromInt(1)}}
+	for _, entry := range strings.Split(table, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		code, value, ok := strings.Cut(entry, "=")
+		code, value = strings.TrimSpace(code), strings.TrimSpace(value)
+		if !ok || !codePattern.MatchString(code) {
+			return Rates{}, fmt.Errorf("%w: %q", ErrInvalidCurrency, entry)
+		}
+
+		rate, err := decimal.NewFromString(value)
+		if err != nil || !rate.IsPositive() {
+			return Rates{}, fmt.Errorf("%w: %q", ErrInvalidRate, entry)
+		}
+		if code == currency && !rate.Equal(decimal.NewFromInt(1)) {
+			return Rates{}, fmt.Errorf("%w: the reporting currency %s converts at a rate of 1", ErrInvalidRate, currency)
+		}
+		r.rates[code] = rate
+	}
+
+	return r, nil
+}
+
+// Currency returns the ISO 4217 code of the reporting currency.
+func (r Rates) Currency() string {
+	return r.currency
+}
+
+// Convert returns the amount of the currency in the reporting currency, false when the currency has no rate.
+func (r Rates) Convert(amount decimal.Decimal, currency string) (decimal.Decimal, bool) {
+	rate, ok := r.rates[currency]
+	if !ok {
+		return decimal.Decimal{}, false
+	}
+	return amount.Mul(rate), true
+}
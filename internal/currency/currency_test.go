@@ -0,0 +1,57 @@
+package currency_test
+
+import (
+	"testing"
+
+	"github.com/ponrove/ponrove-backend/internal/currency"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRates(t *testing.T) {
+	t.Parallel()
+
+	rates, err := currency.ParseRates("USD", " EUR=1.08, SEK = 0.094,,USD=1")
+	require.NoError(t, err)
+	assert.Equal(t, "USD", rates.Currency())
+
+	testCases := []struct {
+		amount   string
+		currency string
+		expected string
+	}{
+		{amount: "10", currency: "USD", expected: "10"},
+		{amount: "49.90", currency: "EUR", expected: "53.892"},
+		{amount: "100", currency: "SEK", expected: "9.4"},
+	}
+	for _, tc := range testCases {
+		converted, ok := rates.Convert(decimal.RequireFromString(tc.amount), tc.currency)
+		require.True(t, ok, tc.currency)
+		assert.True(t, decimal.RequireFromString(tc.expected).Equal(converted), "%s %s converted to %s", tc.amount, tc.currency, converted)
+	}
+
+	_, ok := rates.Convert(decimal.NewFromInt(1), "GBP")
+	assert.False(t, ok)
+}
+
+func TestParseRatesInvalid(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		currency string
+		table    string
+		err      error
+	}{
+		{currency: "usd", table: "", err: currency.ErrInvalidCurrency},
+		{currency: "USD", table: "EUR", err: currency.ErrInvalidCurrency},
+		{currency: "USD", table: "EURO=1.08", err: currency.ErrInvalidCurrency},
+		{currency: "USD", table: "EUR=x", err: currency.ErrInvalidRate},
+		{currency: "USD", table: "EUR=0", err: currency.ErrInvalidRate},
+		{currency: "USD", table: "USD=2", err: currency.ErrInvalidRate},
+	}
+	for _, tc := range testCases {
+		_, err := currency.ParseRates(tc.currency, tc.table)
+		assert.ErrorIs(t, err, tc.err, tc.table)
+	}
+}
@@ -0,0 +1 @@
+DROP TABLE visitor_salts;
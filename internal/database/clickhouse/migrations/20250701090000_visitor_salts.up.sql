@@ -0,0 +1,10 @@
+-- Discarding the salt once its day is over makes fingerprints impossible to recompute
+CREATE TABLE visitor_salts
+(
+    `day` Date COMMENT 'The UTC day the salt is used for.',
+    `salt` String COMMENT 'Hex encoded random salt, keying the hash of visitor fingerprints computed on this day.',
+    `created_at` DateTime64(6, 'UTC') DEFAULT now64(6) COMMENT 'When the salt was proposed, the oldest salt of a day wins when replicas race.'
+)
+ENGINE = MergeTree()
+ORDER BY (day, created_at)
+TTL day + INTERVAL 1 DAY;
@@ -0,0 +1 @@
+ALTER TABLE raw_events DROP INDEX idx_visitor_fingerprint;
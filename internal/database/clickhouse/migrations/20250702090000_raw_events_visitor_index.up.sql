@@ -0,0 +1,3 @@
+-- Speeds up looking up the latest session of a visitor, which doesn't match the sorting key of raw_events
+ALTER TABLE raw_events ADD INDEX idx_visitor_fingerprint visitor_fingerprint TYPE bloom_filter(0.01) GRANULARITY 4;
+ALTER TABLE raw_events MATERIALIZE INDEX idx_visitor_fingerprint;
@@ -0,0 +1,25 @@
+DROP VIEW visitor_directory;
+DROP VIEW visitor_directory_mv;
+DROP TABLE visitor_directory_state;
+
+CREATE TABLE visitor_directory
+(
+    `project_id` String COMMENT 'Identifier for the project to which this visitor belongs.',
+    `visitor_fingerprint` String COMMENT 'The unique and persistent identifier for the visitor.',
+
+    `first_seen_timestamp` DateTime('UTC') COMMENT 'Timestamp of when the visitor was first seen.',
+    `last_seen_timestamp` DateTime('UTC') COMMENT 'Timestamp of when the visitor was last seen, used by ReplacingMergeTree for updates.',
+    `total_sessions` UInt32 COMMENT 'The total number of sessions recorded for this visitor.',
+    `total_events` UInt64 COMMENT 'The total number of events recorded for this visitor.',
+
+    `initial_referrer_host` LowCardinality(String) COMMENT 'The hostname of the first-ever referrer for this visitor.',
+    `initial_utm_campaign` LowCardinality(String) COMMENT 'The first UTM campaign that brought this visitor to the site.',
+
+    `last_known_country_code` LowCardinality(String) COMMENT 'The most recent country code associated with the visitor.',
+    `last_known_device_type` LowCardinality(String) COMMENT 'The most recent device type used by the visitor.',
+
+    `custom_user_properties` Map(String, String) COMMENT 'A key-value map for storing custom properties about the user that persist across sessions.'
+)
+ENGINE = ReplacingMergeTree(last_seen_timestamp) -- Keeps only the latest version of a visitor row
+PRIMARY KEY (project_id, visitor_fingerprint)
+ORDER BY (project_id, visitor_fingerprint);
@@ -0,0 +1,77 @@
+-- visitor_directory was never written. Replacing rows would lose the first seen timestamp and totals of a visitor, so it
+-- becomes a view over aggregate states maintained by a materialized view of raw_events instead.
+DROP TABLE visitor_directory;
+
+CREATE TABLE visitor_directory_state
+(
+    `project_id` String COMMENT 'Identifier for the project to which this visitor belongs.',
+    `visitor_fingerprint` String COMMENT 'The identifier of the visitor, which rotates daily with the salt of the fingerprint.',
+
+    `first_seen_timestamp` SimpleAggregateFunction(min, DateTime('UTC')) COMMENT 'Timestamp of when the visitor was first seen.',
+    `last_seen_timestamp` SimpleAggregateFunction(max, DateTime('UTC')) COMMENT 'Timestamp of when the visitor was last seen.',
+    `sessions` AggregateFunction(uniqExact, String) COMMENT 'The distinct sessions recorded for this visitor.',
+    `total_events` SimpleAggregateFunction(sum, UInt64) COMMENT 'The total number of events recorded for this visitor.',
+
+    `initial_referrer_host` AggregateFunction(argMin, String, DateTime64(3, 'UTC')) COMMENT 'The hostname of the referrer of the first event of this visitor.',
+    `initial_utm_campaign` AggregateFunction(argMin, String, DateTime64(3, 'UTC')) COMMENT 'The UTM campaign of the first event of this visitor.',
+
+    `last_known_country_code` AggregateFunction(argMax, String, DateTime64(3, 'UTC')) COMMENT 'The country code of the latest event of this visitor.',
+    `last_known_device_type` AggregateFunction(argMax, String, DateTime64(3, 'UTC')) COMMENT 'The device type of the latest event of this visitor.',
+
+    `retention_days` SimpleAggregateFunction(max, UInt32) COMMENT 'Retention of the project, the directory entry expires with the last event of the visitor.'
+)
+ENGINE = AggregatingMergeTree()
+ORDER BY (project_id, visitor_fingerprint)
+TTL last_seen_timestamp + INTERVAL retention_days DAY;
+
+-- Bot traffic is left out, the directory only lists human visitors.
+CREATE MATERIALIZED VIEW visitor_directory_mv TO visitor_directory_state AS
+SELECT
+    project_id,
+    visitor_fingerprint,
+    min(toDateTime(event_timestamp, 'UTC')) AS first_seen_timestamp,
+    max(toDateTime(event_timestamp, 'UTC')) AS last_seen_timestamp,
+    uniqExactStateIf(session_id, session_id != '') AS sessions,
+    count() AS total_events,
+    argMinState(toString(referrer_host), event_timestamp) AS initial_referrer_host,
+    argMinState(ifNull(toString(utm_campaign), ''), event_timestamp) AS initial_utm_campaign,
+    argMaxState(toString(country_code), event_timestamp) AS last_known_country_code,
+    argMaxState(toString(device_type), event_timestamp) AS last_known_device_type,
+    max(retention_days) AS retention_days
+FROM raw_events
+WHERE visitor_fingerprint != '' AND is_bot = 0
+GROUP BY project_id, visitor_fingerprint;
+
+-- Visitors fingerprinted before the materialized view existed.
+INSERT INTO visitor_directory_state
+SELECT
+    project_id,
+    visitor_fingerprint,
+    min(toDateTime(event_timestamp, 'UTC')),
+    max(toDateTime(event_timestamp, 'UTC')),
+    uniqExactStateIf(session_id, session_id != ''),
+    count(),
+    argMinState(toString(referrer_host), event_timestamp),
+    argMinState(ifNull(toString(utm_campaign), ''), event_timestamp),
+    argMaxState(toString(country_code), event_timestamp),
+    argMaxState(toString(device_type), event_timestamp),
+    max(retention_days)
+FROM raw_events
+WHERE visitor_fingerprint != '' AND is_bot = 0
+GROUP BY project_id, visitor_fingerprint;
+
+-- The directory as read by the hub, one row per visitor with the states merged.
+CREATE VIEW visitor_directory AS
+SELECT
+    project_id,
+    visitor_fingerprint,
+    min(first_seen_timestamp) AS first_seen_timestamp,
+    max(last_seen_timestamp) AS last_seen_timestamp,
+    toUInt32(uniqExactMerge(sessions)) AS total_sessions,
+    sum(total_events) AS total_events,
+    argMinMerge(initial_referrer_host) AS initial_referrer_host,
+    argMinMerge(initial_utm_campaign) AS initial_utm_campaign,
+    argMaxMerge(last_known_country_code) AS last_known_country_code,
+    argMaxMerge(last_known_device_type) AS last_known_device_type
+FROM visitor_directory_state
+GROUP BY project_id, visitor_fingerprint;
@@ -0,0 +1,12 @@
+CREATE TABLE projects
+(
+    `project_id` String COMMENT 'Identifier of the project, the project_id of its events.',
+    `name` String COMMENT 'Display name of the project.',
+    `ingestion_key` String COMMENT 'Public key sent with the reports of the project, embedded in its pages.',
+    `allowed_hosts` Array(String) COMMENT 'Hostnames the project reports from, a leading "*." matches every subdomain. Empty matches every host.',
+    `created_at` DateTime64(3, 'UTC') COMMENT 'When the project was created.',
+    `updated_at` DateTime64(3, 'UTC') COMMENT 'When the project was last changed, the latest version of a project wins on merge.',
+    `is_deleted` UInt8 DEFAULT 0 COMMENT 'Tombstone of a deleted project (1 for deleted, 0 otherwise).'
+)
+ENGINE = ReplacingMergeTree(updated_at, is_deleted)
+ORDER BY (project_id);
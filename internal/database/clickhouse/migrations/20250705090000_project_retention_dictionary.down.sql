@@ -0,0 +1,9 @@
+CREATE OR REPLACE DICTIONARY project_retention_dictionary
+(
+    `project_id` String,
+    `retention_days` UInt32
+)
+PRIMARY KEY project_id
+SOURCE(CLICKHOUSE(TABLE 'project_settings'))
+LAYOUT(FLAT())
+LIFETIME(MIN 300 MAX 360);
@@ -0,0 +1,11 @@
+-- The dictionary reads the latest settings of each project, project_settings holds every version until merged. String
+-- keys need a complex key layout, FLAT only supports UInt64 keys.
+CREATE OR REPLACE DICTIONARY project_retention_dictionary
+(
+    `project_id` String,
+    `retention_days` UInt32
+)
+PRIMARY KEY project_id
+SOURCE(CLICKHOUSE(QUERY 'SELECT project_id, retention_days FROM project_settings FINAL'))
+LAYOUT(COMPLEX_KEY_HASHED())
+LIFETIME(MIN 300 MAX 360);
@@ -0,0 +1,15 @@
+CREATE TABLE goals
+(
+    `project_id` String COMMENT 'Identifier of the project the goal belongs to.',
+    `goal_id` String COMMENT 'Identifier of the goal, unique within the project.',
+    `name` String COMMENT 'Display name of the goal.',
+    `type` LowCardinality(String) COMMENT 'What completes the goal, pageview for pageviews of paths or event for events of a name.',
+    `match` String COMMENT 'Path pattern of the pageviews, * matching any characters, or name of the events completing the goal.',
+    `filter` String COMMENT 'Filter the events completing the goal must match, in the filter language of the hub. Empty matches every event.',
+    `value` Nullable(Decimal(18, 4)) COMMENT 'Monetary value of a completion of the goal in the reporting currency of the hub, null when the goal has none.',
+    `created_at` DateTime64(3, 'UTC') COMMENT 'When the goal was created.',
+    `updated_at` DateTime64(3, 'UTC') COMMENT 'When the goal was last changed, the latest version of a goal wins on merge.',
+    `is_deleted` UInt8 DEFAULT 0 COMMENT 'Tombstone of a deleted goal (1 for deleted, 0 otherwise).'
+)
+ENGINE = ReplacingMergeTree(updated_at, is_deleted)
+ORDER BY (project_id, goal_id);
@@ -0,0 +1 @@
+ALTER TABLE raw_events DROP COLUMN `revenue_currency`, DROP COLUMN `revenue_amount`;
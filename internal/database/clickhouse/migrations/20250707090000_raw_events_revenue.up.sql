@@ -0,0 +1,4 @@
+-- Revenue attached to custom events, such as purchases, in the currency it was reported in
+ALTER TABLE raw_events
+    ADD COLUMN `revenue_amount` Decimal(18, 4) DEFAULT 0 COMMENT 'Revenue attached to the event, 0 for events without revenue.' AFTER `largest_contentful_paint_ms`,
+    ADD COLUMN `revenue_currency` LowCardinality(String) DEFAULT '' COMMENT 'ISO 4217 code of the currency of the revenue, empty for events without revenue.' AFTER `revenue_amount`;
@@ -0,0 +1,53 @@
+package database_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	migratedb "github.com/golang-migrate/migrate/v4/database/clickhouse"
+	"github.com/golang-migrate/migrate/v4/database/multistmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMigrationStatements splits the migrations on semicolons like the ClickHouse driver of golang-migrate does with
+// multi statements enabled, and checks that every statement it sends holds more than comments, which ClickHouse
+// rejects as empty queries.
+func TestMigrationStatements(t *testing.T) {
+	t.Parallel()
+
+	files, err := filepath.Glob("clickhouse/migrations/*.sql")
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	for _, file := range files {
+		migration, err := os.ReadFile(file)
+		require.NoError(t, err)
+
+		err = multistmt.Parse(bytes.NewReader(migration), []byte(";"), migratedb.DefaultMultiStatementMaxSize, func(statement []byte) bool {
+			// Statements of whitespace only are skipped by the driver.
+			if strings.TrimSpace(string(statement)) == "" {
+				return true
+			}
+			assert.True(t, hasQuery(string(statement)), "%s: statement without a query: %q", filepath.Base(file), statement)
+			return true
+		})
+		require.NoError(t, err, file)
+	}
+}
+
+// hasQuery reports whether the statement holds anything but comments, whitespace and its delimiter.
+func hasQuery(statement string) bool {
+	for _, line := range strings.Split(statement, "\n") {
+		if i := strings.Index(line, "--"); i >= 0 {
+			line = line[:i]
+		}
+		if strings.Trim(line, " \t\r;") != "" {
+			return true
+		}
+	}
+	return false
+}
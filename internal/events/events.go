@@ -0,0 +1,252 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/ponrove/octobe/driver/clickhouse"
+	"github.com/shopspring/decimal"
+)
+
+// Source identifies where an event originated, it mirrors the source enum of the raw_events table.
+type Source string
+
+const (
+	SourceClient Source = "client"
+	SourceServer Source = "server"
+)
+
+// EventNamePageview is the event name reserved for pageviews.
+const EventNamePageview = "page_view"
+
+// EventNameWebVitals is the event name reserved for the web vitals of a page load, reported once the metrics are final.
+const EventNameWebVitals = "web_vitals"
+
+// Event is a single row of the raw_events table. Columns with defaults or materialized values on the ClickHouse side
+// (event_id, ingestion_timestamp and retention_days) are left out, and filled in by the database on insert.
+type Event struct {
+	// Core Identifiers
+	ProjectID      string
+	EventTimestamp time.Time
+	EventName      string
+	Source         Source
+
+	// Visitor & Session Information
+	VisitorFingerprint string
+	SessionID          string
+
+	// VisitorIP is the IP address of the visitor supplied by server-side reports, which only keys the visitor
+	// fingerprint. It isn't a column of raw_events, and is never stored nor spooled.
+	VisitorIP netip.Addr `json:"-"`
+
+	// Page & URL Information
+	URL          string
+	URLPath      string
+	URLHost      string
+	URLQuery     string
+	ReferrerURL  string
+	ReferrerHost string
+
+	// Marketing & Attribution (UTM parameters)
+	UTMSource   *string
+	UTMMedium   *string
+	UTMCampaign *string
+	UTMTerm     *string
+	UTMContent  *string
+
+	// A/B Testing
+	ABTestName    *string
+	ABTestVariant *string
+
+	// Geographical & IP-based Location
+	CountryCode string
+	RegionName  string
+	CityName    string
+
+	// Privacy & Security Flags
+	IsVPN         bool
+	VPNProvider   *string
+	IsProxy       bool
+	ProxyProvider *string
+	IsTorNode     bool
+	IsBot         bool
+	BotName       *string
+
+	// Client/Device Information
+	UserAgent      string
+	BrowserName    string
+	BrowserVersion string
+	OSName         string
+	OSVersion      string
+	DeviceType     string
+	ScreenWidth    *uint16
+	ScreenHeight   *uint16
+
+	// Performance Metrics (Core Web Vitals & others)
+	PageLoadTimeMs           uint32
+	TimeOnPageS              uint16
+	FirstContentfulPaintMs   uint32
+	LargestContentfulPaintMs uint32
+
+	// Revenue, in the currency identified by its ISO 4217 code. Events without revenue have no amount.
+	RevenueAmount   *decimal.Decimal
+	RevenueCurrency string
+
+	// Custom Data Payload
+	CustomProperties map[string]string
+}
+
+// Columns lists the raw_events columns written on insert, in the same order as the values returned by Event.Values.
+var Columns = []string{
+	"project_id",
+	"event_timestamp",
+	"event_name",
+	"source",
+	"visitor_fingerprint",
+	"session_id",
+	"url",
+	"url_path",
+	"url_host",
+	"url_query",
+	"referrer_url",
+	"referrer_host",
+	"utm_source",
+	"utm_medium",
+	"utm_campaign",
+	"utm_term",
+	"utm_content",
+	"ab_test_name",
+	"ab_test_variant",
+	"country_code",
+	"region_name",
+	"city_name",
+	"is_vpn",
+	"vpn_provider",
+	"is_proxy",
+	"proxy_provider",
+	"is_tor_node",
+	"is_bot",
+	"bot_name",
+	"user_agent",
+	"browser_name",
+	"browser_version",
+	"os_name",
+	"os_version",
+	"device_type",
+	"screen_width",
+	"screen_height",
+	"page_load_time_ms",
+	"time_on_page_s",
+	"first_contentful_paint_ms",
+	"largest_contentful_paint_ms",
+	"revenue_amount",
+	"revenue_currency",
+	"custom_properties",
+}
+
+// InsertStatement is the statement used to prepare native batches against the raw_events table.
+var InsertStatement = fmt.Sprintf("INSERT INTO raw_events (%s)", strings.Join(Columns, ", "))
+
+// Values returns the column values of the event, in the same order as Columns.
+func (e *Event) Values() []any {
+	customProperties := e.CustomProperties
+	if customProperties == nil {
+		customProperties = map[string]string{}
+	}
+
+	revenueAmount := decimal.Zero
+	if e.RevenueAmount != nil {
+		revenueAmount = *e.RevenueAmount
+	}
+
+	return []any{
+		e.ProjectID,
+		e.EventTimestamp.UTC(),
+		e.EventName,
+		string(e.Source),
+		e.VisitorFingerprint,
+		e.SessionID,
+		e.URL,
+		e.URLPath,
+		e.URLHost,
+		e.URLQuery,
+		e.ReferrerURL,
+		e.ReferrerHost,
+		e.UTMSource,
+		e.UTMMedium,
+		e.UTMCampaign,
+		e.UTMTerm,
+		e.UTMContent,
+		e.ABTestName,
+		e.ABTestVariant,
+		e.CountryCode,
+		e.RegionName,
+		e.CityName,
+		boolToUInt8(e.IsVPN),
+		e.VPNProvider,
+		boolToUInt8(e.IsProxy),
+		e.ProxyProvider,
+		boolToUInt8(e.IsTorNode),
+		boolToUInt8(e.IsBot),
+		e.BotName,
+		e.UserAgent,
+		e.BrowserName,
+		e.BrowserVersion,
+		e.OSName,
+		e.OSVersion,
+		e.DeviceType,
+		e.ScreenWidth,
+		e.ScreenHeight,
+		e.PageLoadTimeMs,
+		e.TimeOnPageS,
+		e.FirstContentfulPaintMs,
+		e.LargestContentfulPaintMs,
+		revenueAmount,
+		e.RevenueCurrency,
+		customProperties,
+	}
+}
+
+// InsertBatch writes the events to the raw_events table as a single native batch, creating one part in ClickHouse
+// instead of one per event.
+func InsertBatch(ctx context.Context, driver clickhouse.Driver, batch []Event) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	session, err := driver.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin ClickHouse session: %w", err)
+	}
+
+	nativeBatch, err := session.Builder()(InsertStatement).PrepareBatch()
+	if err != nil {
+		return fmt.Errorf("failed to prepare raw_events batch: %w", err)
+	}
+
+	for _, event := range batch {
+		err = nativeBatch.Append(event.Values()...)
+		if err != nil {
+			_ = nativeBatch.Abort()
+			return fmt.Errorf("failed to append event to raw_events batch: %w", err)
+		}
+	}
+
+	err = nativeBatch.Send()
+	if err != nil {
+		return fmt.Errorf("failed to send raw_events batch: %w", err)
+	}
+
+	return nil
+}
+
+// boolToUInt8 converts a boolean to the UInt8 flag representation used by the raw_events table.
+func boolToUInt8(b bool) uint8 {
+	if b {
+		return 1
+	}
+	return 0
+}
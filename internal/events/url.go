@@ -0,0 +1,80 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+var (
+	ErrInvalidURL         = errors.New("invalid url")
+	ErrUnsupportedURLType = errors.New("unsupported url scheme")
+)
+
+// SetURL parses the page URL and populates the URL columns of the event, including the UTM parameters found in its
+// query string. Only absolute http and https URLs are accepted.
+func (e *Event) SetURL(rawURL string) error {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: %q", ErrUnsupportedURLType, u.Scheme)
+	}
+
+	if u.Hostname() == "" {
+		return fmt.Errorf("%w: missing host", ErrInvalidURL)
+	}
+
+	e.URL = u.String()
+	e.URLHost = strings.ToLower(u.Hostname())
+	e.URLPath = u.EscapedPath()
+	if e.URLPath == "" {
+		e.URLPath = "/"
+	}
+
+	e.URLQuery = ""
+	if u.RawQuery != "" {
+		e.URLQuery = "?" + u.RawQuery
+	}
+
+	query := u.Query()
+	e.UTMSource = queryValue(query, "utm_source")
+	e.UTMMedium = queryValue(query, "utm_medium")
+	e.UTMCampaign = queryValue(query, "utm_campaign")
+	e.UTMTerm = queryValue(query, "utm_term")
+	e.UTMContent = queryValue(query, "utm_content")
+
+	return nil
+}
+
+// SetReferrer parses the referrer URL and populates the referrer columns of the event. An empty referrer clears the
+// columns, as direct traffic has no referrer.
+func (e *Event) SetReferrer(rawURL string) error {
+	rawURL = strings.TrimSpace(rawURL)
+	if rawURL == "" {
+		e.ReferrerURL = ""
+		e.ReferrerHost = ""
+		return nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+
+	e.ReferrerURL = u.String()
+	e.ReferrerHost = strings.ToLower(u.Hostname())
+	return nil
+}
+
+// queryValue returns a pointer to the first non-empty value of key in the query, or nil if it is absent.
+func queryValue(query url.Values, key string) *string {
+	value := strings.TrimSpace(query.Get(key))
+	if value == "" {
+		return nil
+	}
+	return &value
+}
@@ -0,0 +1,159 @@
+package events_test
+
+import (
+	"testing"
+
+	"github.com/ponrove/ponrove-backend/internal/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func ptr(s string) *string {
+	return &s
+}
+
+func TestSetURL(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		rawURL   string
+		err      error
+		expected events.Event
+	}{
+		{
+			name:   "Plain URL",
+			rawURL: "https://example.com/pricing",
+			expected: events.Event{
+				URL:     "https://example.com/pricing",
+				URLPath: "/pricing",
+				URLHost: "example.com",
+			},
+		},
+		{
+			name:   "Missing path defaults to root",
+			rawURL: "https://Example.com",
+			expected: events.Event{
+				URL:     "https://Example.com",
+				URLPath: "/",
+				URLHost: "example.com",
+			},
+		},
+		{
+			name:   "Query, port and fragment",
+			rawURL: "http://example.com:8080/blog/post?id=123#comments",
+			expected: events.Event{
+				URL:      "http://example.com:8080/blog/post?id=123#comments",
+				URLPath:  "/blog/post",
+				URLHost:  "example.com",
+				URLQuery: "?id=123",
+			},
+		},
+		{
+			name:   "UTM parameters",
+			rawURL: "https://example.com/?utm_source=newsletter&utm_medium=email&utm_campaign=launch&utm_term=&utm_content=cta",
+			expected: events.Event{
+				URL:         "https://example.com/?utm_source=newsletter&utm_medium=email&utm_campaign=launch&utm_term=&utm_content=cta",
+				URLPath:     "/",
+				URLHost:     "example.com",
+				URLQuery:    "?utm_source=newsletter&utm_medium=email&utm_campaign=launch&utm_term=&utm_content=cta",
+				UTMSource:   ptr("newsletter"),
+				UTMMedium:   ptr("email"),
+				UTMCampaign: ptr("launch"),
+				UTMContent:  ptr("cta"),
+			},
+		},
+		{
+			name:   "Relative URL",
+			rawURL: "/pricing",
+			err:    events.ErrUnsupportedURLType,
+		},
+		{
+			name:   "Unsupported scheme",
+			rawURL: "ftp://example.com/file",
+			err:    events.ErrUnsupportedURLType,
+		},
+		{
+			name:   "Missing host",
+			rawURL: "https:///pricing",
+			err:    events.ErrInvalidURL,
+		},
+		{
+			name:   "Unparsable URL",
+			rawURL: "https://exa mple.com/%zz",
+			err:    events.ErrInvalidURL,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var event events.Event
+			err := event.SetURL(tc.rawURL)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, event)
+		})
+	}
+}
+
+func TestSetReferrer(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name         string
+		rawURL       string
+		err          error
+		expectedURL  string
+		expectedHost string
+	}{
+		{
+			name:         "Search engine referrer",
+			rawURL:       "https://www.Google.com/search?q=ponrove",
+			expectedURL:  "https://www.Google.com/search?q=ponrove",
+			expectedHost: "www.google.com",
+		},
+		{
+			name:         "App referrer",
+			rawURL:       "android-app://com.slack/",
+			expectedURL:  "android-app://com.slack/",
+			expectedHost: "com.slack",
+		},
+		{
+			name: "Direct traffic",
+		},
+		{
+			name:   "Unparsable referrer",
+			rawURL: "http://[::1",
+			err:    events.ErrInvalidURL,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var event events.Event
+			err := event.SetReferrer(tc.rawURL)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedURL, event.ReferrerURL)
+			assert.Equal(t, tc.expectedHost, event.ReferrerHost)
+		})
+	}
+}
+
+func TestValuesMatchColumns(t *testing.T) {
+	t.Parallel()
+
+	event := events.Event{}
+	assert.Len(t, event.Values(), len(events.Columns))
+}
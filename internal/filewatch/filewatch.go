@@ -0,0 +1,71 @@
+package filewatch
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// state is what the watcher compares to tell whether a file changed.
+type state struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+// Watcher polls a file for changes. Polling, rather than filesystem notifications, also catches files replaced by
+// symlink swaps, as done for mounted Kubernetes config maps and secrets.
+type Watcher struct {
+	path     string
+	interval time.Duration
+	last     state
+}
+
+// New returns a watcher for the file at path, checking for changes every interval. The current state of the file is
+// recorded right away, so create the watcher before loading the file to never miss a change.
+func New(path string, interval time.Duration) *Watcher {
+	w := &Watcher{path: path, interval: interval}
+	w.last = w.stat()
+	return w
+}
+
+// Changed reports whether the file changed since the previous call, or since the watcher was created. A file that is
+// removed doesn't count as changed, so a deployment briefly removing a file keeps the loaded data.
+func (w *Watcher) Changed() bool {
+	current := w.stat()
+	if current == w.last {
+		return false
+	}
+
+	w.last = current
+	return current.exists
+}
+
+// Run calls onChange every time the file changed, until ctx is done.
+func (w *Watcher) Run(ctx context.Context, onChange func()) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if w.Changed() {
+			slog.InfoContext(ctx, "File changed, reloading", slog.String("path", w.path))
+			onChange()
+		}
+	}
+}
+
+// stat returns the current state of the file, following symlinks.
+func (w *Watcher) stat() state {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return state{}
+	}
+	return state{exists: true, size: info.Size(), modTime: info.ModTime()}
+}
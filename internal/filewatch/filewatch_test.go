@@ -0,0 +1,53 @@
+package filewatch_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ponrove/ponrove-backend/internal/filewatch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChanged(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "data")
+	require.NoError(t, os.WriteFile(path, []byte("v1"), 0o600))
+
+	w := filewatch.New(path, time.Hour)
+	assert.False(t, w.Changed())
+
+	require.NoError(t, os.WriteFile(path, []byte("version 2"), 0o600))
+	assert.True(t, w.Changed())
+	assert.False(t, w.Changed(), "a change should be reported once")
+
+	require.NoError(t, os.Remove(path))
+	assert.False(t, w.Changed(), "a removed file should keep the loaded data")
+
+	require.NoError(t, os.WriteFile(path, []byte("v3"), 0o600))
+	assert.True(t, w.Changed())
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "data")
+	require.NoError(t, os.WriteFile(path, []byte("v1"), 0o600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var reloads atomic.Int32
+	go filewatch.New(path, time.Millisecond).Run(ctx, func() { reloads.Add(1) })
+
+	time.Sleep(10 * time.Millisecond)
+	assert.Zero(t, reloads.Load())
+
+	require.NoError(t, os.WriteFile(path, []byte("version 2"), 0o600))
+	assert.Eventually(t, func() bool { return reloads.Load() == 1 }, time.Second, time.Millisecond)
+}
@@ -0,0 +1,228 @@
+// Package filter parses the filters of hub queries, such as `url_path ~ "/blog/*" and country_code in (SE, NO)`, and
+// compiles them into ClickHouse conditions on raw_events. Fields are resolved against a whitelist of columns and every
+// value is bound as a query argument, so user input never ends up in the SQL itself.
+//
+// The language combines comparisons with and, or, not and parentheses, and is insensitive to the case of keywords:
+//
+//	field = value        field != value
+//	field ~ glob         field !~ glob       (strings, * matches any characters)
+//	field > number       field >= number     field < number    field <= number
+//	field in (a, b)      field not in (a, b)
+//
+// Values are bare words or double quoted strings with \" and \\ escapes. Custom properties are referenced as
+// custom_properties.<key>, booleans compare to true or false.
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ponrove/ponrove-backend/internal/events"
+)
+
+const (
+	// MaxLength is the length of the longest filter parsed.
+	MaxLength = 2000
+	// maxDepth is how deeply expressions nest, through parentheses or not.
+	maxDepth = 32
+	// maxComparisons is the most comparisons in a filter.
+	maxComparisons = 64
+	// maxPropertyKeyLength is the length of the longest custom property key.
+	maxPropertyKeyLength = 128
+	// PropertyPrefix prefixes the key of a custom property field.
+	PropertyPrefix = "custom_properties."
+)
+
+// kind is the type of the values a field compares to.
+type kind int
+
+const (
+	kindString kind = iota
+	kindBool
+	kindNumber
+)
+
+// field is a column of raw_events a filter compares.
+type field struct {
+	expression string
+	kind       kind
+}
+
+// fields maps the fields of raw_events a filter compares to their expression. Nullable columns compare as their zero
+// value when null.
+var fields = map[string]field{
+	"event_name":                  {"event_name", kindString},
+	"source":                      {"toString(source)", kindString},
+	"visitor_fingerprint":         {"visitor_fingerprint", kindString},
+	"session_id":                  {"session_id", kindString},
+	"url":                         {"url", kindString},
+	"url_path":                    {"url_path", kindString},
+	"url_host":                    {"url_host", kindString},
+	"url_query":                   {"url_query", kindString},
+	"referrer_url":                {"referrer_url", kindString},
+	"referrer_host":               {"referrer_host", kindString},
+	"utm_source":                  {"ifNull(utm_source, '')", kindString},
+	"utm_medium":                  {"ifNull(utm_medium, '')", kindString},
+	"utm_campaign":                {"ifNull(utm_campaign, '')", kindString},
+	"utm_term":                    {"ifNull(utm_term, '')", kindString},
+	"utm_content":                 {"ifNull(utm_content, '')", kindString},
+	"ab_test_name":                {"ifNull(ab_test_name, '')", kindString},
+	"ab_test_variant":             {"ifNull(ab_test_variant, '')", kindString},
+	"country_code":                {"country_code", kindString},
+	"region_name":                 {"region_name", kindString},
+	"city_name":                   {"city_name", kindString},
+	"is_vpn":                      {"is_vpn", kindBool},
+	"vpn_provider":                {"ifNull(vpn_provider, '')", kindString},
+	"is_proxy":                    {"is_proxy", kindBool},
+	"proxy_provider":              {"ifNull(proxy_provider, '')", kindString},
+	"is_tor_node":                 {"is_tor_node", kindBool},
+	"is_bot":                      {"is_bot", kindBool},
+	"bot_name":                    {"ifNull(bot_name, '')", kindString},
+	"browser_name":                {"browser_name", kindString},
+	"browser_version":             {"browser_version", kindString},
+	"os_name":                     {"os_name", kindString},
+	"os_version":                  {"os_version", kindString},
+	"device_type":                 {"device_type", kindString},
+	"screen_width":                {"ifNull(screen_width, 0)", kindNumber},
+	"screen_height":               {"ifNull(screen_height, 0)", kindNumber},
+	"page_load_time_ms":           {"page_load_time_ms", kindNumber},
+	"time_on_page_s":              {"time_on_page_s", kindNumber},
+	"first_contentful_paint_ms":   {"first_contentful_paint_ms", kindNumber},
+	"largest_contentful_paint_ms": {"largest_contentful_paint_ms", kindNumber},
+}
+
+// Fields returns the fields a filter compares, sorted, custom properties aside.
+func Fields() []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ErrTooComplex is returned for filters nesting too deeply or with too many comparisons.
+var ErrTooComplex = errors.New("filter is too complex")
+
+// SyntaxError reports why and where a filter could not be parsed.
+type SyntaxError struct {
+	// Offset is the byte offset in the filter where the error was found.
+	Offset  int
+	Message string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at offset %d", e.Message, e.Offset)
+}
+
+// Filter is a parsed filter. The zero Filter matches every event.
+type Filter struct {
+	root node
+}
+
+// Parse parses a filter, an empty or blank filter matching every event. A *SyntaxError is returned for invalid
+// filters, ErrTooComplex for filters too expensive to run.
+func Parse(input string) (Filter, error) {
+	if len(input) > MaxLength {
+		return Filter{}, &SyntaxError{Offset: MaxLength, Message: fmt.Sprintf("filter exceeds %d bytes", MaxLength)}
+	}
+	if strings.TrimSpace(input) == "" {
+		return Filter{}, nil
+	}
+
+	p := &parser{lexer: lexer{input: input}}
+	if err := p.advance(); err != nil {
+		return Filter{}, err
+	}
+	root, err := p.parseOr(0)
+	if err != nil {
+		return Filter{}, err
+	}
+	if p.token.kind != tokenEOF {
+		return Filter{}, p.errorf("unexpected %s", p.token)
+	}
+	return Filter{root: root}, nil
+}
+
+// Empty reports whether the filter matches every event.
+func (f Filter) Empty() bool {
+	return f.root == nil
+}
+
+// References reports whether the filter compares the field.
+func (f Filter) References(name string) bool {
+	return f.root != nil && f.root.references(name)
+}
+
+// SQL compiles the filter into a ClickHouse condition on raw_events and its arguments, bound in order to the ?
+// placeholders of the condition. The condition is empty for the zero Filter.
+func (f Filter) SQL() (string, []any) {
+	if f.root == nil {
+		return "", nil
+	}
+	var (
+		b    strings.Builder
+		args []any
+	)
+	f.root.compile(&b, &args)
+	return b.String(), args
+}
+
+// String returns the filter in its canonical form, parsing into the same filter.
+func (f Filter) String() string {
+	if f.root == nil {
+		return ""
+	}
+	var b strings.Builder
+	f.root.format(&b)
+	return b.String()
+}
+
+// Compare returns the filter comparing the field to a single value, validated like a parsed comparison.
+func Compare(name, operator, value string) (Filter, error) {
+	return Parse(name + " " + operator + " " + quote(value))
+}
+
+// Pageviews returns the filter of the pageviews of the paths matching the glob, where * matches any characters, and
+// matching the filter.
+func Pageviews(pathGlob string, f Filter) (Filter, error) {
+	pageview, err := Compare("event_name", "=", events.EventNamePageview)
+	if err != nil {
+		return Filter{}, err
+	}
+	path, err := Compare("url_path", "~", pathGlob)
+	if err != nil {
+		return Filter{}, err
+	}
+	return And(pageview, path, f), nil
+}
+
+// Events returns the filter of the events with the name and matching the filter.
+func Events(eventName string, f Filter) (Filter, error) {
+	event, err := Compare("event_name", "=", eventName)
+	if err != nil {
+		return Filter{}, err
+	}
+	return And(event, f), nil
+}
+
+// And returns the filter matching the events every filter matches, empty filters aside.
+func And(filters ...Filter) Filter {
+	var operands []node
+	for _, f := range filters {
+		if f.root != nil {
+			operands = append(operands, f.root)
+		}
+	}
+
+	switch len(operands) {
+	case 0:
+		return Filter{}
+	case 1:
+		return Filter{root: operands[0]}
+	default:
+		return Filter{root: &logical{and: true, operands: operands}}
+	}
+}
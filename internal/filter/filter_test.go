@@ -0,0 +1,274 @@
+package filter_test
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/ponrove/ponrove-backend/internal/filter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		input     string
+		sql       string
+		args      []any
+		canonical string
+	}{
+		{
+			input:     `url_path ~ "/blog/*"`,
+			sql:       `url_path LIKE ?`,
+			args:      []any{"/blog/%"},
+			canonical: `url_path ~ "/blog/*"`,
+		},
+		{
+			input:     `country_code in (SE, NO)`,
+			sql:       `country_code IN (?, ?)`,
+			args:      []any{"SE", "NO"},
+			canonical: `country_code in ("SE", "NO")`,
+		},
+		{
+			input:     `custom_properties.plan = "pro"`,
+			sql:       `custom_properties[?] = ?`,
+			args:      []any{"plan", "pro"},
+			canonical: `custom_properties.plan = "pro"`,
+		},
+		{
+			input:     `is_bot = false`,
+			sql:       `is_bot = ?`,
+			args:      []any{uint8(0)},
+			canonical: `is_bot = false`,
+		},
+		{
+			input:     `url_path~/docs/* AND NOT (utm_source = google OR utm_source = "bing") and page_load_time_ms >= 2500`,
+			sql:       `(url_path LIKE ? AND NOT ((ifNull(utm_source, '') = ? OR ifNull(utm_source, '') = ?)) AND page_load_time_ms >= ?)`,
+			args:      []any{"/docs/%", "google", "bing", uint64(2500)},
+			canonical: `(url_path ~ "/docs/*" and not (utm_source = "google" or utm_source = "bing") and page_load_time_ms >= 2500)`,
+		},
+		{
+			input:     `device_type not in (mobile) or referrer_host !~ "*.google.*"`,
+			sql:       `(device_type NOT IN (?) OR referrer_host NOT LIKE ?)`,
+			args:      []any{"mobile", "%.google.%"},
+			canonical: `(device_type not in ("mobile") or referrer_host !~ "*.google.*")`,
+		},
+		{
+			// Wildcards of LIKE are matched literally, only * is a wildcard.
+			input:     `url_query ~ "utm_%\\*"`,
+			sql:       `url_query LIKE ?`,
+			args:      []any{`utm\_\%\\%`},
+			canonical: `url_query ~ "utm_%\\*"`,
+		},
+		{
+			input:     `event_name = "say \"hi\""`,
+			sql:       `event_name = ?`,
+			args:      []any{`say "hi"`},
+			canonical: `event_name = "say \"hi\""`,
+		},
+	}
+
+	for _, tc := range testCases {
+		f, err := filter.Parse(tc.input)
+		require.NoError(t, err, tc.input)
+
+		sql, args := f.SQL()
+		assert.Equal(t, tc.sql, sql, tc.input)
+		assert.Equal(t, tc.args, args, tc.input)
+		assert.Equal(t, tc.canonical, f.String(), tc.input)
+	}
+}
+
+func TestParseEmpty(t *testing.T) {
+	t.Parallel()
+
+	f, err := filter.Parse(" \t")
+	require.NoError(t, err)
+	assert.True(t, f.Empty())
+
+	sql, args := f.SQL()
+	assert.Empty(t, sql)
+	assert.Empty(t, args)
+}
+
+func TestParseErrors(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		input  string
+		offset int
+	}{
+		{input: `password = x`, offset: 0},
+		{input: `url_path`, offset: 8},
+		{input: `url_path = `, offset: 11},
+		{input: `url_path > 3`, offset: 9},
+		{input: `is_bot = maybe`, offset: 9},
+		{input: `is_bot in (true)`, offset: 7},
+		{input: `screen_width = -1`, offset: 15},
+		{input: `url_path = "open`, offset: 11},
+		{input: `url_path = "\n"`, offset: 12},
+		{input: `url_path = x and`, offset: 16},
+		{input: `(url_path = x`, offset: 13},
+		{input: `url_path = x)`, offset: 12},
+		{input: `country_code in (SE NO)`, offset: 20},
+		{input: `country_code not (SE)`, offset: 17},
+		{input: `custom_properties. = x`, offset: 0},
+		{input: `url_path ! x`, offset: 9},
+		{input: `"url_path" = x`, offset: 0},
+	}
+
+	for _, tc := range testCases {
+		_, err := filter.Parse(tc.input)
+		var syntaxErr *filter.SyntaxError
+		require.ErrorAs(t, err, &syntaxErr, tc.input)
+		assert.Equal(t, tc.offset, syntaxErr.Offset, tc.input)
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	t.Parallel()
+
+	_, err := filter.Parse(strings.Repeat("(", 40) + "url_path = x" + strings.Repeat(")", 40))
+	assert.ErrorIs(t, err, filter.ErrTooComplex)
+
+	_, err = filter.Parse(strings.Repeat("not ", 40) + "url_path = x")
+	assert.ErrorIs(t, err, filter.ErrTooComplex)
+
+	_, err = filter.Parse(strings.Repeat("url_path = x or ", 64) + "url_path = x")
+	assert.ErrorIs(t, err, filter.ErrTooComplex)
+
+	var syntaxErr *filter.SyntaxError
+	_, err = filter.Parse("url_path = " + strings.Repeat("x", filter.MaxLength))
+	assert.ErrorAs(t, err, &syntaxErr)
+}
+
+func TestReferences(t *testing.T) {
+	t.Parallel()
+
+	f, err := filter.Parse(`url_path = / and not (is_bot = true or custom_properties.plan = pro)`)
+	require.NoError(t, err)
+	assert.True(t, f.References("is_bot"))
+	assert.True(t, f.References("custom_properties.plan"))
+	assert.False(t, f.References("country_code"))
+	assert.False(t, filter.Filter{}.References("is_bot"))
+}
+
+// sqlToken matches the tokens of a compiled filter.
+var sqlToken = regexp.MustCompile(`^(\s+|[A-Za-z_][A-Za-z0-9_]*|\?|''|0|!=|>=|<=|[=<>(),\[\]])`)
+
+// FuzzParse verifies that nothing of the filter but its structure ends up in the SQL it compiles into. Every token of
+// the SQL is a whitelisted field, a keyword, a placeholder, an operator or a constant, and every placeholder has an
+// argument.
+func FuzzParse(f *testing.F) {
+	for _, seed := range []string{
+		`url_path ~ "/blog/*"`,
+		`country_code in (SE, NO)`,
+		`custom_properties.plan = "pro"`,
+		`is_bot = false`,
+		`(url_path != "/" or not referrer_host ~ '*') and screen_width <= 1024`,
+		`custom_properties.x') OR 1=1 -- = "'; DROP TABLE raw_events; --"`,
+		`utm_source not in ("a\"b", c\d, "e\\")`,
+		`url_path = ?`,
+	} {
+		f.Add(seed)
+	}
+
+	allowed := map[string]bool{
+		"AND": true, "OR": true, "NOT": true, "IN": true, "LIKE": true,
+		"ifNull": true, "toString": true, "custom_properties": true,
+	}
+	for _, name := range filter.Fields() {
+		allowed[name] = true
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		parsed, err := filter.Parse(input)
+		if err != nil {
+			var syntaxErr *filter.SyntaxError
+			if !errors.As(err, &syntaxErr) && !errors.Is(err, filter.ErrTooComplex) {
+				t.Fatalf("unexpected error %v", err)
+			}
+			return
+		}
+
+		sql, args := parsed.SQL()
+		placeholders := 0
+		for rest := sql; rest != ""; {
+			tok := sqlToken.FindString(rest)
+			if tok == "" {
+				t.Fatalf("unexpected SQL %q in %q", rest, sql)
+			}
+			if tok == "?" {
+				placeholders++
+			}
+			if c := tok[0]; (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || c == '_' {
+				if !allowed[tok] {
+					t.Fatalf("unexpected identifier %q in %q", tok, sql)
+				}
+			}
+			rest = rest[len(tok):]
+		}
+		if placeholders != len(args) {
+			t.Fatalf("%d placeholders for %d arguments in %q", placeholders, len(args), sql)
+		}
+
+		// The canonical form parses into the same filter, unless its quotes and parentheses make it too long or nest
+		// too deeply.
+		canonical := parsed.String()
+		if len(canonical) > filter.MaxLength {
+			return
+		}
+		reparsed, err := filter.Parse(canonical)
+		if errors.Is(err, filter.ErrTooComplex) {
+			return
+		}
+		if err != nil {
+			t.Fatalf("failed to parse canonical form %q: %v", canonical, err)
+		}
+		resql, reargs := reparsed.SQL()
+		if resql != sql || !assert.ObjectsAreEqual(args, reargs) {
+			t.Fatalf("canonical form %q compiles into %q %v instead of %q %v", canonical, resql, reargs, sql, args)
+		}
+	})
+}
+
+func TestCompareAnd(t *testing.T) {
+	t.Parallel()
+
+	path, err := filter.Compare("url_path", "~", `/say "hi"/*`)
+	require.NoError(t, err)
+	plan, err := filter.Parse(`custom_properties.plan = pro`)
+	require.NoError(t, err)
+
+	f := filter.And(path, filter.Filter{}, plan)
+	sql, args := f.SQL()
+	assert.Equal(t, `(url_path LIKE ? AND custom_properties[?] = ?)`, sql)
+	assert.Equal(t, []any{`/say "hi"/%`, "plan", "pro"}, args)
+	assert.Equal(t, path, filter.And(filter.Filter{}, path))
+	assert.True(t, filter.And().Empty())
+
+	_, err = filter.Compare("password", "=", "x")
+	assert.Error(t, err)
+}
+
+func TestPageviewsEvents(t *testing.T) {
+	t.Parallel()
+
+	plan, err := filter.Parse(`custom_properties.plan = pro`)
+	require.NoError(t, err)
+
+	f, err := filter.Pageviews("/thanks/*", plan)
+	require.NoError(t, err)
+	sql, args := f.SQL()
+	assert.Equal(t, `(event_name = ? AND url_path LIKE ? AND custom_properties[?] = ?)`, sql)
+	assert.Equal(t, []any{"page_view", "/thanks/%", "plan", "pro"}, args)
+
+	f, err = filter.Events("signup", filter.Filter{})
+	require.NoError(t, err)
+	sql, args = f.SQL()
+	assert.Equal(t, `event_name = ?`, sql)
+	assert.Equal(t, []any{"signup"}, args)
+}
@@ -0,0 +1,186 @@
+package filter
+
+import (
+	"strconv"
+	"strings"
+)
+
+// node is an expression of a filter.
+type node interface {
+	// compile writes the ClickHouse condition of the expression, appending the arguments of its placeholders.
+	compile(b *strings.Builder, args *[]any)
+	// format writes the canonical form of the expression.
+	format(b *strings.Builder)
+	references(name string) bool
+}
+
+// logical joins expressions with and, or or.
+type logical struct {
+	and      bool
+	operands []node
+}
+
+func (l *logical) compile(b *strings.Builder, args *[]any) {
+	separator := " OR "
+	if l.and {
+		separator = " AND "
+	}
+	b.WriteString("(")
+	for n, operand := range l.operands {
+		if n > 0 {
+			b.WriteString(separator)
+		}
+		operand.compile(b, args)
+	}
+	b.WriteString(")")
+}
+
+func (l *logical) format(b *strings.Builder) {
+	separator := " or "
+	if l.and {
+		separator = " and "
+	}
+	b.WriteString("(")
+	for n, operand := range l.operands {
+		if n > 0 {
+			b.WriteString(separator)
+		}
+		operand.format(b)
+	}
+	b.WriteString(")")
+}
+
+func (l *logical) references(name string) bool {
+	for _, operand := range l.operands {
+		if operand.references(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// negation negates an expression.
+type negation struct {
+	operand node
+}
+
+func (n *negation) compile(b *strings.Builder, args *[]any) {
+	b.WriteString("NOT (")
+	n.operand.compile(b, args)
+	b.WriteString(")")
+}
+
+func (n *negation) format(b *strings.Builder) {
+	b.WriteString("not ")
+	n.operand.format(b)
+}
+
+func (n *negation) references(name string) bool {
+	return n.operand.references(name)
+}
+
+// value is a value a field compares to, as bound to the query and as written in the filter.
+type value struct {
+	arg  any
+	text string
+}
+
+// operators maps the operators of the language to their ClickHouse equivalent.
+var operators = map[string]string{
+	"=":      "=",
+	"!=":     "!=",
+	"~":      "LIKE",
+	"!~":     "NOT LIKE",
+	">":      ">",
+	">=":     ">=",
+	"<":      "<",
+	"<=":     "<=",
+	"in":     "IN",
+	"not in": "NOT IN",
+}
+
+// allows reports whether the operator applies to the values of the field.
+func (f field) allows(operator string) bool {
+	switch operator {
+	case "=", "!=":
+		return true
+	case "~", "!~":
+		return f.kind == kindString
+	case ">", ">=", "<", "<=":
+		return f.kind == kindNumber
+	case "in", "not in":
+		return f.kind != kindBool
+	default:
+		return false
+	}
+}
+
+// comparison compares a field to one value, or to a list of values for in and not in.
+type comparison struct {
+	name        string
+	field       field
+	propertyKey string
+	operator    string
+	values      []value
+}
+
+func (c *comparison) compile(b *strings.Builder, args *[]any) {
+	b.WriteString(c.field.expression)
+	if c.propertyKey != "" {
+		*args = append(*args, c.propertyKey)
+	}
+	b.WriteString(" ")
+	b.WriteString(operators[c.operator])
+
+	if c.operator != "in" && c.operator != "not in" {
+		b.WriteString(" ?")
+		*args = append(*args, c.values[0].arg)
+		return
+	}
+	b.WriteString(" (")
+	for n, v := range c.values {
+		if n > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString("?")
+		*args = append(*args, v.arg)
+	}
+	b.WriteString(")")
+}
+
+func (c *comparison) format(b *strings.Builder) {
+	b.WriteString(c.name)
+	b.WriteString(" ")
+	b.WriteString(c.operator)
+	b.WriteString(" ")
+
+	list := c.operator == "in" || c.operator == "not in"
+	if list {
+		b.WriteString("(")
+	}
+	for n, v := range c.values {
+		if n > 0 {
+			b.WriteString(", ")
+		}
+		switch arg := v.arg.(type) {
+		case uint8:
+			b.WriteString(strconv.FormatBool(arg == 1))
+		case uint64:
+			b.WriteString(strconv.FormatUint(arg, 10))
+		default:
+			b.WriteString(quote(v.text))
+		}
+	}
+	if list {
+		b.WriteString(")")
+	}
+}
+
+func (c *comparison) references(name string) bool {
+	return c.name == name
+}
+
+// quote returns the value as a double quoted string of the language.
+func quote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
@@ -0,0 +1,342 @@
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxListValues is the most values of an in list.
+const maxListValues = 100
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenString
+	tokenOperator
+	tokenLeftParen
+	tokenRightParen
+	tokenComma
+)
+
+type token struct {
+	kind   tokenKind
+	text   string
+	offset int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of filter"
+	case tokenString:
+		return strconv.Quote(t.text)
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
+
+// keyword reports whether the token is the keyword, in any case.
+func (t token) keyword(keyword string) bool {
+	return t.kind == tokenWord && strings.EqualFold(t.text, keyword)
+}
+
+// wordDelimiters end a bare word, besides whitespace.
+const wordDelimiters = `()",=!~<>`
+
+// lexer splits a filter into tokens.
+type lexer struct {
+	input  string
+	offset int
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// next returns the next token of the filter.
+func (l *lexer) next() (token, error) {
+	for l.offset < len(l.input) && isSpace(l.input[l.offset]) {
+		l.offset++
+	}
+	start := l.offset
+	if start == len(l.input) {
+		return token{kind: tokenEOF, offset: start}, nil
+	}
+
+	switch c := l.input[start]; c {
+	case '(':
+		l.offset++
+		return token{kind: tokenLeftParen, text: "(", offset: start}, nil
+	case ')':
+		l.offset++
+		return token{kind: tokenRightParen, text: ")", offset: start}, nil
+	case ',':
+		l.offset++
+		return token{kind: tokenComma, text: ",", offset: start}, nil
+	case '"':
+		return l.quoted()
+	case '=', '~':
+		l.offset++
+		return token{kind: tokenOperator, text: string(c), offset: start}, nil
+	case '!':
+		if l.offset+1 < len(l.input) && (l.input[l.offset+1] == '=' || l.input[l.offset+1] == '~') {
+			l.offset += 2
+			return token{kind: tokenOperator, text: l.input[start:l.offset], offset: start}, nil
+		}
+		return token{}, &SyntaxError{Offset: start, Message: `expected != or !~`}
+	case '<', '>':
+		l.offset++
+		if l.offset < len(l.input) && l.input[l.offset] == '=' {
+			l.offset++
+		}
+		return token{kind: tokenOperator, text: l.input[start:l.offset], offset: start}, nil
+	}
+
+	for l.offset < len(l.input) && !isSpace(l.input[l.offset]) && !strings.ContainsRune(wordDelimiters, rune(l.input[l.offset])) {
+		l.offset++
+	}
+	return token{kind: tokenWord, text: l.input[start:l.offset], offset: start}, nil
+}
+
+// quoted reads a double quoted string, unescaping \" and \\.
+func (l *lexer) quoted() (token, error) {
+	start := l.offset
+	l.offset++
+
+	var b strings.Builder
+	for l.offset < len(l.input) {
+		c := l.input[l.offset]
+		switch c {
+		case '"':
+			l.offset++
+			return token{kind: tokenString, text: b.String(), offset: start}, nil
+		case '\\':
+			if l.offset+1 == len(l.input) || (l.input[l.offset+1] != '"' && l.input[l.offset+1] != '\\') {
+				return token{}, &SyntaxError{Offset: l.offset, Message: `expected \" or \\ escape`}
+			}
+			b.WriteByte(l.input[l.offset+1])
+			l.offset += 2
+		default:
+			b.WriteByte(c)
+			l.offset++
+		}
+	}
+	return token{}, &SyntaxError{Offset: start, Message: "unterminated string"}
+}
+
+// parser builds the expression of a filter, one token ahead of the lexer.
+type parser struct {
+	lexer       lexer
+	token       token
+	comparisons int
+}
+
+func (p *parser) advance() error {
+	t, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+	p.token = t
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return &SyntaxError{Offset: p.token.offset, Message: fmt.Sprintf(format, args...)}
+}
+
+// parseOr parses expressions joined by or, which binds looser than and.
+func (p *parser) parseOr(depth int) (node, error) {
+	return p.parseLogical(depth, "or", p.parseAnd)
+}
+
+// parseAnd parses expressions joined by and.
+func (p *parser) parseAnd(depth int) (node, error) {
+	return p.parseLogical(depth, "and", p.parseUnary)
+}
+
+func (p *parser) parseLogical(depth int, keyword string, operand func(int) (node, error)) (node, error) {
+	first, err := operand(depth)
+	if err != nil {
+		return nil, err
+	}
+
+	operands := []node{first}
+	for p.token.keyword(keyword) {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		next, err := operand(depth)
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, next)
+	}
+
+	if len(operands) == 1 {
+		return first, nil
+	}
+	return &logical{and: keyword == "and", operands: operands}, nil
+}
+
+// parseUnary parses a negated expression, an expression in parentheses or a comparison.
+func (p *parser) parseUnary(depth int) (node, error) {
+	if depth > maxDepth {
+		return nil, ErrTooComplex
+	}
+
+	switch {
+	case p.token.keyword("not"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		operand, err := p.parseUnary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return &negation{operand: operand}, nil
+	case p.token.kind == tokenLeftParen:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		n, err := p.parseOr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if p.token.kind != tokenRightParen {
+			return nil, p.errorf("expected ) instead of %s", p.token)
+		}
+		return n, p.advance()
+	default:
+		return p.parseComparison()
+	}
+}
+
+// parseComparison parses a field compared to a value, or to a list of values.
+func (p *parser) parseComparison() (node, error) {
+	if p.token.kind != tokenWord {
+		return nil, p.errorf("expected a field instead of %s", p.token)
+	}
+	p.comparisons++
+	if p.comparisons > maxComparisons {
+		return nil, ErrTooComplex
+	}
+
+	c := &comparison{name: p.token.text}
+	if key, ok := strings.CutPrefix(c.name, PropertyPrefix); ok {
+		if key == "" || len(key) > maxPropertyKeyLength {
+			return nil, p.errorf("expected a custom property key of 1 to %d bytes", maxPropertyKeyLength)
+		}
+		c.field = field{expression: "custom_properties[?]", kind: kindString}
+		c.propertyKey = key
+	} else if f, ok := fields[c.name]; ok {
+		c.field = f
+	} else {
+		return nil, p.errorf("unknown field %q", c.name)
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case p.token.kind == tokenOperator:
+		c.operator = p.token.text
+		if !c.field.allows(c.operator) {
+			return nil, p.errorf("operator %s does not apply to %s", c.operator, c.name)
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		v, err := p.parseValue(c)
+		if err != nil {
+			return nil, err
+		}
+		c.values = []value{v}
+		return c, nil
+	case p.token.keyword("in"):
+		c.operator = "in"
+	case p.token.keyword("not"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if !p.token.keyword("in") {
+			return nil, p.errorf("expected in instead of %s", p.token)
+		}
+		c.operator = "not in"
+	default:
+		return nil, p.errorf("expected an operator instead of %s", p.token)
+	}
+
+	if !c.field.allows(c.operator) {
+		return nil, p.errorf("operator %s does not apply to %s", c.operator, c.name)
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.token.kind != tokenLeftParen {
+		return nil, p.errorf("expected ( instead of %s", p.token)
+	}
+	for {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if len(c.values) == maxListValues {
+			return nil, p.errorf("expected at most %d values", maxListValues)
+		}
+		v, err := p.parseValue(c)
+		if err != nil {
+			return nil, err
+		}
+		c.values = append(c.values, v)
+
+		switch p.token.kind {
+		case tokenComma:
+			continue
+		case tokenRightParen:
+			return c, p.advance()
+		default:
+			return nil, p.errorf("expected , or ) instead of %s", p.token)
+		}
+	}
+}
+
+// parseValue parses a value the field of the comparison compares to.
+func (p *parser) parseValue(c *comparison) (value, error) {
+	if p.token.kind != tokenWord && p.token.kind != tokenString {
+		return value{}, p.errorf("expected a value instead of %s", p.token)
+	}
+	text := p.token.text
+
+	var v value
+	switch c.field.kind {
+	case kindBool:
+		switch strings.ToLower(text) {
+		case "true", "1":
+			v = value{arg: uint8(1)}
+		case "false", "0":
+			v = value{arg: uint8(0)}
+		default:
+			return value{}, p.errorf("expected true or false for %s", c.name)
+		}
+	case kindNumber:
+		n, err := strconv.ParseUint(text, 10, 64)
+		if err != nil {
+			return value{}, p.errorf("expected a non-negative integer for %s", c.name)
+		}
+		v = value{arg: n}
+	default:
+		v = value{arg: text}
+		if c.operator == "~" || c.operator == "!~" {
+			v.arg = globToLike(text)
+		}
+	}
+	v.text = text
+	return v, p.advance()
+}
+
+// globToLike converts a glob, where * matches any characters, into a LIKE pattern.
+func globToLike(glob string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`, `*`, `%`).Replace(glob)
+}
@@ -0,0 +1,199 @@
+package fingerprint
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// Length is the length of a fingerprint, in hexadecimal characters.
+const Length = 32
+
+// defaultRefreshInterval is how often the salt of the day is checked against the store, so replicas that disagreed on
+// the salt, e.g. when one of them couldn't reach the store, converge again.
+const defaultRefreshInterval = time.Minute
+
+// defaultFetchTimeout bounds fetching the salt of the day from the store.
+const defaultFetchTimeout = 5 * time.Second
+
+// SaltStore keeps the salt of each day, shared by every replica computing fingerprints.
+type SaltStore interface {
+	// Salt returns the salt of the day, storing the proposed salt when the day has none yet. Callers proposing different
+	// salts for the same day receive the same salt.
+	Salt(ctx context.Context, day time.Time, proposal string) (string, error)
+}
+
+// Option is a function that modifies the hasher configuration.
+type Option func(*Hasher)
+
+// WithRefreshInterval sets how often the salt of the day is checked against the store.
+func WithRefreshInterval(interval time.Duration) Option {
+	return func(h *Hasher) {
+		h.refreshInterval = interval
+	}
+}
+
+// WithFetchTimeout sets how long fetching the salt of the day from the store may take.
+func WithFetchTimeout(timeout time.Duration) Option {
+	return func(h *Hasher) {
+		h.fetchTimeout = timeout
+	}
+}
+
+// WithClock sets the clock deciding the day, used by tests.
+func WithClock(now func() time.Time) Option {
+	return func(h *Hasher) {
+		h.now = now
+	}
+}
+
+// Hasher derives visitor fingerprints from a keyed hash of the project, the IP address and the user agent of the
+// visitor, keyed by a salt that rotates every day. The same visitor gets the same fingerprint for a day, so unique
+// visitors can be counted without cookies, while fingerprints can't be linked across days or back to an IP address once
+// the salt of the day is discarded. Fingerprints are computed from the salt known for the day, which Watch refreshes in
+// the background.
+type Hasher struct {
+	store           SaltStore
+	refreshInterval time.Duration
+	fetchTimeout    time.Duration
+	now             func() time.Time
+
+	// fetch serializes fetching the salt from the store, mu guards the salt known for the day.
+	fetch sync.Mutex
+	mu    sync.RWMutex
+	day   time.Time
+	salt  []byte
+}
+
+// New returns a hasher sharing its salts through the store.
+func New(store SaltStore, opts ...Option) *Hasher {
+	h := &Hasher{
+		store:           store,
+		refreshInterval: defaultRefreshInterval,
+		fetchTimeout:    defaultFetchTimeout,
+		now:             time.Now,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// Fingerprint returns the fingerprint of the visitor. The address may be invalid when it couldn't be resolved, the
+// fingerprint is then derived from the project and user agent alone.
+func (h *Hasher) Fingerprint(ctx context.Context, projectID string, addr netip.Addr, userAgent string) string {
+	var ip string
+	if addr.IsValid() {
+		ip = addr.Unmap().String()
+	}
+
+	mac := hmac.New(sha256.New, h.saltOfDay(ctx))
+	for _, part := range []string{projectID, ip, userAgent} {
+		mac.Write([]byte(part))
+		// Parts are separated by a byte that can't occur in any of them, so they can't be shifted into each other.
+		mac.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(mac.Sum(nil)[:Length/2])
+}
+
+// Refresh fetches the salt of the current UTC day from the store, so replicas that disagreed on the salt converge
+// again. When the store is unavailable, the salt known for the day is kept, or a new salt is used locally until the
+// store can be reached again.
+func (h *Hasher) Refresh(ctx context.Context) {
+	h.fetch.Lock()
+	defer h.fetch.Unlock()
+
+	h.fetchSalt(ctx, h.today())
+}
+
+// Watch refreshes the salt of the day every refresh interval, until ctx is done.
+func (h *Hasher) Watch(ctx context.Context) {
+	ticker := time.NewTicker(h.refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.Refresh(ctx)
+		}
+	}
+}
+
+// saltOfDay returns the salt of the current UTC day. The salt of a new day is fetched by the first caller needing it,
+// the others waiting for it rather than using salts of their own.
+func (h *Hasher) saltOfDay(ctx context.Context) []byte {
+	day := h.today()
+	if salt, ok := h.known(day); ok {
+		return salt
+	}
+
+	h.fetch.Lock()
+	defer h.fetch.Unlock()
+
+	if salt, ok := h.known(day); ok {
+		return salt
+	}
+	return h.fetchSalt(ctx, day)
+}
+
+// known returns the salt known for the day, false when the salt of another day is known.
+func (h *Hasher) known(day time.Time) ([]byte, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.salt, day.Equal(h.day)
+}
+
+// fetchSalt fetches the salt of the day from the store, proposing the salt known for the day or a new one, and returns
+// the salt now known for the day. The fetch is detached from the cancellation of ctx, a request going away must not
+// leave the replica with a salt of its own. The caller holds h.fetch.
+func (h *Hasher) fetchSalt(ctx context.Context, day time.Time) []byte {
+	proposal, ok := h.known(day)
+	if !ok {
+		proposal = newSalt()
+	}
+
+	fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), h.fetchTimeout)
+	defer cancel()
+
+	salt, err := h.store.Salt(fetchCtx, day, hex.EncodeToString(proposal))
+	if err == nil {
+		var decoded []byte
+		decoded, err = hex.DecodeString(salt)
+		if err == nil {
+			proposal = decoded
+		}
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to fetch the salt of the day, using a local salt", slog.Time("day", day), slog.Any("error", err))
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.day = day
+	h.salt = proposal
+	return proposal
+}
+
+// today returns the start of the current UTC day.
+func (h *Hasher) today() time.Time {
+	now := h.now().UTC()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// newSalt returns a random salt.
+func newSalt() []byte {
+	salt := make([]byte, 32)
+	_, _ = rand.Read(salt)
+	return salt
+}
@@ -0,0 +1,187 @@
+package fingerprint_test
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ponrove/octobe"
+	"github.com/ponrove/octobe/driver/clickhouse"
+	"github.com/ponrove/octobe/driver/clickhouse/mock"
+	"github.com/ponrove/ponrove-backend/internal/fingerprint"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const userAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:126.0) Gecko/20100101 Firefox/126.0"
+
+// clock is a settable clock for the hasher.
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// failingStore is a salt store that can't be reached.
+type failingStore struct {
+	calls atomic.Int64
+}
+
+func (s *failingStore) Salt(context.Context, time.Time, string) (string, error) {
+	s.calls.Add(1)
+	return "", errors.New("connection refused")
+}
+
+// contextStore is a salt store failing requests whose context is done, like the ClickHouse store does.
+type contextStore struct {
+	*fingerprint.MemoryStore
+}
+
+func (s contextStore) Salt(ctx context.Context, day time.Time, proposal string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return s.MemoryStore.Salt(ctx, day, proposal)
+}
+
+func TestFingerprint(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	addr := netip.MustParseAddr("81.2.69.160")
+	c := &clock{now: time.Date(2025, 6, 16, 9, 0, 0, 0, time.UTC)}
+	store := fingerprint.NewMemoryStore()
+	hasher := fingerprint.New(store, fingerprint.WithClock(c.Now))
+
+	fp := hasher.Fingerprint(ctx, "project-1", addr, userAgent)
+	assert.Len(t, fp, fingerprint.Length)
+	assert.Equal(t, fp, hasher.Fingerprint(ctx, "project-1", addr, userAgent))
+	assert.Equal(t, fp, hasher.Fingerprint(ctx, "project-1", netip.MustParseAddr("::ffff:81.2.69.160"), userAgent), "mapped addresses should be unmapped")
+	assert.NotEqual(t, fp, hasher.Fingerprint(ctx, "project-2", addr, userAgent))
+	assert.NotEqual(t, fp, hasher.Fingerprint(ctx, "project-1", netip.MustParseAddr("81.2.69.161"), userAgent))
+	assert.NotEqual(t, fp, hasher.Fingerprint(ctx, "project-1", addr, userAgent+" "))
+	assert.NotEqual(t, hasher.Fingerprint(ctx, "ab", netip.Addr{}, "c"), hasher.Fingerprint(ctx, "a", netip.Addr{}, "bc"))
+
+	// Another replica sharing the store agrees on the fingerprint.
+	replica := fingerprint.New(store, fingerprint.WithClock(c.Now))
+	assert.Equal(t, fp, replica.Fingerprint(ctx, "project-1", addr, userAgent))
+
+	// The salt rotates with the day.
+	c.Set(time.Date(2025, 6, 17, 0, 0, 1, 0, time.UTC))
+	next := hasher.Fingerprint(ctx, "project-1", addr, userAgent)
+	assert.NotEqual(t, fp, next)
+	assert.Equal(t, next, replica.Fingerprint(ctx, "project-1", addr, userAgent))
+}
+
+func TestFingerprintStoreUnavailable(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	addr := netip.MustParseAddr("81.2.69.160")
+	c := &clock{now: time.Date(2025, 6, 16, 9, 0, 0, 0, time.UTC)}
+	store := &failingStore{}
+	hasher := fingerprint.New(store, fingerprint.WithClock(c.Now))
+
+	fp := hasher.Fingerprint(ctx, "project-1", addr, userAgent)
+	assert.Len(t, fp, fingerprint.Length)
+	c.Set(c.Now().Add(time.Hour))
+	assert.Equal(t, fp, hasher.Fingerprint(ctx, "project-1", addr, userAgent), "the local salt should be kept for the day")
+	assert.Equal(t, int64(1), store.calls.Load(), "the store should only be retried by refreshes")
+
+	hasher.Refresh(ctx)
+	assert.Equal(t, fp, hasher.Fingerprint(ctx, "project-1", addr, userAgent))
+	assert.Equal(t, int64(2), store.calls.Load())
+}
+
+func TestFingerprintCanceledRequest(t *testing.T) {
+	t.Parallel()
+
+	addr := netip.MustParseAddr("81.2.69.160")
+	c := &clock{now: time.Date(2025, 6, 16, 9, 0, 0, 0, time.UTC)}
+	store := contextStore{fingerprint.NewMemoryStore()}
+	replica := fingerprint.New(store, fingerprint.WithClock(c.Now))
+	fp := replica.Fingerprint(context.Background(), "project-1", addr, userAgent)
+
+	// A request going away while the salt of the day is fetched doesn't leave the hasher with a salt of its own.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	hasher := fingerprint.New(store, fingerprint.WithClock(c.Now))
+	assert.Equal(t, fp, hasher.Fingerprint(ctx, "project-1", addr, userAgent))
+}
+
+func TestWatch(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := &failingStore{}
+	hasher := fingerprint.New(store, fingerprint.WithRefreshInterval(time.Millisecond))
+
+	go hasher.Watch(ctx)
+	assert.Eventually(t, func() bool { return store.calls.Load() >= 2 }, time.Second, time.Millisecond)
+}
+
+func TestMemoryStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := fingerprint.NewMemoryStore()
+	day := time.Date(2025, 6, 16, 0, 0, 0, 0, time.UTC)
+
+	salt, err := store.Salt(ctx, day, "first")
+	require.NoError(t, err)
+	assert.Equal(t, "first", salt)
+
+	salt, err = store.Salt(ctx, day, "second")
+	require.NoError(t, err)
+	assert.Equal(t, "first", salt, "the first salt of the day should win")
+
+	salt, err = store.Salt(ctx, day.AddDate(0, 0, 1), "third")
+	require.NoError(t, err)
+	assert.Equal(t, "third", salt)
+}
+
+func TestClickHouseStore(t *testing.T) {
+	t.Parallel()
+
+	conn := mock.NewMock()
+	driver, err := octobe.New(clickhouse.OpenNativeWithConn(conn))
+	require.NoError(t, err)
+	store := fingerprint.NewClickHouseStore(driver)
+	ctx := context.Background()
+	day := time.Date(2025, 6, 16, 0, 0, 0, 0, time.UTC)
+
+	// The day has no salt yet, the proposal is inserted and the winning salt read back.
+	conn.ExpectQueryRow("SELECT count(), argMin(salt, (created_at, salt)) FROM visitor_salts").WillReturnRow(mock.NewMockRow(uint64(0), ""))
+	conn.ExpectExec("INSERT INTO visitor_salts (day, salt)")
+	conn.ExpectQueryRow("SELECT count(), argMin(salt, (created_at, salt)) FROM visitor_salts").WillReturnRow(mock.NewMockRow(uint64(2), "other"))
+
+	salt, err := store.Salt(ctx, day, "proposal")
+	require.NoError(t, err)
+	assert.Equal(t, "other", salt, "the salt of the replica inserting first should win")
+
+	conn.ExpectQueryRow("SELECT count(), argMin(salt, (created_at, salt)) FROM visitor_salts").WillReturnRow(mock.NewMockRow(uint64(1), "other"))
+	salt, err = store.Salt(ctx, day, "proposal")
+	require.NoError(t, err)
+	assert.Equal(t, "other", salt)
+	assert.NoError(t, conn.AllExpectationsMet())
+
+	conn.ExpectQueryRow("SELECT count()").WillReturnRow(mock.NewMockRow().WillReturnError(errors.New("connection refused")))
+	_, err = store.Salt(ctx, day, "proposal")
+	assert.Error(t, err)
+}
@@ -0,0 +1,95 @@
+package fingerprint
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ponrove/octobe/driver/clickhouse"
+)
+
+// ClickHouseStore keeps the salts in the visitor_salts table, so all ingestion replicas fingerprint visitors alike.
+// Salts expire with the day they belong to.
+type ClickHouseStore struct {
+	driver clickhouse.Driver
+}
+
+// NewClickHouseStore returns a salt store backed by ClickHouse.
+func NewClickHouseStore(driver clickhouse.Driver) *ClickHouseStore {
+	return &ClickHouseStore{driver: driver}
+}
+
+// Salt returns the salt of the day, inserting the proposal when the day has none yet. Replicas racing to insert a salt
+// all read back the oldest salt of the day.
+func (s *ClickHouseStore) Salt(ctx context.Context, day time.Time, proposal string) (string, error) {
+	salt, found, err := s.find(ctx, day)
+	if err != nil || found {
+		return salt, err
+	}
+
+	session, err := s.driver.Begin(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin ClickHouse session: %w", err)
+	}
+
+	err = session.Builder()(`INSERT INTO visitor_salts (day, salt) VALUES (?, ?)`).Arguments(day, proposal).Exec()
+	if err != nil {
+		return "", fmt.Errorf("failed to insert visitor salt: %w", err)
+	}
+
+	salt, found, err = s.find(ctx, day)
+	if err == nil && !found {
+		err = fmt.Errorf("visitor salt of %s not found after insert", day.Format(time.DateOnly))
+	}
+	return salt, err
+}
+
+// find returns the oldest salt of the day, ties broken by the salt itself so every replica picks the same one.
+func (s *ClickHouseStore) find(ctx context.Context, day time.Time) (string, bool, error) {
+	session, err := s.driver.Begin(ctx)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to begin ClickHouse session: %w", err)
+	}
+
+	var (
+		count uint64
+		salt  string
+	)
+	err = session.Builder()(`SELECT count(), argMin(salt, (created_at, salt)) FROM visitor_salts WHERE day = ?`).Arguments(day).QueryRow(&count, &salt)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to query visitor salt: %w", err)
+	}
+
+	return salt, count > 0, nil
+}
+
+// MemoryStore keeps the salts in memory, for single instance deployments and tests.
+type MemoryStore struct {
+	mu    sync.Mutex
+	salts map[time.Time]string
+}
+
+// NewMemoryStore returns an empty in-memory salt store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{salts: make(map[time.Time]string)}
+}
+
+// Salt returns the salt of the day, storing the proposal when the day has none yet. Salts of previous days are
+// discarded.
+func (s *MemoryStore) Salt(_ context.Context, day time.Time, proposal string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if salt, ok := s.salts[day]; ok {
+		return salt, nil
+	}
+
+	for d := range s.salts {
+		if d.Before(day) {
+			delete(s.salts, d)
+		}
+	}
+	s.salts[day] = proposal
+	return proposal, nil
+}
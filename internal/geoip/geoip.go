@@ -0,0 +1,125 @@
+package geoip
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/ponrove/ponrove-backend/internal/filewatch"
+)
+
+// Location is the part of a GeoIP record stored with events. Fields are empty when unknown.
+type Location struct {
+	CountryCode string
+	RegionName  string
+	CityName    string
+}
+
+// record is the layout of the City and Country databases of MaxMind, and of compatible databases such as DB-IP.
+type record struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+}
+
+// Database resolves IP addresses to locations, using a MaxMind format (MMDB) database file. The file is read locally,
+// addresses never leave the process, and can be replaced while running.
+type Database struct {
+	path    string
+	watcher *filewatch.Watcher
+
+	mu     sync.RWMutex
+	reader *maxminddb.Reader
+}
+
+// Open opens the database file at path, checking the file for changes every reloadInterval once watched.
+func Open(path string, reloadInterval time.Duration) (*Database, error) {
+	// Watch from before opening, so a file replaced while opening is reloaded.
+	watcher := filewatch.New(path, reloadInterval)
+
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GeoIP database: %w", err)
+	}
+
+	return &Database{path: path, watcher: watcher, reader: reader}, nil
+}
+
+// Lookup returns the location of the address, and an empty location when the address is unknown, or the database is
+// nil. Names are the English ones.
+func (d *Database) Lookup(addr netip.Addr) Location {
+	if d == nil || !addr.IsValid() {
+		return Location{}
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.reader == nil {
+		return Location{}
+	}
+
+	var rec record
+	err := d.reader.Lookup(addr.AsSlice(), &rec)
+	if err != nil {
+		slog.Debug("Failed to look up GeoIP record", slog.Any("error", err))
+		return Location{}
+	}
+
+	loc := Location{CountryCode: rec.Country.ISOCode, CityName: rec.City.Names["en"]}
+	if len(rec.Subdivisions) > 0 {
+		loc.RegionName = rec.Subdivisions[0].Names["en"]
+	}
+
+	return loc
+}
+
+// Reload reopens the database file, swapping it in once opened. The previous database is kept when the file can't be
+// opened, e.g. while it is being replaced.
+func (d *Database) Reload() error {
+	reader, err := maxminddb.Open(d.path)
+	if err != nil {
+		return fmt.Errorf("failed to reload GeoIP database: %w", err)
+	}
+
+	d.mu.Lock()
+	previous := d.reader
+	d.reader = reader
+	d.mu.Unlock()
+
+	if previous != nil {
+		return previous.Close()
+	}
+	return nil
+}
+
+// Watch reloads the database whenever its file changes, until ctx is done.
+func (d *Database) Watch(ctx context.Context) {
+	d.watcher.Run(ctx, func() {
+		if err := d.Reload(); err != nil {
+			slog.ErrorContext(ctx, "Keeping previous GeoIP database", slog.Any("error", err))
+		}
+	})
+}
+
+// Close closes the database, lookups return empty locations afterwards.
+func (d *Database) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.reader == nil {
+		return nil
+	}
+
+	err := d.reader.Close()
+	d.reader = nil
+	return err
+}
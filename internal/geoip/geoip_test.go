@@ -0,0 +1,74 @@
+package geoip_test
+
+import (
+	"context"
+	"net/netip"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ponrove/ponrove-backend/internal/geoip"
+	"github.com/ponrove/ponrove-backend/internal/geoip/geoiptest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var london = geoip.Location{CountryCode: "GB", RegionName: "England", CityName: "London"}
+
+func TestLookup(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "City.mmdb")
+	require.NoError(t, geoiptest.WriteDatabase(path, map[string]geoip.Location{
+		"81.2.69.0/24":     london,
+		"2a02:c7f::/32":    {CountryCode: "GB"},
+		"1.128.0.0/11":     {CountryCode: "AU", RegionName: "New South Wales"},
+		"89.160.20.128/25": {CountryCode: "SE", RegionName: "Stockholm County", CityName: "Stockholm"},
+	}))
+
+	db, err := geoip.Open(path, time.Hour)
+	require.NoError(t, err)
+	defer db.Close()
+
+	assert.Equal(t, london, db.Lookup(netip.MustParseAddr("81.2.69.160")))
+	assert.Equal(t, geoip.Location{CountryCode: "GB"}, db.Lookup(netip.MustParseAddr("2a02:c7f:1234::1")))
+	assert.Equal(t, geoip.Location{CountryCode: "AU", RegionName: "New South Wales"}, db.Lookup(netip.MustParseAddr("1.130.0.1")))
+	assert.Equal(t, geoip.Location{}, db.Lookup(netip.MustParseAddr("89.160.20.1")), "unknown addresses should have no location")
+	assert.Equal(t, geoip.Location{}, db.Lookup(netip.Addr{}))
+
+	var disabled *geoip.Database
+	assert.Equal(t, geoip.Location{}, disabled.Lookup(netip.MustParseAddr("81.2.69.160")), "a nil database should be usable")
+}
+
+func TestOpenMissingFile(t *testing.T) {
+	t.Parallel()
+
+	_, err := geoip.Open(filepath.Join(t.TempDir(), "missing.mmdb"), time.Hour)
+	assert.Error(t, err)
+}
+
+func TestWatchReloads(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "City.mmdb")
+	require.NoError(t, geoiptest.WriteDatabase(path, map[string]geoip.Location{"81.2.69.0/24": london}))
+
+	db, err := geoip.Open(path, time.Millisecond)
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go db.Watch(ctx)
+
+	paris := geoip.Location{CountryCode: "FR", RegionName: "Île-de-France", CityName: "Paris"}
+	require.NoError(t, geoiptest.WriteDatabase(path, map[string]geoip.Location{
+		"81.2.69.0/24":   london,
+		"90.84.140.0/24": paris,
+	}))
+
+	assert.Eventually(t, func() bool {
+		return db.Lookup(netip.MustParseAddr("90.84.140.1")) == paris
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, london, db.Lookup(netip.MustParseAddr("81.2.69.160")))
+}
@@ -0,0 +1,53 @@
+// Package geoiptest writes small GeoIP databases for tests.
+package geoiptest
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/ponrove/ponrove-backend/internal/geoip"
+)
+
+// WriteDatabase writes a City database to path, holding the given locations by CIDR network.
+func WriteDatabase(path string, locations map[string]geoip.Location) error {
+	tree, err := mmdbwriter.New(mmdbwriter.Options{DatabaseType: "GeoIP2-City", RecordSize: 24})
+	if err != nil {
+		return err
+	}
+
+	for cidr, loc := range locations {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return err
+		}
+
+		err = tree.Insert(network, mmdbtype.Map{
+			"country":      mmdbtype.Map{"iso_code": mmdbtype.String(loc.CountryCode)},
+			"subdivisions": mmdbtype.Slice{mmdbtype.Map{"names": mmdbtype.Map{"en": mmdbtype.String(loc.RegionName)}}},
+			"city":         mmdbtype.Map{"names": mmdbtype.Map{"en": mmdbtype.String(loc.CityName)}},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Write next to the destination and rename, like a database update would, so readers never see a partial file.
+	f, err := os.CreateTemp(filepath.Dir(path), ".geoip-*.mmdb")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = tree.WriteTo(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,87 @@
+package goals
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/ponrove/ponrove-backend/internal/filter"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrNotFound    = errors.New("goal not found")
+	ErrInvalidType = errors.New("invalid goal type")
+)
+
+// idPrefix marks goal IDs.
+const idPrefix = "goal_"
+
+// Type is what completes a goal.
+type Type string
+
+const (
+	// TypePageview goals are completed by pageviews of the paths matching a pattern, * matching any characters.
+	TypePageview Type = "pageview"
+	// TypeEvent goals are completed by events of a name.
+	TypeEvent Type = "event"
+)
+
+// Goal is a conversion a project tracks, the pageviews or events completing it.
+type Goal struct {
+	ID        string
+	ProjectID string
+	Name      string
+	Type      Type
+	// Match is the path pattern of the pageviews, or the name of the events, completing the goal.
+	Match string
+	// Filter is the filter the events completing the goal match, in its canonical form. Empty matches every event.
+	Filter string
+	// Value is the monetary value of a completion of the goal in the reporting currency of the hub, nil when the goal
+	// has none.
+	Value     *decimal.Decimal
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Condition returns the filter selecting the events completing the goal.
+func (g Goal) Condition() (filter.Filter, error) {
+	f, err := filter.Parse(g.Filter)
+	if err != nil {
+		return filter.Filter{}, err
+	}
+
+	switch g.Type {
+	case TypePageview:
+		return filter.Pageviews(g.Match, f)
+	case TypeEvent:
+		return filter.Events(g.Match, f)
+	default:
+		return filter.Filter{}, ErrInvalidType
+	}
+}
+
+// NewID returns a random goal ID.
+func NewID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return idPrefix + hex.EncodeToString(b), nil
+}
+
+// Store keeps the goals of the projects.
+type Store interface {
+	// List returns the goals of the project, ordered by ID.
+	List(ctx context.Context, projectID string) ([]Goal, error)
+	// Get returns the goal of the project, ErrNotFound when there is none with the ID.
+	Get(ctx context.Context, projectID, id string) (Goal, error)
+	// Create stores a new goal.
+	Create(ctx context.Context, g Goal) error
+	// Update replaces a stored goal, ErrNotFound when there is none with the ID.
+	Update(ctx context.Context, g Goal) error
+	// Delete removes the goal of the project, ErrNotFound when there is none with the ID.
+	Delete(ctx context.Context, projectID, id string) error
+}
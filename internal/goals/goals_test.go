@@ -0,0 +1,112 @@
+package goals_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ponrove/octobe"
+	"github.com/ponrove/octobe/driver/clickhouse"
+	"github.com/ponrove/octobe/driver/clickhouse/mock"
+	"github.com/ponrove/ponrove-backend/internal/goals"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCondition(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		goal goals.Goal
+		sql  string
+		args []any
+	}{
+		{
+			goal: goals.Goal{Type: goals.TypePageview, Match: "/thanks/*", Filter: `custom_properties.plan = "pro"`},
+			sql:  "(event_name = ? AND url_path LIKE ? AND custom_properties[?] = ?)",
+			args: []any{"page_view", "/thanks/%", "plan", "pro"},
+		},
+		{
+			goal: goals.Goal{Type: goals.TypeEvent, Match: "signup"},
+			sql:  "event_name = ?",
+			args: []any{"signup"},
+		},
+	}
+
+	for _, tc := range testCases {
+		f, err := tc.goal.Condition()
+		require.NoError(t, err)
+		sql, args := f.SQL()
+		assert.Equal(t, tc.sql, sql)
+		assert.Equal(t, tc.args, args)
+	}
+
+	_, err := goals.Goal{Type: "click", Match: "signup"}.Condition()
+	assert.ErrorIs(t, err, goals.ErrInvalidType)
+	_, err = goals.Goal{Type: goals.TypeEvent, Match: "signup", Filter: "password = x"}.Condition()
+	assert.Error(t, err)
+}
+
+func TestNewID(t *testing.T) {
+	t.Parallel()
+
+	id, err := goals.NewID()
+	require.NoError(t, err)
+	assert.Regexp(t, `^goal_[0-9a-f]{16}$`, id)
+}
+
+func TestMemoryStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := goals.NewMemoryStore(goals.Goal{ID: "goal_b", ProjectID: "project-1"}, goals.Goal{ID: "goal_c", ProjectID: "project-2"})
+
+	require.NoError(t, store.Create(ctx, goals.Goal{ID: "goal_a", ProjectID: "project-1"}))
+	list, err := store.List(ctx, "project-1")
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "goal_a", list[0].ID)
+	assert.Equal(t, "goal_b", list[1].ID)
+
+	// Goals belong to their project.
+	_, err = store.Get(ctx, "project-1", "goal_c")
+	assert.ErrorIs(t, err, goals.ErrNotFound)
+	assert.ErrorIs(t, store.Update(ctx, goals.Goal{ID: "goal_c", ProjectID: "project-1"}), goals.ErrNotFound)
+
+	require.NoError(t, store.Update(ctx, goals.Goal{ID: "goal_a", ProjectID: "project-1", Name: "Signup"}))
+	g, err := store.Get(ctx, "project-1", "goal_a")
+	require.NoError(t, err)
+	assert.Equal(t, "Signup", g.Name)
+
+	require.NoError(t, store.Delete(ctx, "project-1", "goal_a"))
+	assert.ErrorIs(t, store.Delete(ctx, "project-1", "goal_a"), goals.ErrNotFound)
+}
+
+func TestClickHouseStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	conn := mock.NewMock()
+	driver, err := octobe.New(clickhouse.OpenNativeWithConn(conn))
+	require.NoError(t, err)
+	store := goals.NewClickHouseStore(driver)
+	created := time.Date(2025, 6, 16, 9, 0, 0, 0, time.UTC)
+	value := decimal.RequireFromString("49.90")
+
+	// Deleting a goal inserts a tombstone replacing it.
+	conn.ExpectQueryRow("FROM goals FINAL").WillReturnRow(mock.NewMockRow("goal_1", "project-1", "Signup", "event", "signup", "", &value, created, created))
+	conn.ExpectExec("INSERT INTO goals")
+	require.NoError(t, store.Delete(ctx, "project-1", "goal_1"))
+
+	rows := mock.NewMockRows([]string{"goal_id", "project_id", "name", "type", "match", "filter", "value", "created_at", "updated_at"}).
+		AddRow("goal_2", "project-1", "Thanks", "pageview", "/thanks", "", (*decimal.Decimal)(nil), created, created)
+	conn.ExpectQuery("FROM goals FINAL WHERE project_id = ? AND is_deleted = 0").WillReturnRows(rows)
+	list, err := store.List(ctx, "project-1")
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, goals.TypePageview, list[0].Type)
+	assert.Nil(t, list[0].Value)
+
+	assert.NoError(t, conn.AllExpectationsMet())
+}
@@ -0,0 +1,215 @@
+package goals
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ponrove/octobe/driver/clickhouse"
+)
+
+// goalColumns are the columns of the goals table read into a Goal, in order.
+const goalColumns = `goal_id, project_id, name, type, match, filter, value, created_at, updated_at`
+
+// ClickHouseStore keeps the goals in the goals table, a ReplacingMergeTree holding the latest version of each goal.
+// Deleted goals are kept as tombstones until merged away.
+type ClickHouseStore struct {
+	driver clickhouse.Driver
+}
+
+// NewClickHouseStore returns a goal store backed by ClickHouse.
+func NewClickHouseStore(driver clickhouse.Driver) *ClickHouseStore {
+	return &ClickHouseStore{driver: driver}
+}
+
+// List returns the goals of the project, ordered by ID.
+func (s *ClickHouseStore) List(ctx context.Context, projectID string) ([]Goal, error) {
+	session, err := s.driver.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin ClickHouse session: %w", err)
+	}
+
+	var list []Goal
+	err = session.Builder()(`SELECT ` + goalColumns + ` FROM goals FINAL WHERE project_id = ? AND is_deleted = 0 ORDER BY goal_id`).
+		Arguments(projectID).
+		Query(func(rows clickhouse.Rows) error {
+			for rows.Next() {
+				var (
+					g    Goal
+					kind string
+				)
+				err := rows.Scan(&g.ID, &g.ProjectID, &g.Name, &kind, &g.Match, &g.Filter, &g.Value, &g.CreatedAt, &g.UpdatedAt)
+				if err != nil {
+					return err
+				}
+				g.Type = Type(kind)
+				list = append(list, g)
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query goals: %w", err)
+	}
+
+	return list, nil
+}
+
+// Get returns the goal of the project, ErrNotFound when there is none with the ID.
+func (s *ClickHouseStore) Get(ctx context.Context, projectID, id string) (Goal, error) {
+	session, err := s.driver.Begin(ctx)
+	if err != nil {
+		return Goal{}, fmt.Errorf("failed to begin ClickHouse session: %w", err)
+	}
+
+	var (
+		g    Goal
+		kind string
+	)
+	err = session.Builder()(`SELECT `+goalColumns+` FROM goals FINAL WHERE project_id = ? AND goal_id = ? AND is_deleted = 0`).
+		Arguments(projectID, id).
+		QueryRow(&g.ID, &g.ProjectID, &g.Name, &kind, &g.Match, &g.Filter, &g.Value, &g.CreatedAt, &g.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Goal{}, ErrNotFound
+	}
+	if err != nil {
+		return Goal{}, fmt.Errorf("failed to query goal: %w", err)
+	}
+
+	g.Type = Type(kind)
+	return g, nil
+}
+
+// Create stores a new goal, its random ID making conflicts unlikely enough not to check for them.
+func (s *ClickHouseStore) Create(ctx context.Context, g Goal) error {
+	return s.insert(ctx, g, false)
+}
+
+// Update replaces a stored goal, ErrNotFound when there is none with the ID.
+func (s *ClickHouseStore) Update(ctx context.Context, g Goal) error {
+	_, err := s.Get(ctx, g.ProjectID, g.ID)
+	if err != nil {
+		return err
+	}
+
+	return s.insert(ctx, g, false)
+}
+
+// Delete removes the goal of the project, ErrNotFound when there is none with the ID.
+func (s *ClickHouseStore) Delete(ctx context.Context, projectID, id string) error {
+	g, err := s.Get(ctx, projectID, id)
+	if err != nil {
+		return err
+	}
+
+	g.UpdatedAt = time.Now().UTC()
+	return s.insert(ctx, g, true)
+}
+
+// insert writes a new version of the goal, replacing the previous one once parts are merged.
+func (s *ClickHouseStore) insert(ctx context.Context, g Goal, deleted bool) error {
+	session, err := s.driver.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin ClickHouse session: %w", err)
+	}
+
+	var isDeleted uint8
+	if deleted {
+		isDeleted = 1
+	}
+
+	err = session.Builder()(`INSERT INTO goals (`+goalColumns+`, is_deleted) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`).
+		Arguments(g.ID, g.ProjectID, g.Name, string(g.Type), g.Match, g.Filter, g.Value, g.CreatedAt, g.UpdatedAt, isDeleted).
+		Exec()
+	if err != nil {
+		return fmt.Errorf("failed to insert goal: %w", err)
+	}
+
+	return nil
+}
+
+// MemoryStore keeps the goals in memory, for single instance deployments and tests.
+type MemoryStore struct {
+	mu    sync.Mutex
+	goals map[string]Goal
+}
+
+// NewMemoryStore returns an in-memory goal store holding the given goals.
+func NewMemoryStore(goals ...Goal) *MemoryStore {
+	s := &MemoryStore{goals: make(map[string]Goal, len(goals))}
+	for _, g := range goals {
+		s.goals[memoryKey(g.ProjectID, g.ID)] = g
+	}
+	return s
+}
+
+// memoryKey returns the key of a goal of a project in the map of a MemoryStore.
+func memoryKey(projectID, id string) string {
+	return projectID + "\x00" + id
+}
+
+// List returns the goals of the project, ordered by ID.
+func (s *MemoryStore) List(_ context.Context, projectID string) ([]Goal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var list []Goal
+	for _, g := range s.goals {
+		if g.ProjectID == projectID {
+			list = append(list, g)
+		}
+	}
+	slices.SortFunc(list, func(a, b Goal) int { return strings.Compare(a.ID, b.ID) })
+	return list, nil
+}
+
+// Get returns the goal of the project, ErrNotFound when there is none with the ID.
+func (s *MemoryStore) Get(_ context.Context, projectID, id string) (Goal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g, ok := s.goals[memoryKey(projectID, id)]
+	if !ok {
+		return Goal{}, ErrNotFound
+	}
+	return g, nil
+}
+
+// Create stores a new goal.
+func (s *MemoryStore) Create(_ context.Context, g Goal) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.goals[memoryKey(g.ProjectID, g.ID)] = g
+	return nil
+}
+
+// Update replaces a stored goal, ErrNotFound when there is none with the ID.
+func (s *MemoryStore) Update(_ context.Context, g Goal) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := memoryKey(g.ProjectID, g.ID)
+	if _, ok := s.goals[key]; !ok {
+		return ErrNotFound
+	}
+	s.goals[key] = g
+	return nil
+}
+
+// Delete removes the goal of the project, ErrNotFound when there is none with the ID.
+func (s *MemoryStore) Delete(_ context.Context, projectID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := memoryKey(projectID, id)
+	if _, ok := s.goals[key]; !ok {
+		return ErrNotFound
+	}
+	delete(s.goals, key)
+	return nil
+}
@@ -0,0 +1,210 @@
+package iprep
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ponrove/ponrove-backend/internal/filewatch"
+)
+
+var ErrInvalidList = errors.New("invalid IP list")
+
+// Category is the kind of traffic an IP range is known for, mirroring the flags of the raw_events table.
+type Category string
+
+const (
+	CategoryVPN   Category = "vpn"
+	CategoryProxy Category = "proxy"
+	CategoryTor   Category = "tor"
+)
+
+// Source tags IP addresses of a single category. Lists loaded from files are the built-in sources, other sources such
+// as external reputation services can be plugged in by implementing this interface.
+type Source interface {
+	// Category returns the category of the addresses known to the source.
+	Category() Category
+	// Lookup returns the provider of the address, e.g. the VPN or hosting company, and whether the address is known.
+	Lookup(addr netip.Addr) (string, bool)
+}
+
+// Result holds the reputation of an address. Providers are nil when unknown.
+type Result struct {
+	IsVPN         bool
+	VPNProvider   *string
+	IsProxy       bool
+	ProxyProvider *string
+	IsTorNode     bool
+}
+
+// Reputation combines sources into the reputation of addresses.
+type Reputation struct {
+	sources []Source
+}
+
+// New returns a reputation checking the given sources, in order. The first source knowing an address names its
+// provider, sources of other categories still set their flag.
+func New(sources ...Source) *Reputation {
+	return &Reputation{sources: sources}
+}
+
+// Lookup returns the reputation of the address, an empty result when the reputation is nil.
+func (r *Reputation) Lookup(addr netip.Addr) Result {
+	var res Result
+	if r == nil || !addr.IsValid() {
+		return res
+	}
+
+	for _, source := range r.sources {
+		provider, ok := source.Lookup(addr)
+		if !ok {
+			continue
+		}
+
+		switch source.Category() {
+		case CategoryVPN:
+			if !res.IsVPN {
+				res.IsVPN = true
+				res.VPNProvider = providerName(provider)
+			}
+		case CategoryProxy:
+			if !res.IsProxy {
+				res.IsProxy = true
+				res.ProxyProvider = providerName(provider)
+			}
+		case CategoryTor:
+			res.IsTorNode = true
+		}
+	}
+
+	return res
+}
+
+// List is a source backed by a local file of IP addresses and CIDR networks, one per line, optionally followed by the
+// name of the provider. Empty lines and lines starting with # are ignored, entries without a provider are attributed
+// to the name of the file, e.g. mullvad for mullvad.txt. Lists can be replaced while running.
+type List struct {
+	category Category
+	path     string
+	watcher  *filewatch.Watcher
+	entries  atomic.Pointer[trie]
+}
+
+// OpenList loads the list file at path, checking the file for changes every reloadInterval once watched.
+func OpenList(category Category, path string, reloadInterval time.Duration) (*List, error) {
+	l := &List{category: category, path: path, watcher: filewatch.New(path, reloadInterval)}
+	err := l.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// Category returns the category of the list.
+func (l *List) Category() Category {
+	return l.category
+}
+
+// Lookup returns the provider of the longest entry containing the address.
+func (l *List) Lookup(addr netip.Addr) (string, bool) {
+	return l.entries.Load().lookup(addr)
+}
+
+// Len returns the number of entries of the list.
+func (l *List) Len() int {
+	return l.entries.Load().size
+}
+
+// Reload reads the list file again, swapping the entries in once read. The previous entries are kept when the file
+// can't be read.
+func (l *List) Reload() error {
+	f, err := os.Open(l.path)
+	if err != nil {
+		return fmt.Errorf("failed to open IP list: %w", err)
+	}
+	defer f.Close()
+
+	provider := strings.TrimSuffix(filepath.Base(l.path), filepath.Ext(l.path))
+	entries, err := parseList(f, provider)
+	if err != nil {
+		return fmt.Errorf("%s: %w", l.path, err)
+	}
+
+	l.entries.Store(entries)
+	return nil
+}
+
+// Watch reloads the list whenever its file changes, until ctx is done.
+func (l *List) Watch(ctx context.Context) {
+	l.watcher.Run(ctx, func() {
+		if err := l.Reload(); err != nil {
+			slog.ErrorContext(ctx, "Keeping previous IP list", slog.String("category", string(l.category)), slog.Any("error", err))
+		}
+	})
+}
+
+// parseList reads list entries into a trie, attributing entries without a provider to defaultProvider.
+func parseList(r io.Reader, defaultProvider string) (*trie, error) {
+	entries := newTrie()
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		entry, provider, _ := strings.Cut(line, " ")
+		provider = strings.TrimSpace(provider)
+		if provider == "" {
+			provider = defaultProvider
+		}
+
+		prefix, err := parsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidList, lineNumber, err)
+		}
+		entries.insert(prefix, provider)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidList, err)
+	}
+
+	return entries, nil
+}
+
+// parsePrefix parses a CIDR network, or a single address as a network of its own.
+func parsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return prefix.Masked(), nil
+	}
+
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// providerName returns the provider as stored in raw_events, nil when unknown.
+func providerName(provider string) *string {
+	if provider == "" {
+		return nil
+	}
+	return &provider
+}
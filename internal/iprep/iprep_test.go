@@ -0,0 +1,143 @@
+package iprep_test
+
+import (
+	"context"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ponrove/ponrove-backend/internal/iprep"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeList(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path+".tmp", []byte(content), 0o600))
+	require.NoError(t, os.Rename(path+".tmp", path))
+}
+
+func TestListLookup(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "datacenters.txt")
+	writeList(t, path, `# Hosting ranges
+203.0.113.0/24 Example Hosting
+203.0.113.128/25 Example Cloud
+
+198.51.100.7
+2001:db8::/32 Example Hosting
+::ffff:192.0.2.1
+`)
+
+	list, err := iprep.OpenList(iprep.CategoryProxy, path, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, iprep.CategoryProxy, list.Category())
+	assert.Equal(t, 5, list.Len())
+
+	testCases := []struct {
+		addr     string
+		provider string
+	}{
+		{addr: "203.0.113.1", provider: "Example Hosting"},
+		{addr: "203.0.113.200", provider: "Example Cloud"},
+		{addr: "198.51.100.7", provider: "datacenters"},
+		{addr: "198.51.100.8"},
+		{addr: "2001:db8:1::1", provider: "Example Hosting"},
+		{addr: "2001:db9::1"},
+		{addr: "192.0.2.1", provider: "datacenters"},
+		{addr: "::ffff:203.0.113.1", provider: "Example Hosting"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.addr, func(t *testing.T) {
+			t.Parallel()
+			provider, ok := list.Lookup(netip.MustParseAddr(tc.addr))
+			assert.Equal(t, tc.provider != "", ok)
+			assert.Equal(t, tc.provider, provider)
+		})
+	}
+}
+
+func TestOpenListInvalid(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "vpn.txt")
+	writeList(t, path, "10.0.0.0/8\nnot-an-address\n")
+
+	_, err := iprep.OpenList(iprep.CategoryVPN, path, time.Minute)
+	assert.ErrorIs(t, err, iprep.ErrInvalidList)
+
+	_, err = iprep.OpenList(iprep.CategoryVPN, filepath.Join(t.TempDir(), "missing.txt"), time.Minute)
+	assert.Error(t, err)
+}
+
+func TestListWatch(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "tor.txt")
+	writeList(t, path, "192.0.2.1\n")
+
+	list, err := iprep.OpenList(iprep.CategoryTor, path, time.Millisecond)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go list.Watch(ctx)
+
+	// An invalid list is rejected, the previous entries are kept.
+	writeList(t, path, "invalid\n")
+	time.Sleep(20 * time.Millisecond)
+	_, ok := list.Lookup(netip.MustParseAddr("192.0.2.1"))
+	assert.True(t, ok)
+
+	writeList(t, path, "192.0.2.2\n# trailing comment\n")
+	assert.Eventually(t, func() bool {
+		_, ok := list.Lookup(netip.MustParseAddr("192.0.2.2"))
+		return ok
+	}, time.Second, time.Millisecond)
+	_, ok = list.Lookup(netip.MustParseAddr("192.0.2.1"))
+	assert.False(t, ok)
+}
+
+type staticSource struct {
+	category iprep.Category
+	prefix   netip.Prefix
+	provider string
+}
+
+func (s staticSource) Category() iprep.Category { return s.category }
+
+func (s staticSource) Lookup(addr netip.Addr) (string, bool) {
+	return s.provider, s.prefix.Contains(addr)
+}
+
+func TestReputationLookup(t *testing.T) {
+	t.Parallel()
+
+	rep := iprep.New(
+		staticSource{category: iprep.CategoryVPN, prefix: netip.MustParsePrefix("192.0.2.0/24"), provider: "First VPN"},
+		staticSource{category: iprep.CategoryVPN, prefix: netip.MustParsePrefix("192.0.0.0/16"), provider: "Second VPN"},
+		staticSource{category: iprep.CategoryProxy, prefix: netip.MustParsePrefix("192.0.2.0/24")},
+		staticSource{category: iprep.CategoryTor, prefix: netip.MustParsePrefix("192.0.2.1/32")},
+	)
+
+	res := rep.Lookup(netip.MustParseAddr("192.0.2.1"))
+	assert.True(t, res.IsVPN)
+	require.NotNil(t, res.VPNProvider)
+	assert.Equal(t, "First VPN", *res.VPNProvider, "the first source knowing the address names the provider")
+	assert.True(t, res.IsProxy)
+	assert.Nil(t, res.ProxyProvider)
+	assert.True(t, res.IsTorNode)
+
+	res = rep.Lookup(netip.MustParseAddr("192.0.3.1"))
+	assert.True(t, res.IsVPN)
+	assert.Equal(t, "Second VPN", *res.VPNProvider)
+	assert.False(t, res.IsProxy)
+	assert.False(t, res.IsTorNode)
+
+	assert.Equal(t, iprep.Result{}, rep.Lookup(netip.MustParseAddr("198.51.100.1")))
+	assert.Equal(t, iprep.Result{}, (*iprep.Reputation)(nil).Lookup(netip.MustParseAddr("192.0.2.1")))
+}
@@ -0,0 +1,77 @@
+package iprep
+
+import (
+	"net/netip"
+)
+
+// trie is a binary prefix trie over IP addresses, IPv4 and IPv6 in separate trees. Lookups walk at most one node per
+// bit of the address, whatever the number of prefixes, and return the value of the longest matching prefix.
+type trie struct {
+	v4   *node
+	v6   *node
+	size int
+}
+
+type node struct {
+	children [2]*node
+	value    *string
+}
+
+func newTrie() *trie {
+	return &trie{v4: &node{}, v6: &node{}}
+}
+
+// insert adds the prefix with its value, replacing the value of an identical prefix.
+func (t *trie) insert(prefix netip.Prefix, value string) {
+	addr := prefix.Addr().Unmap()
+	bits := prefix.Bits()
+	if prefix.Addr().Is4In6() {
+		bits -= 96
+	}
+
+	n, bytes := t.root(addr)
+	for i := range max(bits, 0) {
+		bit := (bytes[i/8] >> (7 - i%8)) & 1
+		if n.children[bit] == nil {
+			n.children[bit] = &node{}
+		}
+		n = n.children[bit]
+	}
+
+	if n.value == nil {
+		t.size++
+	}
+	n.value = &value
+}
+
+// lookup returns the value of the longest prefix containing the address.
+func (t *trie) lookup(addr netip.Addr) (string, bool) {
+	addr = addr.Unmap()
+	n, bytes := t.root(addr)
+
+	var match *string
+	for i := 0; n != nil; i++ {
+		if n.value != nil {
+			match = n.value
+		}
+		if i == len(bytes)*8 {
+			break
+		}
+		n = n.children[(bytes[i/8]>>(7-i%8))&1]
+	}
+
+	if match == nil {
+		return "", false
+	}
+	return *match, true
+}
+
+// root returns the tree and the bytes of the address.
+func (t *trie) root(addr netip.Addr) (*node, []byte) {
+	if addr.Is4() {
+		b := addr.As4()
+		return t.v4, b[:]
+	}
+	b := addr.As16()
+	return t.v6, b[:]
+}
@@ -0,0 +1,84 @@
+package projects
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("project not found")
+	ErrExists   = errors.New("project already exists")
+)
+
+// ingestionKeyPrefix marks ingestion keys, so they are recognisable when leaked or pasted in the wrong place.
+const ingestionKeyPrefix = "pk_"
+
+// Project is a site or application reporting events. Its ingestion key is public, it is embedded in the pages of the
+// project, while its allowed hosts restrict the pages the key can report from.
+type Project struct {
+	ID           string
+	Name         string
+	IngestionKey string
+	// AllowedHosts are the hostnames the project reports from. A leading "*." matches every subdomain, and an empty
+	// list matches every host, e.g. for projects reporting from servers only.
+	AllowedHosts []string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// AllowsHost reports whether the host, with or without a port, is one of the allowed hosts of the project.
+func (p Project) AllowsHost(host string) bool {
+	if len(p.AllowedHosts) == 0 {
+		return true
+	}
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "" {
+		return false
+	}
+
+	for _, allowed := range p.AllowedHosts {
+		allowed = strings.ToLower(allowed)
+		if suffix, ok := strings.CutPrefix(allowed, "*"); ok {
+			if strings.HasSuffix(host, suffix) && len(host) > len(suffix) {
+				return true
+			}
+			continue
+		}
+		if host == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// NewIngestionKey returns a random ingestion key.
+func NewIngestionKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return ingestionKeyPrefix + hex.EncodeToString(b), nil
+}
+
+// Store keeps the projects.
+type Store interface {
+	// List returns every project, ordered by ID.
+	List(ctx context.Context) ([]Project, error)
+	// Get returns the project, ErrNotFound when there is none with the ID.
+	Get(ctx context.Context, id string) (Project, error)
+	// Create stores a new project, ErrExists when there already is one with the ID.
+	Create(ctx context.Context, p Project) error
+	// Update replaces a stored project, ErrNotFound when there is none with the ID.
+	Update(ctx context.Context, p Project) error
+	// Delete removes the project, ErrNotFound when there is none with the ID.
+	Delete(ctx context.Context, id string) error
+}
@@ -0,0 +1,177 @@
+package projects_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ponrove/octobe"
+	"github.com/ponrove/octobe/driver/clickhouse"
+	"github.com/ponrove/octobe/driver/clickhouse/mock"
+	"github.com/ponrove/ponrove-backend/internal/projects"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingStore is a project store that can't be reached.
+type failingStore struct {
+	projects.Store
+}
+
+func (failingStore) List(context.Context) ([]projects.Project, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestAllowsHost(t *testing.T) {
+	t.Parallel()
+
+	p := projects.Project{ID: "project-1", AllowedHosts: []string{"example.com", "*.example.org"}}
+	testCases := []struct {
+		host    string
+		allowed bool
+	}{
+		{host: "example.com", allowed: true},
+		{host: "EXAMPLE.com.", allowed: true},
+		{host: "example.com:8443", allowed: true},
+		{host: "www.example.com", allowed: false},
+		{host: "www.example.org", allowed: true},
+		{host: "a.b.example.org", allowed: true},
+		{host: "example.org", allowed: false},
+		{host: "evilexample.org", allowed: false},
+		{host: "example.com.evil.net", allowed: false},
+		{host: "", allowed: false},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.allowed, p.AllowsHost(tc.host), tc.host)
+	}
+
+	// Without allowed hosts, the project reports from anywhere.
+	assert.True(t, projects.Project{ID: "project-2"}.AllowsHost("anything.net"))
+}
+
+func TestNewIngestionKey(t *testing.T) {
+	t.Parallel()
+
+	key, err := projects.NewIngestionKey()
+	require.NoError(t, err)
+	assert.Regexp(t, `^pk_[0-9a-f]{32}$`, key)
+
+	other, err := projects.NewIngestionKey()
+	require.NoError(t, err)
+	assert.NotEqual(t, key, other)
+}
+
+func TestMemoryStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := projects.NewMemoryStore(projects.Project{ID: "project-b", IngestionKey: "pk_b"})
+
+	require.NoError(t, store.Create(ctx, projects.Project{ID: "project-a", IngestionKey: "pk_a"}))
+	assert.ErrorIs(t, store.Create(ctx, projects.Project{ID: "project-a"}), projects.ErrExists)
+
+	list, err := store.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "project-a", list[0].ID)
+	assert.Equal(t, "project-b", list[1].ID)
+
+	require.NoError(t, store.Update(ctx, projects.Project{ID: "project-a", Name: "Site A", IngestionKey: "pk_a"}))
+	p, err := store.Get(ctx, "project-a")
+	require.NoError(t, err)
+	assert.Equal(t, "Site A", p.Name)
+	assert.ErrorIs(t, store.Update(ctx, projects.Project{ID: "unknown"}), projects.ErrNotFound)
+
+	require.NoError(t, store.Delete(ctx, "project-a"))
+	_, err = store.Get(ctx, "project-a")
+	assert.ErrorIs(t, err, projects.ErrNotFound)
+	assert.ErrorIs(t, store.Delete(ctx, "project-a"), projects.ErrNotFound)
+}
+
+func TestClickHouseStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	conn := mock.NewMock()
+	driver, err := octobe.New(clickhouse.OpenNativeWithConn(conn))
+	require.NoError(t, err)
+	store := projects.NewClickHouseStore(driver)
+	created := time.Date(2025, 6, 16, 9, 0, 0, 0, time.UTC)
+
+	// Creating a project that exists is a conflict, nothing is inserted.
+	conn.ExpectQueryRow("FROM projects FINAL").WillReturnRow(mock.NewMockRow("project-1", "Site", "pk_1", []string{"example.com"}, created, created))
+	assert.ErrorIs(t, store.Create(ctx, projects.Project{ID: "project-1"}), projects.ErrExists)
+
+	// Deleting a project inserts a tombstone replacing it.
+	conn.ExpectQueryRow("FROM projects FINAL").WillReturnRow(mock.NewMockRow("project-1", "Site", "pk_1", []string{"example.com"}, created, created))
+	conn.ExpectExec("INSERT INTO projects")
+	require.NoError(t, store.Delete(ctx, "project-1"))
+
+	rows := mock.NewMockRows([]string{"project_id", "name", "ingestion_key", "allowed_hosts", "created_at", "updated_at"}).
+		AddRow("project-2", "Other site", "pk_2", []string{}, created, created)
+	conn.ExpectQuery("FROM projects FINAL WHERE is_deleted = 0").WillReturnRows(rows)
+	list, err := store.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "pk_2", list[0].IngestionKey)
+
+	assert.NoError(t, conn.AllExpectationsMet())
+}
+
+func TestRegistry(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := projects.NewMemoryStore(projects.Project{ID: "project-1", IngestionKey: "pk_1"})
+	registry := projects.NewRegistry(store)
+
+	// Projects are only known once loaded.
+	_, ok := registry.ByKey("pk_1")
+	assert.False(t, ok)
+
+	require.NoError(t, registry.Refresh(ctx))
+	p, ok := registry.ByKey("pk_1")
+	require.True(t, ok)
+	assert.Equal(t, "project-1", p.ID)
+
+	// A rotated key replaces the previous one on refresh.
+	p.IngestionKey = "pk_rotated"
+	require.NoError(t, store.Update(ctx, p))
+	require.NoError(t, registry.Refresh(ctx))
+	_, ok = registry.ByKey("pk_1")
+	assert.False(t, ok)
+	_, ok = registry.ByKey("pk_rotated")
+	assert.True(t, ok)
+	assert.Equal(t, 1, registry.Len())
+}
+
+func TestRegistryKeepsProjectsOnError(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := &switchingStore{Store: projects.NewMemoryStore(projects.Project{ID: "project-1", IngestionKey: "pk_1"})}
+	registry := projects.NewRegistry(store)
+	require.NoError(t, registry.Refresh(ctx))
+
+	store.Store = failingStore{}
+	assert.Error(t, registry.Refresh(ctx))
+	_, ok := registry.ByKey("pk_1")
+	assert.True(t, ok)
+}
+
+// switchingStore delegates to a store that can be swapped between refreshes.
+type switchingStore struct {
+	projects.Store
+}
+
+func TestRegistryWatchDefaultInterval(t *testing.T) {
+	t.Parallel()
+
+	// A non-positive interval keeps the default rather than making the ticker panic.
+	registry := projects.NewRegistry(projects.NewMemoryStore(), projects.WithRefreshInterval(0))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.NotPanics(t, func() { registry.Watch(ctx) })
+}
@@ -0,0 +1,93 @@
+package projects
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// defaultRefreshInterval is how often the registry reloads the projects from the store.
+const defaultRefreshInterval = 30 * time.Second
+
+// snapshot indexes the projects loaded from the store by their ingestion key.
+type snapshot struct {
+	byKey map[string]Project
+}
+
+// Option is a function that modifies the registry configuration.
+type Option func(*Registry)
+
+// WithRefreshInterval sets how often the registry reloads the projects from the store.
+func WithRefreshInterval(interval time.Duration) Option {
+	return func(r *Registry) {
+		if interval > 0 {
+			r.refreshInterval = interval
+		}
+	}
+}
+
+// Registry caches the projects of a store in memory, so ingestion looks up projects without a query per event. Changes
+// to the projects, such as a new project or a rotated key, are picked up within the refresh interval.
+type Registry struct {
+	store           Store
+	refreshInterval time.Duration
+	projects        atomic.Pointer[snapshot]
+}
+
+// NewRegistry returns an empty registry of the projects of the store, filled by Refresh.
+func NewRegistry(store Store, opts ...Option) *Registry {
+	r := &Registry{
+		store:           store,
+		refreshInterval: defaultRefreshInterval,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	r.projects.Store(&snapshot{byKey: map[string]Project{}})
+	return r
+}
+
+// ByKey returns the project with the ingestion key.
+func (r *Registry) ByKey(key string) (Project, bool) {
+	p, ok := r.projects.Load().byKey[key]
+	return p, ok
+}
+
+// Len returns the number of cached projects.
+func (r *Registry) Len() int {
+	return len(r.projects.Load().byKey)
+}
+
+// Refresh reloads the projects from the store, swapping them in once loaded. The previous projects are kept when the
+// store fails.
+func (r *Registry) Refresh(ctx context.Context) error {
+	list, err := r.store.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	s := &snapshot{byKey: make(map[string]Project, len(list))}
+	for _, p := range list {
+		s.byKey[p.IngestionKey] = p
+	}
+	r.projects.Store(s)
+	return nil
+}
+
+// Watch refreshes the projects every refresh interval, until ctx is done.
+func (r *Registry) Watch(ctx context.Context) {
+	ticker := time.NewTicker(r.refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Refresh(ctx); err != nil {
+				slog.ErrorContext(ctx, "Keeping previous projects", slog.Any("error", err))
+			}
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package projects
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ponrove/octobe/driver/clickhouse"
+)
+
+// projectColumns are the columns of the projects table read into a Project, in order.
+const projectColumns = `project_id, name, ingestion_key, allowed_hosts, created_at, updated_at`
+
+// ClickHouseStore keeps the projects in the projects table, a ReplacingMergeTree holding the latest version of each
+// project. Deleted projects are kept as tombstones until merged away.
+type ClickHouseStore struct {
+	driver clickhouse.Driver
+}
+
+// NewClickHouseStore returns a project store backed by ClickHouse.
+func NewClickHouseStore(driver clickhouse.Driver) *ClickHouseStore {
+	return &ClickHouseStore{driver: driver}
+}
+
+// List returns every project, ordered by ID.
+func (s *ClickHouseStore) List(ctx context.Context) ([]Project, error) {
+	session, err := s.driver.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin ClickHouse session: %w", err)
+	}
+
+	var list []Project
+	err = session.Builder()(`SELECT ` + projectColumns + ` FROM projects FINAL WHERE is_deleted = 0 ORDER BY project_id`).Query(func(rows clickhouse.Rows) error {
+		for rows.Next() {
+			var p Project
+			err := rows.Scan(&p.ID, &p.Name, &p.IngestionKey, &p.AllowedHosts, &p.CreatedAt, &p.UpdatedAt)
+			if err != nil {
+				return err
+			}
+			list = append(list, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query projects: %w", err)
+	}
+
+	return list, nil
+}
+
+// Get returns the project, ErrNotFound when there is none with the ID.
+func (s *ClickHouseStore) Get(ctx context.Context, id string) (Project, error) {
+	session, err := s.driver.Begin(ctx)
+	if err != nil {
+		return Project{}, fmt.Errorf("failed to begin ClickHouse session: %w", err)
+	}
+
+	var p Project
+	err = session.Builder()(`SELECT `+projectColumns+` FROM projects FINAL WHERE project_id = ? AND is_deleted = 0`).
+		Arguments(id).
+		QueryRow(&p.ID, &p.Name, &p.IngestionKey, &p.AllowedHosts, &p.CreatedAt, &p.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Project{}, ErrNotFound
+	}
+	if err != nil {
+		return Project{}, fmt.Errorf("failed to query project: %w", err)
+	}
+
+	return p, nil
+}
+
+// Create stores a new project, ErrExists when there already is one with the ID. Two replicas creating the same project
+// concurrently both succeed, and the latest write wins.
+func (s *ClickHouseStore) Create(ctx context.Context, p Project) error {
+	_, err := s.Get(ctx, p.ID)
+	if err == nil {
+		return ErrExists
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	return s.insert(ctx, p, false)
+}
+
+// Update replaces a stored project, ErrNotFound when there is none with the ID.
+func (s *ClickHouseStore) Update(ctx context.Context, p Project) error {
+	_, err := s.Get(ctx, p.ID)
+	if err != nil {
+		return err
+	}
+
+	return s.insert(ctx, p, false)
+}
+
+// Delete removes the project, ErrNotFound when there is none with the ID.
+func (s *ClickHouseStore) Delete(ctx context.Context, id string) error {
+	p, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	p.UpdatedAt = time.Now().UTC()
+	return s.insert(ctx, p, true)
+}
+
+// insert writes a new version of the project, replacing the previous one once parts are merged.
+func (s *ClickHouseStore) insert(ctx context.Context, p Project, deleted bool) error {
+	session, err := s.driver.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin ClickHouse session: %w", err)
+	}
+
+	var isDeleted uint8
+	if deleted {
+		isDeleted = 1
+	}
+
+	err = session.Builder()(`INSERT INTO projects (`+projectColumns+`, is_deleted) VALUES (?, ?, ?, ?, ?, ?, ?)`).
+		Arguments(p.ID, p.Name, p.IngestionKey, p.AllowedHosts, p.CreatedAt, p.UpdatedAt, isDeleted).
+		Exec()
+	if err != nil {
+		return fmt.Errorf("failed to insert project: %w", err)
+	}
+
+	return nil
+}
+
+// MemoryStore keeps the projects in memory, for single instance deployments and tests.
+type MemoryStore struct {
+	mu       sync.Mutex
+	projects map[string]Project
+}
+
+// NewMemoryStore returns an in-memory project store holding the given projects.
+func NewMemoryStore(projects ...Project) *MemoryStore {
+	s := &MemoryStore{projects: make(map[string]Project, len(projects))}
+	for _, p := range projects {
+		s.projects[p.ID] = p
+	}
+	return s
+}
+
+// List returns every project, ordered by ID.
+func (s *MemoryStore) List(_ context.Context) ([]Project, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]Project, 0, len(s.projects))
+	for _, p := range s.projects {
+		list = append(list, p)
+	}
+	slices.SortFunc(list, func(a, b Project) int { return strings.Compare(a.ID, b.ID) })
+	return list, nil
+}
+
+// Get returns the project, ErrNotFound when there is none with the ID.
+func (s *MemoryStore) Get(_ context.Context, id string) (Project, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.projects[id]
+	if !ok {
+		return Project{}, ErrNotFound
+	}
+	return p, nil
+}
+
+// Create stores a new project, ErrExists when there already is one with the ID.
+func (s *MemoryStore) Create(_ context.Context, p Project) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.projects[p.ID]; ok {
+		return ErrExists
+	}
+	s.projects[p.ID] = p
+	return nil
+}
+
+// Update replaces a stored project, ErrNotFound when there is none with the ID.
+func (s *MemoryStore) Update(_ context.Context, p Project) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.projects[p.ID]; !ok {
+		return ErrNotFound
+	}
+	s.projects[p.ID] = p
+	return nil
+}
+
+// Delete removes the project, ErrNotFound when there is none with the ID.
+func (s *MemoryStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.projects[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.projects, id)
+	return nil
+}
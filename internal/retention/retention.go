@@ -0,0 +1,96 @@
+package retention
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ponrove/octobe/driver/clickhouse"
+)
+
+// DefaultDays is the retention of projects without settings, the default of the retention_days column of raw_events.
+const DefaultDays = 365
+
+// Retention reports how long the events of a project are kept.
+type Retention struct {
+	ProjectID string
+	// ConfiguredDays is the retention set for the project, nil when the project uses the default.
+	ConfiguredDays *uint32
+	// EffectiveDays is the retention applied to events ingested now, as currently loaded by the retention dictionary.
+	EffectiveDays uint32
+	// StoredEvents is the number of events of the project currently stored.
+	StoredEvents uint64
+	// OldestEvent is the timestamp of the oldest stored event, and OldestEventExpiresAt when its TTL removes it. Events
+	// keep the retention they were ingested with. Both are nil when the project has no events.
+	OldestEvent          *time.Time
+	OldestEventExpiresAt *time.Time
+}
+
+// Get returns the retention of the project.
+func Get(ctx context.Context, driver clickhouse.Driver, projectID string) (Retention, error) {
+	session, err := driver.Begin(ctx)
+	if err != nil {
+		return Retention{}, fmt.Errorf("failed to begin ClickHouse session: %w", err)
+	}
+
+	r := Retention{ProjectID: projectID}
+
+	var configured uint32
+	err = session.Builder()(`SELECT retention_days FROM project_settings FINAL WHERE project_id = ?`).Arguments(projectID).QueryRow(&configured)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return Retention{}, fmt.Errorf("failed to query project settings: %w", err)
+	default:
+		r.ConfiguredDays = &configured
+	}
+
+	err = session.Builder()(`SELECT get_event_ttl(?, ?)`).Arguments(projectID, uint32(DefaultDays)).QueryRow(&r.EffectiveDays)
+	if err != nil {
+		return Retention{}, fmt.Errorf("failed to query effective retention: %w", err)
+	}
+
+	var (
+		oldest     time.Time
+		oldestDays uint32
+	)
+	err = session.Builder()(`
+		SELECT count(), min(event_timestamp), argMin(retention_days, event_timestamp)
+		FROM raw_events
+		WHERE project_id = ?`,
+	).Arguments(projectID).QueryRow(&r.StoredEvents, &oldest, &oldestDays)
+	if err != nil {
+		return Retention{}, fmt.Errorf("failed to query oldest event: %w", err)
+	}
+
+	if r.StoredEvents > 0 {
+		expires := oldest.AddDate(0, 0, int(oldestDays))
+		r.OldestEvent = &oldest
+		r.OldestEventExpiresAt = &expires
+	}
+
+	return r, nil
+}
+
+// Set changes the retention of the project, and reloads the retention dictionary so events ingested from now on are
+// kept for the new number of days. Events already stored keep the retention they were ingested with.
+func Set(ctx context.Context, driver clickhouse.Driver, projectID string, days uint32) error {
+	session, err := driver.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin ClickHouse session: %w", err)
+	}
+
+	err = session.Builder()(`INSERT INTO project_settings (project_id, retention_days) VALUES (?, ?)`).Arguments(projectID, days).Exec()
+	if err != nil {
+		return fmt.Errorf("failed to insert project settings: %w", err)
+	}
+
+	err = session.Builder()(`SYSTEM RELOAD DICTIONARY project_retention_dictionary`).Exec()
+	if err != nil {
+		return fmt.Errorf("failed to reload retention dictionary: %w", err)
+	}
+
+	return nil
+}
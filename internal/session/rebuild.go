@@ -0,0 +1,165 @@
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ponrove/octobe/driver/clickhouse"
+)
+
+// Range selects the events whose sessions are rebuilt, the project is optional.
+type Range struct {
+	ProjectID string
+	From      time.Time
+	To        time.Time
+}
+
+// Days returns the range widened to whole UTC days. Visitor fingerprints rotate with the day, so sessions never span
+// midnight, and rebuilding whole days never splits a session at the edges of the range.
+func (r Range) Days() Range {
+	from := r.From.UTC().Truncate(24 * time.Hour)
+	to := r.To.UTC().Truncate(24 * time.Hour)
+	if to.Before(r.To) {
+		to = to.Add(24 * time.Hour)
+	}
+	return Range{ProjectID: r.ProjectID, From: from, To: to}
+}
+
+// rebuildStatement computes the sessions of the events in the range into the join table. An event starts a session
+// when it is the first of the visitor, or follows the previous one by more than the timeout, and its session ID is
+// derived from the start of its session the same way ID does.
+const rebuildStatement = `
+	INSERT INTO %[1]s (event_id, session_id)
+	SELECT
+		event_id,
+		substring(lower(hex(SHA256(concat(project_id, '\0', visitor_fingerprint, '\0', toString(toUnixTimestamp64Milli(session_start)))))), 1, 32)
+	FROM
+	(
+		SELECT
+			event_id,
+			project_id,
+			visitor_fingerprint,
+			maxIf(event_timestamp, starts_session) OVER (
+				PARTITION BY project_id, visitor_fingerprint
+				ORDER BY event_timestamp, event_id
+				ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW
+			) AS session_start
+		FROM
+		(
+			SELECT
+				event_id,
+				project_id,
+				visitor_fingerprint,
+				event_timestamp,
+				row_number() OVER w = 1 OR dateDiff('millisecond', lagInFrame(event_timestamp) OVER w, event_timestamp) > ? AS starts_session
+			FROM raw_events
+			WHERE %[2]s
+			WINDOW w AS (
+				PARTITION BY project_id, visitor_fingerprint
+				ORDER BY event_timestamp, event_id
+				ROWS BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING
+			)
+		)
+	)`
+
+// directoryStatement recomputes the visitor directory entries of the selected visitors, like the visitor_directory_mv
+// materialized view does for inserted events.
+const directoryStatement = `
+	INSERT INTO visitor_directory_state
+	SELECT
+		project_id,
+		visitor_fingerprint,
+		min(toDateTime(event_timestamp, 'UTC')),
+		max(toDateTime(event_timestamp, 'UTC')),
+		uniqExactStateIf(session_id, session_id != ''),
+		count(),
+		argMinState(toString(referrer_host), event_timestamp),
+		argMinState(ifNull(toString(utm_campaign), ''), event_timestamp),
+		argMaxState(toString(country_code), event_timestamp),
+		argMaxState(toString(device_type), event_timestamp),
+		max(retention_days)
+	FROM raw_events
+	WHERE %s AND visitor_fingerprint != '' AND is_bot = 0
+	GROUP BY project_id, visitor_fingerprint`
+
+// Rebuild recomputes the session IDs of past events, e.g. after changing the timeout, or to merge sessions split
+// across ingestion replicas. The range is widened to whole UTC days. Sessions are computed into a temporary join table
+// first, then written back with a single mutation of raw_events, waiting for it to complete, and the visitor directory
+// entries of the range are recomputed. Events ingested into the range while rebuilding keep the session ID assigned at
+// ingestion, and may be counted twice by the directory, so ranges are best rebuilt once the day is over.
+func Rebuild(ctx context.Context, driver clickhouse.Driver, r Range, timeout time.Duration) (err error) {
+	r = r.Days()
+	if !r.From.Before(r.To) {
+		return errors.New("session rebuild range is empty")
+	}
+
+	dbSession, err := driver.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin ClickHouse session: %w", err)
+	}
+	builder := dbSession.Builder()
+
+	suffix := make([]byte, 8)
+	_, _ = rand.Read(suffix)
+	table := "session_rebuild_" + hex.EncodeToString(suffix)
+
+	where := "event_timestamp >= ? AND event_timestamp < ? AND visitor_fingerprint != ''"
+	args := []any{r.From, r.To}
+	if r.ProjectID != "" {
+		where += " AND project_id = ?"
+		args = append(args, r.ProjectID)
+	}
+
+	err = builder(fmt.Sprintf("CREATE TABLE %s (event_id UUID, session_id String) ENGINE = Join(ANY, LEFT, event_id)", table)).Exec()
+	if err != nil {
+		return fmt.Errorf("failed to create session rebuild table: %w", err)
+	}
+	defer func() {
+		// The table is dropped even when the rebuild was cancelled.
+		dropErr := dropTable(context.WithoutCancel(ctx), driver, table)
+		if dropErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to drop session rebuild table: %w", dropErr))
+		}
+	}()
+
+	err = builder(fmt.Sprintf(rebuildStatement, table, where)).Arguments(append([]any{timeout.Milliseconds()}, args...)...).Exec()
+	if err != nil {
+		return fmt.Errorf("failed to compute sessions: %w", err)
+	}
+
+	err = builder(fmt.Sprintf(
+		"ALTER TABLE raw_events UPDATE session_id = ifNull(joinGetOrNull('%s', 'session_id', event_id), session_id) WHERE %s SETTINGS mutations_sync = 2",
+		table, where,
+	)).Arguments(args...).Exec()
+	if err != nil {
+		return fmt.Errorf("failed to update sessions: %w", err)
+	}
+
+	// Mutations don't reach materialized views, so the directory entries of the visitors in the range are recomputed.
+	// Fingerprints rotate daily, all events of these visitors are within the range.
+	visitors := fmt.Sprintf("(project_id, visitor_fingerprint) IN (SELECT DISTINCT project_id, visitor_fingerprint FROM raw_events WHERE %s)", where)
+	err = builder(fmt.Sprintf("ALTER TABLE visitor_directory_state DELETE WHERE %s SETTINGS mutations_sync = 2", visitors)).Arguments(args...).Exec()
+	if err != nil {
+		return fmt.Errorf("failed to clear visitor directory: %w", err)
+	}
+
+	err = builder(fmt.Sprintf(directoryStatement, where+" AND "+visitors)).Arguments(append(args, args...)...).Exec()
+	if err != nil {
+		return fmt.Errorf("failed to recompute visitor directory: %w", err)
+	}
+
+	return nil
+}
+
+// dropTable drops the temporary table of a rebuild.
+func dropTable(ctx context.Context, driver clickhouse.Driver, table string) error {
+	dbSession, err := driver.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	return dbSession.Builder()(fmt.Sprintf("DROP TABLE IF EXISTS %s", table)).Exec()
+}
@@ -0,0 +1,270 @@
+package session
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ponrove/configura"
+	"github.com/ponrove/octobe/driver/clickhouse"
+)
+
+const (
+	// Inactivity timeout after which the next event of a visitor starts a new session. Shared by ingestion, assigning
+	// sessions as events arrive, and the hub, rebuilding sessions of past events, so both agree on session boundaries.
+	SESSION_TIMEOUT_MS configura.Variable[int64] = "SESSION_TIMEOUT_MS"
+)
+
+// DefaultTimeout is the inactivity timeout used unless configured otherwise.
+const DefaultTimeout = 30 * time.Minute
+
+// Unless configured otherwise, looking up the session of a visitor in the history may take defaultLookupTimeout, and
+// lookups are skipped for defaultLookupBackoff after one failed, so an unavailable history does not slow down every
+// event of a new visitor.
+const (
+	defaultLookupTimeout = 500 * time.Millisecond
+	defaultLookupBackoff = 30 * time.Second
+)
+
+// idLength is the length of a session ID, in hexadecimal characters.
+const idLength = 32
+
+// ID returns the ID of the session of a visitor starting at start. Session IDs are derived from the session rather than
+// generated, so replicas and the rebuild job assign the same ID to the same session. Rebuild computes the same hash in
+// SQL.
+func ID(projectID, visitorFingerprint string, start time.Time) string {
+	sum := sha256.Sum256([]byte(projectID + "\x00" + visitorFingerprint + "\x00" + strconv.FormatInt(start.UnixMilli(), 10)))
+	return hex.EncodeToString(sum[:])[:idLength]
+}
+
+// Session is the activity of a visitor without a gap longer than the inactivity timeout.
+type Session struct {
+	ID       string
+	Start    time.Time
+	LastSeen time.Time
+}
+
+// covers reports whether an event at the given time belongs to the session.
+func (s Session) covers(at time.Time, timeout time.Duration) bool {
+	return !at.Before(s.Start.Add(-timeout)) && !at.After(s.LastSeen.Add(timeout))
+}
+
+// History finds the sessions of visitors seen by other replicas.
+type History interface {
+	// LatestSession returns the session of the visitor with the latest activity since the given time, and whether one
+	// was found.
+	LatestSession(ctx context.Context, projectID, visitorFingerprint string, since time.Time) (Session, bool, error)
+}
+
+// Option is a function that modifies the sessionizer configuration.
+type Option func(*Sessionizer)
+
+// WithTimeout sets the inactivity timeout after which a visitor starts a new session.
+func WithTimeout(timeout time.Duration) Option {
+	return func(s *Sessionizer) {
+		s.timeout = timeout
+	}
+}
+
+// WithLookupTimeout sets how long looking up the session of a visitor in the history may take.
+func WithLookupTimeout(timeout time.Duration) Option {
+	return func(s *Sessionizer) {
+		if timeout > 0 {
+			s.lookupTimeout = timeout
+		}
+	}
+}
+
+// WithLookupBackoff sets how long lookups in the history are skipped after one failed.
+func WithLookupBackoff(backoff time.Duration) Option {
+	return func(s *Sessionizer) {
+		if backoff > 0 {
+			s.lookupBackoff = backoff
+		}
+	}
+}
+
+// WithClock sets the clock deciding when idle sessions are forgotten and lookups resume after a failure, used by tests.
+func WithClock(now func() time.Time) Option {
+	return func(s *Sessionizer) {
+		s.now = now
+	}
+}
+
+type visitorKey struct {
+	projectID          string
+	visitorFingerprint string
+}
+
+// Sessionizer assigns session IDs to the events of visitors. Sessions are kept in memory while active, a visitor
+// without an active session is looked up in the history first, so a session started on another replica is continued
+// rather than split. Only events already written to ClickHouse are visible to other replicas, sessions of events still
+// buffered on another replica may be split, which the hub can correct by rebuilding sessions.
+type Sessionizer struct {
+	history       History
+	timeout       time.Duration
+	lookupTimeout time.Duration
+	lookupBackoff time.Duration
+	now           func() time.Time
+
+	mu       sync.Mutex
+	sessions map[visitorKey]Session
+	swept    time.Time
+	failed   time.Time
+}
+
+// New returns a sessionizer looking up visitors without an active session in the history.
+func New(history History, opts ...Option) *Sessionizer {
+	s := &Sessionizer{
+		history:       history,
+		timeout:       DefaultTimeout,
+		lookupTimeout: defaultLookupTimeout,
+		lookupBackoff: defaultLookupBackoff,
+		now:           time.Now,
+		sessions:      make(map[visitorKey]Session),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.swept = s.now()
+	return s
+}
+
+// Assign returns the session ID of the event of a visitor occurring at the given time.
+func (s *Sessionizer) Assign(ctx context.Context, projectID, visitorFingerprint string, at time.Time) string {
+	key := visitorKey{projectID: projectID, visitorFingerprint: visitorFingerprint}
+	if id, ok := s.extend(key, at); ok {
+		return id
+	}
+
+	session, found := s.lookup(ctx, projectID, visitorFingerprint, at)
+	if !found || !session.covers(at, s.timeout) {
+		session = Session{ID: ID(projectID, visitorFingerprint, at), Start: at, LastSeen: at}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Another event of the visitor may have started a session while the history was looked up.
+	if current, ok := s.sessions[key]; ok && current.covers(at, s.timeout) {
+		session = current
+	}
+	session.LastSeen = latest(session.LastSeen, at)
+	s.sessions[key] = session
+	s.sweep()
+
+	return session.ID
+}
+
+// lookup returns the latest session of the visitor in the history before the given time, and whether one was found.
+// While a lookup failed within the backoff, the history is not consulted and the visitor starts a new session.
+func (s *Sessionizer) lookup(ctx context.Context, projectID, visitorFingerprint string, at time.Time) (Session, bool) {
+	s.mu.Lock()
+	backingOff := !s.failed.IsZero() && s.now().Sub(s.failed) < s.lookupBackoff
+	s.mu.Unlock()
+	if backingOff {
+		return Session{}, false
+	}
+
+	lookupCtx, cancel := context.WithTimeout(ctx, s.lookupTimeout)
+	defer cancel()
+
+	session, found, err := s.history.LatestSession(lookupCtx, projectID, visitorFingerprint, at.Add(-s.timeout))
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to look up the session of a visitor, starting new sessions without lookups",
+			slog.Any("error", err), slog.Duration("backoff", s.lookupBackoff))
+		s.mu.Lock()
+		s.failed = s.now()
+		s.mu.Unlock()
+		return Session{}, false
+	}
+	return session, found
+}
+
+// extend returns the ID of the active session of the visitor covering the time, extending the session to it.
+func (s *Sessionizer) extend(key visitorKey, at time.Time) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[key]
+	if !ok || !session.covers(at, s.timeout) {
+		return "", false
+	}
+
+	session.LastSeen = latest(session.LastSeen, at)
+	s.sessions[key] = session
+	return session.ID, true
+}
+
+// sweep forgets sessions idle for longer than the timeout, at most once per timeout. The lock must be held.
+func (s *Sessionizer) sweep() {
+	now := s.now()
+	if now.Sub(s.swept) < s.timeout {
+		return
+	}
+
+	for key, session := range s.sessions {
+		if now.Sub(session.LastSeen) > s.timeout {
+			delete(s.sessions, key)
+		}
+	}
+	s.swept = now
+}
+
+// Len returns the number of sessions kept in memory.
+func (s *Sessionizer) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+// latest returns the later of both times.
+func latest(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
+// ClickHouseHistory looks up the sessions of visitors in the raw_events table.
+type ClickHouseHistory struct {
+	driver clickhouse.Driver
+}
+
+// NewClickHouseHistory returns a history backed by ClickHouse.
+func NewClickHouseHistory(driver clickhouse.Driver) *ClickHouseHistory {
+	return &ClickHouseHistory{driver: driver}
+}
+
+// LatestSession returns the session of the visitor with the latest event since the given time.
+func (h *ClickHouseHistory) LatestSession(ctx context.Context, projectID, visitorFingerprint string, since time.Time) (Session, bool, error) {
+	dbSession, err := h.driver.Begin(ctx)
+	if err != nil {
+		return Session{}, false, fmt.Errorf("failed to begin ClickHouse session: %w", err)
+	}
+
+	var session Session
+	err = dbSession.Builder()(`
+		SELECT session_id, min(event_timestamp), max(event_timestamp)
+		FROM raw_events
+		WHERE project_id = ? AND visitor_fingerprint = ? AND event_timestamp >= ? AND session_id != ''
+		GROUP BY session_id
+		ORDER BY max(event_timestamp) DESC
+		LIMIT 1`,
+	).Arguments(projectID, visitorFingerprint, since).QueryRow(&session.ID, &session.Start, &session.LastSeen)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Session{}, false, nil
+	}
+	if err != nil {
+		return Session{}, false, fmt.Errorf("failed to query latest session: %w", err)
+	}
+
+	return session, true, nil
+}
@@ -0,0 +1,213 @@
+package session_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ponrove/octobe"
+	"github.com/ponrove/octobe/driver/clickhouse"
+	"github.com/ponrove/octobe/driver/clickhouse/mock"
+	"github.com/ponrove/ponrove-backend/internal/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2025, 6, 16, 12, 0, 0, 0, time.UTC)
+
+// history is a session history of a single visitor, counting lookups.
+type history struct {
+	mu      sync.Mutex
+	session session.Session
+	found   bool
+	err     error
+	lookups int
+}
+
+func (h *history) LatestSession(context.Context, string, string, time.Time) (session.Session, bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lookups++
+	return h.session, h.found, h.err
+}
+
+func TestID(t *testing.T) {
+	t.Parallel()
+
+	id := session.ID("project-1", "visitor", start)
+	assert.Len(t, id, 32)
+	assert.Equal(t, id, session.ID("project-1", "visitor", start))
+	assert.NotEqual(t, id, session.ID("project-1", "visitor", start.Add(time.Millisecond)))
+	assert.NotEqual(t, id, session.ID("project-2", "visitor", start))
+	assert.NotEqual(t, id, session.ID("project-1", "other", start))
+}
+
+func TestAssign(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	h := &history{}
+	s := session.New(h, session.WithTimeout(30*time.Minute), session.WithClock(func() time.Time { return start }))
+
+	first := s.Assign(ctx, "project-1", "visitor", start)
+	assert.Equal(t, session.ID("project-1", "visitor", start), first)
+	assert.Equal(t, first, s.Assign(ctx, "project-1", "visitor", start.Add(20*time.Minute)))
+	assert.Equal(t, first, s.Assign(ctx, "project-1", "visitor", start.Add(45*time.Minute)), "activity should extend the session")
+	assert.Equal(t, first, s.Assign(ctx, "project-1", "visitor", start.Add(5*time.Minute)), "late events should join the session")
+	assert.Equal(t, 1, h.lookups, "active sessions should not be looked up")
+
+	next := s.Assign(ctx, "project-1", "visitor", start.Add(80*time.Minute))
+	assert.Equal(t, session.ID("project-1", "visitor", start.Add(80*time.Minute)), next, "inactivity should start a new session")
+	assert.Equal(t, 2, h.lookups)
+
+	other := s.Assign(ctx, "project-1", "other", start)
+	assert.NotEqual(t, first, other)
+	assert.Equal(t, 2, s.Len())
+}
+
+func TestAssignContinuesSessionOfOtherReplica(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	seen := session.Session{ID: "seen-by-other-replica", Start: start, LastSeen: start.Add(10 * time.Minute)}
+	h := &history{session: seen, found: true}
+	s := session.New(h, session.WithTimeout(30*time.Minute))
+
+	assert.Equal(t, seen.ID, s.Assign(ctx, "project-1", "visitor", start.Add(25*time.Minute)))
+	assert.Equal(t, seen.ID, s.Assign(ctx, "project-1", "visitor", start.Add(50*time.Minute)))
+	assert.Equal(t, 1, h.lookups)
+
+	// A session that ended too long ago is not continued.
+	s = session.New(h, session.WithTimeout(30*time.Minute))
+	at := start.Add(time.Hour)
+	assert.Equal(t, session.ID("project-1", "visitor", at), s.Assign(ctx, "project-1", "visitor", at))
+}
+
+func TestAssignHistoryUnavailable(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	h := &history{err: errors.New("connection refused")}
+	s := session.New(h)
+
+	id := s.Assign(ctx, "project-1", "visitor", start)
+	assert.Equal(t, session.ID("project-1", "visitor", start), id)
+	assert.Equal(t, id, s.Assign(ctx, "project-1", "visitor", start.Add(time.Minute)))
+}
+
+// blockingHistory is a session history never answering before the lookup is canceled, counting lookups.
+type blockingHistory struct {
+	lookups atomic.Int64
+}
+
+func (h *blockingHistory) LatestSession(ctx context.Context, _, _ string, _ time.Time) (session.Session, bool, error) {
+	h.lookups.Add(1)
+	<-ctx.Done()
+	return session.Session{}, false, ctx.Err()
+}
+
+func TestAssignHistoryBlocking(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	now := start
+	h := &blockingHistory{}
+	s := session.New(h,
+		session.WithLookupTimeout(10*time.Millisecond),
+		session.WithLookupBackoff(time.Minute),
+		session.WithClock(func() time.Time { return now }),
+	)
+
+	began := time.Now()
+	assert.Equal(t, session.ID("project-1", "first", start), s.Assign(ctx, "project-1", "first", start))
+	assert.Less(t, time.Since(began), time.Second, "the lookup should time out")
+
+	// After the failed lookup, new visitors start a session without waiting for the history.
+	assert.Equal(t, session.ID("project-1", "second", start), s.Assign(ctx, "project-1", "second", start))
+	assert.Equal(t, int64(1), h.lookups.Load())
+
+	now = start.Add(2 * time.Minute)
+	s.Assign(ctx, "project-1", "third", now)
+	assert.Equal(t, int64(2), h.lookups.Load(), "lookups should resume after the backoff")
+}
+
+func TestAssignForgetsIdleSessions(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	now := start
+	s := session.New(&history{}, session.WithTimeout(30*time.Minute), session.WithClock(func() time.Time { return now }))
+
+	s.Assign(ctx, "project-1", "first", start)
+	now = start.Add(40 * time.Minute)
+	s.Assign(ctx, "project-1", "second", now)
+	assert.Equal(t, 1, s.Len(), "the idle session should be forgotten")
+}
+
+func TestRangeDays(t *testing.T) {
+	t.Parallel()
+
+	r := session.Range{ProjectID: "project-1", From: start, To: start.Add(2 * time.Hour)}.Days()
+	assert.Equal(t, time.Date(2025, 6, 16, 0, 0, 0, 0, time.UTC), r.From)
+	assert.Equal(t, time.Date(2025, 6, 17, 0, 0, 0, 0, time.UTC), r.To)
+	assert.Equal(t, "project-1", r.ProjectID)
+
+	midnight := time.Date(2025, 6, 17, 0, 0, 0, 0, time.UTC)
+	r = session.Range{From: midnight, To: midnight.AddDate(0, 0, 1)}.Days()
+	assert.Equal(t, midnight, r.From)
+	assert.Equal(t, midnight.AddDate(0, 0, 1), r.To)
+}
+
+func setupDB(t *testing.T) (*mock.Mock, clickhouse.Driver) {
+	t.Helper()
+	conn := mock.NewMock()
+	driver, err := octobe.New(clickhouse.OpenNativeWithConn(conn))
+	require.NoError(t, err)
+	return conn, driver
+}
+
+func TestClickHouseHistory(t *testing.T) {
+	t.Parallel()
+
+	conn, driver := setupDB(t)
+	h := session.NewClickHouseHistory(driver)
+	ctx := context.Background()
+
+	conn.ExpectQueryRow("SELECT session_id, min(event_timestamp), max(event_timestamp)").WillReturnRow(mock.NewMockRow("session-1", start, start.Add(time.Minute)))
+	s, found, err := h.LatestSession(ctx, "project-1", "visitor", start)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, session.Session{ID: "session-1", Start: start, LastSeen: start.Add(time.Minute)}, s)
+
+	conn.ExpectQueryRow("SELECT session_id").WillReturnRow(mock.NewMockRow().WillReturnError(sql.ErrNoRows))
+	_, found, err = h.LatestSession(ctx, "project-1", "visitor", start)
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	conn.ExpectQueryRow("SELECT session_id").WillReturnRow(mock.NewMockRow().WillReturnError(errors.New("connection refused")))
+	_, _, err = h.LatestSession(ctx, "project-1", "visitor", start)
+	assert.Error(t, err)
+}
+
+func TestRebuild(t *testing.T) {
+	t.Parallel()
+
+	conn, driver := setupDB(t)
+	conn.ExpectExec("CREATE TABLE session_rebuild_")
+	conn.ExpectExec("INSERT INTO session_rebuild_")
+	conn.ExpectExec("joinGetOrNull('session_rebuild_")
+	conn.ExpectExec("ALTER TABLE visitor_directory_state DELETE")
+	conn.ExpectExec("INSERT INTO visitor_directory_state")
+	conn.ExpectExec("DROP TABLE IF EXISTS session_rebuild_")
+
+	err := session.Rebuild(context.Background(), driver, session.Range{From: start, To: start.Add(time.Hour)}, session.DefaultTimeout)
+	require.NoError(t, err)
+	assert.NoError(t, conn.AllExpectationsMet())
+
+	err = session.Rebuild(context.Background(), driver, session.Range{From: start, To: start}, session.DefaultTimeout)
+	assert.Error(t, err)
+}
@@ -0,0 +1,52 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// Func is a function run when the service shuts down, it must return once ctx is done.
+type Func func(ctx context.Context) error
+
+type hook struct {
+	name string
+	fn   Func
+}
+
+var (
+	hooksLock sync.Mutex
+	hooks     []hook
+)
+
+// Register adds a function to run when the service shuts down. ponrunner has no shutdown hooks for API bundles, so
+// bundles owning background work (buffers, watchers, refreshers) register it here, and the service entrypoints call Run
+// once ponrunner.Start returns.
+func Register(name string, fn Func) {
+	hooksLock.Lock()
+	defer hooksLock.Unlock()
+	hooks = append(hooks, hook{name: name, fn: fn})
+}
+
+// Run runs all registered functions in reverse order of registration, bounded by ctx, and forgets them. Errors are
+// logged and joined, a failing function doesn't stop the remaining ones from running.
+func Run(ctx context.Context) error {
+	hooksLock.Lock()
+	pending := hooks
+	hooks = nil
+	hooksLock.Unlock()
+
+	var errs []error
+	for i := len(pending) - 1; i >= 0; i-- {
+		h := pending[i]
+		slog.InfoContext(ctx, "Running shutdown hook", slog.String("name", h.name))
+		if err := h.fn(ctx); err != nil {
+			slog.ErrorContext(ctx, "Shutdown hook failed", slog.String("name", h.name), slog.Any("error", err))
+			errs = append(errs, fmt.Errorf("%s: %w", h.name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,36 @@
+package shutdown_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ponrove/ponrove-backend/internal/shutdown"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	var order []string
+	failure := errors.New("failed to drain")
+
+	shutdown.Register("first", func(ctx context.Context) error {
+		order = append(order, "first")
+		return nil
+	})
+	shutdown.Register("second", func(ctx context.Context) error {
+		order = append(order, "second")
+		return failure
+	})
+	shutdown.Register("third", func(ctx context.Context) error {
+		order = append(order, "third")
+		return nil
+	})
+
+	err := shutdown.Run(context.Background())
+	assert.ErrorIs(t, err, failure)
+	assert.Equal(t, []string{"third", "second", "first"}, order, "hooks should run in reverse order, even after a failure")
+
+	// Hooks are forgotten once run.
+	assert.NoError(t, shutdown.Run(context.Background()))
+	assert.Len(t, order, 3)
+}
@@ -0,0 +1,66 @@
+package spool
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const meterName = "github.com/ponrove/ponrove-backend/internal/spool"
+
+// RegisterMetrics reports the depth, size and age of the spool as OpenTelemetry gauges, through the global meter
+// provider set up by ponrunner when metrics are enabled. The returned function unregisters the gauges.
+func (s *Spool) RegisterMetrics() (func() error, error) {
+	meter := otel.Meter(meterName)
+
+	segments, err := meter.Int64ObservableGauge(
+		"ingestion.spool.segments",
+		metric.WithDescription("Number of event batches waiting in the spool to be replayed."),
+		metric.WithUnit("{segment}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	spooledEvents, err := meter.Int64ObservableGauge(
+		"ingestion.spool.events",
+		metric.WithDescription("Number of events waiting in the spool to be replayed."),
+		metric.WithUnit("{event}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := meter.Int64ObservableGauge(
+		"ingestion.spool.size",
+		metric.WithDescription("Size of the spool on disk."),
+		metric.WithUnit("By"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	age, err := meter.Float64ObservableGauge(
+		"ingestion.spool.oldest_age",
+		metric.WithDescription("Age of the oldest batch waiting in the spool."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	registration, err := meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		stats := s.Stats()
+		o.ObserveInt64(segments, int64(stats.Segments))
+		o.ObserveInt64(spooledEvents, int64(stats.Events))
+		o.ObserveInt64(size, stats.Bytes)
+		o.ObserveFloat64(age, stats.OldestAge.Seconds())
+		return nil
+	}, segments, spooledEvents, size, age)
+	if err != nil {
+		return nil, err
+	}
+
+	return registration.Unregister, nil
+}
@@ -0,0 +1,308 @@
+package spool
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ponrove/ponrove-backend/internal/events"
+)
+
+var (
+	// ErrFull is returned when writing a batch would exceed the maximum size of the spool.
+	ErrFull = errors.New("spool is full")
+	// ErrEmptyBatch is returned when writing a batch without events.
+	ErrEmptyBatch = errors.New("spool batch is empty")
+)
+
+const (
+	segmentExt        = ".seg"
+	tempExt           = ".tmp"
+	corruptExt        = ".corrupt"
+	segmentNameFormat = "%020d-%d" + segmentExt
+)
+
+// Flusher writes a batch of spooled events to its destination.
+type Flusher func(ctx context.Context, batch []events.Event) error
+
+// segment is a single spooled batch on disk. The sequence number orders segments, and together with the number of
+// events it is encoded in the file name, so the spool can be indexed on start without reading every segment.
+type segment struct {
+	seq     uint64
+	events  int
+	size    int64
+	created time.Time
+}
+
+// Stats describes the content of the spool.
+type Stats struct {
+	Segments int
+	Events   int
+	Bytes    int64
+	// OldestAge is the age of the oldest spooled segment, zero when the spool is empty.
+	OldestAge time.Duration
+}
+
+// Spool is an append-only, disk-backed queue of event batches. Every written batch becomes a new segment file, written
+// atomically, and segments are replayed and removed strictly in the order they were written. Segments left behind by a
+// previous process are picked up on Open.
+type Spool struct {
+	dir      string
+	maxBytes int64
+
+	// replayLock serializes replays, so segments are never flushed twice concurrently.
+	replayLock sync.Mutex
+
+	mu       sync.Mutex
+	segments []segment
+	bytes    int64
+	nextSeq  uint64
+}
+
+// Open opens the spool in dir, creating the directory if needed, and indexes the segments already in it. A maxBytes of
+// zero or less disables the size limit.
+func Open(dir string, maxBytes int64) (*Spool, error) {
+	err := os.MkdirAll(dir, 0o750)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool directory: %w", err)
+	}
+
+	s := &Spool{dir: dir, maxBytes: maxBytes, nextSeq: 1}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasSuffix(name, tempExt) {
+			// A write that never completed, the batch was not acknowledged as spooled.
+			_ = os.Remove(filepath.Join(dir, name))
+			continue
+		}
+
+		seg, ok := parseSegmentName(name)
+		if !ok {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat spool segment %s: %w", name, err)
+		}
+		seg.size = info.Size()
+		seg.created = info.ModTime()
+
+		s.segments = append(s.segments, seg)
+		s.bytes += seg.size
+		s.nextSeq = max(s.nextSeq, seg.seq+1)
+	}
+
+	slices.SortFunc(s.segments, func(a, b segment) int {
+		return cmp.Compare(a.seq, b.seq)
+	})
+
+	return s, nil
+}
+
+// Write appends the batch to the spool as a new segment.
+func (s *Spool) Write(batch []events.Event) error {
+	if len(batch) == 0 {
+		return ErrEmptyBatch
+	}
+
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("failed to encode spool segment: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.maxBytes > 0 && s.bytes+int64(len(data)) > s.maxBytes {
+		return fmt.Errorf("%w: %d of %d bytes used", ErrFull, s.bytes, s.maxBytes)
+	}
+
+	seg := segment{seq: s.nextSeq, events: len(batch), size: int64(len(data)), created: time.Now()}
+	path := s.path(seg)
+
+	// Write to a temporary file first, so a crash never leaves a partial segment behind.
+	err = os.WriteFile(path+tempExt, data, 0o640)
+	if err != nil {
+		_ = os.Remove(path + tempExt)
+		return fmt.Errorf("failed to write spool segment: %w", err)
+	}
+
+	err = os.Rename(path+tempExt, path)
+	if err != nil {
+		_ = os.Remove(path + tempExt)
+		return fmt.Errorf("failed to commit spool segment: %w", err)
+	}
+
+	s.nextSeq++
+	s.segments = append(s.segments, seg)
+	s.bytes += seg.size
+	return nil
+}
+
+// Len returns the number of spooled segments.
+func (s *Spool) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.segments)
+}
+
+// Stats returns the current depth, size and age of the spool.
+func (s *Spool) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := Stats{Segments: len(s.segments), Bytes: s.bytes}
+	for _, seg := range s.segments {
+		stats.Events += seg.events
+	}
+	if len(s.segments) > 0 {
+		stats.OldestAge = time.Since(s.segments[0].created)
+	}
+
+	return stats
+}
+
+// Replay flushes the spooled segments in order, removing each segment once flushed, until the spool is empty, ctx is
+// done or a flush fails. It returns the number of replayed events. Segments that can't be decoded are renamed with a
+// .corrupt suffix and skipped, so a single bad file doesn't block the spool forever.
+func (s *Spool) Replay(ctx context.Context, flush Flusher) (int, error) {
+	s.replayLock.Lock()
+	defer s.replayLock.Unlock()
+
+	var replayed int
+	for ctx.Err() == nil {
+		s.mu.Lock()
+		if len(s.segments) == 0 {
+			s.mu.Unlock()
+			return replayed, nil
+		}
+		seg := s.segments[0]
+		s.mu.Unlock()
+
+		batch, err := s.read(seg)
+		if err != nil {
+			slog.ErrorContext(ctx, "Skipping corrupt spool segment", slog.String("segment", s.path(seg)), slog.Any("error", err))
+			_ = os.Rename(s.path(seg), s.path(seg)+corruptExt)
+			s.pop(seg)
+			continue
+		}
+
+		err = flush(ctx, batch)
+		if err != nil {
+			return replayed, fmt.Errorf("failed to replay spool segment %d: %w", seg.seq, err)
+		}
+
+		err = os.Remove(s.path(seg))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return replayed, fmt.Errorf("failed to remove replayed spool segment %d: %w", seg.seq, err)
+		}
+		s.pop(seg)
+		replayed += len(batch)
+	}
+
+	return replayed, ctx.Err()
+}
+
+// Run replays the spool every interval, as soon as ping reports the destination to be reachable again, until ctx is
+// done.
+func (s *Spool) Run(ctx context.Context, interval time.Duration, ping func(ctx context.Context) error, flush Flusher) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if s.Len() == 0 {
+			continue
+		}
+
+		if err := ping(ctx); err != nil {
+			slog.DebugContext(ctx, "Spool replay postponed, destination unreachable", slog.Any("error", err))
+			continue
+		}
+
+		replayed, err := s.Replay(ctx, flush)
+		if replayed > 0 {
+			slog.InfoContext(ctx, "Replayed spooled events", slog.Int("events", replayed), slog.Int("remaining_segments", s.Len()))
+		}
+		if err != nil && ctx.Err() == nil {
+			slog.WarnContext(ctx, "Failed to replay spool, retrying on next interval", slog.Any("error", err))
+		}
+	}
+}
+
+// read decodes the events of a segment.
+func (s *Spool) read(seg segment) ([]events.Event, error) {
+	data, err := os.ReadFile(s.path(seg))
+	if err != nil {
+		return nil, err
+	}
+
+	var batch []events.Event
+	err = json.Unmarshal(data, &batch)
+	if err != nil {
+		return nil, err
+	}
+
+	return batch, nil
+}
+
+// pop removes the head segment from the index, if it is still seg.
+func (s *Spool) pop(seg segment) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.segments) > 0 && s.segments[0].seq == seg.seq {
+		s.segments = s.segments[1:]
+		s.bytes -= seg.size
+	}
+}
+
+// path returns the file path of a segment.
+func (s *Spool) path(seg segment) string {
+	return filepath.Join(s.dir, fmt.Sprintf(segmentNameFormat, seg.seq, seg.events))
+}
+
+// parseSegmentName parses the sequence number and event count from a segment file name.
+func parseSegmentName(name string) (segment, bool) {
+	base, ok := strings.CutSuffix(name, segmentExt)
+	if !ok {
+		return segment{}, false
+	}
+
+	seqPart, eventsPart, ok := strings.Cut(base, "-")
+	if !ok {
+		return segment{}, false
+	}
+
+	seq, err := strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return segment{}, false
+	}
+
+	count, err := strconv.Atoi(eventsPart)
+	if err != nil {
+		return segment{}, false
+	}
+
+	return segment{seq: seq, events: count}, true
+}
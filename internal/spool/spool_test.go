@@ -0,0 +1,198 @@
+package spool_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ponrove/ponrove-backend/internal/events"
+	"github.com/ponrove/ponrove-backend/internal/spool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder is a flusher recording every flushed batch, optionally failing while err is set.
+type recorder struct {
+	mu      sync.Mutex
+	batches [][]events.Event
+	err     error
+}
+
+func (r *recorder) flush(ctx context.Context, batch []events.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	r.batches = append(r.batches, batch)
+	return nil
+}
+
+func (r *recorder) setErr(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.err = err
+}
+
+func (r *recorder) flushed() [][]events.Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.batches
+}
+
+func eventsNamed(names ...string) []events.Event {
+	batch := make([]events.Event, len(names))
+	for i, name := range names {
+		batch[i] = events.Event{
+			ProjectID:        "project",
+			EventTimestamp:   time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC),
+			EventName:        name,
+			Source:           events.SourceClient,
+			CustomProperties: map[string]string{"name": name},
+		}
+	}
+	return batch
+}
+
+func TestReplayInOrder(t *testing.T) {
+	t.Parallel()
+
+	sp, err := spool.Open(t.TempDir(), 0)
+	require.NoError(t, err)
+
+	require.NoError(t, sp.Write(eventsNamed("a", "b")))
+	require.NoError(t, sp.Write(eventsNamed("c")))
+	assert.ErrorIs(t, sp.Write(nil), spool.ErrEmptyBatch)
+
+	stats := sp.Stats()
+	assert.Equal(t, 2, stats.Segments)
+	assert.Equal(t, 3, stats.Events)
+	assert.Positive(t, stats.Bytes)
+
+	rec := &recorder{}
+	replayed, err := sp.Replay(context.Background(), rec.flush)
+	require.NoError(t, err)
+	assert.Equal(t, 3, replayed)
+	assert.Equal(t, [][]events.Event{eventsNamed("a", "b"), eventsNamed("c")}, rec.flushed())
+	assert.Equal(t, spool.Stats{}, sp.Stats())
+}
+
+func TestReplayStopsOnFailure(t *testing.T) {
+	t.Parallel()
+
+	sp, err := spool.Open(t.TempDir(), 0)
+	require.NoError(t, err)
+	require.NoError(t, sp.Write(eventsNamed("a")))
+	require.NoError(t, sp.Write(eventsNamed("b")))
+
+	failure := errors.New("connection refused")
+	rec := &recorder{err: failure}
+	replayed, err := sp.Replay(context.Background(), rec.flush)
+	assert.ErrorIs(t, err, failure)
+	assert.Zero(t, replayed)
+	assert.Equal(t, 2, sp.Len(), "segments should be kept while replaying fails")
+
+	rec.setErr(nil)
+	replayed, err = sp.Replay(context.Background(), rec.flush)
+	require.NoError(t, err)
+	assert.Equal(t, 2, replayed)
+	assert.Equal(t, [][]events.Event{eventsNamed("a"), eventsNamed("b")}, rec.flushed())
+}
+
+func TestMaxBytes(t *testing.T) {
+	t.Parallel()
+
+	sp, err := spool.Open(t.TempDir(), 1)
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, sp.Write(eventsNamed("a")), spool.ErrFull)
+	assert.Zero(t, sp.Len())
+}
+
+func TestReopen(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	sp, err := spool.Open(dir, 0)
+	require.NoError(t, err)
+	require.NoError(t, sp.Write(eventsNamed("a")))
+	require.NoError(t, sp.Write(eventsNamed("b", "c")))
+
+	// A write interrupted by a crash leaves a temporary file behind, it must not be replayed.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "00000000000000000003-1.seg.tmp"), []byte("[{"), 0o640))
+
+	reopened, err := spool.Open(dir, 0)
+	require.NoError(t, err)
+	stats := reopened.Stats()
+	assert.Equal(t, 2, stats.Segments)
+	assert.Equal(t, 3, stats.Events)
+	assert.NoFileExists(t, filepath.Join(dir, "00000000000000000003-1.seg.tmp"))
+
+	require.NoError(t, reopened.Write(eventsNamed("d")))
+
+	rec := &recorder{}
+	replayed, err := reopened.Replay(context.Background(), rec.flush)
+	require.NoError(t, err)
+	assert.Equal(t, 4, replayed)
+	assert.Equal(t, [][]events.Event{eventsNamed("a"), eventsNamed("b", "c"), eventsNamed("d")}, rec.flushed())
+}
+
+func TestCorruptSegmentIsSkipped(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "00000000000000000001-1.seg"), []byte("not json"), 0o640))
+
+	sp, err := spool.Open(dir, 0)
+	require.NoError(t, err)
+	require.NoError(t, sp.Write(eventsNamed("a")))
+
+	rec := &recorder{}
+	replayed, err := sp.Replay(context.Background(), rec.flush)
+	require.NoError(t, err)
+	assert.Equal(t, 1, replayed)
+	assert.Equal(t, [][]events.Event{eventsNamed("a")}, rec.flushed())
+	assert.FileExists(t, filepath.Join(dir, "00000000000000000001-1.seg.corrupt"))
+	assert.Zero(t, sp.Len())
+}
+
+func TestRunReplaysOnceReachable(t *testing.T) {
+	t.Parallel()
+
+	sp, err := spool.Open(t.TempDir(), 0)
+	require.NoError(t, err)
+	require.NoError(t, sp.Write(eventsNamed("a")))
+
+	var (
+		mu        sync.Mutex
+		reachable bool
+	)
+	ping := func(ctx context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if !reachable {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rec := &recorder{}
+	go sp.Run(ctx, 5*time.Millisecond, ping, rec.flush)
+
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, 1, sp.Len(), "the spool should not be replayed while unreachable")
+
+	mu.Lock()
+	reachable = true
+	mu.Unlock()
+
+	assert.Eventually(t, func() bool { return sp.Len() == 0 }, time.Second, time.Millisecond)
+	assert.Equal(t, [][]events.Event{eventsNamed("a")}, rec.flushed())
+}
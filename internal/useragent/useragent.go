@@ -0,0 +1,236 @@
+package useragent
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Device types reported by the parser, mirroring the values stored in the device_type column of raw_events.
+const (
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceTV      = "tv"
+	DeviceConsole = "console"
+)
+
+// maxUserAgentLength bounds the part of a user agent matched against the rules, so oversized headers can't make parsing
+// expensive.
+const maxUserAgentLength = 1024
+
+var ErrInvalidRules = errors.New("invalid user agent rules")
+
+// defaultRules is the rule set shipped with the binary, it can be replaced at runtime by loading a rules file with the
+// same layout.
+//
+//go:embed rules.json
+var defaultRules []byte
+
+// Result holds the browser, operating system and device derived from a user agent. Fields are empty when unknown.
+type Result struct {
+	BrowserName    string
+	BrowserVersion string
+	OSName         string
+	OSVersion      string
+	DeviceType     string
+}
+
+// Rules is the serialized form of a rule set. Rules of each kind are evaluated in order, the first match wins, so more
+// specific rules (e.g. Edge, which also identifies as Chrome) must come before the generic ones.
+type Rules struct {
+	Browsers         []NameRule   `json:"browsers"`
+	OperatingSystems []NameRule   `json:"operating_systems"`
+	Devices          []DeviceRule `json:"devices"`
+}
+
+// NameRule matches a browser or operating system. The first capture group of the pattern, if any, is the version, and
+// versions maps raw versions to display versions, e.g. Windows NT 6.1 to 7.
+type NameRule struct {
+	Name     string            `json:"name"`
+	Pattern  string            `json:"pattern"`
+	Versions map[string]string `json:"versions,omitempty"`
+}
+
+// DeviceRule matches a device type. A rule with an exclude pattern only matches user agents not matching exclude.
+type DeviceRule struct {
+	Type    string `json:"type"`
+	Pattern string `json:"pattern"`
+	Exclude string `json:"exclude,omitempty"`
+}
+
+type nameMatcher struct {
+	name     string
+	pattern  *regexp.Regexp
+	versions map[string]string
+}
+
+type deviceMatcher struct {
+	deviceType string
+	pattern    *regexp.Regexp
+	exclude    *regexp.Regexp
+}
+
+// Parser derives the browser, operating system and device type from user agents.
+type Parser struct {
+	browsers []nameMatcher
+	systems  []nameMatcher
+	devices  []deviceMatcher
+}
+
+var defaultParser = sync.OnceValue(func() *Parser {
+	p, err := Load(bytes.NewReader(defaultRules))
+	if err != nil {
+		panic(fmt.Sprintf("embedded user agent rules are invalid: %v", err))
+	}
+	return p
+})
+
+// Default returns the parser using the embedded rule set.
+func Default() *Parser {
+	return defaultParser()
+}
+
+// LoadFile returns a parser using the rule set in the file at path, falling back to the embedded rule set when path
+// is empty. Rule files are updated independently of releases, to keep up with new browsers and devices.
+func LoadFile(path string) (*Parser, error) {
+	if path == "" {
+		return Default(), nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open user agent rules: %w", err)
+	}
+	defer f.Close()
+
+	return Load(f)
+}
+
+// Load returns a parser using the JSON encoded rule set read from r.
+func Load(r io.Reader) (*Parser, error) {
+	var rules Rules
+	err := json.NewDecoder(r).Decode(&rules)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRules, err)
+	}
+
+	return New(rules)
+}
+
+// New returns a parser using the given rule set, compiling all of its patterns.
+func New(rules Rules) (*Parser, error) {
+	p := &Parser{}
+
+	for _, rule := range rules.Browsers {
+		m, err := compileNameRule("browser", rule)
+		if err != nil {
+			return nil, err
+		}
+		p.browsers = append(p.browsers, m)
+	}
+
+	for _, rule := range rules.OperatingSystems {
+		m, err := compileNameRule("operating system", rule)
+		if err != nil {
+			return nil, err
+		}
+		p.systems = append(p.systems, m)
+	}
+
+	for _, rule := range rules.Devices {
+		if rule.Type == "" {
+			return nil, fmt.Errorf("%w: device rule %q has no type", ErrInvalidRules, rule.Pattern)
+		}
+
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%w: device %s: %v", ErrInvalidRules, rule.Type, err)
+		}
+
+		m := deviceMatcher{deviceType: rule.Type, pattern: pattern}
+		if rule.Exclude != "" {
+			m.exclude, err = regexp.Compile(rule.Exclude)
+			if err != nil {
+				return nil, fmt.Errorf("%w: device %s: %v", ErrInvalidRules, rule.Type, err)
+			}
+		}
+		p.devices = append(p.devices, m)
+	}
+
+	return p, nil
+}
+
+// Parse derives the browser, operating system and device type from the user agent. Devices not matching any device
+// rule are reported as desktop once their operating system is known, and left empty otherwise.
+func (p *Parser) Parse(userAgent string) Result {
+	userAgent = strings.TrimSpace(userAgent)
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+
+	var res Result
+	if userAgent == "" {
+		return res
+	}
+
+	res.BrowserName, res.BrowserVersion = matchName(p.browsers, userAgent)
+	res.OSName, res.OSVersion = matchName(p.systems, userAgent)
+
+	for _, device := range p.devices {
+		if device.pattern.MatchString(userAgent) && (device.exclude == nil || !device.exclude.MatchString(userAgent)) {
+			res.DeviceType = device.deviceType
+			return res
+		}
+	}
+
+	if res.OSName != "" {
+		res.DeviceType = DeviceDesktop
+	}
+
+	return res
+}
+
+// compileNameRule compiles the pattern of a browser or operating system rule.
+func compileNameRule(kind string, rule NameRule) (nameMatcher, error) {
+	if rule.Name == "" {
+		return nameMatcher{}, fmt.Errorf("%w: %s rule %q has no name", ErrInvalidRules, kind, rule.Pattern)
+	}
+
+	pattern, err := regexp.Compile(rule.Pattern)
+	if err != nil {
+		return nameMatcher{}, fmt.Errorf("%w: %s %s: %v", ErrInvalidRules, kind, rule.Name, err)
+	}
+
+	return nameMatcher{name: rule.Name, pattern: pattern, versions: rule.Versions}, nil
+}
+
+// matchName returns the name and version of the first matching rule. Underscore separated versions, as used by Apple
+// platforms, are normalized to dots.
+func matchName(matchers []nameMatcher, userAgent string) (string, string) {
+	for _, m := range matchers {
+		match := m.pattern.FindStringSubmatch(userAgent)
+		if match == nil {
+			continue
+		}
+
+		var version string
+		if len(match) > 1 {
+			version = strings.ReplaceAll(match[1], "_", ".")
+		}
+		if mapped, ok := m.versions[version]; ok {
+			version = mapped
+		}
+
+		return m.name, version
+	}
+
+	return "", ""
+}
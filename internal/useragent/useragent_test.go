@@ -0,0 +1,193 @@
+package useragent_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ponrove/ponrove-backend/internal/useragent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		userAgent string
+		expected  useragent.Result
+	}{
+		{
+			name:      "Chrome on Windows",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/125.0.0.0 Safari/537.36",
+			expected:  useragent.Result{BrowserName: "Chrome", BrowserVersion: "125.0.0.0", OSName: "Windows", OSVersion: "10", DeviceType: useragent.DeviceDesktop},
+		},
+		{
+			name:      "Edge on Windows",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/125.0.0.0 Safari/537.36 Edg/125.0.2535.67",
+			expected:  useragent.Result{BrowserName: "Edge", BrowserVersion: "125.0.2535.67", OSName: "Windows", OSVersion: "10", DeviceType: useragent.DeviceDesktop},
+		},
+		{
+			name:      "Internet Explorer on Windows 7",
+			userAgent: "Mozilla/5.0 (Windows NT 6.1; WOW64; Trident/7.0; rv:11.0) like Gecko",
+			expected:  useragent.Result{BrowserName: "Internet Explorer", BrowserVersion: "11.0", OSName: "Windows", OSVersion: "7", DeviceType: useragent.DeviceDesktop},
+		},
+		{
+			name:      "Firefox on Linux",
+			userAgent: "Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:126.0) Gecko/20100101 Firefox/126.0",
+			expected:  useragent.Result{BrowserName: "Firefox", BrowserVersion: "126.0", OSName: "Linux", DeviceType: useragent.DeviceDesktop},
+		},
+		{
+			name:      "Safari on macOS",
+			userAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4.1 Safari/605.1.15",
+			expected:  useragent.Result{BrowserName: "Safari", BrowserVersion: "17.4.1", OSName: "macOS", OSVersion: "10.15.7", DeviceType: useragent.DeviceDesktop},
+		},
+		{
+			name:      "Opera on macOS",
+			userAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 OPR/110.0.0.0",
+			expected:  useragent.Result{BrowserName: "Opera", BrowserVersion: "110.0.0.0", OSName: "macOS", OSVersion: "10.15.7", DeviceType: useragent.DeviceDesktop},
+		},
+		{
+			name:      "Chrome on Chrome OS",
+			userAgent: "Mozilla/5.0 (X11; CrOS x86_64 14541.0.0) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/125.0.0.0 Safari/537.36",
+			expected:  useragent.Result{BrowserName: "Chrome", BrowserVersion: "125.0.0.0", OSName: "Chrome OS", OSVersion: "14541.0.0", DeviceType: useragent.DeviceDesktop},
+		},
+		{
+			name:      "Safari on iPhone",
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Mobile/15E148 Safari/604.1",
+			expected:  useragent.Result{BrowserName: "Safari", BrowserVersion: "17.5", OSName: "iOS", OSVersion: "17.5", DeviceType: useragent.DeviceMobile},
+		},
+		{
+			name:      "Chrome on iPhone",
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/125.0.6422.80 Mobile/15E148 Safari/604.1",
+			expected:  useragent.Result{BrowserName: "Chrome", BrowserVersion: "125.0.6422.80", OSName: "iOS", OSVersion: "17.5", DeviceType: useragent.DeviceMobile},
+		},
+		{
+			name:      "Chrome on Android phone",
+			userAgent: "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/125.0.6422.113 Mobile Safari/537.36",
+			expected:  useragent.Result{BrowserName: "Chrome", BrowserVersion: "125.0.6422.113", OSName: "Android", OSVersion: "14", DeviceType: useragent.DeviceMobile},
+		},
+		{
+			name:      "Samsung Internet on Android phone",
+			userAgent: "Mozilla/5.0 (Linux; Android 13; SM-S911B) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/25.0 Chrome/121.0.0.0 Mobile Safari/537.36",
+			expected:  useragent.Result{BrowserName: "Samsung Internet", BrowserVersion: "25.0", OSName: "Android", OSVersion: "13", DeviceType: useragent.DeviceMobile},
+		},
+		{
+			name:      "Android WebView",
+			userAgent: "Mozilla/5.0 (Linux; Android 12; SM-G991B; wv) AppleWebKit/537.36 (KHTML, like Gecko) Version/4.0 Chrome/125.0.6422.113 Mobile Safari/537.36",
+			expected:  useragent.Result{BrowserName: "Chrome WebView", BrowserVersion: "125.0.6422.113", OSName: "Android", OSVersion: "12", DeviceType: useragent.DeviceMobile},
+		},
+		{
+			name:      "Windows Phone",
+			userAgent: "Mozilla/5.0 (Windows Phone 10.0; Android 6.0.1; Microsoft; Lumia 950) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/52.0.2743.116 Mobile Safari/537.36 Edge/15.15063",
+			expected:  useragent.Result{BrowserName: "Edge", BrowserVersion: "15.15063", OSName: "Windows Phone", OSVersion: "10.0", DeviceType: useragent.DeviceMobile},
+		},
+		{
+			name:      "Safari on iPad",
+			userAgent: "Mozilla/5.0 (iPad; CPU OS 16_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.6 Mobile/15E148 Safari/604.1",
+			expected:  useragent.Result{BrowserName: "Safari", BrowserVersion: "16.6", OSName: "iOS", OSVersion: "16.6", DeviceType: useragent.DeviceTablet},
+		},
+		{
+			name:      "Chrome on Android tablet",
+			userAgent: "Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/125.0.0.0 Safari/537.36",
+			expected:  useragent.Result{BrowserName: "Chrome", BrowserVersion: "125.0.0.0", OSName: "Android", OSVersion: "13", DeviceType: useragent.DeviceTablet},
+		},
+		{
+			name:      "Silk on Kindle Fire",
+			userAgent: "Mozilla/5.0 (Linux; Android 9; KFTRWI) AppleWebKit/537.36 (KHTML, like Gecko) Silk/124.3.1 like Chrome/124.0.6367.219 Safari/537.36",
+			expected:  useragent.Result{BrowserName: "Silk", BrowserVersion: "124.3.1", OSName: "Fire OS", DeviceType: useragent.DeviceTablet},
+		},
+		{
+			name:      "Samsung smart TV",
+			userAgent: "Mozilla/5.0 (SMART-TV; LINUX; Tizen 6.0) AppleWebKit/537.36 (KHTML, like Gecko) 76.0.3809.146/6.0 TV Safari/537.36",
+			expected:  useragent.Result{OSName: "Tizen", OSVersion: "6.0", DeviceType: useragent.DeviceTV},
+		},
+		{
+			name:      "LG webOS TV",
+			userAgent: "Mozilla/5.0 (Web0S; Linux/SmartTV) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/79.0.3945.79 Safari/537.36 DMOST/2.0.0 (; LGE; webOSTV; WEBOS6.3.2 03.34.95; W6_lm21a;)",
+			expected:  useragent.Result{BrowserName: "Chrome", BrowserVersion: "79.0.3945.79", OSName: "webOS", DeviceType: useragent.DeviceTV},
+		},
+		{
+			name:      "Fire TV",
+			userAgent: "Mozilla/5.0 (Linux; Android 9; AFTKA Build/PS7633) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.230 Mobile Safari/537.36",
+			expected:  useragent.Result{BrowserName: "Chrome", BrowserVersion: "120.0.6099.230", OSName: "Fire OS", DeviceType: useragent.DeviceTV},
+		},
+		{
+			name:      "Chromecast",
+			userAgent: "Mozilla/5.0 (X11; Linux armv7l) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/114.0.0.0 Safari/537.36 CrKey/1.56.500000 DeviceType/AndroidTV",
+			expected:  useragent.Result{BrowserName: "Chrome", BrowserVersion: "114.0.0.0", OSName: "Linux", DeviceType: useragent.DeviceTV},
+		},
+		{
+			name:      "PlayStation 5",
+			userAgent: "Mozilla/5.0 (PlayStation 5 3.11) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/5.0 Safari/605.1.15",
+			expected:  useragent.Result{BrowserName: "Safari", BrowserVersion: "5.0", OSName: "PlayStation", OSVersion: "5", DeviceType: useragent.DeviceConsole},
+		},
+		{
+			name:      "Headless Chrome",
+			userAgent: "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) HeadlessChrome/125.0.6422.60 Safari/537.36",
+			expected:  useragent.Result{BrowserName: "Headless Chrome", BrowserVersion: "125.0.6422.60", OSName: "Linux", DeviceType: useragent.DeviceDesktop},
+		},
+		{
+			name:      "Unknown client",
+			userAgent: "Go-http-client/1.1",
+			expected:  useragent.Result{},
+		},
+		{
+			name:      "Empty",
+			userAgent: "  ",
+			expected:  useragent.Result{},
+		},
+	}
+
+	parser := useragent.Default()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, parser.Parse(tc.userAgent))
+		})
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "rules.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"browsers": [{"name": "Ponrove Browser", "pattern": "PonroveBrowser/(\\d+)"}],
+		"operating_systems": [{"name": "Ponrove OS", "pattern": "PonroveOS (\\d+)", "versions": {"2": "Two"}}],
+		"devices": [{"type": "watch", "pattern": "Watch"}]
+	}`), 0o600))
+
+	parser, err := useragent.LoadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t,
+		useragent.Result{BrowserName: "Ponrove Browser", BrowserVersion: "3", OSName: "Ponrove OS", OSVersion: "Two", DeviceType: "watch"},
+		parser.Parse("PonroveBrowser/3 (PonroveOS 2; Watch)"),
+	)
+
+	parser, err = useragent.LoadFile("")
+	require.NoError(t, err)
+	assert.Same(t, useragent.Default(), parser, "an empty path should use the embedded rules")
+}
+
+func TestLoadInvalidRules(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]string{
+		"Malformed JSON":    `{"browsers": [`,
+		"Invalid pattern":   `{"browsers": [{"name": "Broken", "pattern": "("}]}`,
+		"Missing name":      `{"operating_systems": [{"pattern": "Linux"}]}`,
+		"Missing type":      `{"devices": [{"pattern": "Mobile"}]}`,
+		"Invalid exclusion": `{"devices": [{"type": "tablet", "pattern": "Android", "exclude": "["}]}`,
+	}
+
+	for name, rules := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			_, err := useragent.Load(strings.NewReader(rules))
+			assert.ErrorIs(t, err, useragent.ErrInvalidRules)
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package hub
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/ponrove/ponrove-backend/internal/analytics"
+)
+
+// BreakdownGroup holds the metrics of the events sharing a value of the dimension.
+type BreakdownGroup struct {
+	Value     string               `json:"value" doc:"Value of the dimension, empty when the events don't have one."`
+	Visitors  uint64               `json:"visitors" doc:"Number of unique visitors."`
+	Pageviews uint64               `json:"pageviews" doc:"Number of pageviews."`
+	Events    uint64               `json:"events" doc:"Number of events, pageviews included."`
+	Sessions  uint64               `json:"sessions" doc:"Number of sessions."`
+	Goals     []GoalConversion     `json:"goals,omitempty" doc:"Conversions of the requested goals, in order, omitted without goals."`
+	Compare   *BreakdownComparison `json:"compare,omitempty" doc:"Metrics of the value in the window compared to, omitted without comparison."`
+}
+
+// BreakdownComparison holds the metrics of a value in the window compared to, and how they changed since.
+type BreakdownComparison struct {
+	Visitors  uint64           `json:"visitors" doc:"Number of unique visitors."`
+	Pageviews uint64           `json:"pageviews" doc:"Number of pageviews."`
+	Events    uint64           `json:"events" doc:"Number of events, pageviews included."`
+	Sessions  uint64           `json:"sessions" doc:"Number of sessions."`
+	Goals     []GoalConversion `json:"goals,omitempty" doc:"Conversions of the requested goals, in order, omitted without goals."`
+	Change    BreakdownChange  `json:"change" doc:"Change of the metrics of the value."`
+}
+
+// BreakdownChange holds how the metrics of a value changed since the window compared to.
+type BreakdownChange struct {
+	Visitors  Change `json:"visitors"`
+	Pageviews Change `json:"pageviews"`
+	Events    Change `json:"events"`
+	Sessions  Change `json:"sessions"`
+}
+
+type (
+	BreakdownRequest struct {
+		ProjectID string    `path:"project_id" maxLength:"128" doc:"Identifier of the project."`
+		Dimension string    `query:"dimension" required:"true" maxLength:"137" doc:"Dimension to group the events by, a column such as url_path, referrer_host, utm_source or country_code, or a custom property key prefixed by property:, e.g. property:plan."`
+		From      time.Time `query:"from" required:"true" doc:"Start of the range, inclusive."`
+		To        time.Time `query:"to" required:"true" doc:"End of the range, exclusive."`
+		Sort      string    `query:"sort" default:"visitors" enum:"visitors,pageviews,events,sessions" doc:"Metric the groups are sorted by."`
+		Order     string    `query:"order" default:"desc" enum:"asc,desc" doc:"Direction the groups are sorted in, ties are sorted by value."`
+		Limit     int       `query:"limit" default:"10" minimum:"1" maximum:"1000" doc:"Maximum number of groups."`
+		Offset    int       `query:"offset" default:"0" minimum:"0" maximum:"100000" doc:"Number of groups to skip, to page through them."`
+		FilterParam
+		CompareParam
+		GoalsParam
+	}
+	BreakdownResponse struct {
+		Body struct {
+			Dimension   string           `json:"dimension" doc:"Dimension the events are grouped by."`
+			CompareFrom *time.Time       `json:"compare_from,omitempty" doc:"Start of the window compared to, inclusive, omitted without comparison."`
+			CompareTo   *time.Time       `json:"compare_to,omitempty" doc:"End of the window compared to, exclusive, omitted without comparison."`
+			Groups      []BreakdownGroup `json:"groups" doc:"A page of the groups, sorted."`
+			HasMore     bool             `json:"has_more" doc:"Whether more groups follow this page."`
+		}
+	}
+)
+
+// RegisterBreakdownEndpoints registers the endpoints ranking the values of a dimension, such as the top pages,
+// referrers or countries of a project.
+func (a *server) RegisterBreakdownEndpoints(api huma.API) {
+	dimensions := analytics.Dimensions()
+
+	huma.Register(api, huma.Operation{
+		OperationID: "Get Breakdown",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/breakdown",
+		Tags:        []string{"Breakdown"},
+		Description: fmt.Sprintf("Groups the events by a dimension, one of %s or a custom property. Events without the custom property are left out. Compared, the groups of the range hold the metrics of their value in the window compared to, from the same query.", strings.Join(dimensions, ", ")),
+	}, func(ctx context.Context, i *BreakdownRequest) (*BreakdownResponse, error) {
+		if !i.From.Before(i.To) {
+			return nil, huma.Error422UnprocessableEntity("validation failed", &huma.ErrorDetail{Location: "query.to", Message: "must be after from", Value: i.To})
+		}
+
+		dimension, err := analytics.ParseDimension(i.Dimension)
+		if err != nil {
+			return nil, huma.Error422UnprocessableEntity("validation failed", &huma.ErrorDetail{
+				Location: "query.dimension",
+				Message:  fmt.Sprintf("expected one of %s, or %s followed by a custom property key", strings.Join(dimensions, ", "), analytics.PropertyDimensionPrefix),
+				Value:    i.Dimension,
+			})
+		}
+
+		f, err := i.parse()
+		if err != nil {
+			return nil, err
+		}
+
+		goalList, goalConditions, err := i.load(ctx, a.goals, i.ProjectID)
+		if err != nil {
+			return nil, err
+		}
+
+		groups, hasMore, err := analytics.Breakdown(ctx, a.clickhouse, analytics.BreakdownQuery{
+			ProjectID: i.ProjectID,
+			From:      i.From,
+			To:        i.To,
+			Filter:    f,
+			Dimension: dimension,
+			SortBy:    analytics.Metric(i.Sort),
+			Ascending: i.Order == "asc",
+			Limit:     i.Limit,
+			Offset:    i.Offset,
+			Compare:   analytics.Comparison(i.Compare),
+			Goals:     goalConditions,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		resp := &BreakdownResponse{}
+		resp.Body.Dimension = dimension.String()
+		resp.Body.HasMore = hasMore
+		if i.Compare != "" {
+			compareFrom, compareTo, err := analytics.Comparison(i.Compare).Window(i.From, i.To)
+			if err != nil {
+				return nil, err
+			}
+			resp.Body.CompareFrom, resp.Body.CompareTo = &compareFrom, &compareTo
+		}
+		resp.Body.Groups = make([]BreakdownGroup, 0, len(groups))
+		for _, g := range groups {
+			group := BreakdownGroup{
+				Value:     g.Value,
+				Visitors:  g.Visitors,
+				Pageviews: g.Pageviews,
+				Events:    g.Events,
+				Sessions:  g.Sessions,
+				Goals:     goalConversions(goalList, g.Conversions, g.Visitors),
+			}
+			if c := g.Compare; c != nil {
+				group.Compare = &BreakdownComparison{
+					Visitors:  c.Visitors,
+					Pageviews: c.Pageviews,
+					Events:    c.Events,
+					Sessions:  c.Sessions,
+					Goals:     goalConversions(goalList, c.Conversions, c.Visitors),
+					Change: BreakdownChange{
+						Visitors:  change(float64(g.Visitors), float64(c.Visitors)),
+						Pageviews: change(float64(g.Pageviews), float64(c.Pageviews)),
+						Events:    change(float64(g.Events), float64(c.Events)),
+						Sessions:  change(float64(g.Sessions), float64(c.Sessions)),
+					},
+				}
+			}
+			resp.Body.Groups = append(resp.Body.Groups, group)
+		}
+		return resp, nil
+	})
+}
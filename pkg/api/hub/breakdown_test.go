@@ -0,0 +1,155 @@
+package hub_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ponrove/octobe/driver/clickhouse/mock"
+	"github.com/ponrove/ponrove-backend/pkg/api/hub"
+)
+
+func (suite *HubAPITestSuite) TestBreakdown() {
+	columns := []string{"value", "visitors", "pageviews", "events", "sessions"}
+	conn, driver := setupDB(suite.T())
+	conn.ExpectQuery("ORDER BY visitors DESC, value").WillReturnRows(mock.NewMockRows(columns).
+		AddRow("/pricing", uint64(300), uint64(420), uint64(510), uint64(330)).
+		AddRow("/", uint64(250), uint64(600), uint64(640), uint64(280)).
+		AddRow("/blog", uint64(90), uint64(120), uint64(120), uint64(95)),
+	)
+	conn.ExpectQuery("mapContains(custom_properties, ?)").WillReturnRows(mock.NewMockRows(columns).
+		AddRow("pro", uint64(12), uint64(0), uint64(14), uint64(13)),
+	)
+	srv := suite.startServer(driver)
+	defer srv.Close()
+
+	type body struct {
+		Dimension string               `json:"dimension"`
+		Groups    []hub.BreakdownGroup `json:"groups"`
+		HasMore   bool                 `json:"has_more"`
+	}
+
+	// The query returns one more group than requested, telling another page follows.
+	resp, err := http.Get(srv.URL + "/api/hub/projects/project-1/breakdown?dimension=url_path&limit=2&from=2025-06-16T00:00:00Z&to=2025-06-17T00:00:00Z")
+	suite.NoError(err)
+	defer resp.Body.Close()
+	suite.Equal(http.StatusOK, resp.StatusCode)
+
+	var pages body
+	suite.NoError(json.NewDecoder(resp.Body).Decode(&pages))
+	suite.Equal(body{
+		Dimension: "url_path",
+		Groups: []hub.BreakdownGroup{
+			{Value: "/pricing", Visitors: 300, Pageviews: 420, Events: 510, Sessions: 330},
+			{Value: "/", Visitors: 250, Pageviews: 600, Events: 640, Sessions: 280},
+		},
+		HasMore: true,
+	}, pages)
+
+	resp, err = http.Get(srv.URL + "/api/hub/projects/project-1/breakdown?dimension=property:plan&sort=events&order=asc&from=2025-06-16T00:00:00Z&to=2025-06-17T00:00:00Z")
+	suite.NoError(err)
+	defer resp.Body.Close()
+	suite.Equal(http.StatusOK, resp.StatusCode)
+
+	var plans body
+	suite.NoError(json.NewDecoder(resp.Body).Decode(&plans))
+	suite.Equal(body{
+		Dimension: "property:plan",
+		Groups:    []hub.BreakdownGroup{{Value: "pro", Visitors: 12, Events: 14, Sessions: 13}},
+	}, plans)
+
+	suite.NoError(conn.AllExpectationsMet())
+}
+
+func (suite *HubAPITestSuite) TestBreakdownValidation() {
+	_, driver := setupDB(suite.T())
+	srv := suite.startServer(driver)
+	defer srv.Close()
+
+	for _, query := range []url.Values{
+		{"dimension": {"url_path"}, "from": {"2025-06-17T00:00:00Z"}, "to": {"2025-06-16T00:00:00Z"}},
+		{"dimension": {"visitor_fingerprint"}, "from": {"2025-06-16T00:00:00Z"}, "to": {"2025-06-17T00:00:00Z"}},
+		{"dimension": {"url_path) FROM system.users --"}, "from": {"2025-06-16T00:00:00Z"}, "to": {"2025-06-17T00:00:00Z"}},
+		{"dimension": {"property:"}, "from": {"2025-06-16T00:00:00Z"}, "to": {"2025-06-17T00:00:00Z"}},
+		{"dimension": {"url_path"}, "sort": {"value"}, "from": {"2025-06-16T00:00:00Z"}, "to": {"2025-06-17T00:00:00Z"}},
+		{"dimension": {"url_path"}, "limit": {"0"}, "from": {"2025-06-16T00:00:00Z"}, "to": {"2025-06-17T00:00:00Z"}},
+		{"dimension": {"url_path"}, "offset": {"-1"}, "from": {"2025-06-16T00:00:00Z"}, "to": {"2025-06-17T00:00:00Z"}},
+	} {
+		resp, err := http.Get(srv.URL + "/api/hub/projects/project-1/breakdown?" + query.Encode())
+		suite.NoError(err)
+		resp.Body.Close()
+		suite.Equal(http.StatusUnprocessableEntity, resp.StatusCode, query.Encode())
+	}
+}
+
+func (suite *HubAPITestSuite) TestBreakdownComparison() {
+	columns := []string{"value", "visitors", "pageviews", "events", "sessions", "compare_visitors", "compare_pageviews", "compare_events", "compare_sessions"}
+	conn, driver := setupDB(suite.T())
+	conn.ExpectQuery("uniqExactIf(visitor_fingerprint, visitor_fingerprint != '' AND in_comparison)").WillReturnRows(mock.NewMockRows(columns).
+		AddRow("google.com", uint64(120), uint64(200), uint64(240), uint64(130), uint64(80), uint64(160), uint64(200), uint64(100)).
+		AddRow("news.ycombinator.com", uint64(40), uint64(45), uint64(45), uint64(41), uint64(0), uint64(0), uint64(0), uint64(0)),
+	)
+	srv := suite.startServer(driver)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/hub/projects/project-1/breakdown?dimension=referrer_host&compare=previous_period&from=2025-06-16T00:00:00Z&to=2025-06-23T00:00:00Z")
+	suite.NoError(err)
+	defer resp.Body.Close()
+	suite.Equal(http.StatusOK, resp.StatusCode)
+
+	var body hub.BreakdownResponse
+	suite.NoError(json.NewDecoder(resp.Body).Decode(&body.Body))
+	suite.Equal("2025-06-09T00:00:00Z", body.Body.CompareFrom.Format(time.RFC3339))
+	suite.Equal("2025-06-16T00:00:00Z", body.Body.CompareTo.Format(time.RFC3339))
+
+	percent := func(p float64) *float64 { return &p }
+	suite.Equal([]hub.BreakdownGroup{
+		{
+			Value: "google.com", Visitors: 120, Pageviews: 200, Events: 240, Sessions: 130,
+			Compare: &hub.BreakdownComparison{
+				Visitors: 80, Pageviews: 160, Events: 200, Sessions: 100,
+				Change: hub.BreakdownChange{
+					Visitors:  hub.Change{Absolute: 40, Percent: percent(50)},
+					Pageviews: hub.Change{Absolute: 40, Percent: percent(25)},
+					Events:    hub.Change{Absolute: 40, Percent: percent(20)},
+					Sessions:  hub.Change{Absolute: 30, Percent: percent(30)},
+				},
+			},
+		},
+		{
+			Value: "news.ycombinator.com", Visitors: 40, Pageviews: 45, Events: 45, Sessions: 41,
+			Compare: &hub.BreakdownComparison{
+				Change: hub.BreakdownChange{
+					Visitors:  hub.Change{Absolute: 40},
+					Pageviews: hub.Change{Absolute: 45},
+					Events:    hub.Change{Absolute: 45},
+					Sessions:  hub.Change{Absolute: 41},
+				},
+			},
+		},
+	}, body.Body.Groups)
+	suite.NoError(conn.AllExpectationsMet())
+}
+
+func (suite *HubAPITestSuite) TestBreakdownServerEvents() {
+	columns := []string{"value", "visitors", "pageviews", "events", "sessions"}
+	conn, driver := setupDB(suite.T())
+	// Server-side events sent without the IP address of the visitor have an empty fingerprint and session, they count as
+	// events but not as a visitor or session.
+	conn.ExpectQuery("uniqExactIf(session_id, session_id != '') AS sessions").WillReturnRows(mock.NewMockRows(columns).
+		AddRow("purchase", uint64(0), uint64(0), uint64(3), uint64(0)),
+	)
+	srv := suite.startServer(driver)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/hub/projects/project-1/breakdown?dimension=event_name&from=2025-06-16T00:00:00Z&to=2025-06-17T00:00:00Z")
+	suite.NoError(err)
+	defer resp.Body.Close()
+	suite.Equal(http.StatusOK, resp.StatusCode)
+
+	var body hub.BreakdownResponse
+	suite.NoError(json.NewDecoder(resp.Body).Decode(&body.Body))
+	suite.Equal([]hub.BreakdownGroup{{Value: "purchase", Events: 3}}, body.Body.Groups)
+	suite.NoError(conn.AllExpectationsMet())
+}
@@ -0,0 +1,118 @@
+package hub
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/ponrove/ponrove-backend/internal/analytics"
+	"github.com/ponrove/ponrove-backend/internal/filter"
+)
+
+// Cohort holds how many of the users first seen in a period came back in each following period.
+type Cohort struct {
+	Start   time.Time      `json:"start" doc:"Start of the period the users were first seen in, in the requested timezone."`
+	Users   uint64         `json:"users" doc:"Number of users first seen in the period."`
+	Periods []CohortPeriod `json:"periods" doc:"Returns of the users in every following period up to the end of the range, in order."`
+}
+
+// CohortPeriod holds how many users of a cohort returned in a period following it.
+type CohortPeriod struct {
+	Period   int       `json:"period" doc:"Number of periods since the cohort, starting at 1."`
+	Start    time.Time `json:"start" doc:"Start of the period, in the requested timezone."`
+	Returned uint64    `json:"returned" doc:"Number of users of the cohort returning in the period."`
+	Rate     float64   `json:"rate" doc:"Share of the users of the cohort returning in the period, between 0 and 1."`
+}
+
+type (
+	CohortsRequest struct {
+		ProjectID      string    `path:"project_id" maxLength:"128" doc:"Identifier of the project."`
+		From           time.Time `query:"from" required:"true" doc:"Start of the range users are first seen in, inclusive."`
+		To             time.Time `query:"to" required:"true" doc:"End of the range, exclusive."`
+		Interval       string    `query:"interval" default:"week" enum:"day,week,month" doc:"Width of the periods. Weeks start on Monday."`
+		Timezone       string    `query:"timezone" default:"UTC" maxLength:"64" doc:"IANA timezone the periods are aligned to, e.g. Europe/Stockholm."`
+		Identity       string    `query:"identity" required:"true" minLength:"1" maxLength:"128" doc:"Key of the custom property identifying users, e.g. user_id. Events without it are left out."`
+		ReturningEvent string    `query:"returning_event" maxLength:"128" doc:"Name of the event counting as a return, any event when omitted."`
+		LookbackDays   int       `query:"lookback_days" default:"90" minimum:"0" maximum:"365" doc:"Days before the range users are looked for, users seen within them are left out. Users last seen before count as first seen when they come back in the range."`
+		FilterParam
+	}
+	CohortsResponse struct {
+		Body struct {
+			Interval string   `json:"interval" doc:"Width of the periods."`
+			Timezone string   `json:"timezone" doc:"Timezone the periods are aligned to."`
+			Identity string   `json:"identity" doc:"Key of the custom property identifying users."`
+			Cohorts  []Cohort `json:"cohorts" doc:"Cohort per period overlapping the range, empty cohorts included, ordered by time."`
+		}
+	}
+)
+
+// RegisterCohortEndpoints registers the endpoints reporting how the users of a project return over time.
+func (a *server) RegisterCohortEndpoints(api huma.API) {
+	huma.Register(api, huma.Operation{
+		OperationID: "Get Cohort Retention",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/cohorts",
+		Tags:        []string{"Cohorts"},
+		Description: "Groups users by the day, week or month they were first seen in and reports the share returning in every following period. Visitor fingerprints rotate daily and never return in a later period, so users are identified by a custom property the site sends, such as a user ID. Sites that don't send one get empty cohorts. Users seen in the lookback before the range are left out, at most a year of events before the range is scanned.",
+	}, func(ctx context.Context, i *CohortsRequest) (*CohortsResponse, error) {
+		if !i.From.Before(i.To) {
+			return nil, huma.Error422UnprocessableEntity("validation failed", &huma.ErrorDetail{Location: "query.to", Message: "must be after from", Value: i.To})
+		}
+
+		f, err := i.parse()
+		if err != nil {
+			return nil, err
+		}
+
+		loc, err := analytics.LoadTimezone(i.Timezone)
+		if err != nil {
+			return nil, huma.Error422UnprocessableEntity("validation failed", &huma.ErrorDetail{Location: "query.timezone", Message: "expected an IANA timezone", Value: i.Timezone})
+		}
+
+		var returning filter.Filter
+		if i.ReturningEvent != "" {
+			returning, err = filter.Compare("event_name", "=", i.ReturningEvent)
+			if err != nil {
+				return nil, huma.Error422UnprocessableEntity("validation failed", &huma.ErrorDetail{Location: "query.returning_event", Message: filterMessage(err), Value: i.ReturningEvent})
+			}
+		}
+
+		cohorts, err := analytics.Cohorts(ctx, a.clickhouse, analytics.CohortQuery{
+			ProjectID:   i.ProjectID,
+			From:        i.From,
+			To:          i.To,
+			Filter:      f,
+			Interval:    analytics.Interval(i.Interval),
+			Location:    loc,
+			IdentityKey: i.Identity,
+			Returning:   returning,
+			Lookback:    time.Duration(i.LookbackDays) * 24 * time.Hour,
+		})
+		if errors.Is(err, analytics.ErrTooManyCohorts) || errors.Is(err, analytics.ErrTooManyBuckets) {
+			return nil, huma.Error422UnprocessableEntity("validation failed", &huma.ErrorDetail{Location: "query.interval", Message: analytics.ErrTooManyCohorts.Error() + ", use a wider interval or a shorter range", Value: i.Interval})
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		resp := &CohortsResponse{}
+		resp.Body.Interval = i.Interval
+		resp.Body.Timezone = loc.String()
+		resp.Body.Identity = i.Identity
+		resp.Body.Cohorts = make([]Cohort, 0, len(cohorts))
+		for n, c := range cohorts {
+			cohort := Cohort{Start: c.Start, Users: c.Actors, Periods: make([]CohortPeriod, 0, len(c.Returned))}
+			for k, returned := range c.Returned {
+				period := CohortPeriod{Period: k + 1, Start: cohorts[n+k+1].Start, Returned: returned}
+				if c.Actors > 0 {
+					period.Rate = float64(returned) / float64(c.Actors)
+				}
+				cohort.Periods = append(cohort.Periods, period)
+			}
+			resp.Body.Cohorts = append(resp.Body.Cohorts, cohort)
+		}
+		return resp, nil
+	})
+}
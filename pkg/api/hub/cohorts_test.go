@@ -0,0 +1,79 @@
+package hub_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/ponrove/octobe/driver/clickhouse/mock"
+	"github.com/ponrove/ponrove-backend/pkg/api/hub"
+)
+
+func (suite *HubAPITestSuite) TestCohorts() {
+	week := func(d int) time.Time { return time.Date(2025, 6, d, 0, 0, 0, 0, time.UTC) }
+
+	// Users are looked for in the lookback before the range, rather than in every retained event.
+	conn, driver := setupDB(suite.T())
+	conn.ExpectQuery("groupUniqArrayIf(toDateTime(toStartOfWeek(event_timestamp, 1, 'UTC'), 'UTC'), event_name = ?)").WithArgs(
+		"user_id", "login", "project-1", week(2).AddDate(0, 0, -30), time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC), "user_id", week(2),
+	).WillReturnRows(mock.NewMockRows([]string{"cohort", "period_start", "users"}).
+		AddRow(week(2), week(2), uint64(200)).
+		AddRow(week(2), week(9), uint64(50)).
+		AddRow(week(2), week(23), uint64(20)).
+		AddRow(week(9), week(9), uint64(80)).
+		AddRow(week(9), week(16), uint64(40)),
+	)
+	srv := suite.startServer(driver)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/hub/projects/project-1/cohorts?identity=user_id&returning_event=login&lookback_days=30&from=2025-06-02T00:00:00Z&to=2025-06-30T00:00:00Z")
+	suite.NoError(err)
+	defer resp.Body.Close()
+	suite.Equal(http.StatusOK, resp.StatusCode)
+
+	var body hub.CohortsResponse
+	suite.NoError(json.NewDecoder(resp.Body).Decode(&body.Body))
+	suite.Equal("week", body.Body.Interval)
+	suite.Equal("user_id", body.Body.Identity)
+	suite.Equal([]hub.Cohort{
+		{
+			Start: week(2), Users: 200,
+			Periods: []hub.CohortPeriod{
+				{Period: 1, Start: week(9), Returned: 50, Rate: 0.25},
+				{Period: 2, Start: week(16)},
+				{Period: 3, Start: week(23), Returned: 20, Rate: 0.1},
+			},
+		},
+		{
+			Start: week(9), Users: 80,
+			Periods: []hub.CohortPeriod{
+				{Period: 1, Start: week(16), Returned: 40, Rate: 0.5},
+				{Period: 2, Start: week(23)},
+			},
+		},
+		{Start: week(16), Periods: []hub.CohortPeriod{{Period: 1, Start: week(23)}}},
+		{Start: week(23), Periods: []hub.CohortPeriod{}},
+	}, body.Body.Cohorts)
+	suite.NoError(conn.AllExpectationsMet())
+}
+
+func (suite *HubAPITestSuite) TestCohortsValidation() {
+	_, driver := setupDB(suite.T())
+	srv := suite.startServer(driver)
+	defer srv.Close()
+
+	for _, query := range []string{
+		"identity=user_id&from=2025-06-17T00:00:00Z&to=2025-06-16T00:00:00Z",
+		"from=2025-06-16T00:00:00Z&to=2025-06-17T00:00:00Z",
+		"identity=user_id&interval=hour&from=2025-06-16T00:00:00Z&to=2025-06-17T00:00:00Z",
+		"identity=user_id&timezone=Mars/Olympus&from=2025-06-16T00:00:00Z&to=2025-06-17T00:00:00Z",
+		"identity=user_id&interval=day&from=2020-01-01T00:00:00Z&to=2025-01-01T00:00:00Z",
+		"identity=user_id&filter=password+%3D+x&from=2025-06-16T00:00:00Z&to=2025-06-17T00:00:00Z",
+		"identity=user_id&lookback_days=366&from=2025-06-16T00:00:00Z&to=2025-06-17T00:00:00Z",
+	} {
+		resp, err := http.Get(srv.URL + "/api/hub/projects/project-1/cohorts?" + query)
+		suite.NoError(err)
+		resp.Body.Close()
+		suite.Equal(http.StatusUnprocessableEntity, resp.StatusCode, query)
+	}
+}
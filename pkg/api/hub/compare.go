@@ -0,0 +1,22 @@
+package hub
+
+// CompareParam is embedded in the requests of the endpoints comparing their range to another window.
+type CompareParam struct {
+	Compare string `query:"compare" enum:"previous_period,previous_year" doc:"Compare the range to the range of the same length right before it, or to the same range a year earlier."`
+}
+
+// Change is the difference between a value and the value it is compared to.
+type Change struct {
+	Absolute float64  `json:"absolute" doc:"Difference with the compared value."`
+	Percent  *float64 `json:"percent" doc:"Difference in percent of the compared value, null when the compared value is zero."`
+}
+
+// change returns the difference between the value and the value it is compared to.
+func change(value, compared float64) Change {
+	c := Change{Absolute: value - compared}
+	if compared != 0 {
+		percent := c.Absolute / compared * 100
+		c.Percent = &percent
+	}
+	return c
+}
@@ -7,6 +7,8 @@ import (
 	"github.com/danielgtaylor/huma/v2/adapters/humachi"
 	"github.com/go-chi/chi/v5"
 	"github.com/ponrove/configura"
+	"github.com/ponrove/ponrove-backend/internal/currency"
+	"github.com/ponrove/ponrove-backend/internal/session"
 	"github.com/ponrove/ponrove-backend/pkg/api/hub"
 	"github.com/ponrove/ponrove-backend/pkg/config"
 	"github.com/stretchr/testify/assert"
@@ -30,3 +32,63 @@ func TestRequiredConfiguration(t *testing.T) {
 	assert.Error(t, err, "expected error when registering users API with empty configuration")
 	assert.ErrorIs(t, err, configura.ErrMissingVariable)
 }
+
+// TestInvalidRetentionBounds checks that the hub API refuses to start with retention bounds that can't be satisfied.
+func TestInvalidRetentionBounds(t *testing.T) {
+	t.Parallel()
+
+	_, driver := setupDB(t)
+	for _, bounds := range [][2]int64{{0, 30}, {90, 30}} {
+		cfg := configura.NewConfigImpl()
+		assert.NoError(t, configura.WriteConfiguration(cfg, map[configura.Variable[bool]]bool{hub.HUB_API_TEST_FLAG: false}))
+		assert.NoError(t, configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+			session.SESSION_TIMEOUT_MS: session.DefaultTimeout.Milliseconds(),
+			hub.HUB_RETENTION_MIN_DAYS: bounds[0],
+			hub.HUB_RETENTION_MAX_DAYS: bounds[1],
+		}))
+		assert.NoError(t, configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+			hub.HUB_REVENUE_CURRENCY: "USD",
+			hub.HUB_REVENUE_RATES:    "",
+		}))
+
+		err := hub.Register(
+			hub.WithClickhouseDriver(driver),
+		)(cfg, humachi.New(chi.NewRouter(), huma.DefaultConfig("", "")))
+		assert.Error(t, err, bounds)
+	}
+}
+
+// TestInvalidRevenueRates checks that the hub API refuses to start with a reporting currency or exchange rates it can't
+// convert revenue with.
+func TestInvalidRevenueRates(t *testing.T) {
+	t.Parallel()
+
+	_, driver := setupDB(t)
+	testCases := []struct {
+		currency string
+		rates    string
+		err      error
+	}{
+		{currency: "usd", rates: "", err: currency.ErrInvalidCurrency},
+		{currency: "USD", rates: "EUR", err: currency.ErrInvalidCurrency},
+		{currency: "USD", rates: "EUR=-1", err: currency.ErrInvalidRate},
+	}
+	for _, tc := range testCases {
+		cfg := configura.NewConfigImpl()
+		assert.NoError(t, configura.WriteConfiguration(cfg, map[configura.Variable[bool]]bool{hub.HUB_API_TEST_FLAG: false}))
+		assert.NoError(t, configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+			session.SESSION_TIMEOUT_MS: session.DefaultTimeout.Milliseconds(),
+			hub.HUB_RETENTION_MIN_DAYS: 1,
+			hub.HUB_RETENTION_MAX_DAYS: 3650,
+		}))
+		assert.NoError(t, configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+			hub.HUB_REVENUE_CURRENCY: tc.currency,
+			hub.HUB_REVENUE_RATES:    tc.rates,
+		}))
+
+		err := hub.Register(
+			hub.WithClickhouseDriver(driver),
+		)(cfg, humachi.New(chi.NewRouter(), huma.DefaultConfig("", "")))
+		assert.ErrorIs(t, err, tc.err, tc.rates)
+	}
+}
@@ -0,0 +1,35 @@
+package hub
+
+import (
+	"errors"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/ponrove/ponrove-backend/internal/filter"
+)
+
+// FilterParam is embedded in the requests of the endpoints querying events, to narrow them down with the filter
+// language of the hub.
+type FilterParam struct {
+	Filter string `query:"filter" maxLength:"2000" doc:"Filter the events, e.g. url_path ~ \"/blog/*\" and country_code in (SE, NO). Comparisons use =, !=, ~ and !~ with * as wildcard, <, <=, > and >= on numbers, in and not in with a list of values, and combine with and, or, not and parentheses. Fields are columns of the events such as url_path, referrer_host, utm_source or country_code, or custom properties such as custom_properties.plan. Bot traffic is excluded unless the filter compares is_bot."`
+}
+
+// parse parses the filter, as a validation error of the query when invalid.
+func (p FilterParam) parse() (filter.Filter, error) {
+	f, err := filter.Parse(p.Filter)
+	if err != nil {
+		return filter.Filter{}, huma.Error422UnprocessableEntity("validation failed", &huma.ErrorDetail{Location: "query.filter", Message: filterMessage(err), Value: p.Filter})
+	}
+	return f, nil
+}
+
+// filterMessage describes why a filter could not be parsed.
+func filterMessage(err error) string {
+	var syntaxErr *filter.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return syntaxErr.Error()
+	}
+	if errors.Is(err, filter.ErrTooComplex) {
+		return "filter is too complex, simplify its comparisons and nesting"
+	}
+	return err.Error()
+}
@@ -0,0 +1,56 @@
+package hub_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/ponrove/octobe/driver/clickhouse/mock"
+)
+
+func (suite *HubAPITestSuite) TestFilteredQueries() {
+	columns := []string{"value", "visitors", "pageviews", "events", "sessions"}
+	conn, driver := setupDB(suite.T())
+	conn.ExpectQuery("AND is_bot = 0 AND (url_path LIKE ? AND country_code IN (?, ?))").WillReturnRows(mock.NewMockRows(columns))
+	// Comparing is_bot replaces the exclusion of bot traffic.
+	conn.ExpectQuery("event_timestamp < ? AND is_bot = ?").WillReturnRows(mock.NewMockRows(columns))
+	conn.ExpectQuery("AND is_bot = 0 AND custom_properties[?] = ?").WillReturnRows(mock.NewMockRows([]string{"bucket", "pageviews", "visitors"}))
+	conn.ExpectQuery("AND session_id != '' AND is_bot = 0 AND custom_properties[?] = ?").WillReturnRows(mock.NewMockRows([]string{"bucket", "sessions", "bounces", "sessions_with_views", "duration"}))
+	conn.ExpectQuery("AND is_bot = 0 AND device_type = ?").WillReturnRows(mock.NewMockRows([]string{"url_path"}))
+	srv := suite.startServer(driver)
+	defer srv.Close()
+
+	for _, path := range []string{
+		"/breakdown?" + url.Values{"dimension": {"url_path"}, "filter": {`url_path ~ "/blog/*" and country_code in (SE, NO)`}}.Encode(),
+		"/breakdown?" + url.Values{"dimension": {"browser_name"}, "filter": {`is_bot = true`}}.Encode(),
+		"/timeseries?" + url.Values{"filter": {`custom_properties.plan = "pro"`}}.Encode(),
+		"/vitals?" + url.Values{"filter": {`device_type = mobile`}}.Encode(),
+	} {
+		resp, err := http.Get(srv.URL + "/api/hub/projects/project-1" + path + "&from=2025-06-16T00:00:00Z&to=2025-06-17T00:00:00Z")
+		suite.NoError(err)
+		resp.Body.Close()
+		suite.Equal(http.StatusOK, resp.StatusCode, path)
+	}
+	suite.NoError(conn.AllExpectationsMet())
+}
+
+func (suite *HubAPITestSuite) TestFilterValidation() {
+	_, driver := setupDB(suite.T())
+	srv := suite.startServer(driver)
+	defer srv.Close()
+
+	for _, path := range []string{"/timeseries?", "/breakdown?dimension=url_path&", "/vitals?"} {
+		query := url.Values{"filter": {`url_path = "/" or password = x`}, "from": {"2025-06-16T00:00:00Z"}, "to": {"2025-06-17T00:00:00Z"}}
+		resp, err := http.Get(srv.URL + "/api/hub/projects/project-1" + path + query.Encode())
+		suite.NoError(err)
+		defer resp.Body.Close()
+		suite.Equal(http.StatusUnprocessableEntity, resp.StatusCode, path)
+
+		var model huma.ErrorModel
+		suite.NoError(json.NewDecoder(resp.Body).Decode(&model))
+		suite.Require().Len(model.Errors, 1, path)
+		suite.Equal("query.filter", model.Errors[0].Location)
+		suite.Equal(`unknown field "password" at offset 18`, model.Errors[0].Message)
+	}
+}
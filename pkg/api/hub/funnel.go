@@ -0,0 +1,185 @@
+package hub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/ponrove/ponrove-backend/internal/analytics"
+	"github.com/ponrove/ponrove-backend/internal/filter"
+)
+
+// Funnel step types.
+const (
+	FunnelStepPageview = "pageview"
+	FunnelStepEvent    = "event"
+)
+
+// FunnelStepPayload is a step of a funnel.
+type FunnelStepPayload struct {
+	Label  string `json:"label,omitempty" maxLength:"128" doc:"Display name of the step, the match by default."`
+	Type   string `json:"type" enum:"pageview,event" doc:"Whether the step matches pageviews by path, or events by name."`
+	Match  string `json:"match" minLength:"1" maxLength:"1024" doc:"Path of the pageviews, * matches any characters, e.g. /blog/*. Name of the events for event steps."`
+	Filter string `json:"filter,omitempty" maxLength:"2000" doc:"Filter the events of the step, e.g. custom_properties.plan = \"pro\"."`
+}
+
+// FunnelPayload is the body of a funnel request.
+type FunnelPayload struct {
+	From      time.Time           `json:"from" doc:"Start of the range, inclusive."`
+	To        time.Time           `json:"to" doc:"End of the range, exclusive. Steps after the end of the range are not counted."`
+	Steps     []FunnelStepPayload `json:"steps" minItems:"2" maxItems:"10" doc:"Steps of the funnel, in order."`
+	WindowS   int64               `json:"window_s" minimum:"1" maximum:"7776000" doc:"Seconds after their first step actors have to complete the following steps."`
+	By        string              `json:"by,omitempty" enum:"visitor,session" default:"visitor" doc:"Whether visitors or sessions go through the funnel."`
+	Breakdown string              `json:"breakdown,omitempty" maxLength:"137" doc:"Dimension to group the actors by, with its value at their first event of the first step. Same dimensions as breakdowns."`
+	Limit     int                 `json:"limit,omitempty" default:"10" minimum:"1" maximum:"100" doc:"Maximum number of groups of a breakdown, those with the most actors entering the funnel first."`
+	Filter    string              `json:"filter,omitempty" maxLength:"2000" doc:"Filter the events of every step, in the filter language of the hub."`
+}
+
+// FunnelStep reports how many actors reached a step of the funnel.
+type FunnelStep struct {
+	Label              string   `json:"label" doc:"Display name of the step."`
+	Actors             uint64   `json:"actors" doc:"Number of actors reaching the step."`
+	ConversionRate     float64  `json:"conversion_rate" doc:"Share of the actors entering the funnel reaching the step, between 0 and 1."`
+	StepConversionRate float64  `json:"step_conversion_rate" doc:"Share of the actors reaching the previous step reaching this step, between 0 and 1."`
+	DropOff            uint64   `json:"drop_off" doc:"Number of actors reaching the previous step but not this step."`
+	DropOffRate        float64  `json:"drop_off_rate" doc:"Share of the actors reaching the previous step but not this step, between 0 and 1."`
+	MedianTimeS        *float64 `json:"median_time_from_previous_s,omitempty" doc:"Median seconds between the first event of the previous step and the first event of this step, omitted for the first step or without actors."`
+}
+
+// FunnelGroup holds the steps of the actors sharing a value of the dimension of a breakdown.
+type FunnelGroup struct {
+	Value string       `json:"value" doc:"Value of the dimension, empty without breakdown."`
+	Steps []FunnelStep `json:"steps" doc:"Steps of the funnel, in order."`
+}
+
+type (
+	FunnelRequest struct {
+		ProjectID string `path:"project_id" maxLength:"128" doc:"Identifier of the project."`
+		Body      FunnelPayload
+	}
+	FunnelResponse struct {
+		Body struct {
+			By        string        `json:"by" doc:"Whether visitors or sessions go through the funnel."`
+			Breakdown string        `json:"breakdown,omitempty" doc:"Dimension the actors are grouped by, omitted without breakdown."`
+			Groups    []FunnelGroup `json:"groups" doc:"Funnel per value of the dimension, a single group without breakdown."`
+		}
+	}
+)
+
+// RegisterFunnelEndpoints registers the endpoints computing conversion funnels.
+func (a *server) RegisterFunnelEndpoints(api huma.API) {
+	huma.Register(api, huma.Operation{
+		OperationID: "Compute Funnel",
+		Method:      http.MethodPost,
+		Path:        "/projects/{project_id}/funnel",
+		Tags:        []string{"Funnels"},
+		Description: "Computes how many visitors or sessions go through the steps of a funnel in order, within the conversion window of their first step, with ClickHouse windowFunnel. Bot traffic is excluded unless the filter compares is_bot.",
+	}, func(ctx context.Context, i *FunnelRequest) (*FunnelResponse, error) {
+		if !i.Body.From.Before(i.Body.To) {
+			return nil, huma.Error422UnprocessableEntity("validation failed", &huma.ErrorDetail{Location: "body.to", Message: "must be after from", Value: i.Body.To})
+		}
+
+		f, err := parseFilter("body.filter", i.Body.Filter)
+		if err != nil {
+			return nil, err
+		}
+
+		steps := make([]analytics.FunnelStep, 0, len(i.Body.Steps))
+		for n, s := range i.Body.Steps {
+			stepFilter, err := parseFilter(fmt.Sprintf("body.steps[%d].filter", n), s.Filter)
+			if err != nil {
+				return nil, err
+			}
+
+			if s.Type == FunnelStepPageview {
+				stepFilter, err = filter.Pageviews(s.Match, stepFilter)
+			} else {
+				stepFilter, err = filter.Events(s.Match, stepFilter)
+			}
+			if err != nil {
+				return nil, huma.Error422UnprocessableEntity("validation failed", &huma.ErrorDetail{Location: fmt.Sprintf("body.steps[%d].match", n), Message: err.Error(), Value: s.Match})
+			}
+			steps = append(steps, analytics.FunnelStep{Filter: stepFilter})
+		}
+
+		q := analytics.FunnelQuery{
+			ProjectID: i.ProjectID,
+			From:      i.Body.From,
+			To:        i.Body.To,
+			Filter:    f,
+			Steps:     steps,
+			Window:    time.Duration(i.Body.WindowS) * time.Second,
+			By:        analytics.FunnelActor(i.Body.By),
+			Limit:     i.Body.Limit,
+		}
+		if i.Body.Breakdown != "" {
+			dimension, err := analytics.ParseDimension(i.Body.Breakdown)
+			if err != nil {
+				return nil, huma.Error422UnprocessableEntity("validation failed", &huma.ErrorDetail{Location: "body.breakdown", Message: fmt.Sprintf("expected one of %s, or %s followed by a custom property key", strings.Join(analytics.Dimensions(), ", "), analytics.PropertyDimensionPrefix), Value: i.Body.Breakdown})
+			}
+			q.Breakdown = &dimension
+		}
+
+		groups, err := analytics.Funnel(ctx, a.clickhouse, q)
+		if errors.Is(err, analytics.ErrInvalidFunnel) {
+			return nil, huma.Error422UnprocessableEntity("validation failed", &huma.ErrorDetail{Location: "body.steps", Message: err.Error()})
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		resp := &FunnelResponse{}
+		resp.Body.By = string(q.By)
+		if q.Breakdown != nil {
+			resp.Body.Breakdown = q.Breakdown.String()
+		}
+		resp.Body.Groups = make([]FunnelGroup, 0, len(groups))
+		for _, g := range groups {
+			resp.Body.Groups = append(resp.Body.Groups, funnelGroup(i.Body.Steps, g))
+		}
+		return resp, nil
+	})
+}
+
+// funnelGroup converts how far the actors of a group went through the steps into the conversions of each step.
+func funnelGroup(payload []FunnelStepPayload, g analytics.FunnelGroup) FunnelGroup {
+	group := FunnelGroup{Value: g.Value, Steps: make([]FunnelStep, len(g.Reached))}
+	for n, reached := range g.Reached {
+		step := FunnelStep{Label: payload[n].Label, Actors: reached}
+		if step.Label == "" {
+			step.Label = payload[n].Match
+		}
+		if entered := g.Reached[0]; entered > 0 {
+			step.ConversionRate = float64(reached) / float64(entered)
+		}
+
+		previous := reached
+		if n > 0 {
+			previous = g.Reached[n-1]
+			if median := g.MedianTimes[n-1]; median != nil {
+				seconds := median.Seconds()
+				step.MedianTimeS = &seconds
+			}
+		}
+		if previous > 0 {
+			step.StepConversionRate = float64(reached) / float64(previous)
+			step.DropOff = previous - reached
+			step.DropOffRate = float64(step.DropOff) / float64(previous)
+		}
+		group.Steps[n] = step
+	}
+	return group
+}
+
+// parseFilter parses a filter of a request body, as a validation error at the location when invalid.
+func parseFilter(location, input string) (filter.Filter, error) {
+	f, err := filter.Parse(input)
+	if err != nil {
+		return filter.Filter{}, huma.Error422UnprocessableEntity("validation failed", &huma.ErrorDetail{Location: location, Message: filterMessage(err), Value: input})
+	}
+	return f, nil
+}
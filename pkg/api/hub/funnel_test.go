@@ -0,0 +1,123 @@
+package hub_test
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/ponrove/octobe/driver/clickhouse/mock"
+	"github.com/ponrove/ponrove-backend/pkg/api/hub"
+)
+
+func (suite *HubAPITestSuite) TestFunnel() {
+	conn, driver := setupDB(suite.T())
+	conn.ExpectQuery("windowFunnel(1800)").WillReturnRows(mock.NewMockRows([]string{"value", "step_1", "step_2", "step_3", "median_2", "median_3"}).
+		AddRow("", uint64(200), uint64(50), uint64(0), float64(42500), math.NaN()),
+	)
+	conn.ExpectQuery("argMinIf(referrer_host, event_timestamp").WillReturnRows(mock.NewMockRows([]string{"value", "step_1", "step_2", "median_2"}).
+		AddRow("google.com", uint64(80), uint64(20), float64(1000)).
+		AddRow("", uint64(0), uint64(0), math.NaN()),
+	)
+	srv := suite.startServer(driver)
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/api/hub/projects/project-1/funnel", "application/json", strings.NewReader(`{
+		"from": "2025-06-16T00:00:00Z",
+		"to": "2025-06-17T00:00:00Z",
+		"window_s": 1800,
+		"steps": [
+			{"label": "Pricing", "type": "pageview", "match": "/pricing*"},
+			{"type": "event", "match": "signup", "filter": "custom_properties.plan = pro"},
+			{"type": "event", "match": "purchase"}
+		]
+	}`))
+	suite.NoError(err)
+	defer resp.Body.Close()
+	suite.Equal(http.StatusOK, resp.StatusCode)
+
+	var body hub.FunnelResponse
+	suite.NoError(json.NewDecoder(resp.Body).Decode(&body.Body))
+	seconds := func(s float64) *float64 { return &s }
+	suite.Equal("visitor", body.Body.By)
+	suite.Equal([]hub.FunnelGroup{{
+		Value: "",
+		Steps: []hub.FunnelStep{
+			{Label: "Pricing", Actors: 200, ConversionRate: 1, StepConversionRate: 1},
+			{Label: "signup", Actors: 50, ConversionRate: 0.25, StepConversionRate: 0.25, DropOff: 150, DropOffRate: 0.75, MedianTimeS: seconds(42.5)},
+			{Label: "purchase", Actors: 0, DropOff: 50, DropOffRate: 1},
+		},
+	}}, body.Body.Groups)
+
+	resp, err = http.Post(srv.URL+"/api/hub/projects/project-1/funnel", "application/json", strings.NewReader(`{
+		"from": "2025-06-16T00:00:00Z",
+		"to": "2025-06-17T00:00:00Z",
+		"window_s": 600,
+		"by": "session",
+		"breakdown": "referrer_host",
+		"steps": [
+			{"type": "pageview", "match": "/"},
+			{"type": "pageview", "match": "/signup"}
+		]
+	}`))
+	suite.NoError(err)
+	defer resp.Body.Close()
+	suite.Equal(http.StatusOK, resp.StatusCode)
+
+	body = hub.FunnelResponse{}
+	suite.NoError(json.NewDecoder(resp.Body).Decode(&body.Body))
+	suite.Equal("session", body.Body.By)
+	suite.Equal("referrer_host", body.Body.Breakdown)
+	suite.Equal([]hub.FunnelGroup{
+		{
+			Value: "google.com",
+			Steps: []hub.FunnelStep{
+				{Label: "/", Actors: 80, ConversionRate: 1, StepConversionRate: 1},
+				{Label: "/signup", Actors: 20, ConversionRate: 0.25, StepConversionRate: 0.25, DropOff: 60, DropOffRate: 0.75, MedianTimeS: seconds(1)},
+			},
+		},
+		{
+			Value: "",
+			Steps: []hub.FunnelStep{{Label: "/"}, {Label: "/signup"}},
+		},
+	}, body.Body.Groups)
+
+	suite.NoError(conn.AllExpectationsMet())
+}
+
+func (suite *HubAPITestSuite) TestFunnelValidation() {
+	_, driver := setupDB(suite.T())
+	srv := suite.startServer(driver)
+	defer srv.Close()
+
+	steps := `[{"type": "pageview", "match": "/"}, {"type": "event", "match": "signup"}]`
+	for _, tc := range []struct {
+		name     string
+		body     string
+		location string
+	}{
+		{"range reversed", `{"from": "2025-06-17T00:00:00Z", "to": "2025-06-16T00:00:00Z", "window_s": 60, "steps": ` + steps + `}`, "body.to"},
+		{"single step", `{"from": "2025-06-16T00:00:00Z", "to": "2025-06-17T00:00:00Z", "window_s": 60, "steps": [{"type": "pageview", "match": "/"}]}`, "body.steps"},
+		{"window too long", `{"from": "2025-06-16T00:00:00Z", "to": "2025-06-17T00:00:00Z", "window_s": 7776001, "steps": ` + steps + `}`, "body.window_s"},
+		{"unknown step type", `{"from": "2025-06-16T00:00:00Z", "to": "2025-06-17T00:00:00Z", "window_s": 60, "steps": [{"type": "click", "match": "/"}, {"type": "event", "match": "signup"}]}`, "body.steps[0].type"},
+		{"invalid step filter", `{"from": "2025-06-16T00:00:00Z", "to": "2025-06-17T00:00:00Z", "window_s": 60, "steps": [{"type": "pageview", "match": "/"}, {"type": "event", "match": "signup", "filter": "password = x"}]}`, "body.steps[1].filter"},
+		{"invalid filter", `{"from": "2025-06-16T00:00:00Z", "to": "2025-06-17T00:00:00Z", "window_s": 60, "filter": "country_code =", "steps": ` + steps + `}`, "body.filter"},
+		{"unknown breakdown", `{"from": "2025-06-16T00:00:00Z", "to": "2025-06-17T00:00:00Z", "window_s": 60, "breakdown": "visitor_fingerprint", "steps": ` + steps + `}`, "body.breakdown"},
+	} {
+		suite.Run(tc.name, func() {
+			resp, err := http.Post(srv.URL+"/api/hub/projects/project-1/funnel", "application/json", strings.NewReader(tc.body))
+			suite.NoError(err)
+			defer resp.Body.Close()
+			suite.Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+
+			var body struct {
+				Errors []struct {
+					Location string `json:"location"`
+				} `json:"errors"`
+			}
+			suite.NoError(json.NewDecoder(resp.Body).Decode(&body))
+			suite.Require().NotEmpty(body.Errors)
+			suite.Equal(tc.location, body.Errors[0].Location)
+		})
+	}
+}
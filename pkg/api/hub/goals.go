@@ -0,0 +1,310 @@
+package hub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/ponrove/ponrove-backend/internal/analytics"
+	"github.com/ponrove/ponrove-backend/internal/filter"
+	"github.com/ponrove/ponrove-backend/internal/goals"
+	"github.com/shopspring/decimal"
+)
+
+// maxGoals is the most goals of a project.
+const maxGoals = 100
+
+// GoalResource is a goal of a project.
+type GoalResource struct {
+	GoalID    string    `json:"goal_id" doc:"Identifier of the goal."`
+	ProjectID string    `json:"project_id" doc:"Identifier of the project the goal belongs to."`
+	Name      string    `json:"name" doc:"Display name of the goal."`
+	Type      string    `json:"type" doc:"Whether pageviews of paths or events of a name complete the goal."`
+	Match     string    `json:"match" doc:"Path of the pageviews, * matching any characters, or name of the events completing the goal."`
+	Filter    string    `json:"filter" doc:"Filter the events completing the goal match, empty for every event."`
+	Value     *float64  `json:"value,omitempty" doc:"Monetary value of a completion of the goal, omitted when the goal has none."`
+	Currency  string    `json:"currency,omitempty" doc:"ISO 4217 code of the reporting currency of the value, omitted when the goal has none."`
+	CreatedAt time.Time `json:"created_at" doc:"When the goal was created."`
+	UpdatedAt time.Time `json:"updated_at" doc:"When the goal was last changed."`
+}
+
+// GoalPayload is the body of a goal creation or update request.
+type GoalPayload struct {
+	Name   string   `json:"name" minLength:"1" maxLength:"256" doc:"Display name of the goal."`
+	Type   string   `json:"type" enum:"pageview,event" doc:"Whether pageviews of paths or events of a name complete the goal."`
+	Match  string   `json:"match" minLength:"1" maxLength:"1024" doc:"Path of the pageviews, * matches any characters, e.g. /thanks/*. Name of the events for event goals."`
+	Filter string   `json:"filter,omitempty" maxLength:"2000" doc:"Filter the events completing the goal match, e.g. custom_properties.plan = \"pro\"."`
+	Value  *float64 `json:"value,omitempty" minimum:"0" maximum:"99999999999999" doc:"Monetary value of a completion of the goal in the reporting currency, with at most 4 fractional digits."`
+}
+
+// GoalConversion reports how often a goal was completed.
+type GoalConversion struct {
+	GoalID         string   `json:"goal_id" doc:"Identifier of the goal."`
+	Name           string   `json:"name" doc:"Display name of the goal."`
+	Conversions    uint64   `json:"conversions" doc:"Number of unique visitors completing the goal."`
+	Completions    uint64   `json:"completions" doc:"Number of events completing the goal."`
+	ConversionRate float64  `json:"conversion_rate" doc:"Share of the visitors completing the goal, between 0 and 1."`
+	Value          *float64 `json:"value,omitempty" doc:"Value of the completions in the reporting currency, omitted when the goal has none."`
+}
+
+// GoalsParam selects the goals a query reports the conversions of.
+type GoalsParam struct {
+	Goals []string `query:"goals" maxItems:"10" doc:"Identifiers of the goals to report the conversions of, separated by commas."`
+}
+
+type (
+	GoalListRequest struct {
+		ProjectID string `path:"project_id" maxLength:"128" doc:"Identifier of the project."`
+	}
+	GoalListResponse struct {
+		Body struct {
+			Goals []GoalResource `json:"goals" doc:"Every goal of the project, ordered by ID."`
+		}
+	}
+	GoalCreateRequest struct {
+		ProjectID string `path:"project_id" maxLength:"128" doc:"Identifier of the project."`
+		Body      GoalPayload
+	}
+	GoalRequest struct {
+		ProjectID string `path:"project_id" maxLength:"128" doc:"Identifier of the project."`
+		GoalID    string `path:"goal_id" maxLength:"128" doc:"Identifier of the goal."`
+	}
+	GoalUpdateRequest struct {
+		ProjectID string `path:"project_id" maxLength:"128" doc:"Identifier of the project."`
+		GoalID    string `path:"goal_id" maxLength:"128" doc:"Identifier of the goal."`
+		Body      GoalPayload
+	}
+	GoalResponse struct {
+		Status int `header:"-"`
+		Body   GoalResource
+	}
+	GoalDeleteResponse struct{}
+)
+
+// RegisterGoalEndpoints registers the endpoints managing the goals of a project, whose conversions the time series and
+// breakdowns report.
+func (a *server) RegisterGoalEndpoints(api huma.API) {
+	huma.Register(api, huma.Operation{
+		OperationID: "List Goals",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/goals",
+		Tags:        []string{"Goals"},
+	}, func(ctx context.Context, i *GoalListRequest) (*GoalListResponse, error) {
+		list, err := a.goals.List(ctx, i.ProjectID)
+		if err != nil {
+			return nil, err
+		}
+
+		resp := &GoalListResponse{}
+		resp.Body.Goals = make([]GoalResource, 0, len(list))
+		for _, g := range list {
+			resp.Body.Goals = append(resp.Body.Goals, a.goalResource(g))
+		}
+		return resp, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "Create Goal",
+		Method:        http.MethodPost,
+		Path:          "/projects/{project_id}/goals",
+		Tags:          []string{"Goals"},
+		DefaultStatus: http.StatusCreated,
+		Description:   fmt.Sprintf("Creates a goal of the project, completed by pageviews of paths or events of a name. Projects have at most %d goals.", maxGoals),
+	}, func(ctx context.Context, i *GoalCreateRequest) (*GoalResponse, error) {
+		f, err := parseFilter("body.filter", i.Body.Filter)
+		if err != nil {
+			return nil, err
+		}
+
+		list, err := a.goals.List(ctx, i.ProjectID)
+		if err != nil {
+			return nil, err
+		}
+		if len(list) >= maxGoals {
+			return nil, huma.Error422UnprocessableEntity(fmt.Sprintf("project already has %d goals", maxGoals))
+		}
+
+		id, err := goals.NewID()
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now().UTC().Truncate(time.Millisecond)
+		g := goals.Goal{
+			ID:        id,
+			ProjectID: i.ProjectID,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if err := setGoal(&g, i.Body, f); err != nil {
+			return nil, err
+		}
+
+		err = a.goals.Create(ctx, g)
+		if err != nil {
+			return nil, err
+		}
+
+		return &GoalResponse{Status: http.StatusCreated, Body: a.goalResource(g)}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "Get Goal",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/goals/{goal_id}",
+		Tags:        []string{"Goals"},
+	}, func(ctx context.Context, i *GoalRequest) (*GoalResponse, error) {
+		g, err := a.goals.Get(ctx, i.ProjectID, i.GoalID)
+		if errors.Is(err, goals.ErrNotFound) {
+			return nil, huma.Error404NotFound("goal not found")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return &GoalResponse{Status: http.StatusOK, Body: a.goalResource(g)}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "Update Goal",
+		Method:      http.MethodPut,
+		Path:        "/projects/{project_id}/goals/{goal_id}",
+		Tags:        []string{"Goals"},
+	}, func(ctx context.Context, i *GoalUpdateRequest) (*GoalResponse, error) {
+		f, err := parseFilter("body.filter", i.Body.Filter)
+		if err != nil {
+			return nil, err
+		}
+
+		g, err := a.goals.Get(ctx, i.ProjectID, i.GoalID)
+		if errors.Is(err, goals.ErrNotFound) {
+			return nil, huma.Error404NotFound("goal not found")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		g.UpdatedAt = time.Now().UTC().Truncate(time.Millisecond)
+		if err := setGoal(&g, i.Body, f); err != nil {
+			return nil, err
+		}
+
+		err = a.goals.Update(ctx, g)
+		if errors.Is(err, goals.ErrNotFound) {
+			return nil, huma.Error404NotFound("goal not found")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return &GoalResponse{Status: http.StatusOK, Body: a.goalResource(g)}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "Delete Goal",
+		Method:        http.MethodDelete,
+		Path:          "/projects/{project_id}/goals/{goal_id}",
+		Tags:          []string{"Goals"},
+		DefaultStatus: http.StatusNoContent,
+	}, func(ctx context.Context, i *GoalRequest) (*GoalDeleteResponse, error) {
+		err := a.goals.Delete(ctx, i.ProjectID, i.GoalID)
+		if errors.Is(err, goals.ErrNotFound) {
+			return nil, huma.Error404NotFound("goal not found")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return &GoalDeleteResponse{}, nil
+	})
+}
+
+// setGoal sets the goal from the payload and its parsed filter, stored in its canonical form, as a validation error
+// when the goal can't be matched.
+func setGoal(g *goals.Goal, payload GoalPayload, f filter.Filter) error {
+	g.Name = payload.Name
+	g.Type = goals.Type(payload.Type)
+	g.Match = payload.Match
+	g.Filter = f.String()
+	g.Value = nil
+	if payload.Value != nil {
+		value := decimal.NewFromFloat(*payload.Value)
+		if !value.Equal(value.Round(4)) {
+			return huma.Error422UnprocessableEntity("validation failed", &huma.ErrorDetail{Location: "body.value", Message: "expected at most 4 fractional digits", Value: *payload.Value})
+		}
+		g.Value = &value
+	}
+
+	if _, err := g.Condition(); err != nil {
+		return huma.Error422UnprocessableEntity("validation failed", &huma.ErrorDetail{Location: "body.match", Message: err.Error(), Value: payload.Match})
+	}
+	return nil
+}
+
+// load returns the goals of the project, and the conditions of the events completing them, as a validation error of
+// the query when a goal is unknown.
+func (p GoalsParam) load(ctx context.Context, store goals.Store, projectID string) ([]goals.Goal, []filter.Filter, error) {
+	list := make([]goals.Goal, 0, len(p.Goals))
+	conditions := make([]filter.Filter, 0, len(p.Goals))
+	for _, id := range p.Goals {
+		g, err := store.Get(ctx, projectID, id)
+		if errors.Is(err, goals.ErrNotFound) {
+			return nil, nil, huma.Error422UnprocessableEntity("validation failed", &huma.ErrorDetail{Location: "query.goals", Message: "unknown goal", Value: id})
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		condition, err := g.Condition()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to compile goal %s: %w", g.ID, err)
+		}
+		list = append(list, g)
+		conditions = append(conditions, condition)
+	}
+	return list, conditions, nil
+}
+
+// goalConversions converts the conversions of the goals out of the visitors, nil without goals.
+func goalConversions(list []goals.Goal, conversions []analytics.GoalConversions, visitors uint64) []GoalConversion {
+	if len(list) == 0 {
+		return nil
+	}
+
+	out := make([]GoalConversion, len(list))
+	for n, g := range list {
+		c := conversions[n]
+		out[n] = GoalConversion{GoalID: g.ID, Name: g.Name, Conversions: c.Visitors, Completions: c.Completions}
+		if visitors > 0 {
+			out[n].ConversionRate = float64(c.Visitors) / float64(visitors)
+		}
+		if g.Value != nil {
+			value := money(g.Value.Mul(decimal.NewFromUint64(c.Completions)))
+			out[n].Value = &value
+		}
+	}
+	return out
+}
+
+// goalResource converts a goal into its resource, its value in the reporting currency.
+func (a *server) goalResource(g goals.Goal) GoalResource {
+	r := GoalResource{
+		GoalID:    g.ID,
+		ProjectID: g.ProjectID,
+		Name:      g.Name,
+		Type:      string(g.Type),
+		Match:     g.Match,
+		Filter:    g.Filter,
+		CreatedAt: g.CreatedAt,
+		UpdatedAt: g.UpdatedAt,
+	}
+	if g.Value != nil {
+		value := money(*g.Value)
+		r.Value = &value
+		r.Currency = a.rates.Currency()
+	}
+	return r
+}
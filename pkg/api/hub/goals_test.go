@@ -0,0 +1,121 @@
+package hub_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/ponrove/octobe/driver/clickhouse/mock"
+	"github.com/ponrove/ponrove-backend/internal/goals"
+	"github.com/ponrove/ponrove-backend/pkg/api/hub"
+	"github.com/shopspring/decimal"
+)
+
+func (suite *HubAPITestSuite) TestGoalCRUD() {
+	_, driver := setupDB(suite.T())
+	store := goals.NewMemoryStore()
+	srv := suite.startServer(driver, hub.WithGoalStore(store))
+	defer srv.Close()
+	base := srv.URL + "/api/hub/projects/project-1/goals"
+
+	// Filters are stored in their canonical form.
+	var created hub.GoalResource
+	resp := suite.sendJSON(http.MethodPost, base, `{"name": "Pro signup", "type": "event", "match": "signup", "filter": "custom_properties.plan=pro", "value": 49}`, &created)
+	suite.Equal(http.StatusCreated, resp.StatusCode)
+	suite.Regexp(`^goal_[0-9a-f]{16}$`, created.GoalID)
+	suite.Equal("project-1", created.ProjectID)
+	suite.Equal(`custom_properties.plan = "pro"`, created.Filter)
+	suite.Require().NotNil(created.Value)
+	suite.Equal(49.0, *created.Value)
+	suite.Equal("USD", created.Currency)
+
+	var list struct {
+		Goals []hub.GoalResource `json:"goals"`
+	}
+	resp = suite.sendJSON(http.MethodGet, base, "", &list)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	suite.Equal([]hub.GoalResource{created}, list.Goals)
+
+	// Goals belong to their project.
+	resp = suite.sendJSON(http.MethodGet, srv.URL+"/api/hub/projects/project-2/goals/"+created.GoalID, "", nil)
+	suite.Equal(http.StatusNotFound, resp.StatusCode)
+
+	var updated hub.GoalResource
+	resp = suite.sendJSON(http.MethodPut, base+"/"+created.GoalID, `{"name": "Thanks", "type": "pageview", "match": "/thanks/*"}`, &updated)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	suite.Equal("pageview", updated.Type)
+	suite.Empty(updated.Filter)
+	suite.Nil(updated.Value)
+	suite.Empty(updated.Currency)
+	suite.Equal(created.CreatedAt, updated.CreatedAt)
+
+	resp = suite.sendJSON(http.MethodDelete, base+"/"+created.GoalID, "", nil)
+	suite.Equal(http.StatusNoContent, resp.StatusCode)
+	resp = suite.sendJSON(http.MethodDelete, base+"/"+created.GoalID, "", nil)
+	suite.Equal(http.StatusNotFound, resp.StatusCode)
+
+	for _, body := range []string{
+		`{"name": "Signup", "type": "click", "match": "signup"}`,
+		`{"name": "Signup", "type": "event", "match": ""}`,
+		`{"name": "Signup", "type": "event", "match": "signup", "filter": "password = x"}`,
+		`{"name": "Signup", "type": "event", "match": "signup", "value": -1}`,
+		`{"name": "Signup", "type": "event", "match": "signup", "value": 0.00001}`,
+	} {
+		resp = suite.sendJSON(http.MethodPost, base, body, nil)
+		suite.Equal(http.StatusUnprocessableEntity, resp.StatusCode, body)
+	}
+}
+
+func (suite *HubAPITestSuite) TestGoalConversions() {
+	value := decimal.RequireFromString("49.90")
+	store := goals.NewMemoryStore(
+		goals.Goal{ID: "goal_signup", ProjectID: "project-1", Name: "Signup", Type: goals.TypeEvent, Match: "signup", Value: &value},
+		goals.Goal{ID: "goal_thanks", ProjectID: "project-1", Name: "Thanks", Type: goals.TypePageview, Match: "/thanks/*"},
+	)
+
+	conn, driver := setupDB(suite.T())
+	conn.ExpectQuery("countIf((event_name = ? AND url_path LIKE ?))").WillReturnRows(mock.NewMockRows([]string{"value", "visitors", "pageviews", "events", "sessions", "signup_visitors", "signup_completions", "thanks_visitors", "thanks_completions"}).
+		AddRow("google.com", uint64(200), uint64(300), uint64(400), uint64(220), uint64(20), uint64(25), uint64(10), uint64(10)),
+	)
+	conn.ExpectQuery("uniqExactIf(visitor_fingerprint, visitor_fingerprint != '' AND (event_name = ?))").WillReturnRows(mock.NewMockRows([]string{"series", "bucket", "pageviews", "visitors", "signup_visitors", "signup_completions"}).
+		AddRow(uint8(0), time.Date(2025, 6, 16, 0, 0, 0, 0, time.UTC), uint64(120), uint64(40), uint64(4), uint64(5)),
+	)
+	conn.ExpectQuery("GROUP BY session_id").WillReturnRows(mock.NewMockRows([]string{"series", "bucket", "sessions", "bounces", "sessions_with_views", "duration"}))
+	srv := suite.startServer(driver, hub.WithGoalStore(store))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/hub/projects/project-1/breakdown?dimension=referrer_host&goals=goal_signup,goal_thanks&from=2025-06-16T00:00:00Z&to=2025-06-17T00:00:00Z")
+	suite.NoError(err)
+	defer resp.Body.Close()
+	suite.Equal(http.StatusOK, resp.StatusCode)
+
+	var breakdown hub.BreakdownResponse
+	suite.NoError(json.NewDecoder(resp.Body).Decode(&breakdown.Body))
+	signupValue := 1247.5
+	suite.Require().Len(breakdown.Body.Groups, 1)
+	suite.Equal([]hub.GoalConversion{
+		{GoalID: "goal_signup", Name: "Signup", Conversions: 20, Completions: 25, ConversionRate: 0.1, Value: &signupValue},
+		{GoalID: "goal_thanks", Name: "Thanks", Conversions: 10, Completions: 10, ConversionRate: 0.05},
+	}, breakdown.Body.Groups[0].Goals)
+
+	resp, err = http.Get(srv.URL + "/api/hub/projects/project-1/timeseries?goals=goal_signup&from=2025-06-16T00:00:00Z&to=2025-06-18T00:00:00Z")
+	suite.NoError(err)
+	defer resp.Body.Close()
+	suite.Equal(http.StatusOK, resp.StatusCode)
+
+	var timeseries hub.TimeseriesResponse
+	suite.NoError(json.NewDecoder(resp.Body).Decode(&timeseries.Body))
+	bucketValue := 249.5
+	zero := 0.0
+	suite.Require().Len(timeseries.Body.Points, 2)
+	suite.Equal([]hub.GoalConversion{{GoalID: "goal_signup", Name: "Signup", Conversions: 4, Completions: 5, ConversionRate: 0.1, Value: &bucketValue}}, timeseries.Body.Points[0].Goals)
+	suite.Equal([]hub.GoalConversion{{GoalID: "goal_signup", Name: "Signup", Value: &zero}}, timeseries.Body.Points[1].Goals)
+
+	// Unknown goals, such as those of other projects, are rejected.
+	resp, err = http.Get(srv.URL + "/api/hub/projects/project-2/breakdown?dimension=referrer_host&goals=goal_signup&from=2025-06-16T00:00:00Z&to=2025-06-17T00:00:00Z")
+	suite.NoError(err)
+	defer resp.Body.Close()
+	suite.Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+
+	suite.NoError(conn.AllExpectationsMet())
+}
@@ -2,29 +2,52 @@ package hub
 
 import (
 	"context"
+	"fmt"
+	"math"
 	"net/http"
+	"time"
 
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/open-feature/go-sdk/openfeature"
 	"github.com/ponrove/configura"
 	"github.com/ponrove/octobe/driver/clickhouse"
+	"github.com/ponrove/ponrove-backend/internal/currency"
 	"github.com/ponrove/ponrove-backend/internal/database"
+	"github.com/ponrove/ponrove-backend/internal/goals"
+	"github.com/ponrove/ponrove-backend/internal/projects"
+	"github.com/ponrove/ponrove-backend/internal/session"
+	"github.com/ponrove/ponrove-backend/internal/shutdown"
 	"github.com/ponrove/ponrunner"
 )
 
 const (
 	HUB_API_TEST_FLAG configura.Variable[bool] = "HUB_API_TEST_FLAG" // Bootstrap flag, will become obsolete
+
+	// Bounds of the retention set per project, in days
+	HUB_RETENTION_MIN_DAYS configura.Variable[int64] = "HUB_RETENTION_MIN_DAYS"
+	HUB_RETENTION_MAX_DAYS configura.Variable[int64] = "HUB_RETENTION_MAX_DAYS"
+
+	// Reporting currency of revenue, and the comma separated CODE=RATE exchange rates converting other currencies into
+	// it, e.g. EUR=1.08,SEK=0.094 where a rate is the value of one unit of the currency in the reporting currency
+	HUB_REVENUE_CURRENCY configura.Variable[string] = "HUB_REVENUE_CURRENCY"
+	HUB_REVENUE_RATES    configura.Variable[string] = "HUB_REVENUE_RATES"
 )
 
 type server struct {
 	openfeatureClient *openfeature.Client
 	config            configura.Config
 	clickhouse        clickhouse.Driver
+	rebuilds          *rebuildJobs
+	projects          projects.Store
+	goals             goals.Store
+	rates             currency.Rates
 }
 
 // ingestionAPIConfig holds the configuration for the Ingestion API.
 type hubAPIConfig struct {
 	clickhouseDriver clickhouse.Driver
+	projectStore     projects.Store
+	goalStore        goals.Store
 }
 
 // Option is a function that modifies the api configuration.
@@ -37,6 +60,22 @@ func WithClickhouseDriver(driver clickhouse.Driver) Option {
 	}
 }
 
+// WithProjectStore allows setting a custom store of the project registry, which defaults to the ClickHouse driver of
+// the hub API.
+func WithProjectStore(store projects.Store) Option {
+	return func(cfg *hubAPIConfig) {
+		cfg.projectStore = store
+	}
+}
+
+// WithGoalStore allows setting a custom store of the goals of the projects, which defaults to the ClickHouse driver of
+// the hub API.
+func WithGoalStore(store goals.Store) Option {
+	return func(cfg *hubAPIConfig) {
+		cfg.goalStore = store
+	}
+}
+
 // Register creates a new instance of the Hub API.
 func Register(opts ...Option) ponrunner.APIBundle {
 	// Init a default server configuration, then apply any options passed in.
@@ -48,11 +87,26 @@ func Register(opts ...Option) ponrunner.APIBundle {
 	return func(cfg configura.Config, api huma.API) error {
 		err := cfg.ConfigurationKeysRegistered(
 			HUB_API_TEST_FLAG,
+			HUB_RETENTION_MIN_DAYS,
+			HUB_RETENTION_MAX_DAYS,
+			HUB_REVENUE_CURRENCY,
+			HUB_REVENUE_RATES,
+			session.SESSION_TIMEOUT_MS,
 		)
 		if err != nil {
 			return err
 		}
 
+		minRetention, maxRetention := cfg.Int64(HUB_RETENTION_MIN_DAYS), cfg.Int64(HUB_RETENTION_MAX_DAYS)
+		if minRetention < 1 || minRetention > maxRetention || maxRetention > math.MaxUint32 {
+			return fmt.Errorf("invalid retention bounds, expected 1 <= %s <= %s, got %d and %d", HUB_RETENTION_MIN_DAYS, HUB_RETENTION_MAX_DAYS, minRetention, maxRetention)
+		}
+
+		rates, err := currency.ParseRates(cfg.String(HUB_REVENUE_CURRENCY), cfg.String(HUB_REVENUE_RATES))
+		if err != nil {
+			return fmt.Errorf("invalid %s or %s: %w", HUB_REVENUE_CURRENCY, HUB_REVENUE_RATES, err)
+		}
+
 		if apiConfig.clickhouseDriver == nil {
 			clickhouseDriver, err := database.NewClickhouse(cfg)
 			if err != nil {
@@ -61,9 +115,25 @@ func Register(opts ...Option) ponrunner.APIBundle {
 			apiConfig.clickhouseDriver = clickhouseDriver
 		}
 
+		if apiConfig.projectStore == nil {
+			apiConfig.projectStore = projects.NewClickHouseStore(apiConfig.clickhouseDriver)
+		}
+
+		if apiConfig.goalStore == nil {
+			apiConfig.goalStore = goals.NewClickHouseStore(apiConfig.clickhouseDriver)
+		}
+
+		rebuilds := newRebuildJobs(apiConfig.clickhouseDriver, time.Duration(cfg.Int64(session.SESSION_TIMEOUT_MS))*time.Millisecond)
+		shutdown.Register("session rebuild jobs", rebuilds.Close)
+
 		huma.AutoRegister(huma.NewGroup(api, "/api/hub"), &server{
 			openfeatureClient: openfeature.NewClient("hub-api"),
 			config:            cfg,
+			clickhouse:        apiConfig.clickhouseDriver,
+			rebuilds:          rebuilds,
+			projects:          apiConfig.projectStore,
+			goals:             apiConfig.goalStore,
+			rates:             rates,
 		})
 		return err
 	}
@@ -3,12 +3,14 @@ package hub_test
 import (
 	"encoding/json"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/ponrove/configura"
 	"github.com/ponrove/octobe"
 	"github.com/ponrove/octobe/driver/clickhouse"
 	"github.com/ponrove/octobe/driver/clickhouse/mock"
+	"github.com/ponrove/ponrove-backend/internal/session"
 	"github.com/ponrove/ponrove-backend/pkg/api/hub"
 	"github.com/ponrove/ponrove-backend/test/testserver"
 	"github.com/stretchr/testify/suite"
@@ -28,6 +30,41 @@ type HubAPITestSuite struct {
 	suite.Suite
 }
 
+// testConfig returns the configuration of the hub API used by the tests.
+func (suite *HubAPITestSuite) testConfig(testFlag bool) configura.Config {
+	cfg := configura.NewConfigImpl()
+	err := configura.WriteConfiguration(cfg, map[configura.Variable[bool]]bool{
+		hub.HUB_API_TEST_FLAG: testFlag,
+	})
+	suite.NoError(err)
+
+	err = configura.WriteConfiguration(cfg, map[configura.Variable[int64]]int64{
+		session.SESSION_TIMEOUT_MS: session.DefaultTimeout.Milliseconds(),
+		hub.HUB_RETENTION_MIN_DAYS: 30,
+		hub.HUB_RETENTION_MAX_DAYS: 730,
+	})
+	suite.NoError(err)
+
+	err = configura.WriteConfiguration(cfg, map[configura.Variable[string]]string{
+		hub.HUB_REVENUE_CURRENCY: "USD",
+		hub.HUB_REVENUE_RATES:    "EUR=1.08,SEK=0.094",
+	})
+	suite.NoError(err)
+
+	return cfg
+}
+
+// startServer starts a test server with the hub API registered against the given driver, opts are applied after the
+// driver.
+func (suite *HubAPITestSuite) startServer(driver clickhouse.Driver, opts ...hub.Option) *httptest.Server {
+	srv, err := testserver.CreateServer(
+		testserver.WithConfig(suite.testConfig(false)),
+		testserver.WithAPIBundle(hub.Register(append([]hub.Option{hub.WithClickhouseDriver(driver)}, opts...)...)),
+	)
+	suite.NoError(err)
+	return srv
+}
+
 // Bootstrap Test for foundational logic, this will become obsolete.
 func (suite *HubAPITestSuite) TestRootEndpointFeatureFlagTrue() {
 	var body struct {
@@ -35,15 +72,9 @@ func (suite *HubAPITestSuite) TestRootEndpointFeatureFlagTrue() {
 		Message         string `json:"message"`
 		TestFeatureFlag bool   `json:"test_feature_flag"`
 	}
-	cfg := configura.NewConfigImpl()
-	err := configura.WriteConfiguration(cfg, map[configura.Variable[bool]]bool{
-		hub.HUB_API_TEST_FLAG: true,
-	})
-	suite.NoError(err)
-
 	_, driver := setupDB(suite.T())
 	srv, err := testserver.CreateServer(
-		testserver.WithConfig(cfg),
+		testserver.WithConfig(suite.testConfig(true)),
 		testserver.WithAPIBundle(hub.Register(hub.WithClickhouseDriver(driver))),
 	)
 	suite.NoError(err)
@@ -67,15 +98,9 @@ func (suite *HubAPITestSuite) TestRootEndpointFeatureFlagFalse() {
 		Message         string `json:"message"`
 		TestFeatureFlag bool   `json:"test_feature_flag"`
 	}
-	cfg := configura.NewConfigImpl()
-	err := configura.WriteConfiguration(cfg, map[configura.Variable[bool]]bool{
-		hub.HUB_API_TEST_FLAG: false,
-	})
-	suite.NoError(err)
-
 	_, driver := setupDB(suite.T())
 	srv, err := testserver.CreateServer(
-		testserver.WithConfig(cfg),
+		testserver.WithConfig(suite.testConfig(false)),
 		testserver.WithAPIBundle(hub.Register(hub.WithClickhouseDriver(driver))),
 	)
 	suite.NoError(err)
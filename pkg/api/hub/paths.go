@@ -0,0 +1,91 @@
+package hub
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/ponrove/ponrove-backend/internal/analytics"
+)
+
+// Path is a sequence of steps of sessions.
+type Path struct {
+	Steps    []string `json:"steps" doc:"Steps of the path, in order."`
+	Sessions uint64   `json:"sessions" doc:"Number of sessions going through the path."`
+	Share    float64  `json:"share" doc:"Share of the sessions going through the start or end step going through the path, between 0 and 1."`
+}
+
+type (
+	PathsRequest struct {
+		ProjectID string    `path:"project_id" maxLength:"128" doc:"Identifier of the project."`
+		From      time.Time `query:"from" required:"true" doc:"Start of the range, inclusive."`
+		To        time.Time `query:"to" required:"true" doc:"End of the range, exclusive."`
+		Step      string    `query:"step" default:"url_path" enum:"url_path,event_name" doc:"Whether the steps are the paths of the pages viewed or the names of the events sent."`
+		Start     string    `query:"start" maxLength:"1024" doc:"Step the paths start at, e.g. /pricing. Paths start at the first step of the session when neither start nor end is set."`
+		End       string    `query:"end" maxLength:"1024" doc:"Step the paths end at, e.g. /signup. Exclusive with start."`
+		Depth     int       `query:"depth" default:"5" minimum:"2" maximum:"10" doc:"Maximum number of steps of a path, the start or end step included."`
+		Limit     int       `query:"limit" default:"10" minimum:"1" maximum:"100" doc:"Maximum number of paths."`
+		MinShare  float64   `query:"min_share" default:"0" minimum:"0" maximum:"1" doc:"Leave out the paths of a smaller share of the sessions going through the start or end step, between 0 and 1."`
+		FilterParam
+	}
+	PathsResponse struct {
+		Body struct {
+			Step     string `json:"step" doc:"What the steps of the paths are."`
+			Sessions uint64 `json:"sessions" doc:"Number of sessions going through the start or end step, every session with steps without them."`
+			Paths    []Path `json:"paths" doc:"Most common paths, those of the most sessions first."`
+		}
+	}
+)
+
+// RegisterPathEndpoints registers the endpoints exploring the paths sessions take through a project.
+func (a *server) RegisterPathEndpoints(api huma.API) {
+	huma.Register(api, huma.Operation{
+		OperationID: "Get Paths",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/paths",
+		Tags:        []string{"Paths"},
+		Description: "Computes the most common sequences of pages viewed or events sent within sessions, starting or ending at the first occurrence of a step in the session. Repeated steps, such as reloads of a page, count as a single step.",
+	}, func(ctx context.Context, i *PathsRequest) (*PathsResponse, error) {
+		if !i.From.Before(i.To) {
+			return nil, huma.Error422UnprocessableEntity("validation failed", &huma.ErrorDetail{Location: "query.to", Message: "must be after from", Value: i.To})
+		}
+		if i.Start != "" && i.End != "" {
+			return nil, huma.Error422UnprocessableEntity("validation failed", &huma.ErrorDetail{Location: "query.end", Message: "cannot be set with start", Value: i.End})
+		}
+
+		f, err := i.parse()
+		if err != nil {
+			return nil, err
+		}
+
+		result, err := analytics.Paths(ctx, a.clickhouse, analytics.PathQuery{
+			ProjectID: i.ProjectID,
+			From:      i.From,
+			To:        i.To,
+			Filter:    f,
+			Step:      analytics.PathStep(i.Step),
+			Start:     i.Start,
+			End:       i.End,
+			Depth:     i.Depth,
+			Limit:     i.Limit,
+			MinShare:  i.MinShare,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		resp := &PathsResponse{}
+		resp.Body.Step = i.Step
+		resp.Body.Sessions = result.Sessions
+		resp.Body.Paths = make([]Path, 0, len(result.Paths))
+		for _, p := range result.Paths {
+			resp.Body.Paths = append(resp.Body.Paths, Path{
+				Steps:    p.Steps,
+				Sessions: p.Sessions,
+				Share:    float64(p.Sessions) / float64(result.Sessions),
+			})
+		}
+		return resp, nil
+	})
+}
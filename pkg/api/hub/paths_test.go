@@ -0,0 +1,71 @@
+package hub_test
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ponrove/octobe/driver/clickhouse/mock"
+	"github.com/ponrove/ponrove-backend/pkg/api/hub"
+)
+
+func (suite *HubAPITestSuite) TestPaths() {
+	columns := []string{"path", "sessions", "total"}
+	conn, driver := setupDB(suite.T())
+	conn.ExpectQuery("arraySlice(steps, anchor, 3)").WillReturnRows(mock.NewMockRows(columns).
+		AddRow([]string{"/pricing", "/signup", "/welcome"}, uint64(60), uint64(200)).
+		AddRow([]string{"/pricing"}, uint64(50), uint64(200)).
+		AddRow([]string{"/pricing", "/"}, uint64(9), uint64(200)),
+	)
+	conn.ExpectQuery("least(anchor, 5)").WillReturnRows(mock.NewMockRows(columns).
+		AddRow([]string{"page_view", "signup"}, uint64(30), uint64(40)),
+	)
+	srv := suite.startServer(driver)
+	defer srv.Close()
+
+	// Paths below the minimum share are left out.
+	resp, err := http.Get(srv.URL + "/api/hub/projects/project-1/paths?start=/pricing&depth=3&min_share=0.05&from=2025-06-16T00:00:00Z&to=2025-06-17T00:00:00Z")
+	suite.NoError(err)
+	defer resp.Body.Close()
+	suite.Equal(http.StatusOK, resp.StatusCode)
+
+	var body hub.PathsResponse
+	suite.NoError(json.NewDecoder(resp.Body).Decode(&body.Body))
+	suite.Equal("url_path", body.Body.Step)
+	suite.Equal(uint64(200), body.Body.Sessions)
+	suite.Equal([]hub.Path{
+		{Steps: []string{"/pricing", "/signup", "/welcome"}, Sessions: 60, Share: 0.3},
+		{Steps: []string{"/pricing"}, Sessions: 50, Share: 0.25},
+	}, body.Body.Paths)
+
+	resp, err = http.Get(srv.URL + "/api/hub/projects/project-1/paths?step=event_name&end=signup&from=2025-06-16T00:00:00Z&to=2025-06-17T00:00:00Z")
+	suite.NoError(err)
+	defer resp.Body.Close()
+	suite.Equal(http.StatusOK, resp.StatusCode)
+
+	body = hub.PathsResponse{}
+	suite.NoError(json.NewDecoder(resp.Body).Decode(&body.Body))
+	suite.Equal([]hub.Path{{Steps: []string{"page_view", "signup"}, Sessions: 30, Share: 0.75}}, body.Body.Paths)
+
+	suite.NoError(conn.AllExpectationsMet())
+}
+
+func (suite *HubAPITestSuite) TestPathsValidation() {
+	_, driver := setupDB(suite.T())
+	srv := suite.startServer(driver)
+	defer srv.Close()
+
+	for _, query := range []string{
+		"from=2025-06-17T00:00:00Z&to=2025-06-16T00:00:00Z",
+		"start=/pricing&end=/signup&from=2025-06-16T00:00:00Z&to=2025-06-17T00:00:00Z",
+		"step=referrer_host&from=2025-06-16T00:00:00Z&to=2025-06-17T00:00:00Z",
+		"depth=1&from=2025-06-16T00:00:00Z&to=2025-06-17T00:00:00Z",
+		"depth=11&from=2025-06-16T00:00:00Z&to=2025-06-17T00:00:00Z",
+		"min_share=1.5&from=2025-06-16T00:00:00Z&to=2025-06-17T00:00:00Z",
+		"filter=password+%3D+x&from=2025-06-16T00:00:00Z&to=2025-06-17T00:00:00Z",
+	} {
+		resp, err := http.Get(srv.URL + "/api/hub/projects/project-1/paths?" + query)
+		suite.NoError(err)
+		resp.Body.Close()
+		suite.Equal(http.StatusUnprocessableEntity, resp.StatusCode, query)
+	}
+}
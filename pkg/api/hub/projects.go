@@ -0,0 +1,253 @@
+package hub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/ponrove/ponrove-backend/internal/projects"
+)
+
+// maxAllowedHosts is the most hosts a project reports from.
+const maxAllowedHosts = 100
+
+// hostPattern matches a hostname, optionally prefixed by "*." to match every subdomain.
+var hostPattern = regexp.MustCompile(`^(\*\.)?[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?(\.[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?)*$`)
+
+// ProjectResource is a project of the registry.
+type ProjectResource struct {
+	ProjectID    string    `json:"project_id" doc:"Identifier of the project, the project_id of its events."`
+	Name         string    `json:"name" doc:"Display name of the project."`
+	IngestionKey string    `json:"ingestion_key" doc:"Public key sent with the reports of the project, embedded in its pages."`
+	AllowedHosts []string  `json:"allowed_hosts" doc:"Hostnames the project reports from, a leading *. matches every subdomain. Empty matches every host."`
+	CreatedAt    time.Time `json:"created_at" doc:"When the project was created."`
+	UpdatedAt    time.Time `json:"updated_at" doc:"When the project was last changed."`
+}
+
+// ProjectCreatePayload is the body of a project creation request.
+type ProjectCreatePayload struct {
+	ProjectID    string   `json:"project_id" minLength:"1" maxLength:"128" pattern:"^[A-Za-z0-9][A-Za-z0-9_.-]*$" doc:"Identifier of the project, the project_id of its events."`
+	Name         string   `json:"name" minLength:"1" maxLength:"256" doc:"Display name of the project."`
+	AllowedHosts []string `json:"allowed_hosts,omitempty" maxItems:"100" doc:"Hostnames the project reports from, a leading *. matches every subdomain. Empty matches every host."`
+}
+
+// ProjectUpdatePayload is the body of a project update request.
+type ProjectUpdatePayload struct {
+	Name               string   `json:"name" minLength:"1" maxLength:"256" doc:"Display name of the project."`
+	AllowedHosts       []string `json:"allowed_hosts,omitempty" maxItems:"100" doc:"Hostnames the project reports from, a leading *. matches every subdomain. Empty matches every host."`
+	RotateIngestionKey bool     `json:"rotate_ingestion_key,omitempty" doc:"Replace the ingestion key with a new one, e.g. when the key is abused from other sites."`
+}
+
+type (
+	ProjectListRequest  struct{}
+	ProjectListResponse struct {
+		Body struct {
+			Projects []ProjectResource `json:"projects" doc:"Every project, ordered by ID."`
+		}
+	}
+	ProjectCreateRequest struct {
+		Body ProjectCreatePayload
+	}
+	ProjectRequest struct {
+		ProjectID string `path:"project_id" maxLength:"128" doc:"Identifier of the project."`
+	}
+	ProjectUpdateRequest struct {
+		ProjectID string `path:"project_id" maxLength:"128" doc:"Identifier of the project."`
+		Body      ProjectUpdatePayload
+	}
+	ProjectResponse struct {
+		Status int `header:"-"`
+		Body   ProjectResource
+	}
+	ProjectDeleteResponse struct{}
+)
+
+// RegisterProjectEndpoints registers the endpoints managing the project registry. Ingestion caches the projects, so
+// changes take effect within its refresh interval.
+func (a *server) RegisterProjectEndpoints(api huma.API) {
+	huma.Register(api, huma.Operation{
+		OperationID: "List Projects",
+		Method:      http.MethodGet,
+		Path:        "/projects",
+		Tags:        []string{"Projects"},
+	}, func(ctx context.Context, i *ProjectListRequest) (*ProjectListResponse, error) {
+		list, err := a.projects.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		resp := &ProjectListResponse{}
+		resp.Body.Projects = make([]ProjectResource, 0, len(list))
+		for _, p := range list {
+			resp.Body.Projects = append(resp.Body.Projects, projectResource(p))
+		}
+		return resp, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "Create Project",
+		Method:        http.MethodPost,
+		Path:          "/projects",
+		Tags:          []string{"Projects"},
+		DefaultStatus: http.StatusCreated,
+		Description:   "Registers a project, generating its ingestion key.",
+	}, func(ctx context.Context, i *ProjectCreateRequest) (*ProjectResponse, error) {
+		hosts, err := normalizeHosts("body.allowed_hosts", i.Body.AllowedHosts)
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := projects.NewIngestionKey()
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now().UTC().Truncate(time.Millisecond)
+		p := projects.Project{
+			ID:           i.Body.ProjectID,
+			Name:         i.Body.Name,
+			IngestionKey: key,
+			AllowedHosts: hosts,
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		}
+		err = a.projects.Create(ctx, p)
+		if errors.Is(err, projects.ErrExists) {
+			return nil, huma.Error409Conflict("project already exists")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return &ProjectResponse{Status: http.StatusCreated, Body: projectResource(p)}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "Get Project",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}",
+		Tags:        []string{"Projects"},
+	}, func(ctx context.Context, i *ProjectRequest) (*ProjectResponse, error) {
+		p, err := a.projects.Get(ctx, i.ProjectID)
+		if errors.Is(err, projects.ErrNotFound) {
+			return nil, huma.Error404NotFound("project not found")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return &ProjectResponse{Status: http.StatusOK, Body: projectResource(p)}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "Update Project",
+		Method:      http.MethodPut,
+		Path:        "/projects/{project_id}",
+		Tags:        []string{"Projects"},
+	}, func(ctx context.Context, i *ProjectUpdateRequest) (*ProjectResponse, error) {
+		hosts, err := normalizeHosts("body.allowed_hosts", i.Body.AllowedHosts)
+		if err != nil {
+			return nil, err
+		}
+
+		p, err := a.projects.Get(ctx, i.ProjectID)
+		if errors.Is(err, projects.ErrNotFound) {
+			return nil, huma.Error404NotFound("project not found")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		p.Name = i.Body.Name
+		p.AllowedHosts = hosts
+		p.UpdatedAt = time.Now().UTC().Truncate(time.Millisecond)
+		if i.Body.RotateIngestionKey {
+			p.IngestionKey, err = projects.NewIngestionKey()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		err = a.projects.Update(ctx, p)
+		if errors.Is(err, projects.ErrNotFound) {
+			return nil, huma.Error404NotFound("project not found")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return &ProjectResponse{Status: http.StatusOK, Body: projectResource(p)}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "Delete Project",
+		Method:        http.MethodDelete,
+		Path:          "/projects/{project_id}",
+		Tags:          []string{"Projects"},
+		DefaultStatus: http.StatusNoContent,
+		Description:   "Removes the project from the registry, its events are kept until they expire.",
+	}, func(ctx context.Context, i *ProjectRequest) (*ProjectDeleteResponse, error) {
+		err := a.projects.Delete(ctx, i.ProjectID)
+		if errors.Is(err, projects.ErrNotFound) {
+			return nil, huma.Error404NotFound("project not found")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return &ProjectDeleteResponse{}, nil
+	})
+}
+
+// normalizeHosts lower cases and deduplicates the allowed hosts of a project, rejecting anything but hostnames, such as
+// URLs or hosts with a port.
+func normalizeHosts(location string, hosts []string) ([]string, error) {
+	if len(hosts) > maxAllowedHosts {
+		return nil, huma.Error422UnprocessableEntity("validation failed", &huma.ErrorDetail{
+			Location: location,
+			Message:  fmt.Sprintf("expected at most %d hosts", maxAllowedHosts),
+			Value:    len(hosts),
+		})
+	}
+
+	normalized := make([]string, 0, len(hosts))
+	seen := make(map[string]bool, len(hosts))
+	for index, host := range hosts {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if len(host) > 253 || !hostPattern.MatchString(host) {
+			return nil, huma.Error422UnprocessableEntity("validation failed", &huma.ErrorDetail{
+				Location: fmt.Sprintf("%s[%d]", location, index),
+				Message:  "expected a hostname, optionally prefixed by *.",
+				Value:    hosts[index],
+			})
+		}
+		if !seen[host] {
+			seen[host] = true
+			normalized = append(normalized, host)
+		}
+	}
+
+	return normalized, nil
+}
+
+// projectResource converts a project of the registry into its resource.
+func projectResource(p projects.Project) ProjectResource {
+	hosts := p.AllowedHosts
+	if hosts == nil {
+		hosts = []string{}
+	}
+
+	return ProjectResource{
+		ProjectID:    p.ID,
+		Name:         p.Name,
+		IngestionKey: p.IngestionKey,
+		AllowedHosts: hosts,
+		CreatedAt:    p.CreatedAt,
+		UpdatedAt:    p.UpdatedAt,
+	}
+}
@@ -0,0 +1,96 @@
+package hub_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/ponrove/ponrove-backend/internal/projects"
+	"github.com/ponrove/ponrove-backend/pkg/api/hub"
+)
+
+// sendJSON sends the body as JSON with the given method, decoding a successful response into out.
+func (suite *HubAPITestSuite) sendJSON(method, url, body string, out any) *http.Response {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	suite.Require().NoError(err)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	suite.Require().NoError(err)
+	defer resp.Body.Close()
+	if out != nil && resp.StatusCode < http.StatusMultipleChoices {
+		suite.NoError(json.NewDecoder(resp.Body).Decode(out))
+	}
+	return resp
+}
+
+func (suite *HubAPITestSuite) TestProjectCRUD() {
+	_, driver := setupDB(suite.T())
+	store := projects.NewMemoryStore()
+	srv := suite.startServer(driver, hub.WithProjectStore(store))
+	defer srv.Close()
+	base := srv.URL + "/api/hub/projects"
+
+	var created hub.ProjectResource
+	resp := suite.sendJSON(http.MethodPost, base, `{"project_id": "site-1", "name": "Site", "allowed_hosts": ["Example.com", "*.example.com", "example.com"]}`, &created)
+	suite.Equal(http.StatusCreated, resp.StatusCode)
+	suite.Equal("site-1", created.ProjectID)
+	suite.Regexp(`^pk_[0-9a-f]{32}$`, created.IngestionKey)
+	suite.Equal([]string{"example.com", "*.example.com"}, created.AllowedHosts)
+	suite.False(created.CreatedAt.IsZero())
+
+	resp = suite.sendJSON(http.MethodPost, base, `{"project_id": "site-1", "name": "Again"}`, nil)
+	suite.Equal(http.StatusConflict, resp.StatusCode)
+
+	var list struct {
+		Projects []hub.ProjectResource `json:"projects"`
+	}
+	resp = suite.sendJSON(http.MethodGet, base, "", &list)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	suite.Require().Len(list.Projects, 1)
+	suite.Equal(created.IngestionKey, list.Projects[0].IngestionKey)
+
+	// Updates keep the ingestion key, unless rotated.
+	var updated hub.ProjectResource
+	resp = suite.sendJSON(http.MethodPut, base+"/site-1", `{"name": "Renamed"}`, &updated)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	suite.Equal("Renamed", updated.Name)
+	suite.Equal(created.IngestionKey, updated.IngestionKey)
+	suite.Empty(updated.AllowedHosts)
+
+	resp = suite.sendJSON(http.MethodPut, base+"/site-1", `{"name": "Renamed", "rotate_ingestion_key": true}`, &updated)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	suite.NotEqual(created.IngestionKey, updated.IngestionKey)
+
+	var fetched hub.ProjectResource
+	resp = suite.sendJSON(http.MethodGet, base+"/site-1", "", &fetched)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	suite.Equal(updated.IngestionKey, fetched.IngestionKey)
+
+	resp = suite.sendJSON(http.MethodDelete, base+"/site-1", "", nil)
+	suite.Equal(http.StatusNoContent, resp.StatusCode)
+	resp = suite.sendJSON(http.MethodGet, base+"/site-1", "", nil)
+	suite.Equal(http.StatusNotFound, resp.StatusCode)
+	resp = suite.sendJSON(http.MethodPut, base+"/site-1", `{"name": "Gone"}`, nil)
+	suite.Equal(http.StatusNotFound, resp.StatusCode)
+	resp = suite.sendJSON(http.MethodDelete, base+"/site-1", "", nil)
+	suite.Equal(http.StatusNotFound, resp.StatusCode)
+}
+
+func (suite *HubAPITestSuite) TestProjectValidation() {
+	_, driver := setupDB(suite.T())
+	srv := suite.startServer(driver, hub.WithProjectStore(projects.NewMemoryStore()))
+	defer srv.Close()
+
+	for _, body := range []string{
+		`{"project_id": "", "name": "Site"}`,
+		`{"project_id": "site 1", "name": "Site"}`,
+		`{"project_id": "site-1", "name": ""}`,
+		`{"project_id": "site-1", "name": "Site", "allowed_hosts": ["https://example.com"]}`,
+		`{"project_id": "site-1", "name": "Site", "allowed_hosts": ["example.com:8080"]}`,
+		`{"project_id": "site-1", "name": "Site", "allowed_hosts": ["*"]}`,
+	} {
+		resp := suite.sendJSON(http.MethodPost, srv.URL+"/api/hub/projects", body, nil)
+		suite.Equal(http.StatusUnprocessableEntity, resp.StatusCode, body)
+	}
+}
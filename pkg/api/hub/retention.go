@@ -0,0 +1,102 @@
+package hub
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/ponrove/ponrove-backend/internal/retention"
+)
+
+// RetentionResource reports how long the events of a project are kept.
+type RetentionResource struct {
+	ProjectID            string     `json:"project_id" doc:"Identifier of the project."`
+	ConfiguredDays       *uint32    `json:"configured_days" doc:"Retention set for the project, null when the project uses the default."`
+	DefaultDays          uint32     `json:"default_days" doc:"Retention of projects without settings."`
+	EffectiveDays        uint32     `json:"effective_days" doc:"Retention applied to events ingested now."`
+	StoredEvents         uint64     `json:"stored_events" doc:"Number of events of the project currently stored."`
+	OldestEvent          *time.Time `json:"oldest_event,omitempty" doc:"Timestamp of the oldest stored event, omitted without events."`
+	OldestEventExpiresAt *time.Time `json:"oldest_event_expires_at,omitempty" doc:"When the oldest stored event expires, with the retention it was ingested with."`
+}
+
+// RetentionPayload is the body of a retention update.
+type RetentionPayload struct {
+	RetentionDays uint32 `json:"retention_days" doc:"Number of days events of the project are kept, within the bounds configured for the hub."`
+}
+
+type (
+	RetentionRequest struct {
+		ProjectID string `path:"project_id" maxLength:"128" doc:"Identifier of the project."`
+	}
+	RetentionUpdateRequest struct {
+		ProjectID string `path:"project_id" maxLength:"128" doc:"Identifier of the project."`
+		Body      RetentionPayload
+	}
+	RetentionResponse struct {
+		Body RetentionResource
+	}
+)
+
+// RegisterRetentionEndpoints registers the endpoints reading and changing the retention of the events of a project,
+// kept in project_settings and applied through the TTL of raw_events.
+func (a *server) RegisterRetentionEndpoints(api huma.API) {
+	minDays, maxDays := a.config.Int64(HUB_RETENTION_MIN_DAYS), a.config.Int64(HUB_RETENTION_MAX_DAYS)
+
+	huma.Register(api, huma.Operation{
+		OperationID: "Get Retention",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/retention",
+		Tags:        []string{"Retention"},
+	}, func(ctx context.Context, i *RetentionRequest) (*RetentionResponse, error) {
+		r, err := retention.Get(ctx, a.clickhouse, i.ProjectID)
+		if err != nil {
+			return nil, err
+		}
+
+		return &RetentionResponse{Body: retentionResource(r)}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "Update Retention",
+		Method:      http.MethodPut,
+		Path:        "/projects/{project_id}/retention",
+		Tags:        []string{"Retention"},
+		Description: fmt.Sprintf("Sets the retention of the project, between %d and %d days. Applies to events ingested from now on, events already stored keep the retention they were ingested with.", minDays, maxDays),
+	}, func(ctx context.Context, i *RetentionUpdateRequest) (*RetentionResponse, error) {
+		days := int64(i.Body.RetentionDays)
+		if days < minDays || days > maxDays {
+			return nil, huma.Error422UnprocessableEntity("validation failed", &huma.ErrorDetail{
+				Location: "body.retention_days",
+				Message:  fmt.Sprintf("expected between %d and %d days", minDays, maxDays),
+				Value:    i.Body.RetentionDays,
+			})
+		}
+
+		err := retention.Set(ctx, a.clickhouse, i.ProjectID, i.Body.RetentionDays)
+		if err != nil {
+			return nil, err
+		}
+
+		r, err := retention.Get(ctx, a.clickhouse, i.ProjectID)
+		if err != nil {
+			return nil, err
+		}
+
+		return &RetentionResponse{Body: retentionResource(r)}, nil
+	})
+}
+
+// retentionResource converts the retention of a project into its resource.
+func retentionResource(r retention.Retention) RetentionResource {
+	return RetentionResource{
+		ProjectID:            r.ProjectID,
+		ConfiguredDays:       r.ConfiguredDays,
+		DefaultDays:          retention.DefaultDays,
+		EffectiveDays:        r.EffectiveDays,
+		StoredEvents:         r.StoredEvents,
+		OldestEvent:          r.OldestEvent,
+		OldestEventExpiresAt: r.OldestEventExpiresAt,
+	}
+}
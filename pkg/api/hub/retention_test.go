@@ -0,0 +1,82 @@
+package hub_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/ponrove/octobe/driver/clickhouse/mock"
+	"github.com/ponrove/ponrove-backend/pkg/api/hub"
+)
+
+func (suite *HubAPITestSuite) TestGetRetention() {
+	oldest := time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)
+
+	conn, driver := setupDB(suite.T())
+	conn.ExpectQueryRow("FROM project_settings FINAL").WillReturnRow(mock.NewMockRow(uint32(90)))
+	conn.ExpectQueryRow("get_event_ttl").WillReturnRow(mock.NewMockRow(uint32(90)))
+	conn.ExpectQueryRow("FROM raw_events").WillReturnRow(mock.NewMockRow(uint64(1200), oldest, uint32(365)))
+
+	// Without settings nor events, the project uses the default retention.
+	conn.ExpectQueryRow("FROM project_settings FINAL").WillReturnRow(mock.NewMockRow().WillReturnError(sql.ErrNoRows))
+	conn.ExpectQueryRow("get_event_ttl").WillReturnRow(mock.NewMockRow(uint32(365)))
+	conn.ExpectQueryRow("FROM raw_events").WillReturnRow(mock.NewMockRow(uint64(0), time.Unix(0, 0).UTC(), uint32(0)))
+	srv := suite.startServer(driver)
+	defer srv.Close()
+
+	var r hub.RetentionResource
+	resp, err := http.Get(srv.URL + "/api/hub/projects/project-1/retention")
+	suite.NoError(err)
+	defer resp.Body.Close()
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	suite.NoError(json.NewDecoder(resp.Body).Decode(&r))
+
+	configured := uint32(90)
+	expires := oldest.AddDate(0, 0, 365)
+	suite.Equal(hub.RetentionResource{
+		ProjectID:            "project-1",
+		ConfiguredDays:       &configured,
+		DefaultDays:          365,
+		EffectiveDays:        90,
+		StoredEvents:         1200,
+		OldestEvent:          &oldest,
+		OldestEventExpiresAt: &expires,
+	}, r)
+
+	resp, err = http.Get(srv.URL + "/api/hub/projects/project-2/retention")
+	suite.NoError(err)
+	defer resp.Body.Close()
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	r = hub.RetentionResource{}
+	suite.NoError(json.NewDecoder(resp.Body).Decode(&r))
+	suite.Equal(hub.RetentionResource{ProjectID: "project-2", DefaultDays: 365, EffectiveDays: 365}, r)
+
+	suite.NoError(conn.AllExpectationsMet())
+}
+
+func (suite *HubAPITestSuite) TestUpdateRetention() {
+	conn, driver := setupDB(suite.T())
+	conn.ExpectExec("INSERT INTO project_settings")
+	conn.ExpectExec("SYSTEM RELOAD DICTIONARY project_retention_dictionary")
+	conn.ExpectQueryRow("FROM project_settings FINAL").WillReturnRow(mock.NewMockRow(uint32(60)))
+	conn.ExpectQueryRow("get_event_ttl").WillReturnRow(mock.NewMockRow(uint32(60)))
+	conn.ExpectQueryRow("FROM raw_events").WillReturnRow(mock.NewMockRow(uint64(0), time.Unix(0, 0).UTC(), uint32(0)))
+	srv := suite.startServer(driver)
+	defer srv.Close()
+
+	// The test configuration bounds retention between 30 and 730 days.
+	for _, body := range []string{`{"retention_days": 29}`, `{"retention_days": 731}`, `{"retention_days": -1}`} {
+		resp := suite.sendJSON(http.MethodPut, srv.URL+"/api/hub/projects/project-1/retention", body, nil)
+		suite.Equal(http.StatusUnprocessableEntity, resp.StatusCode, body)
+	}
+
+	var r hub.RetentionResource
+	resp := suite.sendJSON(http.MethodPut, srv.URL+"/api/hub/projects/project-1/retention", `{"retention_days": 60}`, &r)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	suite.Require().NotNil(r.ConfiguredDays)
+	suite.Equal(uint32(60), *r.ConfiguredDays)
+	suite.Equal(uint32(60), r.EffectiveDays)
+
+	suite.NoError(conn.AllExpectationsMet())
+}
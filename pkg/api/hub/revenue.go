@@ -0,0 +1,103 @@
+package hub
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/ponrove/ponrove-backend/internal/analytics"
+	"github.com/shopspring/decimal"
+)
+
+// CurrencyRevenue is the revenue reported in a currency.
+type CurrencyRevenue struct {
+	Currency  string   `json:"currency" doc:"ISO 4217 code of the currency the revenue was reported in."`
+	Revenue   float64  `json:"revenue" doc:"Total revenue, in the currency it was reported in."`
+	Orders    uint64   `json:"orders" doc:"Number of events with revenue."`
+	Converted *float64 `json:"converted,omitempty" doc:"Total revenue in the reporting currency, omitted when the currency has no exchange rate."`
+}
+
+type (
+	RevenueRequest struct {
+		ProjectID string    `path:"project_id" maxLength:"128" doc:"Identifier of the project."`
+		From      time.Time `query:"from" required:"true" doc:"Start of the range, inclusive."`
+		To        time.Time `query:"to" required:"true" doc:"End of the range, exclusive."`
+		FilterParam
+	}
+	RevenueResponse struct {
+		Body struct {
+			Currency          string            `json:"currency" doc:"ISO 4217 code of the reporting currency the totals are converted into."`
+			Revenue           float64           `json:"revenue" doc:"Total revenue, in the reporting currency."`
+			Orders            uint64            `json:"orders" doc:"Number of events with revenue in currencies with an exchange rate."`
+			Visitors          uint64            `json:"visitors" doc:"Number of unique visitors, with or without revenue."`
+			AverageOrderValue float64           `json:"average_order_value" doc:"Revenue per order, in the reporting currency."`
+			RevenuePerVisitor float64           `json:"revenue_per_visitor" doc:"Revenue per visitor, in the reporting currency."`
+			Currencies        []CurrencyRevenue `json:"currencies" doc:"Revenue of every currency it was reported in, ordered by code."`
+		}
+	}
+)
+
+// RegisterRevenueEndpoints registers the endpoints reporting the revenue attached to the events of a project.
+func (a *server) RegisterRevenueEndpoints(api huma.API) {
+	huma.Register(api, huma.Operation{
+		OperationID: "Get Revenue",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/revenue",
+		Tags:        []string{"Revenue"},
+		Description: "Reports the revenue attached to the events of the project, converted into the reporting currency with a static table of exchange rates. Revenue in currencies without a rate is listed, but left out of the totals.",
+	}, func(ctx context.Context, i *RevenueRequest) (*RevenueResponse, error) {
+		if !i.From.Before(i.To) {
+			return nil, huma.Error422UnprocessableEntity("validation failed", &huma.ErrorDetail{Location: "query.to", Message: "must be after from", Value: i.To})
+		}
+
+		f, err := i.parse()
+		if err != nil {
+			return nil, err
+		}
+
+		result, err := analytics.Revenue(ctx, a.clickhouse, analytics.RevenueQuery{
+			ProjectID: i.ProjectID,
+			From:      i.From,
+			To:        i.To,
+			Filter:    f,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		var (
+			revenue decimal.Decimal
+			orders  uint64
+		)
+		resp := &RevenueResponse{}
+		resp.Body.Currencies = make([]CurrencyRevenue, 0, len(result.Currencies))
+		for _, c := range result.Currencies {
+			out := CurrencyRevenue{Currency: c.Currency, Revenue: money(c.Amount), Orders: c.Orders}
+			if converted, ok := a.rates.Convert(c.Amount, c.Currency); ok {
+				value := money(converted)
+				out.Converted = &value
+				revenue = revenue.Add(converted)
+				orders += c.Orders
+			}
+			resp.Body.Currencies = append(resp.Body.Currencies, out)
+		}
+
+		resp.Body.Currency = a.rates.Currency()
+		resp.Body.Revenue = money(revenue)
+		resp.Body.Orders = orders
+		resp.Body.Visitors = result.Visitors
+		if orders > 0 {
+			resp.Body.AverageOrderValue = money(revenue.Div(decimal.NewFromUint64(orders)))
+		}
+		if result.Visitors > 0 {
+			resp.Body.RevenuePerVisitor = money(revenue.Div(decimal.NewFromUint64(result.Visitors)))
+		}
+		return resp, nil
+	})
+}
+
+// money converts an amount into a number, rounded to the precision revenue is stored with.
+func money(amount decimal.Decimal) float64 {
+	return amount.Round(4).InexactFloat64()
+}
@@ -0,0 +1,50 @@
+package hub_test
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ponrove/octobe/driver/clickhouse/mock"
+	"github.com/ponrove/ponrove-backend/pkg/api/hub"
+	"github.com/shopspring/decimal"
+)
+
+func (suite *HubAPITestSuite) TestRevenue() {
+	conn, driver := setupDB(suite.T())
+	conn.ExpectQueryRow("uniqExactIf(visitor_fingerprint, visitor_fingerprint != '') AS visitors").WillReturnRow(mock.NewMockRow(uint64(40)))
+	conn.ExpectQuery("AND revenue_currency != '' AND is_bot = 0").WillReturnRows(mock.NewMockRows([]string{"revenue_currency", "revenue", "orders"}).
+		AddRow("EUR", decimal.RequireFromString("50"), uint64(1)).
+		AddRow("GBP", decimal.RequireFromString("20"), uint64(1)).
+		AddRow("USD", decimal.RequireFromString("100.5"), uint64(2)),
+	)
+	srv := suite.startServer(driver)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/hub/projects/project-1/revenue?from=2025-06-16T00:00:00Z&to=2025-06-17T00:00:00Z")
+	suite.NoError(err)
+	defer resp.Body.Close()
+	suite.Equal(http.StatusOK, resp.StatusCode)
+
+	// Revenue in currencies without an exchange rate is left out of the totals.
+	var body hub.RevenueResponse
+	suite.NoError(json.NewDecoder(resp.Body).Decode(&body.Body))
+	eur, usd := 54.0, 100.5
+	suite.Equal("USD", body.Body.Currency)
+	suite.Equal(154.5, body.Body.Revenue)
+	suite.Equal(uint64(3), body.Body.Orders)
+	suite.Equal(uint64(40), body.Body.Visitors)
+	suite.Equal(51.5, body.Body.AverageOrderValue)
+	suite.Equal(3.8625, body.Body.RevenuePerVisitor)
+	suite.Equal([]hub.CurrencyRevenue{
+		{Currency: "EUR", Revenue: 50, Orders: 1, Converted: &eur},
+		{Currency: "GBP", Revenue: 20, Orders: 1},
+		{Currency: "USD", Revenue: 100.5, Orders: 2, Converted: &usd},
+	}, body.Body.Currencies)
+
+	resp, err = http.Get(srv.URL + "/api/hub/projects/project-1/revenue?from=2025-06-17T00:00:00Z&to=2025-06-16T00:00:00Z")
+	suite.NoError(err)
+	defer resp.Body.Close()
+	suite.Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+
+	suite.NoError(conn.AllExpectationsMet())
+}
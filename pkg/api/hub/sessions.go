@@ -0,0 +1,223 @@
+package hub
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/ponrove/octobe/driver/clickhouse"
+	"github.com/ponrove/ponrove-backend/internal/session"
+)
+
+// maxRebuildRange is the longest range of sessions rebuilt by a single job.
+const maxRebuildRange = 366 * 24 * time.Hour
+
+// finishedJobRetention is how long finished rebuild jobs can still be looked up.
+const finishedJobRetention = 24 * time.Hour
+
+// Session rebuild job statuses.
+const (
+	RebuildRunning   = "running"
+	RebuildSucceeded = "succeeded"
+	RebuildFailed    = "failed"
+)
+
+// SessionRebuildPayload is the body of a session rebuild request.
+type SessionRebuildPayload struct {
+	ProjectID string    `json:"project_id,omitempty" maxLength:"128" doc:"Identifier of the project whose sessions are rebuilt, all projects when empty."`
+	From      time.Time `json:"from" doc:"Start of the range of events, widened to the start of its UTC day."`
+	To        time.Time `json:"to" doc:"End of the range of events, exclusive, widened to the end of its UTC day."`
+}
+
+// SessionRebuildJob reports the progress of a session rebuild.
+type SessionRebuildJob struct {
+	ID         string     `json:"id" doc:"Identifier of the job."`
+	Status     string     `json:"status" enum:"running,succeeded,failed" doc:"Whether the job is running, succeeded or failed."`
+	ProjectID  string     `json:"project_id,omitempty" doc:"Identifier of the project whose sessions are rebuilt, all projects when empty."`
+	From       time.Time  `json:"from" doc:"Start of the rebuilt range, at the start of a UTC day."`
+	To         time.Time  `json:"to" doc:"End of the rebuilt range, exclusive, at the end of a UTC day."`
+	StartedAt  time.Time  `json:"started_at" doc:"When the job started."`
+	FinishedAt *time.Time `json:"finished_at,omitempty" doc:"When the job finished."`
+	Error      string     `json:"error,omitempty" doc:"Why the job failed."`
+}
+
+type (
+	SessionRebuildRequest struct {
+		Body SessionRebuildPayload
+	}
+	SessionRebuildStatusRequest struct {
+		JobID string `path:"job_id" doc:"Identifier of the job."`
+	}
+	SessionRebuildResponse struct {
+		Status int `header:"-"`
+		Body   SessionRebuildJob
+	}
+)
+
+// RegisterSessionRebuildEndpoints registers the endpoints starting a rebuild of the sessions of past events, and
+// reporting its progress. Rebuilds run in the background, one at a time, as each rewrites the session IDs of raw_events
+// with a mutation.
+func (a *server) RegisterSessionRebuildEndpoints(api huma.API) {
+	huma.Register(api, huma.Operation{
+		OperationID:   "Rebuild Sessions",
+		Method:        http.MethodPost,
+		Path:          "/sessions/rebuild",
+		Tags:          []string{"Sessions"},
+		DefaultStatus: http.StatusAccepted,
+		Description:   "Recomputes the session IDs of past events, e.g. after changing the session timeout.",
+	}, func(ctx context.Context, i *SessionRebuildRequest) (*SessionRebuildResponse, error) {
+		r := session.Range{ProjectID: i.Body.ProjectID, From: i.Body.From, To: i.Body.To}
+		if !r.From.Before(r.To) {
+			return nil, huma.Error422UnprocessableEntity("validation failed", &huma.ErrorDetail{Location: "body.to", Message: "must be after from", Value: i.Body.To})
+		}
+		if r.To.Sub(r.From) > maxRebuildRange {
+			return nil, huma.Error422UnprocessableEntity("validation failed", &huma.ErrorDetail{Location: "body.to", Message: "range must not exceed 366 days", Value: i.Body.To})
+		}
+
+		job, err := a.rebuilds.Start(r)
+		if errors.Is(err, errRebuildRunning) {
+			return nil, huma.Error409Conflict(err.Error())
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return &SessionRebuildResponse{Status: http.StatusAccepted, Body: job}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "Get Session Rebuild",
+		Method:      http.MethodGet,
+		Path:        "/sessions/rebuild/{job_id}",
+		Tags:        []string{"Sessions"},
+	}, func(ctx context.Context, i *SessionRebuildStatusRequest) (*SessionRebuildResponse, error) {
+		job, ok := a.rebuilds.Get(i.JobID)
+		if !ok {
+			return nil, huma.Error404NotFound("session rebuild job not found")
+		}
+
+		return &SessionRebuildResponse{Status: http.StatusOK, Body: job}, nil
+	})
+}
+
+var errRebuildRunning = errors.New("a session rebuild is already running")
+
+// rebuildJobs runs session rebuilds in the background, and keeps track of their progress.
+type rebuildJobs struct {
+	driver  clickhouse.Driver
+	timeout time.Duration
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+
+	mu      sync.Mutex
+	jobs    map[string]*SessionRebuildJob
+	running bool
+}
+
+func newRebuildJobs(driver clickhouse.Driver, timeout time.Duration) *rebuildJobs {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &rebuildJobs{
+		driver:  driver,
+		timeout: timeout,
+		ctx:     ctx,
+		cancel:  cancel,
+		jobs:    make(map[string]*SessionRebuildJob),
+	}
+}
+
+// Start starts rebuilding the sessions of the range, unless a rebuild is already running.
+func (j *rebuildJobs) Start(r session.Range) (SessionRebuildJob, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.running {
+		return SessionRebuildJob{}, errRebuildRunning
+	}
+
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	r = r.Days()
+	job := &SessionRebuildJob{
+		ID:        hex.EncodeToString(id),
+		Status:    RebuildRunning,
+		ProjectID: r.ProjectID,
+		From:      r.From,
+		To:        r.To,
+		StartedAt: time.Now().UTC(),
+	}
+
+	for id, finished := range j.jobs {
+		if finished.FinishedAt != nil && time.Since(*finished.FinishedAt) > finishedJobRetention {
+			delete(j.jobs, id)
+		}
+	}
+	j.jobs[job.ID] = job
+	j.running = true
+
+	j.wg.Add(1)
+	go j.run(job.ID, r)
+
+	return *job, nil
+}
+
+// run rebuilds the sessions of the job, recording the outcome.
+func (j *rebuildJobs) run(id string, r session.Range) {
+	defer j.wg.Done()
+
+	logger := slog.With(slog.String("job_id", id), slog.Time("from", r.From), slog.Time("to", r.To), slog.String("project_id", r.ProjectID))
+	logger.InfoContext(j.ctx, "Rebuilding sessions")
+	err := session.Rebuild(j.ctx, j.driver, r, j.timeout)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	job := j.jobs[id]
+	finished := time.Now().UTC()
+	job.FinishedAt = &finished
+	job.Status = RebuildSucceeded
+	if err != nil {
+		job.Status = RebuildFailed
+		job.Error = err.Error()
+		logger.ErrorContext(j.ctx, "Failed to rebuild sessions", slog.Any("error", err))
+	} else {
+		logger.InfoContext(j.ctx, "Rebuilt sessions", slog.Duration("duration", finished.Sub(job.StartedAt)))
+	}
+	j.running = false
+}
+
+// Get returns the job with the given ID.
+func (j *rebuildJobs) Get(id string) (SessionRebuildJob, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	job, ok := j.jobs[id]
+	if !ok {
+		return SessionRebuildJob{}, false
+	}
+	return *job, true
+}
+
+// Close cancels running rebuilds and waits for them to stop.
+func (j *rebuildJobs) Close(ctx context.Context) error {
+	j.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		j.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,110 @@
+package hub_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ponrove/ponrove-backend/pkg/api/hub"
+)
+
+// getRebuildJob fetches the session rebuild job with the given ID.
+func (suite *HubAPITestSuite) getRebuildJob(url, id string) hub.SessionRebuildJob {
+	resp, err := http.Get(url + "/api/hub/sessions/rebuild/" + id)
+	suite.NoError(err)
+	defer resp.Body.Close()
+	suite.Equal(http.StatusOK, resp.StatusCode)
+
+	var job hub.SessionRebuildJob
+	suite.NoError(json.NewDecoder(resp.Body).Decode(&job))
+	return job
+}
+
+func (suite *HubAPITestSuite) TestSessionRebuild() {
+	conn, driver := setupDB(suite.T())
+	conn.ExpectExec("CREATE TABLE session_rebuild_")
+	conn.ExpectExec("INSERT INTO session_rebuild_")
+	conn.ExpectExec("ALTER TABLE raw_events UPDATE session_id")
+	conn.ExpectExec("ALTER TABLE visitor_directory_state DELETE")
+	conn.ExpectExec("INSERT INTO visitor_directory_state")
+	conn.ExpectExec("DROP TABLE IF EXISTS session_rebuild_")
+	srv := suite.startServer(driver)
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/api/hub/sessions/rebuild", "application/json", strings.NewReader(`{
+		"project_id": "project-1",
+		"from": "2025-06-16T12:00:00Z",
+		"to": "2025-06-17T08:00:00Z"
+	}`))
+	suite.NoError(err)
+	defer resp.Body.Close()
+	suite.Equal(http.StatusAccepted, resp.StatusCode)
+
+	var job hub.SessionRebuildJob
+	suite.NoError(json.NewDecoder(resp.Body).Decode(&job))
+	suite.NotEmpty(job.ID)
+	suite.Equal("project-1", job.ProjectID)
+	suite.Equal(time.Date(2025, 6, 16, 0, 0, 0, 0, time.UTC), job.From, "the range should be widened to whole days")
+	suite.Equal(time.Date(2025, 6, 18, 0, 0, 0, 0, time.UTC), job.To)
+
+	suite.Eventually(func() bool {
+		return suite.getRebuildJob(srv.URL, job.ID).Status == hub.RebuildSucceeded
+	}, time.Second, 5*time.Millisecond)
+	suite.NoError(conn.AllExpectationsMet())
+}
+
+func (suite *HubAPITestSuite) TestSessionRebuildFailure() {
+	conn, driver := setupDB(suite.T())
+	conn.ExpectExec("CREATE TABLE session_rebuild_")
+	conn.ExpectExec("INSERT INTO session_rebuild_").WillReturnError(errors.New("memory limit exceeded"))
+	conn.ExpectExec("DROP TABLE IF EXISTS session_rebuild_")
+	srv := suite.startServer(driver)
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/api/hub/sessions/rebuild", "application/json", strings.NewReader(`{"from": "2025-06-16T00:00:00Z", "to": "2025-06-17T00:00:00Z"}`))
+	suite.NoError(err)
+	defer resp.Body.Close()
+	suite.Equal(http.StatusAccepted, resp.StatusCode)
+
+	var job hub.SessionRebuildJob
+	suite.NoError(json.NewDecoder(resp.Body).Decode(&job))
+
+	suite.Eventually(func() bool {
+		job = suite.getRebuildJob(srv.URL, job.ID)
+		return job.Status == hub.RebuildFailed
+	}, time.Second, 5*time.Millisecond)
+	suite.Contains(job.Error, "memory limit exceeded")
+	suite.NotNil(job.FinishedAt)
+	suite.NoError(conn.AllExpectationsMet(), "the rebuild table should be dropped")
+}
+
+func (suite *HubAPITestSuite) TestSessionRebuildValidation() {
+	_, driver := setupDB(suite.T())
+	srv := suite.startServer(driver)
+	defer srv.Close()
+
+	testCases := []struct {
+		name string
+		body string
+	}{
+		{name: "Empty range", body: `{"from": "2025-06-16T00:00:00Z", "to": "2025-06-16T00:00:00Z"}`},
+		{name: "Range too long", body: `{"from": "2024-01-01T00:00:00Z", "to": "2025-06-16T00:00:00Z"}`},
+		{name: "Missing range", body: `{"project_id": "project-1"}`},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			resp, err := http.Post(srv.URL+"/api/hub/sessions/rebuild", "application/json", strings.NewReader(tc.body))
+			suite.NoError(err)
+			resp.Body.Close()
+			suite.Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+		})
+	}
+
+	resp, err := http.Get(srv.URL + "/api/hub/sessions/rebuild/unknown")
+	suite.NoError(err)
+	resp.Body.Close()
+	suite.Equal(http.StatusNotFound, resp.StatusCode)
+}
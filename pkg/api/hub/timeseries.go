@@ -0,0 +1,153 @@
+package hub
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/ponrove/ponrove-backend/internal/analytics"
+)
+
+// TimeseriesPoint holds the traffic of a single bucket of a time series.
+type TimeseriesPoint struct {
+	Bucket            time.Time               `json:"bucket" doc:"Start of the bucket, in the requested timezone."`
+	Pageviews         uint64                  `json:"pageviews" doc:"Number of pageviews in the bucket."`
+	Visitors          uint64                  `json:"visitors" doc:"Number of unique visitors in the bucket. Visitor fingerprints rotate daily, so visitors returning on another day of a week or month are counted again."`
+	Sessions          uint64                  `json:"sessions" doc:"Number of sessions started in the bucket."`
+	BounceRate        float64                 `json:"bounce_rate" doc:"Share of the sessions with pageviews started in the bucket that have a single pageview, between 0 and 1."`
+	AvgVisitDurationS float64                 `json:"avg_visit_duration_s" doc:"Average duration in seconds of the sessions started in the bucket."`
+	Goals             []GoalConversion        `json:"goals,omitempty" doc:"Conversions of the requested goals in the bucket, in order, omitted without goals."`
+	Compare           *TimeseriesComparePoint `json:"compare,omitempty" doc:"Traffic of the bucket at the same position in the window compared to, omitted without comparison or when that window has fewer buckets."`
+}
+
+// TimeseriesComparePoint holds the traffic of a bucket of the window compared to, and how the traffic changed since.
+type TimeseriesComparePoint struct {
+	Bucket            time.Time        `json:"bucket" doc:"Start of the bucket, in the requested timezone."`
+	Pageviews         uint64           `json:"pageviews" doc:"Number of pageviews in the bucket."`
+	Visitors          uint64           `json:"visitors" doc:"Number of unique visitors in the bucket."`
+	Sessions          uint64           `json:"sessions" doc:"Number of sessions started in the bucket."`
+	BounceRate        float64          `json:"bounce_rate" doc:"Share of the sessions with pageviews started in the bucket that have a single pageview."`
+	AvgVisitDurationS float64          `json:"avg_visit_duration_s" doc:"Average duration in seconds of the sessions started in the bucket."`
+	Goals             []GoalConversion `json:"goals,omitempty" doc:"Conversions of the requested goals in the bucket, in order, omitted without goals."`
+	Change            TimeseriesChange `json:"change" doc:"Change of the traffic of the compared bucket."`
+}
+
+// TimeseriesChange holds how the traffic of a bucket changed since the bucket compared to.
+type TimeseriesChange struct {
+	Pageviews         Change `json:"pageviews"`
+	Visitors          Change `json:"visitors"`
+	Sessions          Change `json:"sessions"`
+	BounceRate        Change `json:"bounce_rate"`
+	AvgVisitDurationS Change `json:"avg_visit_duration_s"`
+}
+
+type (
+	TimeseriesRequest struct {
+		ProjectID string    `path:"project_id" maxLength:"128" doc:"Identifier of the project."`
+		From      time.Time `query:"from" required:"true" doc:"Start of the range, inclusive."`
+		To        time.Time `query:"to" required:"true" doc:"End of the range, exclusive."`
+		Interval  string    `query:"interval" default:"day" enum:"hour,day,week,month" doc:"Width of the buckets. Weeks start on Monday."`
+		Timezone  string    `query:"timezone" default:"UTC" maxLength:"64" doc:"IANA timezone the buckets are aligned to, e.g. Europe/Stockholm."`
+		FilterParam
+		CompareParam
+		GoalsParam
+	}
+	TimeseriesResponse struct {
+		Body struct {
+			Interval    string            `json:"interval" doc:"Width of the buckets."`
+			Timezone    string            `json:"timezone" doc:"Timezone the buckets are aligned to."`
+			CompareFrom *time.Time        `json:"compare_from,omitempty" doc:"Start of the window compared to, inclusive, omitted without comparison."`
+			CompareTo   *time.Time        `json:"compare_to,omitempty" doc:"End of the window compared to, exclusive, omitted without comparison."`
+			Points      []TimeseriesPoint `json:"points" doc:"Traffic per bucket overlapping the range, empty buckets included, ordered by time."`
+		}
+	}
+)
+
+// RegisterTimeseriesEndpoints registers the endpoints reporting the traffic of a project over time.
+func (a *server) RegisterTimeseriesEndpoints(api huma.API) {
+	huma.Register(api, huma.Operation{
+		OperationID: "Get Timeseries",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/timeseries",
+		Tags:        []string{"Timeseries"},
+		Description: "Computes pageviews, unique visitors, sessions, bounce rate and average visit duration per hour, day, week or month. Session metrics are attributed to the bucket the session started in, from the events of the session matching the filter. Compared, every bucket holds the traffic of the bucket at the same position in the window compared to, queried together with the range.",
+	}, func(ctx context.Context, i *TimeseriesRequest) (*TimeseriesResponse, error) {
+		if !i.From.Before(i.To) {
+			return nil, huma.Error422UnprocessableEntity("validation failed", &huma.ErrorDetail{Location: "query.to", Message: "must be after from", Value: i.To})
+		}
+
+		f, err := i.parse()
+		if err != nil {
+			return nil, err
+		}
+
+		goalList, goalConditions, err := i.load(ctx, a.goals, i.ProjectID)
+		if err != nil {
+			return nil, err
+		}
+
+		loc, err := analytics.LoadTimezone(i.Timezone)
+		if err != nil {
+			return nil, huma.Error422UnprocessableEntity("validation failed", &huma.ErrorDetail{Location: "query.timezone", Message: "expected an IANA timezone", Value: i.Timezone})
+		}
+
+		result, err := analytics.Timeseries(ctx, a.clickhouse, analytics.TimeseriesQuery{
+			ProjectID: i.ProjectID,
+			From:      i.From,
+			To:        i.To,
+			Filter:    f,
+			Interval:  analytics.Interval(i.Interval),
+			Location:  loc,
+			Compare:   analytics.Comparison(i.Compare),
+			Goals:     goalConditions,
+		})
+		if errors.Is(err, analytics.ErrTooManyBuckets) {
+			return nil, huma.Error422UnprocessableEntity("validation failed", &huma.ErrorDetail{Location: "query.interval", Message: err.Error() + ", use a wider interval or a shorter range", Value: i.Interval})
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		resp := &TimeseriesResponse{}
+		resp.Body.Interval = i.Interval
+		resp.Body.Timezone = loc.String()
+		if i.Compare != "" {
+			resp.Body.CompareFrom, resp.Body.CompareTo = &result.CompareFrom, &result.CompareTo
+		}
+		resp.Body.Points = make([]TimeseriesPoint, 0, len(result.Points))
+		for n, p := range result.Points {
+			point := TimeseriesPoint{
+				Bucket:            p.Bucket,
+				Pageviews:         p.Pageviews,
+				Visitors:          p.Visitors,
+				Sessions:          p.Sessions,
+				BounceRate:        p.BounceRate,
+				AvgVisitDurationS: p.AvgVisitDuration.Seconds(),
+				Goals:             goalConversions(goalList, p.Conversions, p.Visitors),
+			}
+			if n < len(result.ComparePoints) {
+				c := result.ComparePoints[n]
+				point.Compare = &TimeseriesComparePoint{
+					Bucket:            c.Bucket,
+					Pageviews:         c.Pageviews,
+					Visitors:          c.Visitors,
+					Sessions:          c.Sessions,
+					BounceRate:        c.BounceRate,
+					AvgVisitDurationS: c.AvgVisitDuration.Seconds(),
+					Goals:             goalConversions(goalList, c.Conversions, c.Visitors),
+					Change: TimeseriesChange{
+						Pageviews:         change(float64(p.Pageviews), float64(c.Pageviews)),
+						Visitors:          change(float64(p.Visitors), float64(c.Visitors)),
+						Sessions:          change(float64(p.Sessions), float64(c.Sessions)),
+						BounceRate:        change(p.BounceRate, c.BounceRate),
+						AvgVisitDurationS: change(p.AvgVisitDuration.Seconds(), c.AvgVisitDuration.Seconds()),
+					},
+				}
+			}
+			resp.Body.Points = append(resp.Body.Points, point)
+		}
+		return resp, nil
+	})
+}
@@ -0,0 +1,122 @@
+package hub_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/ponrove/octobe/driver/clickhouse/mock"
+	"github.com/ponrove/ponrove-backend/pkg/api/hub"
+)
+
+func (suite *HubAPITestSuite) TestTimeseries() {
+	stockholm, err := time.LoadLocation("Europe/Stockholm")
+	suite.Require().NoError(err)
+
+	conn, driver := setupDB(suite.T())
+	conn.ExpectQuery("uniqExactIf(visitor_fingerprint, visitor_fingerprint != '')").WillReturnRows(mock.NewMockRows([]string{"series", "bucket", "pageviews", "visitors"}).
+		AddRow(uint8(0), time.Date(2025, 3, 29, 0, 0, 0, 0, stockholm), uint64(120), uint64(40)).
+		AddRow(uint8(0), time.Date(2025, 3, 31, 0, 0, 0, 0, stockholm), uint64(80), uint64(30)),
+	)
+	conn.ExpectQuery("GROUP BY session_id").WillReturnRows(mock.NewMockRows([]string{"series", "bucket", "sessions", "bounces", "sessions_with_views", "duration"}).
+		AddRow(uint8(0), time.Date(2025, 3, 29, 0, 0, 0, 0, stockholm), uint64(50), uint64(20), uint64(48), float64(95.5)),
+	)
+	srv := suite.startServer(driver)
+	defer srv.Close()
+
+	// The range spans the switch to daylight saving time, buckets start at local midnight.
+	resp, err := http.Get(srv.URL + "/api/hub/projects/project-1/timeseries?from=2025-03-28T23:00:00Z&to=2025-03-31T22:00:00Z&timezone=Europe/Stockholm")
+	suite.NoError(err)
+	defer resp.Body.Close()
+	suite.Equal(http.StatusOK, resp.StatusCode)
+
+	type point struct {
+		Bucket            string  `json:"bucket"`
+		Pageviews         uint64  `json:"pageviews"`
+		Visitors          uint64  `json:"visitors"`
+		Sessions          uint64  `json:"sessions"`
+		BounceRate        float64 `json:"bounce_rate"`
+		AvgVisitDurationS float64 `json:"avg_visit_duration_s"`
+	}
+	var body struct {
+		Interval string  `json:"interval"`
+		Timezone string  `json:"timezone"`
+		Points   []point `json:"points"`
+	}
+	suite.NoError(json.NewDecoder(resp.Body).Decode(&body))
+	suite.Equal("day", body.Interval)
+	suite.Equal("Europe/Stockholm", body.Timezone)
+	suite.Equal([]point{
+		{Bucket: "2025-03-29T00:00:00+01:00", Pageviews: 120, Visitors: 40, Sessions: 50, BounceRate: 20.0 / 48, AvgVisitDurationS: 95.5},
+		{Bucket: "2025-03-30T00:00:00+01:00"},
+		{Bucket: "2025-03-31T00:00:00+02:00", Pageviews: 80, Visitors: 30},
+	}, body.Points)
+	suite.NoError(conn.AllExpectationsMet())
+}
+
+func (suite *HubAPITestSuite) TestTimeseriesValidation() {
+	_, driver := setupDB(suite.T())
+	srv := suite.startServer(driver)
+	defer srv.Close()
+
+	for _, query := range []string{
+		"from=2025-06-17T00:00:00Z&to=2025-06-16T00:00:00Z",
+		"from=2025-06-16T00:00:00Z&to=2025-06-17T00:00:00Z&interval=minute",
+		"from=2025-06-16T00:00:00Z&to=2025-06-17T00:00:00Z&timezone=Mars/Olympus",
+		"from=2025-06-16T00:00:00Z&to=2025-06-17T00:00:00Z&timezone=Local",
+		"from=2025-06-16T00:00:00Z&to=2025-06-17T00:00:00Z&timezone=UTC')--",
+		"from=2020-01-01T00:00:00Z&to=2025-01-01T00:00:00Z&interval=hour",
+		"from=2025-06-16T00:00:00Z&to=2025-06-17T00:00:00Z&compare=last_week",
+	} {
+		resp, err := http.Get(srv.URL + "/api/hub/projects/project-1/timeseries?" + query)
+		suite.NoError(err)
+		resp.Body.Close()
+		suite.Equal(http.StatusUnprocessableEntity, resp.StatusCode, query)
+	}
+}
+
+func (suite *HubAPITestSuite) TestTimeseriesComparison() {
+	day := func(year, d int) time.Time { return time.Date(year, 6, d, 0, 0, 0, 0, time.UTC) }
+
+	conn, driver := setupDB(suite.T())
+	conn.ExpectQuery("UNION ALL").WillReturnRows(mock.NewMockRows([]string{"series", "bucket", "pageviews", "visitors"}).
+		AddRow(uint8(0), day(2025, 16), uint64(150), uint64(60)).
+		AddRow(uint8(0), day(2025, 17), uint64(90), uint64(30)).
+		AddRow(uint8(1), day(2024, 16), uint64(100), uint64(60)),
+	)
+	conn.ExpectQuery("UNION ALL").WillReturnRows(mock.NewMockRows([]string{"series", "bucket", "sessions", "bounces", "sessions_with_views", "duration"}).
+		AddRow(uint8(0), day(2025, 16), uint64(80), uint64(20), uint64(80), float64(60)).
+		AddRow(uint8(1), day(2024, 16), uint64(64), uint64(32), uint64(64), float64(40)),
+	)
+	srv := suite.startServer(driver)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/hub/projects/project-1/timeseries?from=2025-06-16T00:00:00Z&to=2025-06-18T00:00:00Z&compare=previous_year")
+	suite.NoError(err)
+	defer resp.Body.Close()
+	suite.Equal(http.StatusOK, resp.StatusCode)
+
+	var body hub.TimeseriesResponse
+	suite.NoError(json.NewDecoder(resp.Body).Decode(&body.Body))
+	suite.Equal(day(2024, 16), *body.Body.CompareFrom)
+	suite.Equal(day(2024, 18), *body.Body.CompareTo)
+	suite.Require().Len(body.Body.Points, 2)
+
+	percent := func(p float64) *float64 { return &p }
+	first := body.Body.Points[0]
+	suite.Require().NotNil(first.Compare)
+	suite.True(day(2024, 16).Equal(first.Compare.Bucket))
+	suite.Equal(hub.TimeseriesChange{
+		Pageviews:         hub.Change{Absolute: 50, Percent: percent(50)},
+		Visitors:          hub.Change{Absolute: 0, Percent: percent(0)},
+		Sessions:          hub.Change{Absolute: 16, Percent: percent(25)},
+		BounceRate:        hub.Change{Absolute: -0.25, Percent: percent(-50)},
+		AvgVisitDurationS: hub.Change{Absolute: 20, Percent: percent(50)},
+	}, first.Compare.Change)
+
+	// Without traffic in the compared bucket, changes have no percentage.
+	second := body.Body.Points[1]
+	suite.Require().NotNil(second.Compare)
+	suite.Equal(hub.Change{Absolute: 90}, second.Compare.Change.Pageviews)
+	suite.NoError(conn.AllExpectationsMet())
+}
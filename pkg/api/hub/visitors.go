@@ -0,0 +1,99 @@
+package hub
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/ponrove/ponrove-backend/internal/analytics"
+)
+
+// VisitorProfile is the directory entry of a visitor.
+type VisitorProfile struct {
+	VisitorFingerprint   string    `json:"visitor_fingerprint" doc:"Identifier of the visitor, which rotates daily."`
+	FirstSeen            time.Time `json:"first_seen" doc:"When the visitor was first seen."`
+	LastSeen             time.Time `json:"last_seen" doc:"When the visitor was last seen."`
+	TotalSessions        uint32    `json:"total_sessions" doc:"Number of sessions of the visitor."`
+	TotalEvents          uint64    `json:"total_events" doc:"Number of events of the visitor, pageviews included."`
+	InitialReferrerHost  string    `json:"initial_referrer_host" doc:"Referrer host of the first event of the visitor, empty for direct traffic."`
+	InitialUTMCampaign   string    `json:"initial_utm_campaign" doc:"UTM campaign of the first event of the visitor."`
+	LastKnownCountryCode string    `json:"last_known_country_code" doc:"Country of the latest event of the visitor."`
+	LastKnownDeviceType  string    `json:"last_known_device_type" doc:"Device type of the latest event of the visitor."`
+}
+
+type (
+	VisitorProfileRequest struct {
+		ProjectID          string `path:"project_id" maxLength:"128" doc:"Identifier of the project."`
+		VisitorFingerprint string `path:"visitor_fingerprint" maxLength:"64" doc:"Identifier of the visitor."`
+	}
+	VisitorProfileResponse struct {
+		Body VisitorProfile
+	}
+	VisitorSummaryRequest struct {
+		ProjectID string    `path:"project_id" maxLength:"128" doc:"Identifier of the project."`
+		From      time.Time `query:"from" required:"true" doc:"Start of the range, inclusive."`
+		To        time.Time `query:"to" required:"true" doc:"End of the range, exclusive."`
+	}
+	VisitorSummaryResponse struct {
+		Body struct {
+			Visitors          uint64 `json:"visitors" doc:"Number of visitors active in the range."`
+			NewVisitors       uint64 `json:"new_visitors" doc:"Visitors first seen in the range, with a single session."`
+			ReturningVisitors uint64 `json:"returning_visitors" doc:"Visitors seen before the range, or who came back for another session."`
+		}
+	}
+)
+
+// RegisterVisitorEndpoints registers the endpoints reading the visitor directory, which is kept up to date as events
+// are ingested. Fingerprints rotate daily, so visitors return within the day they were first seen.
+func (a *server) RegisterVisitorEndpoints(api huma.API) {
+	huma.Register(api, huma.Operation{
+		OperationID: "Summarize Visitors",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/visitors/summary",
+		Tags:        []string{"Visitors"},
+	}, func(ctx context.Context, i *VisitorSummaryRequest) (*VisitorSummaryResponse, error) {
+		if !i.From.Before(i.To) {
+			return nil, huma.Error422UnprocessableEntity("validation failed", &huma.ErrorDetail{Location: "query.to", Message: "must be after from", Value: i.To})
+		}
+
+		summary, err := analytics.SummarizeVisitors(ctx, a.clickhouse, i.ProjectID, i.From, i.To)
+		if err != nil {
+			return nil, err
+		}
+
+		resp := &VisitorSummaryResponse{}
+		resp.Body.Visitors = summary.Visitors
+		resp.Body.NewVisitors = summary.NewVisitors
+		resp.Body.ReturningVisitors = summary.ReturningVisitors
+		return resp, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "Get Visitor",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/visitors/{visitor_fingerprint}",
+		Tags:        []string{"Visitors"},
+	}, func(ctx context.Context, i *VisitorProfileRequest) (*VisitorProfileResponse, error) {
+		v, err := analytics.GetVisitor(ctx, a.clickhouse, i.ProjectID, i.VisitorFingerprint)
+		if errors.Is(err, analytics.ErrNotFound) {
+			return nil, huma.Error404NotFound("visitor not found")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return &VisitorProfileResponse{Body: VisitorProfile{
+			VisitorFingerprint:   v.VisitorFingerprint,
+			FirstSeen:            v.FirstSeen,
+			LastSeen:             v.LastSeen,
+			TotalSessions:        v.TotalSessions,
+			TotalEvents:          v.TotalEvents,
+			InitialReferrerHost:  v.InitialReferrerHost,
+			InitialUTMCampaign:   v.InitialUTMCampaign,
+			LastKnownCountryCode: v.LastKnownCountryCode,
+			LastKnownDeviceType:  v.LastKnownDeviceType,
+		}}, nil
+	})
+}
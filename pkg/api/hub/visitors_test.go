@@ -0,0 +1,75 @@
+package hub_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/ponrove/octobe/driver/clickhouse/mock"
+	"github.com/ponrove/ponrove-backend/pkg/api/hub"
+)
+
+func (suite *HubAPITestSuite) TestGetVisitor() {
+	firstSeen := time.Date(2025, 6, 16, 9, 0, 0, 0, time.UTC)
+	lastSeen := time.Date(2025, 6, 16, 17, 30, 0, 0, time.UTC)
+
+	conn, driver := setupDB(suite.T())
+	conn.ExpectQueryRow("FROM visitor_directory").WillReturnRow(mock.NewMockRow(
+		"0123456789abcdef0123456789abcdef", firstSeen, lastSeen, uint32(3), uint64(12), "www.google.com", "summer-sale", "SE", "mobile",
+	))
+	conn.ExpectQueryRow("FROM visitor_directory").WillReturnRow(mock.NewMockRow().WillReturnError(sql.ErrNoRows))
+	srv := suite.startServer(driver)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/hub/projects/project-1/visitors/0123456789abcdef0123456789abcdef")
+	suite.NoError(err)
+	defer resp.Body.Close()
+	suite.Equal(http.StatusOK, resp.StatusCode)
+
+	var visitor hub.VisitorProfile
+	suite.NoError(json.NewDecoder(resp.Body).Decode(&visitor))
+	suite.Equal(hub.VisitorProfile{
+		VisitorFingerprint:   "0123456789abcdef0123456789abcdef",
+		FirstSeen:            firstSeen,
+		LastSeen:             lastSeen,
+		TotalSessions:        3,
+		TotalEvents:          12,
+		InitialReferrerHost:  "www.google.com",
+		InitialUTMCampaign:   "summer-sale",
+		LastKnownCountryCode: "SE",
+		LastKnownDeviceType:  "mobile",
+	}, visitor)
+
+	resp, err = http.Get(srv.URL + "/api/hub/projects/project-1/visitors/unknown")
+	suite.NoError(err)
+	resp.Body.Close()
+	suite.Equal(http.StatusNotFound, resp.StatusCode)
+}
+
+func (suite *HubAPITestSuite) TestSummarizeVisitors() {
+	conn, driver := setupDB(suite.T())
+	conn.ExpectQueryRow("FROM visitor_directory").WillReturnRow(mock.NewMockRow(uint64(120), uint64(45)))
+	srv := suite.startServer(driver)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/hub/projects/project-1/visitors/summary?from=2025-06-16T00:00:00Z&to=2025-06-17T00:00:00Z")
+	suite.NoError(err)
+	defer resp.Body.Close()
+	suite.Equal(http.StatusOK, resp.StatusCode)
+
+	var summary struct {
+		Visitors          uint64 `json:"visitors"`
+		NewVisitors       uint64 `json:"new_visitors"`
+		ReturningVisitors uint64 `json:"returning_visitors"`
+	}
+	suite.NoError(json.NewDecoder(resp.Body).Decode(&summary))
+	suite.Equal(uint64(120), summary.Visitors)
+	suite.Equal(uint64(75), summary.NewVisitors)
+	suite.Equal(uint64(45), summary.ReturningVisitors)
+
+	resp, err = http.Get(srv.URL + "/api/hub/projects/project-1/visitors/summary?from=2025-06-17T00:00:00Z&to=2025-06-16T00:00:00Z")
+	suite.NoError(err)
+	resp.Body.Close()
+	suite.Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+}
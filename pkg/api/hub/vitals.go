@@ -0,0 +1,93 @@
+package hub
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/ponrove/ponrove-backend/internal/analytics"
+)
+
+// VitalPercentiles summarizes the samples of a single web vital.
+type VitalPercentiles struct {
+	Samples uint64  `json:"samples" doc:"Number of page loads reporting the web vital."`
+	P50     float64 `json:"p50" doc:"Median in milliseconds."`
+	P75     float64 `json:"p75" doc:"75th percentile in milliseconds."`
+	P95     float64 `json:"p95" doc:"95th percentile in milliseconds."`
+}
+
+// VitalsGroup holds the web vitals percentiles of the page loads of a path, on a device type, from a country.
+type VitalsGroup struct {
+	URLPath                string            `json:"url_path" doc:"Path of the measured pages."`
+	DeviceType             string            `json:"device_type" doc:"Device type of the visitors, e.g. desktop or mobile."`
+	CountryCode            string            `json:"country_code" doc:"ISO 3166-1 alpha-2 country code of the visitors, empty when unknown."`
+	Samples                uint64            `json:"samples" doc:"Number of page loads with at least one web vital."`
+	PageLoadTime           *VitalPercentiles `json:"page_load_time_ms,omitempty" doc:"Page load time, omitted without samples."`
+	FirstContentfulPaint   *VitalPercentiles `json:"first_contentful_paint_ms,omitempty" doc:"First Contentful Paint (FCP), omitted without samples."`
+	LargestContentfulPaint *VitalPercentiles `json:"largest_contentful_paint_ms,omitempty" doc:"Largest Contentful Paint (LCP), omitted without samples."`
+}
+
+type (
+	VitalsRequest struct {
+		ProjectID string    `path:"project_id" maxLength:"128" doc:"Identifier of the project."`
+		From      time.Time `query:"from" required:"true" doc:"Start of the range, inclusive."`
+		To        time.Time `query:"to" required:"true" doc:"End of the range, exclusive."`
+		Limit     int       `query:"limit" default:"100" minimum:"1" maximum:"1000" doc:"Maximum number of groups, those with the most samples first."`
+		FilterParam
+	}
+	VitalsResponse struct {
+		Body struct {
+			Groups []VitalsGroup `json:"groups" doc:"Web vitals percentiles per URL path, device type and country."`
+		}
+	}
+)
+
+// RegisterVitalsEndpoints registers the endpoints reporting the web vitals of page loads, to track performance
+// regressions.
+func (a *server) RegisterVitalsEndpoints(api huma.API) {
+	huma.Register(api, huma.Operation{
+		OperationID: "Get Web Vitals Percentiles",
+		Method:      http.MethodGet,
+		Path:        "/projects/{project_id}/vitals",
+		Tags:        []string{"Web Vitals"},
+		Description: "Computes the p50, p75 and p95 of the web vitals per URL path, device type and country.",
+	}, func(ctx context.Context, i *VitalsRequest) (*VitalsResponse, error) {
+		if !i.From.Before(i.To) {
+			return nil, huma.Error422UnprocessableEntity("validation failed", &huma.ErrorDetail{Location: "query.to", Message: "must be after from", Value: i.To})
+		}
+
+		f, err := i.parse()
+		if err != nil {
+			return nil, err
+		}
+
+		groups, err := analytics.VitalsPercentiles(ctx, a.clickhouse, i.ProjectID, i.From, i.To, f, i.Limit)
+		if err != nil {
+			return nil, err
+		}
+
+		resp := &VitalsResponse{}
+		resp.Body.Groups = make([]VitalsGroup, 0, len(groups))
+		for _, g := range groups {
+			resp.Body.Groups = append(resp.Body.Groups, VitalsGroup{
+				URLPath:                g.URLPath,
+				DeviceType:             g.DeviceType,
+				CountryCode:            g.CountryCode,
+				Samples:                g.Samples,
+				PageLoadTime:           vitalPercentiles(g.PageLoadTime),
+				FirstContentfulPaint:   vitalPercentiles(g.FirstContentfulPaint),
+				LargestContentfulPaint: vitalPercentiles(g.LargestContentfulPaint),
+			})
+		}
+		return resp, nil
+	})
+}
+
+// vitalPercentiles converts the percentiles of a web vital, nil without samples.
+func vitalPercentiles(p *analytics.Percentiles) *VitalPercentiles {
+	if p == nil {
+		return nil
+	}
+	return &VitalPercentiles{Samples: p.Samples, P50: p.P50, P75: p.P75, P95: p.P95}
+}
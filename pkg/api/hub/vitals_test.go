@@ -0,0 +1,65 @@
+package hub_test
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ponrove/octobe/driver/clickhouse/mock"
+	"github.com/ponrove/ponrove-backend/pkg/api/hub"
+)
+
+func (suite *HubAPITestSuite) TestWebVitalsPercentiles() {
+	columns := []string{"url_path", "device_type", "country_code", "samples", "load_samples", "load", "fcp_samples", "fcp", "lcp_samples", "lcp"}
+	conn, driver := setupDB(suite.T())
+	conn.ExpectQuery("quantilesIf(0.5, 0.75, 0.95)").WillReturnRows(mock.NewMockRows(columns).
+		AddRow("/pricing", "mobile", "SE", uint64(240), uint64(200), []float64{1800, 2400, 4100}, uint64(240), []float64{600, 900, 1500}, uint64(230), []float64{1200, 1900, 3300}).
+		AddRow("/", "desktop", "", uint64(12), uint64(0), []float64{0, 0, 0}, uint64(12), []float64{300, 410, 700}, uint64(0), []float64{0, 0, 0}),
+	)
+	srv := suite.startServer(driver)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/hub/projects/project-1/vitals?from=2025-06-16T00:00:00Z&to=2025-06-17T00:00:00Z")
+	suite.NoError(err)
+	defer resp.Body.Close()
+	suite.Equal(http.StatusOK, resp.StatusCode)
+
+	var body struct {
+		Groups []hub.VitalsGroup `json:"groups"`
+	}
+	suite.NoError(json.NewDecoder(resp.Body).Decode(&body))
+	suite.Equal([]hub.VitalsGroup{
+		{
+			URLPath:                "/pricing",
+			DeviceType:             "mobile",
+			CountryCode:            "SE",
+			Samples:                240,
+			PageLoadTime:           &hub.VitalPercentiles{Samples: 200, P50: 1800, P75: 2400, P95: 4100},
+			FirstContentfulPaint:   &hub.VitalPercentiles{Samples: 240, P50: 600, P75: 900, P95: 1500},
+			LargestContentfulPaint: &hub.VitalPercentiles{Samples: 230, P50: 1200, P75: 1900, P95: 3300},
+		},
+		{
+			URLPath:              "/",
+			DeviceType:           "desktop",
+			Samples:              12,
+			FirstContentfulPaint: &hub.VitalPercentiles{Samples: 12, P50: 300, P75: 410, P95: 700},
+		},
+	}, body.Groups)
+	suite.NoError(conn.AllExpectationsMet())
+}
+
+func (suite *HubAPITestSuite) TestWebVitalsPercentilesValidation() {
+	_, driver := setupDB(suite.T())
+	srv := suite.startServer(driver)
+	defer srv.Close()
+
+	for _, query := range []string{
+		"from=2025-06-17T00:00:00Z&to=2025-06-16T00:00:00Z",
+		"from=2025-06-16T00:00:00Z&to=2025-06-17T00:00:00Z&limit=0",
+		"from=2025-06-16T00:00:00Z&to=2025-06-17T00:00:00Z&limit=1001",
+	} {
+		resp, err := http.Get(srv.URL + "/api/hub/projects/project-1/vitals?" + query)
+		suite.NoError(err)
+		resp.Body.Close()
+		suite.Equal(http.StatusUnprocessableEntity, resp.StatusCode, query)
+	}
+}
@@ -0,0 +1,252 @@
+package ingestion
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/ponrove/ponrove-backend/internal/events"
+)
+
+const (
+	// maxBatchItems is the maximum number of items accepted in a single batch report.
+	maxBatchItems = 100
+
+	// maxBatchBodyBytes is the maximum size of a batch report body.
+	maxBatchBodyBytes = 4 * 1024 * 1024
+
+	contentTypeNDJSON = "application/x-ndjson"
+)
+
+// Batch item statuses reported back to the client.
+const (
+	BatchItemAccepted = "accepted"
+	BatchItemRejected = "rejected"
+)
+
+// BatchItem is a single item of a batch report, exactly one of pageview, event or vitals must be set.
+type BatchItem struct {
+	Pageview *PageviewPayload `json:"pageview,omitempty" doc:"A pageview, same as the body of the pageview endpoint."`
+	Event    *EventPayload    `json:"event,omitempty" doc:"A custom event, same as the body of the event endpoint."`
+	Vitals   *VitalsPayload   `json:"vitals,omitempty" doc:"Web vitals of a page load, same as the body of the vitals endpoint."`
+}
+
+// BatchItemResult reports whether a single item of a batch was accepted, and why it was rejected otherwise.
+type BatchItemResult struct {
+	Index  int                 `json:"index" doc:"Zero based position of the item in the batch."`
+	Status string              `json:"status" enum:"accepted,rejected" doc:"Whether the item was accepted or rejected."`
+	Errors []*huma.ErrorDetail `json:"errors,omitempty" doc:"Validation errors of a rejected item."`
+}
+
+// BatchRequest is the request of the batch endpoint. The body is parsed by hand, as both a JSON array of items and
+// newline delimited JSON are accepted.
+type BatchRequest struct {
+	ClientHeaders
+	ContentType string `header:"Content-Type"`
+	RawBody     []byte `contentType:"application/x-ndjson"`
+}
+
+// BatchResponse is the response of the batch endpoint.
+type BatchResponse struct {
+	Status int `header:"-"`
+	Body   struct {
+		Accepted int               `json:"accepted" doc:"Number of accepted items."`
+		Rejected int               `json:"rejected" doc:"Number of rejected items."`
+		Results  []BatchItemResult `json:"results" doc:"Per item results, in the order of the batch."`
+	}
+}
+
+// RegisterBatchEndpoint registers the endpoint used by clients to report many pageviews, events and web vitals in one
+// request.
+// Every item is validated on its own, and the accepted items are buffered as a whole, or not at all. Dropped bot traffic
+// is reported as accepted, like any other valid item.
+func (a *server) RegisterBatchEndpoint(api huma.API) {
+	registry := api.OpenAPI().Components.Schemas
+	itemSchema := registry.Schema(reflect.TypeOf(BatchItem{}), true, "BatchItem")
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "Report Batch",
+		Method:        http.MethodPost,
+		Path:          "/report/batch",
+		Tags:          []string{"Ingestion"},
+		DefaultStatus: http.StatusAccepted,
+		MaxBodyBytes:  maxBatchBodyBytes,
+		// Items are validated one by one in the handler, so a single invalid item doesn't reject the whole batch.
+		SkipValidateBody: true,
+		Parameters:       ingestionKeyParams(),
+		Middlewares:      a.projectMiddlewares(api),
+		Description:      fmt.Sprintf("Accepts up to %d items, either as a JSON array or as newline delimited JSON (%s). A text/plain body, as sent by navigator.sendBeacon, is read as a JSON array.", maxBatchItems, contentTypeNDJSON),
+		RequestBody: &huma.RequestBody{
+			Required: true,
+			Content: map[string]*huma.MediaType{
+				"application/json": {
+					Schema: &huma.Schema{Type: huma.TypeArray, Items: itemSchema, MaxItems: ptr(maxBatchItems)},
+				},
+			},
+		},
+	}, func(ctx context.Context, i *BatchRequest) (*BatchResponse, error) {
+		items, err := splitBatch(i.ContentType, i.RawBody)
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		resp := &BatchResponse{Status: http.StatusAccepted}
+		resp.Body.Results = make([]BatchItemResult, len(items))
+		accepted := make([]events.Event, 0, len(items))
+		for index, item := range items {
+			event, details := batchItemEvent(ctx, registry, itemSchema, index, item, now, a.authorize)
+			if len(details) > 0 {
+				resp.Body.Rejected++
+				resp.Body.Results[index] = BatchItemResult{Index: index, Status: BatchItemRejected, Errors: details}
+				continue
+			}
+
+			if a.enrich(ctx, &event, i.ClientHeaders) {
+				accepted = append(accepted, event)
+			}
+			resp.Body.Accepted++
+			resp.Body.Results[index] = BatchItemResult{Index: index, Status: BatchItemAccepted}
+		}
+
+		if len(accepted) > 0 {
+			err = a.store(ctx, accepted...)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return resp, nil
+	})
+}
+
+// splitBatch splits the raw body into its items, based on the content type of the request. Blank lines of newline
+// delimited JSON are skipped, while malformed lines are kept so that they are reported as rejected items. Any other
+// content type, such as the text/plain of the tracker script, is read as a JSON array.
+func splitBatch(contentType string, body []byte) ([]json.RawMessage, error) {
+	var items []json.RawMessage
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == contentTypeNDJSON {
+		scanner := bufio.NewScanner(bytes.NewReader(body))
+		scanner.Buffer(make([]byte, 0, 64*1024), maxBatchBodyBytes)
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			items = append(items, json.RawMessage(bytes.Clone(line)))
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, huma.Error400BadRequest("failed to read newline delimited JSON", err)
+		}
+	} else if err := json.Unmarshal(body, &items); err != nil {
+		return nil, huma.Error400BadRequest("expected a JSON array of batch items", err)
+	}
+
+	if len(items) == 0 {
+		return nil, validationError("body", "expected at least one batch item", len(items))
+	}
+
+	if len(items) > maxBatchItems {
+		return nil, validationError("body", fmt.Sprintf("expected at most %d batch items", maxBatchItems), len(items))
+	}
+
+	return items, nil
+}
+
+// batchItemEvent validates a single batch item against its schema, converts it into a raw event and authorizes the
+// event. Errors are returned as error details located within the batch, e.g. body[3].pageview.url.
+func batchItemEvent(ctx context.Context, registry huma.Registry, schema *huma.Schema, index int, item json.RawMessage, now time.Time, authorize func(context.Context, string, events.Event) error) (events.Event, []*huma.ErrorDetail) {
+	pb := huma.NewPathBuffer([]byte{}, 0)
+	pb.Push("body")
+	pb.PushIndex(index)
+	location := pb.String()
+
+	var value any
+	if err := json.Unmarshal(item, &value); err != nil {
+		return events.Event{}, []*huma.ErrorDetail{{Location: location, Message: "invalid JSON: " + err.Error()}}
+	}
+
+	res := &huma.ValidateResult{}
+	huma.Validate(registry, schema, pb, huma.ModeWriteToServer, value, res)
+	if len(res.Errors) > 0 {
+		return events.Event{}, errorDetails(res.Errors...)
+	}
+
+	var batchItem BatchItem
+	if err := json.Unmarshal(item, &batchItem); err != nil {
+		return events.Event{}, []*huma.ErrorDetail{{Location: location, Message: err.Error()}}
+	}
+
+	var (
+		event    events.Event
+		err      error
+		itemType string
+	)
+	switch {
+	case countSet(batchItem.Pageview != nil, batchItem.Event != nil, batchItem.Vitals != nil) > 1:
+		return events.Event{}, []*huma.ErrorDetail{{Location: location, Message: "expected exactly one of pageview, event or vitals"}}
+	case batchItem.Pageview != nil:
+		itemType = "pageview"
+		event, err = batchItem.Pageview.event(location+"."+itemType, now)
+	case batchItem.Event != nil:
+		itemType = "event"
+		event, err = batchItem.Event.event(location+"."+itemType, now)
+	case batchItem.Vitals != nil:
+		itemType = "vitals"
+		event, err = batchItem.Vitals.event(location+"."+itemType, now)
+	default:
+		return events.Event{}, []*huma.ErrorDetail{{Location: location, Message: "expected one of pageview, event or vitals"}}
+	}
+	if err == nil {
+		err = authorize(ctx, location+"."+itemType, event)
+	}
+	if err != nil {
+		return events.Event{}, errorDetails(err)
+	}
+
+	return event, nil
+}
+
+// errorDetails flattens validation errors into error details, unwrapping the details of huma error models.
+func errorDetails(errs ...error) []*huma.ErrorDetail {
+	details := make([]*huma.ErrorDetail, 0, len(errs))
+	for _, err := range errs {
+		var detail *huma.ErrorDetail
+		var model *huma.ErrorModel
+		switch {
+		case errors.As(err, &model) && len(model.Errors) > 0:
+			details = append(details, model.Errors...)
+		case errors.As(err, &detail):
+			details = append(details, detail)
+		default:
+			details = append(details, &huma.ErrorDetail{Message: err.Error()})
+		}
+	}
+	return details
+}
+
+// countSet returns the number of true values.
+func countSet(set ...bool) int {
+	n := 0
+	for _, s := range set {
+		if s {
+			n++
+		}
+	}
+	return n
+}
+
+// ptr returns a pointer to the given value.
+func ptr[T any](v T) *T {
+	return &v
+}
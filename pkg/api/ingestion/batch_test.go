@@ -0,0 +1,154 @@
+package ingestion_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ponrove/configura"
+	"github.com/ponrove/ponrove-backend/internal/events"
+	"github.com/ponrove/ponrove-backend/pkg/api/ingestion"
+)
+
+type batchResponseBody struct {
+	Accepted int `json:"accepted"`
+	Rejected int `json:"rejected"`
+	Results  []struct {
+		Index  int    `json:"index"`
+		Status string `json:"status"`
+		Errors []struct {
+			Location string `json:"location"`
+		} `json:"errors"`
+	} `json:"results"`
+}
+
+func (suite *IngestionAPITestSuite) postBatch(url, contentType, body string) (*http.Response, batchResponseBody) {
+	var decoded batchResponseBody
+	resp, err := http.Post(url+"/api/ingestion/report/batch", contentType, strings.NewReader(body))
+	suite.Require().NoError(err)
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusAccepted {
+		suite.NoError(json.NewDecoder(resp.Body).Decode(&decoded))
+	}
+	return resp, decoded
+}
+
+func (suite *IngestionAPITestSuite) TestBatchEndpointJSONArray() {
+	conn, driver := setupBatchDB(suite.T())
+	srv := suite.createServer(driver)
+	defer srv.Close()
+
+	resp, body := suite.postBatch(srv.URL, "application/json", `[
+		{"pageview": {"project_id": "project-1", "url": "https://example.com/", "timestamp": "2025-06-16T12:00:00Z"}},
+		{"event": {"project_id": "project-1", "event_name": "signup", "timestamp": "2025-06-16T12:00:01Z"}},
+		{"pageview": {"project_id": "project-1", "url": "/relative"}},
+		{"event": {"project_id": "project-1"}},
+		{},
+		{"pageview": {"project_id": "project-1", "url": "https://example.com/"}, "event": {"project_id": "project-1", "event_name": "click"}}
+	]`)
+	suite.Equal(http.StatusAccepted, resp.StatusCode)
+	suite.Equal(2, body.Accepted)
+	suite.Equal(4, body.Rejected)
+	suite.Require().Len(body.Results, 6)
+
+	suite.Equal("accepted", body.Results[0].Status)
+	suite.Equal("accepted", body.Results[1].Status)
+	suite.Equal("rejected", body.Results[2].Status)
+	suite.Equal("body[2].pageview.url", body.Results[2].Errors[0].Location)
+	suite.Equal("rejected", body.Results[3].Status)
+	suite.Equal("body[3].event", body.Results[3].Errors[0].Location)
+	suite.Equal("rejected", body.Results[4].Status)
+	suite.Equal("body[4]", body.Results[4].Errors[0].Location)
+	suite.Equal("rejected", body.Results[5].Status)
+	suite.Equal("body[5]", body.Results[5].Errors[0].Location)
+
+	// Accepted items are buffered together, and written in a single native batch.
+	suite.Eventually(func() bool { return len(conn.sent()) == 1 }, time.Second, time.Millisecond)
+	suite.Equal(events.InsertStatement, conn.query)
+	batches := conn.sent()
+	suite.Require().Len(batches[0], 2)
+	suite.Equal(events.EventNamePageview, batches[0][0][2])
+	suite.Equal("signup", batches[0][1][2])
+}
+
+func (suite *IngestionAPITestSuite) TestBatchEndpointNDJSON() {
+	conn, driver := setupBatchDB(suite.T())
+	srv := suite.createServer(driver)
+	defer srv.Close()
+
+	resp, body := suite.postBatch(srv.URL, "application/x-ndjson; charset=utf-8", strings.Join([]string{
+		`{"pageview": {"project_id": "project-1", "url": "https://example.com/"}}`,
+		``,
+		`{"event": {"project_id": "project-1", "event_name": "click"}}`,
+		`{not json}`,
+	}, "\n"))
+	suite.Equal(http.StatusAccepted, resp.StatusCode)
+	suite.Equal(2, body.Accepted)
+	suite.Equal(1, body.Rejected)
+	suite.Require().Len(body.Results, 3)
+	suite.Equal("rejected", body.Results[2].Status)
+	suite.Equal("body[2]", body.Results[2].Errors[0].Location)
+
+	suite.Eventually(func() bool { return len(conn.rows()) == 2 }, time.Second, time.Millisecond)
+}
+
+func (suite *IngestionAPITestSuite) TestBatchEndpointAllRejected() {
+	conn, driver := setupBatchDB(suite.T())
+	srv := suite.createServer(driver)
+	defer srv.Close()
+
+	resp, body := suite.postBatch(srv.URL, "application/json", `[{"event": {"project_id": "project-1", "event_name": "page_view"}}]`)
+	suite.Equal(http.StatusAccepted, resp.StatusCode)
+	suite.Equal(0, body.Accepted)
+	suite.Equal(1, body.Rejected)
+	time.Sleep(20 * time.Millisecond)
+	suite.Empty(conn.sent(), "no batch should be sent without accepted items")
+}
+
+func (suite *IngestionAPITestSuite) TestBatchEndpointInvalidBody() {
+	_, driver := setupBatchDB(suite.T())
+	srv := suite.createServer(driver)
+	defer srv.Close()
+
+	tooMany := make([]string, 101)
+	for i := range tooMany {
+		tooMany[i] = `{"event": {"project_id": "project-1", "event_name": "click"}}`
+	}
+
+	testCases := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+	}{
+		{name: "Not an array", contentType: "application/json", body: `{"event": {}}`, status: http.StatusBadRequest},
+		{name: "Empty array", contentType: "application/json", body: `[]`, status: http.StatusUnprocessableEntity},
+		{name: "Empty NDJSON", contentType: "application/x-ndjson", body: "\n\n", status: http.StatusUnprocessableEntity},
+		{name: "Too many items", contentType: "application/json", body: "[" + strings.Join(tooMany, ",") + "]", status: http.StatusUnprocessableEntity},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			resp, _ := suite.postBatch(srv.URL, tc.contentType, tc.body)
+			suite.Equal(tc.status, resp.StatusCode)
+		})
+	}
+}
+
+func (suite *IngestionAPITestSuite) TestBatchEndpointBufferFull() {
+	conn, driver := setupBatchDB(suite.T())
+	srv := suite.createServer(driver, map[configura.Variable[int64]]int64{
+		ingestion.INGESTION_BUFFER_MAX_EVENTS: 1,
+	})
+	defer srv.Close()
+
+	// A batch is buffered as a whole or not at all, two events never fit in a buffer of one.
+	resp, _ := suite.postBatch(srv.URL, "application/json", `[
+		{"event": {"project_id": "project-1", "event_name": "click"}},
+		{"event": {"project_id": "project-1", "event_name": "click"}}
+	]`)
+	suite.Equal(http.StatusServiceUnavailable, resp.StatusCode)
+	time.Sleep(20 * time.Millisecond)
+	suite.Empty(conn.rows())
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/danielgtaylor/huma/v2/adapters/humachi"
 	"github.com/go-chi/chi/v5"
 	"github.com/ponrove/configura"
+	"github.com/ponrove/ponrove-backend/internal/projects"
 	"github.com/ponrove/ponrove-backend/pkg/api/ingestion"
 	"github.com/ponrove/ponrove-backend/pkg/config"
 	"github.com/stretchr/testify/assert"
@@ -20,6 +21,7 @@ func TestRequiredConfiguration(t *testing.T) {
 	_, driver := setupDB(t)
 	err := ingestion.Register(
 		ingestion.WithClickhouseDriver(driver),
+		ingestion.WithProjectStore(projects.NewMemoryStore()),
 	)(cfg, humachi.New(chi.NewRouter(), huma.DefaultConfig("", "")))
 	assert.NoError(t, err, "failed to register users API")
 
@@ -0,0 +1,107 @@
+package ingestion
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/open-feature/go-sdk/openfeature"
+	"github.com/ponrove/ponrove-backend/internal/bots"
+	"github.com/ponrove/ponrove-backend/internal/events"
+)
+
+// flagDropBotTraffic is the feature flag deciding, per project, whether bot traffic is dropped instead of stored
+// flagged. Projects are the targeting key of the evaluation context.
+const flagDropBotTraffic = "ingestion-drop-bot-traffic"
+
+// ClientHeaders are the request headers describing the client that sent a report, along with the connection details
+// used to resolve its IP address. The IP address is only used for enrichment, and never stored.
+type ClientHeaders struct {
+	UserAgent      string `header:"User-Agent"`
+	SecCHUA        string `header:"Sec-CH-UA" doc:"Brands of Chromium based browsers, used to detect headless browsers."`
+	AcceptLanguage string `header:"Accept-Language"`
+
+	remoteAddr   string
+	forwardedFor []string
+}
+
+// Resolve captures the remote address and all X-Forwarded-For headers of the request, which huma doesn't expose as
+// parameters.
+func (c *ClientHeaders) Resolve(ctx huma.Context) []error {
+	c.remoteAddr = ctx.RemoteAddr()
+	ctx.EachHeader(func(name, value string) {
+		if strings.EqualFold(name, "X-Forwarded-For") {
+			c.forwardedFor = append(c.forwardedFor, value)
+		}
+	})
+	return nil
+}
+
+// enrich fills in the columns of an accepted event derived from the request, and reports whether the event should be
+// stored. The user agent header identifies the visitor unless the payload supplied a user agent of its own, as
+// server-side reports do. The location and IP reputation are only resolved for client-side reports, the address of a
+// server reporting on behalf of a visitor says nothing about the visitor. Their fingerprint is derived from the visitor
+// IP of the payload instead, and server-side events without one are left without a visitor and session rather than
+// sharing one. Events classified as bots are not stored when the project drops bot traffic.
+func (a *server) enrich(ctx context.Context, event *events.Event, client ClientHeaders) bool {
+	if event.UserAgent == "" {
+		event.UserAgent = client.UserAgent
+	}
+
+	ua := a.userAgents.Parse(event.UserAgent)
+	event.BrowserName = ua.BrowserName
+	event.BrowserVersion = ua.BrowserVersion
+	event.OSName = ua.OSName
+	event.OSVersion = ua.OSVersion
+	event.DeviceType = ua.DeviceType
+
+	// Bot traffic is classified first, so dropped events never reach the session state.
+	botName, isBot := a.bots.Classify(bots.Signals{
+		UserAgent:      event.UserAgent,
+		SecCHUA:        client.SecCHUA,
+		AcceptLanguage: client.AcceptLanguage,
+		Headers:        event.Source != events.SourceServer,
+	})
+	if isBot {
+		if a.dropBotTraffic(ctx, event.ProjectID) {
+			return false
+		}
+		event.IsBot = true
+		event.BotName = &botName
+	}
+
+	addr := event.VisitorIP
+	if event.Source != events.SourceServer {
+		if resolved, ok := a.clientIPs.Resolve(client.remoteAddr, client.forwardedFor...); ok {
+			addr = resolved
+			loc := a.geoip.Lookup(addr)
+			event.CountryCode = loc.CountryCode
+			event.RegionName = loc.RegionName
+			event.CityName = loc.CityName
+
+			rep := a.reputation.Lookup(addr)
+			event.IsVPN = rep.IsVPN
+			event.VPNProvider = rep.VPNProvider
+			event.IsProxy = rep.IsProxy
+			event.ProxyProvider = rep.ProxyProvider
+			event.IsTorNode = rep.IsTorNode
+		}
+	}
+	if event.Source != events.SourceServer || addr.IsValid() {
+		event.VisitorFingerprint = a.fingerprints.Fingerprint(ctx, event.ProjectID, addr, event.UserAgent)
+		event.SessionID = a.sessions.Assign(ctx, event.ProjectID, event.VisitorFingerprint, event.EventTimestamp)
+	}
+
+	return true
+}
+
+// dropBotTraffic reports whether bot traffic of the project is dropped, defaulting to the configuration when the flag
+// can't be evaluated.
+func (a *server) dropBotTraffic(ctx context.Context, projectID string) bool {
+	drop, err := a.openfeatureClient.BooleanValue(ctx, flagDropBotTraffic, a.config.Bool(INGESTION_BOTS_DROP), openfeature.NewEvaluationContext(projectID, nil))
+	if err != nil {
+		slog.DebugContext(ctx, "Failed to evaluate feature flag, using default", slog.String("flag", flagDropBotTraffic), slog.Any("error", err))
+	}
+	return drop
+}
@@ -0,0 +1,118 @@
+package ingestion
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/ponrove/ponrove-backend/internal/events"
+	"github.com/shopspring/decimal"
+)
+
+// EventPayload is the body of a custom event report.
+type EventPayload struct {
+	ProjectID        string            `json:"project_id" minLength:"1" maxLength:"128" doc:"Identifier of the project the event belongs to."`
+	EventName        string            `json:"event_name" minLength:"1" maxLength:"64" pattern:"^[A-Za-z][A-Za-z0-9_.:-]*$" example:"signup" doc:"Name of the event, e.g. click, form_submit or signup. The names page_view and web_vitals are reserved for pageviews and web vitals."`
+	URL              string            `json:"url,omitempty" maxLength:"4096" doc:"Absolute http(s) URL of the page the event occurred on."`
+	Referrer         string            `json:"referrer,omitempty" maxLength:"4096" doc:"Full URL of the referring page."`
+	Timestamp        time.Time         `json:"timestamp,omitempty" doc:"When the event occurred, defaults to the time the event is received."`
+	CustomProperties map[string]string `json:"custom_properties,omitempty" maxProperties:"32" doc:"Custom key-value data attached to the event, at most 32 properties with keys up to 64 and values up to 512 characters."`
+	TimeOnPageS      uint16            `json:"time_on_page_s,omitempty" doc:"Time in seconds the visitor spent on the page, e.g. when reporting the engagement of a page left by the visitor."`
+	UserAgent        string            `json:"user_agent,omitempty" maxLength:"1024" doc:"User agent of the visitor, for events reported by a server on behalf of the visitor. Marks the event as server-side, and takes precedence over the User-Agent header."`
+	VisitorIP        string            `json:"visitor_ip,omitempty" maxLength:"64" doc:"IP address of the visitor, for events reported by a server on behalf of the visitor along with their user agent. Only derives the visitor fingerprint, and is never stored. Server-side events without it have no visitor nor session."`
+	Revenue          *RevenuePayload   `json:"revenue,omitempty" doc:"Revenue attached to the event, e.g. the total of a purchase."`
+}
+
+// RevenuePayload is the revenue attached to a custom event.
+type RevenuePayload struct {
+	Amount   string `json:"amount" pattern:"^[0-9]{1,14}(\\.[0-9]{1,4})?$" example:"49.90" doc:"Amount of the revenue as a decimal string, with at most 14 integer and 4 fractional digits."`
+	Currency string `json:"currency" pattern:"^[A-Z]{3}$" example:"EUR" doc:"ISO 4217 code of the currency of the amount."`
+}
+
+// EventRequest is the request of the custom event endpoint.
+type EventRequest struct {
+	ClientHeaders
+	Body EventPayload
+}
+
+// RegisterEventEndpoint registers the endpoint used by clients to report custom events, such as clicks and signups.
+func (a *server) RegisterEventEndpoint(api huma.API) {
+	huma.Register(api, huma.Operation{
+		OperationID:   "Report Event",
+		Method:        http.MethodPost,
+		Path:          "/report/event",
+		Tags:          []string{"Ingestion"},
+		DefaultStatus: http.StatusAccepted,
+		Parameters:    ingestionKeyParams(),
+		Middlewares:   a.projectMiddlewares(api),
+	}, func(ctx context.Context, i *EventRequest) (*IngestionEndpointResponse, error) {
+		event, err := i.Body.event("body", time.Now())
+		if err != nil {
+			return nil, err
+		}
+		err = a.authorize(ctx, "body", event)
+		if err != nil {
+			return nil, err
+		}
+		if a.enrich(ctx, &event, i.ClientHeaders) {
+			err = a.store(ctx, event)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return accepted("Event accepted."), nil
+	})
+}
+
+// event converts the payload into a raw event. The returned error is a huma status error pointing at the offending
+// field, relative to location.
+func (p *EventPayload) event(location string, now time.Time) (events.Event, error) {
+	if p.EventName == events.EventNamePageview {
+		return events.Event{}, validationError(location+".event_name", "event name is reserved, use the pageview endpoint", p.EventName)
+	}
+	if p.EventName == events.EventNameWebVitals {
+		return events.Event{}, validationError(location+".event_name", "event name is reserved, use the vitals endpoint", p.EventName)
+	}
+
+	timestamp, err := resolveTimestamp(location+".timestamp", p.Timestamp, now)
+	if err != nil {
+		return events.Event{}, err
+	}
+
+	err = validateCustomProperties(location+".custom_properties", p.CustomProperties)
+	if err != nil {
+		return events.Event{}, err
+	}
+
+	event := events.Event{
+		ProjectID:        p.ProjectID,
+		EventTimestamp:   timestamp,
+		EventName:        p.EventName,
+		Source:           events.SourceClient,
+		TimeOnPageS:      p.TimeOnPageS,
+		CustomProperties: p.CustomProperties,
+	}
+
+	if p.Revenue != nil {
+		amount, err := decimal.NewFromString(p.Revenue.Amount)
+		if err != nil {
+			return events.Event{}, validationError(location+".revenue.amount", "invalid decimal amount", p.Revenue.Amount)
+		}
+		event.RevenueAmount = &amount
+		event.RevenueCurrency = p.Revenue.Currency
+	}
+
+	err = applyServerSide(&event, location, p.UserAgent, p.VisitorIP)
+	if err != nil {
+		return events.Event{}, err
+	}
+
+	err = applyPageContext(&event, location, p.URL, p.Referrer, true)
+	if err != nil {
+		return events.Event{}, err
+	}
+
+	return event, nil
+}
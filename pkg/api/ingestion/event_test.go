@@ -0,0 +1,231 @@
+package ingestion_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ponrove/ponrove-backend/internal/events"
+	"github.com/ponrove/ponrove-backend/internal/session"
+	"github.com/shopspring/decimal"
+)
+
+func (suite *IngestionAPITestSuite) TestEventEndpoint() {
+	var body struct {
+		Message string `json:"message"`
+	}
+
+	// Reported by a script rather than a browser, the event is flagged as bot traffic.
+	botName := "Go HTTP client"
+	expected := events.Event{
+		ProjectID:        "project-1",
+		EventTimestamp:   time.Date(2025, 6, 16, 12, 0, 0, 0, time.UTC),
+		EventName:        "signup",
+		Source:           events.SourceClient,
+		URL:              "https://example.com/signup",
+		URLPath:          "/signup",
+		URLHost:          "example.com",
+		IsBot:            true,
+		BotName:          &botName,
+		UserAgent:        "Go-http-client/1.1",
+		CustomProperties: map[string]string{"plan": "pro"},
+	}
+
+	conn, driver := setupBatchDB(suite.T())
+	srv := suite.createServer(driver)
+	defer srv.Close()
+
+	resp, err := postJSON(srv.URL+"/api/ingestion/report/event", `{
+		"project_id": "project-1",
+		"event_name": "signup",
+		"url": "https://example.com/signup",
+		"timestamp": "2025-06-16T12:00:00Z",
+		"custom_properties": {"plan": "pro"}
+	}`)
+	suite.NoError(err)
+	defer resp.Body.Close()
+	suite.Equal(http.StatusAccepted, resp.StatusCode)
+	suite.NoError(json.NewDecoder(resp.Body).Decode(&body))
+	suite.Equal("Event accepted.", body.Message)
+
+	suite.Eventually(func() bool { return len(conn.rows()) == 1 }, time.Second, time.Millisecond)
+	row := conn.rows()[0]
+	expected.VisitorFingerprint = suite.fingerprint(row)
+	expected.SessionID = session.ID(expected.ProjectID, expected.VisitorFingerprint, expected.EventTimestamp)
+	suite.Equal(expected.Values(), row)
+}
+
+func (suite *IngestionAPITestSuite) TestEventEndpointServerSide() {
+	userAgent := "Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Mobile/15E148 Safari/604.1"
+	amount := decimal.RequireFromString("49.90")
+	expected := events.Event{
+		ProjectID:       "project-1",
+		EventTimestamp:  time.Date(2025, 6, 16, 12, 0, 0, 0, time.UTC),
+		EventName:       "purchase",
+		Source:          events.SourceServer,
+		UserAgent:       userAgent,
+		BrowserName:     "Safari",
+		BrowserVersion:  "17.5",
+		OSName:          "iOS",
+		OSVersion:       "17.5",
+		DeviceType:      "mobile",
+		RevenueAmount:   &amount,
+		RevenueCurrency: "EUR",
+	}
+
+	conn, driver := setupBatchDB(suite.T())
+	srv := suite.createServer(driver)
+	defer srv.Close()
+
+	// Reported by a backend, the user agent of the visitor takes precedence over the one of the backend, and the IP
+	// address of the visitor keys their fingerprint without being stored.
+	report := func(visitorIP string) {
+		resp, err := postJSON(srv.URL+"/api/ingestion/report/event", `{
+			"project_id": "project-1",
+			"event_name": "purchase",
+			"timestamp": "2025-06-16T12:00:00Z",
+			"user_agent": "`+userAgent+`",
+			"visitor_ip": "`+visitorIP+`",
+			"revenue": {"amount": "49.90", "currency": "EUR"}
+		}`)
+		suite.NoError(err)
+		defer resp.Body.Close()
+		suite.Equal(http.StatusAccepted, resp.StatusCode)
+	}
+	report("203.0.113.7")
+	suite.Eventually(func() bool { return len(conn.rows()) == 1 }, time.Second, time.Millisecond)
+	report("198.51.100.1")
+	suite.Eventually(func() bool { return len(conn.rows()) == 2 }, time.Second, time.Millisecond)
+
+	rows := conn.rows()
+	expected.VisitorFingerprint = suite.fingerprint(rows[0])
+	expected.SessionID = session.ID(expected.ProjectID, expected.VisitorFingerprint, expected.EventTimestamp)
+	suite.Equal(expected.Values(), rows[0])
+
+	// Visitors sharing the user agent of a backend are told apart by their IP address.
+	suite.NotEqual(expected.VisitorFingerprint, suite.fingerprint(rows[1]))
+}
+
+func (suite *IngestionAPITestSuite) TestEventEndpointServerSideWithoutVisitorIP() {
+	conn, driver := setupBatchDB(suite.T())
+	srv := suite.createServer(driver)
+	defer srv.Close()
+
+	// Without the IP address of the visitor, server-side events have no visitor rather than one shared by every visitor
+	// with the user agent.
+	resp, err := postJSON(srv.URL+"/api/ingestion/report/event", `{
+		"project_id": "project-1",
+		"event_name": "purchase",
+		"timestamp": "2025-06-16T12:00:00Z",
+		"user_agent": "Go-http-client/1.1"
+	}`)
+	suite.NoError(err)
+	defer resp.Body.Close()
+	suite.Equal(http.StatusAccepted, resp.StatusCode)
+
+	suite.Eventually(func() bool { return len(conn.rows()) == 1 }, time.Second, time.Millisecond)
+	row := conn.rows()[0]
+	suite.Empty(row[slices.Index(events.Columns, "visitor_fingerprint")])
+	suite.Empty(row[slices.Index(events.Columns, "session_id")])
+}
+
+func (suite *IngestionAPITestSuite) TestEventEndpointValidation() {
+	tooManyProperties := make([]string, 0, 33)
+	for i := range 33 {
+		tooManyProperties = append(tooManyProperties, `"key`+strings.Repeat("x", i)+`": "value"`)
+	}
+
+	testCases := []struct {
+		name     string
+		body     string
+		location string
+	}{
+		{
+			name:     "Invalid event name",
+			body:     `{"project_id": "project-1", "event_name": "form submit"}`,
+			location: "body.event_name",
+		},
+		{
+			name:     "Reserved event name",
+			body:     `{"project_id": "project-1", "event_name": "page_view"}`,
+			location: "body.event_name",
+		},
+		{
+			name:     "Reserved web vitals event name",
+			body:     `{"project_id": "project-1", "event_name": "web_vitals"}`,
+			location: "body.event_name",
+		},
+		{
+			name:     "Invalid URL",
+			body:     `{"project_id": "project-1", "event_name": "click", "url": "example.com"}`,
+			location: "body.url",
+		},
+		{
+			name:     "Too many custom properties",
+			body:     `{"project_id": "project-1", "event_name": "click", "custom_properties": {` + strings.Join(tooManyProperties, ",") + `}}`,
+			location: "body.custom_properties",
+		},
+		{
+			name:     "Custom property key too long",
+			body:     `{"project_id": "project-1", "event_name": "click", "custom_properties": {"` + strings.Repeat("k", 65) + `": "value"}}`,
+			location: "body.custom_properties." + strings.Repeat("k", 65),
+		},
+		{
+			name:     "Custom property value too long",
+			body:     `{"project_id": "project-1", "event_name": "click", "custom_properties": {"plan": "` + strings.Repeat("v", 513) + `"}}`,
+			location: "body.custom_properties.plan",
+		},
+		{
+			name:     "Negative revenue",
+			body:     `{"project_id": "project-1", "event_name": "purchase", "revenue": {"amount": "-1", "currency": "EUR"}}`,
+			location: "body.revenue.amount",
+		},
+		{
+			name:     "Revenue with too many fractional digits",
+			body:     `{"project_id": "project-1", "event_name": "purchase", "revenue": {"amount": "1.00001", "currency": "EUR"}}`,
+			location: "body.revenue.amount",
+		},
+		{
+			name:     "Invalid revenue currency",
+			body:     `{"project_id": "project-1", "event_name": "purchase", "revenue": {"amount": "1", "currency": "eur"}}`,
+			location: "body.revenue.currency",
+		},
+		{
+			name:     "Invalid visitor IP",
+			body:     `{"project_id": "project-1", "event_name": "purchase", "user_agent": "Mozilla/5.0", "visitor_ip": "203.0.113"}`,
+			location: "body.visitor_ip",
+		},
+		{
+			name:     "Visitor IP without user agent",
+			body:     `{"project_id": "project-1", "event_name": "purchase", "visitor_ip": "203.0.113.7"}`,
+			location: "body.visitor_ip",
+		},
+	}
+
+	conn, driver := setupBatchDB(suite.T())
+	srv := suite.createServer(driver)
+	defer srv.Close()
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			var body struct {
+				Errors []struct {
+					Location string `json:"location"`
+				} `json:"errors"`
+			}
+
+			resp, err := postJSON(srv.URL+"/api/ingestion/report/event", tc.body)
+			suite.NoError(err)
+			defer resp.Body.Close()
+			suite.Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+			suite.NoError(json.NewDecoder(resp.Body).Decode(&body))
+			suite.Require().NotEmpty(body.Errors)
+			suite.Equal(tc.location, body.Errors[0].Location)
+		})
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	suite.Empty(conn.rows(), "rejected events should never be stored")
+}
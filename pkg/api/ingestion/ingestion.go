@@ -2,29 +2,97 @@ package ingestion
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/open-feature/go-sdk/openfeature"
 	"github.com/ponrove/configura"
 	"github.com/ponrove/octobe/driver/clickhouse"
+	"github.com/ponrove/ponrove-backend/internal/bots"
+	"github.com/ponrove/ponrove-backend/internal/buffer"
+	"github.com/ponrove/ponrove-backend/internal/clientip"
 	"github.com/ponrove/ponrove-backend/internal/database"
+	"github.com/ponrove/ponrove-backend/internal/events"
+	"github.com/ponrove/ponrove-backend/internal/fingerprint"
+	"github.com/ponrove/ponrove-backend/internal/geoip"
+	"github.com/ponrove/ponrove-backend/internal/iprep"
+	"github.com/ponrove/ponrove-backend/internal/projects"
+	"github.com/ponrove/ponrove-backend/internal/session"
+	"github.com/ponrove/ponrove-backend/internal/shutdown"
+	"github.com/ponrove/ponrove-backend/internal/spool"
+	"github.com/ponrove/ponrove-backend/internal/useragent"
 	"github.com/ponrove/ponrunner"
 )
 
 const (
 	INGESTION_API_TEST_FLAG configura.Variable[bool] = "INGESTION_API_TEST_FLAG"
+
+	// Write buffer between the ingestion handlers and ClickHouse
+	INGESTION_BUFFER_MAX_BATCH_SIZE    configura.Variable[int64] = "INGESTION_BUFFER_MAX_BATCH_SIZE"
+	INGESTION_BUFFER_MAX_EVENTS        configura.Variable[int64] = "INGESTION_BUFFER_MAX_EVENTS"
+	INGESTION_BUFFER_FLUSH_INTERVAL_MS configura.Variable[int64] = "INGESTION_BUFFER_FLUSH_INTERVAL_MS"
+
+	// Disk spool holding the batches that failed to flush to ClickHouse, an empty directory disables the spool
+	INGESTION_SPOOL_DIR                configura.Variable[string] = "INGESTION_SPOOL_DIR"
+	INGESTION_SPOOL_MAX_BYTES          configura.Variable[int64]  = "INGESTION_SPOOL_MAX_BYTES"
+	INGESTION_SPOOL_REPLAY_INTERVAL_MS configura.Variable[int64]  = "INGESTION_SPOOL_REPLAY_INTERVAL_MS"
+
+	// Rule set used to parse user agents, an empty path uses the rules embedded in the binary
+	INGESTION_USERAGENT_RULES_FILE configura.Variable[string] = "INGESTION_USERAGENT_RULES_FILE"
+
+	// Bot detection, the deny list holds comma separated user agent fragments classified as bots. Bot traffic is stored
+	// flagged, unless dropping it is enabled, per project through the ingestion-drop-bot-traffic feature flag.
+	INGESTION_BOTS_DENY_LIST configura.Variable[string] = "INGESTION_BOTS_DENY_LIST"
+	INGESTION_BOTS_DROP      configura.Variable[bool]   = "INGESTION_BOTS_DROP"
+
+	// Client IP resolution and GeoIP enrichment. Trusted proxies are comma separated addresses and CIDR networks whose
+	// X-Forwarded-For headers are honoured, an empty database path disables GeoIP enrichment
+	INGESTION_TRUSTED_PROXIES    configura.Variable[string] = "INGESTION_TRUSTED_PROXIES"
+	INGESTION_GEOIP_DATABASE     configura.Variable[string] = "INGESTION_GEOIP_DATABASE"
+	INGESTION_RELOAD_INTERVAL_MS configura.Variable[int64]  = "INGESTION_RELOAD_INTERVAL_MS"
+
+	// IP reputation lists, comma separated paths of files listing the addresses and CIDR networks of VPN providers,
+	// proxies and datacenters, and Tor exit nodes. Lists are reloaded like the GeoIP database
+	INGESTION_IPREP_VPN_LISTS   configura.Variable[string] = "INGESTION_IPREP_VPN_LISTS"
+	INGESTION_IPREP_PROXY_LISTS configura.Variable[string] = "INGESTION_IPREP_PROXY_LISTS"
+	INGESTION_IPREP_TOR_LISTS   configura.Variable[string] = "INGESTION_IPREP_TOR_LISTS"
+
+	// Project registry, when enforced reports must carry the ingestion key of a registered project, and be sent from its
+	// allowed hosts. Enforcement is off by default, enable it once every tracker sends the ingestion key of its project.
+	// Projects are cached, and refreshed from the store every interval
+	INGESTION_PROJECTS_ENFORCE             configura.Variable[bool]  = "INGESTION_PROJECTS_ENFORCE"
+	INGESTION_PROJECTS_REFRESH_INTERVAL_MS configura.Variable[int64] = "INGESTION_PROJECTS_REFRESH_INTERVAL_MS"
 )
 
+// ErrInvalidInterval is returned by Register when an interval of the configuration is not positive.
+var ErrInvalidInterval = errors.New("invalid interval")
+
 type server struct {
 	openfeatureClient *openfeature.Client
 	config            configura.Config
 	clickhouse        clickhouse.Driver
+	buffer            *buffer.Buffer
+	userAgents        *useragent.Parser
+	bots              *bots.Classifier
+	clientIPs         *clientip.Resolver
+	geoip             *geoip.Database
+	reputation        *iprep.Reputation
+	fingerprints      *fingerprint.Hasher
+	sessions          *session.Sessionizer
+	registry          *projects.Registry
 }
 
 // ingestionAPIConfig holds the configuration for the Ingestion API.
 type ingestionAPIConfig struct {
 	clickhouseDriver clickhouse.Driver
+	saltStore        fingerprint.SaltStore
+	sessionHistory   session.History
+	projectStore     projects.Store
 }
 
 // Option is a function that modifies the api configuration.
@@ -37,6 +105,30 @@ func WithClickhouseDriver(driver clickhouse.Driver) Option {
 	}
 }
 
+// WithSaltStore allows setting a custom store for the daily salts of visitor fingerprints, which defaults to the
+// ClickHouse driver of the ingestion API.
+func WithSaltStore(store fingerprint.SaltStore) Option {
+	return func(cfg *ingestionAPIConfig) {
+		cfg.saltStore = store
+	}
+}
+
+// WithSessionHistory allows setting a custom history, used to continue sessions of visitors seen by other replicas,
+// which defaults to the ClickHouse driver of the ingestion API.
+func WithSessionHistory(history session.History) Option {
+	return func(cfg *ingestionAPIConfig) {
+		cfg.sessionHistory = history
+	}
+}
+
+// WithProjectStore allows setting a custom store of the projects reports are checked against, which defaults to the
+// ClickHouse driver of the ingestion API.
+func WithProjectStore(store projects.Store) Option {
+	return func(cfg *ingestionAPIConfig) {
+		cfg.projectStore = store
+	}
+}
+
 // Register creates a new instance of the Ingestion API.
 func Register(opts ...Option) ponrunner.APIBundle {
 	// Init a default server configuration, then apply any options passed in.
@@ -48,6 +140,24 @@ func Register(opts ...Option) ponrunner.APIBundle {
 	return func(cfg configura.Config, api huma.API) error {
 		err := cfg.ConfigurationKeysRegistered(
 			INGESTION_API_TEST_FLAG,
+			INGESTION_BUFFER_MAX_BATCH_SIZE,
+			INGESTION_BUFFER_MAX_EVENTS,
+			INGESTION_BUFFER_FLUSH_INTERVAL_MS,
+			INGESTION_SPOOL_DIR,
+			INGESTION_SPOOL_MAX_BYTES,
+			INGESTION_SPOOL_REPLAY_INTERVAL_MS,
+			INGESTION_USERAGENT_RULES_FILE,
+			INGESTION_BOTS_DENY_LIST,
+			INGESTION_BOTS_DROP,
+			INGESTION_TRUSTED_PROXIES,
+			INGESTION_GEOIP_DATABASE,
+			INGESTION_RELOAD_INTERVAL_MS,
+			INGESTION_IPREP_VPN_LISTS,
+			INGESTION_IPREP_PROXY_LISTS,
+			INGESTION_IPREP_TOR_LISTS,
+			INGESTION_PROJECTS_ENFORCE,
+			INGESTION_PROJECTS_REFRESH_INTERVAL_MS,
+			session.SESSION_TIMEOUT_MS,
 		)
 		if err != nil {
 			return err
@@ -67,10 +177,76 @@ func Register(opts ...Option) ponrunner.APIBundle {
 			apiConfig.clickhouseDriver = clickhouseDriver
 		}
 
+		userAgents, err := useragent.LoadFile(cfg.String(INGESTION_USERAGENT_RULES_FILE))
+		if err != nil {
+			return err
+		}
+
+		trustedProxies, err := clientip.ParseTrustedProxies(cfg.String(INGESTION_TRUSTED_PROXIES))
+		if err != nil {
+			return err
+		}
+
+		// The GeoIP database and IP reputation lists are polled for changes every reload interval.
+		reloadInterval := time.Duration(cfg.Int64(INGESTION_RELOAD_INTERVAL_MS)) * time.Millisecond
+		if reloadInterval <= 0 {
+			return fmt.Errorf("%w: %s must be positive", ErrInvalidInterval, INGESTION_RELOAD_INTERVAL_MS)
+		}
+
+		geoDB, err := openGeoIP(cfg, reloadInterval)
+		if err != nil {
+			return err
+		}
+
+		reputation, err := openReputation(cfg, reloadInterval)
+		if err != nil {
+			return err
+		}
+
+		driver := apiConfig.clickhouseDriver
+		if apiConfig.saltStore == nil {
+			apiConfig.saltStore = fingerprint.NewClickHouseStore(driver)
+		}
+		if apiConfig.sessionHistory == nil {
+			apiConfig.sessionHistory = session.NewClickHouseHistory(driver)
+		}
+		if apiConfig.projectStore == nil {
+			apiConfig.projectStore = projects.NewClickHouseStore(driver)
+		}
+
+		registry, err := openRegistry(cfg, apiConfig.projectStore)
+		if err != nil {
+			return err
+		}
+
+		flush, err := spoolingFlusher(cfg, driver)
+		if err != nil {
+			return err
+		}
+
+		// Events are buffered and flushed in native batches, instead of creating a part in ClickHouse per request. The
+		// buffer is drained when the service shuts down.
+		buf := buffer.New(
+			flush,
+			buffer.WithMaxBatchSize(int(cfg.Int64(INGESTION_BUFFER_MAX_BATCH_SIZE))),
+			buffer.WithMaxEvents(int(cfg.Int64(INGESTION_BUFFER_MAX_EVENTS))),
+			buffer.WithFlushInterval(time.Duration(cfg.Int64(INGESTION_BUFFER_FLUSH_INTERVAL_MS))*time.Millisecond),
+		)
+		shutdown.Register("ingestion buffer", buf.Close)
+
 		huma.AutoRegister(huma.NewGroup(api, "/api/ingestion"), &server{
 			openfeatureClient: openfeature.NewClient("ingestion-api"),
 			config:            cfg,
-			clickhouse:        apiConfig.clickhouseDriver,
+			clickhouse:        driver,
+			buffer:            buf,
+			userAgents:        userAgents,
+			bots:              bots.New(bots.WithDenyList(bots.ParseDenyList(cfg.String(INGESTION_BOTS_DENY_LIST))...)),
+			clientIPs:         clientip.New(trustedProxies...),
+			geoip:             geoDB,
+			reputation:        reputation,
+			fingerprints:      openHasher(apiConfig.saltStore),
+			sessions:          session.New(apiConfig.sessionHistory, session.WithTimeout(time.Duration(cfg.Int64(session.SESSION_TIMEOUT_MS))*time.Millisecond)),
+			registry:          registry,
 		})
 		return nil
 	}
@@ -78,31 +254,212 @@ func Register(opts ...Option) ponrunner.APIBundle {
 
 var _ ponrunner.APIBundle = Register()
 
-type (
-	IngestionEndpointRequest  struct{}
-	IngestionEndpointResponse struct {
-		Status int `header:"-"`
-		Body   struct {
-			Message string `json:"message"`
+// spoolingFlusher returns the flusher of the write buffer. Without a spool directory configured, batches are inserted
+// into ClickHouse directly. Otherwise batches that fail to insert are written to the spool, and replayed in order by a
+// background replayer once ClickHouse responds to pings again. While the spool holds batches, new batches are spooled
+// behind them, so events reach ClickHouse in the order they were received.
+func spoolingFlusher(cfg configura.Config, driver clickhouse.Driver) (buffer.Flusher, error) {
+	insert := func(ctx context.Context, batch []events.Event) error {
+		return events.InsertBatch(ctx, driver, batch)
+	}
+
+	dir := cfg.String(INGESTION_SPOOL_DIR)
+	if dir == "" {
+		return insert, nil
+	}
+
+	interval := time.Duration(cfg.Int64(INGESTION_SPOOL_REPLAY_INTERVAL_MS)) * time.Millisecond
+	if interval <= 0 {
+		return nil, fmt.Errorf("%w: %s must be positive", ErrInvalidInterval, INGESTION_SPOOL_REPLAY_INTERVAL_MS)
+	}
+
+	sp, err := spool.Open(dir, cfg.Int64(INGESTION_SPOOL_MAX_BYTES))
+	if err != nil {
+		return nil, err
+	}
+
+	if stats := sp.Stats(); stats.Segments > 0 {
+		slog.Info("Found spooled events from a previous run", slog.Int("events", stats.Events), slog.Int("segments", stats.Segments))
+	}
+
+	unregister, err := sp.RegisterMetrics()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sp.Run(ctx, interval, driver.Ping, insert)
+	}()
+
+	// Hooks run in reverse order, the replayer is stopped once the buffer is drained, possibly into the spool.
+	shutdown.Register("ingestion spool replayer", func(shutdownCtx context.Context) error {
+		cancel()
+		select {
+		case <-done:
+		case <-shutdownCtx.Done():
+			return shutdownCtx.Err()
+		}
+		return unregister()
+	})
+
+	return func(ctx context.Context, batch []events.Event) error {
+		if sp.Len() > 0 {
+			return sp.Write(batch)
+		}
+
+		err := insert(ctx, batch)
+		if err == nil {
+			return nil
+		}
+
+		spoolErr := sp.Write(batch)
+		if spoolErr != nil {
+			return errors.Join(err, spoolErr)
 		}
+
+		slog.WarnContext(ctx, "Spooled events to disk, ClickHouse is unavailable", slog.Int("events", len(batch)), slog.Any("error", err))
+		return nil
+	}, nil
+}
+
+// openGeoIP opens the configured GeoIP database and reloads it whenever the file changes, until the service shuts down.
+// Without a database configured, nil is returned and events are stored without location.
+func openGeoIP(cfg configura.Config, reloadInterval time.Duration) (*geoip.Database, error) {
+	path := cfg.String(INGESTION_GEOIP_DATABASE)
+	if path == "" {
+		return nil, nil
 	}
-)
 
-func (a *server) RegisterPageviewEndpoint(api huma.API) {
-	huma.Register(api, huma.Operation{
-		OperationID: "Report Pageview",
-		Method:      http.MethodPost,
-		Path:        "/report/pageview",
-		Tags:        []string{"Ingestion"},
-	}, func(ctx context.Context, i *IngestionEndpointRequest) (*IngestionEndpointResponse, error) {
-		// Placeholder for ingestion logic
-		return &IngestionEndpointResponse{
-			Status: 200,
-			Body: struct {
-				Message string `json:"message"`
-			}{
-				Message: "Pageview endpoint hit.",
-			},
-		}, nil
+	db, err := geoip.Open(path, reloadInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go db.Watch(ctx)
+	shutdown.Register("geoip database", func(context.Context) error {
+		cancel()
+		return db.Close()
 	})
+
+	return db, nil
+}
+
+// openReputation loads the configured IP reputation lists and reloads each whenever its file changes, until the
+// service shuts down.
+func openReputation(cfg configura.Config, reloadInterval time.Duration) (*iprep.Reputation, error) {
+	configured := []struct {
+		category iprep.Category
+		paths    string
+	}{
+		{iprep.CategoryVPN, cfg.String(INGESTION_IPREP_VPN_LISTS)},
+		{iprep.CategoryProxy, cfg.String(INGESTION_IPREP_PROXY_LISTS)},
+		{iprep.CategoryTor, cfg.String(INGESTION_IPREP_TOR_LISTS)},
+	}
+
+	var lists []*iprep.List
+	for _, l := range configured {
+		for path := range strings.SplitSeq(l.paths, ",") {
+			path = strings.TrimSpace(path)
+			if path == "" {
+				continue
+			}
+
+			list, err := iprep.OpenList(l.category, path, reloadInterval)
+			if err != nil {
+				return nil, err
+			}
+			lists = append(lists, list)
+		}
+	}
+
+	if len(lists) == 0 {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sources := make([]iprep.Source, len(lists))
+	for i, list := range lists {
+		go list.Watch(ctx)
+		sources[i] = list
+	}
+	shutdown.Register("ip reputation lists", func(context.Context) error {
+		cancel()
+		return nil
+	})
+
+	return iprep.New(sources...), nil
+}
+
+// openHasher returns the hasher of visitor fingerprints, having fetched the salt of the day, and refreshes the salt in
+// the background until the service shuts down.
+func openHasher(store fingerprint.SaltStore) *fingerprint.Hasher {
+	hasher := fingerprint.New(store)
+	hasher.Refresh(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go hasher.Watch(ctx)
+	shutdown.Register("visitor salts", func(context.Context) error {
+		cancel()
+		return nil
+	})
+
+	return hasher
+}
+
+// openRegistry loads the projects of the store, and refreshes them periodically until the service shuts down. Without
+// projects enforced, nil is returned and reports are accepted for any project.
+func openRegistry(cfg configura.Config, store projects.Store) (*projects.Registry, error) {
+	if !cfg.Bool(INGESTION_PROJECTS_ENFORCE) {
+		return nil, nil
+	}
+
+	registry := projects.NewRegistry(store, projects.WithRefreshInterval(time.Duration(cfg.Int64(INGESTION_PROJECTS_REFRESH_INTERVAL_MS))*time.Millisecond))
+	err := registry.Refresh(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go registry.Watch(ctx)
+	shutdown.Register("project registry", func(context.Context) error {
+		cancel()
+		return nil
+	})
+
+	return registry, nil
+}
+
+// IngestionEndpointResponse is the response returned by the ingestion endpoints once an event has been accepted.
+type IngestionEndpointResponse struct {
+	Status int `header:"-"`
+	Body   struct {
+		Message string `json:"message"`
+	}
+}
+
+// accepted returns a response with the status 202 Accepted and the given message.
+func accepted(message string) *IngestionEndpointResponse {
+	resp := &IngestionEndpointResponse{Status: http.StatusAccepted}
+	resp.Body.Message = message
+	return resp
+}
+
+// store hands the events over to the write buffer. When the buffer is full, or draining because the service shuts
+// down, a 503 Service Unavailable is returned so clients back off and retry.
+func (a *server) store(ctx context.Context, batch ...events.Event) error {
+	err := a.buffer.Add(batch...)
+	if errors.Is(err, buffer.ErrFull) || errors.Is(err, buffer.ErrClosed) {
+		slog.WarnContext(ctx, "Rejected events, ingestion buffer unavailable", slog.Int("events", len(batch)), slog.Any("error", err))
+		return huma.Error503ServiceUnavailable("ingestion is temporarily unavailable, retry later")
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to buffer events", slog.Int("events", len(batch)), slog.Any("error", err))
+		return huma.Error500InternalServerError("failed to store events")
+	}
+
+	return nil
 }
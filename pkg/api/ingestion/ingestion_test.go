@@ -1,14 +1,34 @@
 package ingestion_test
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"maps"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/adapters/humachi"
+	"github.com/go-chi/chi/v5"
 	"github.com/ponrove/configura"
 	"github.com/ponrove/octobe"
 	"github.com/ponrove/octobe/driver/clickhouse"
 	"github.com/ponrove/octobe/driver/clickhouse/mock"
+	"github.com/ponrove/ponrove-backend/internal/events"
+	"github.com/ponrove/ponrove-backend/internal/fingerprint"
+	"github.com/ponrove/ponrove-backend/internal/geoip"
+	"github.com/ponrove/ponrove-backend/internal/geoip/geoiptest"
+	"github.com/ponrove/ponrove-backend/internal/session"
 	"github.com/ponrove/ponrove-backend/pkg/api/ingestion"
 	"github.com/ponrove/ponrove-backend/test/testserver"
 	"github.com/stretchr/testify/suite"
@@ -28,34 +48,588 @@ func setupDB(t *testing.T) (*mock.Mock, clickhouse.Driver) {
 	return nativeConn, octdriv
 }
 
-// Test for reporting pageview endpoint
-// Under development, this will change in the future.
-func (suite *IngestionAPITestSuite) RegisterPageviewEndpoint() {
-	var body struct {
-		Schema  string `json:"$schema"`
-		Message string `json:"message"`
+// batchConn extends the mock connection with native batches, which the mock leaves unimplemented. Every sent batch is
+// recorded, so tests can assert on the rows written to ClickHouse.
+type batchConn struct {
+	*mock.Mock
+	mu      sync.Mutex
+	query   string
+	batches [][][]any
+	sendErr error
+}
+
+func (c *batchConn) PrepareBatch(ctx context.Context, query string, opts ...driver.PrepareBatchOption) (driver.Batch, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.query = query
+	return &recordingBatch{conn: c}, nil
+}
+
+// Ping fails while batches fail to send, as if ClickHouse was unreachable.
+func (c *batchConn) Ping(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sendErr
+}
+
+// sent returns the batches sent so far.
+func (c *batchConn) sent() [][][]any {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.batches
+}
+
+// rows returns the rows of all batches sent so far.
+func (c *batchConn) rows() [][]any {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var rows [][]any
+	for _, batch := range c.batches {
+		rows = append(rows, batch...)
+	}
+	return rows
+}
+
+// failSend makes every following batch fail to send with err.
+func (c *batchConn) failSend(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sendErr = err
+}
+
+type recordingBatch struct {
+	mock.MockBatch
+	conn *batchConn
+	rows [][]any
+}
+
+func (b *recordingBatch) Append(v ...any) error {
+	b.rows = append(b.rows, v)
+	return nil
+}
+
+func (b *recordingBatch) Send() error {
+	b.conn.mu.Lock()
+	defer b.conn.mu.Unlock()
+	if b.conn.sendErr != nil {
+		return b.conn.sendErr
+	}
+	b.conn.batches = append(b.conn.batches, b.rows)
+	return nil
+}
+
+func setupBatchDB(t *testing.T) (*batchConn, clickhouse.Driver) {
+	t.Helper()
+	nativeConn := &batchConn{Mock: mock.NewMock()}
+	octdriv, err := octobe.New(clickhouse.OpenNativeWithConn(nativeConn))
+	if err != nil {
+		t.Fatalf("failed to create ClickHouse driver: %v", err)
 	}
+	return nativeConn, octdriv
+}
+
+// noHistory is a session history without sessions, as if no other replica saw the visitor.
+type noHistory struct{}
+
+func (noHistory) LatestSession(context.Context, string, string, time.Time) (session.Session, bool, error) {
+	return session.Session{}, false, nil
+}
+
+// countingHistory is a session history without sessions counting its lookups.
+type countingHistory struct {
+	lookups atomic.Int64
+}
+
+func (h *countingHistory) LatestSession(context.Context, string, string, time.Time) (session.Session, bool, error) {
+	h.lookups.Add(1)
+	return session.Session{}, false, nil
+}
 
+// postJSON posts the body as JSON to the given URL.
+func postJSON(url, body string) (*http.Response, error) {
+	return http.Post(url, "application/json", strings.NewReader(body))
+}
+
+// createServer starts a test server with the ingestion API registered against the given driver. The write buffer
+// flushes every few milliseconds and the spool is disabled, overrides replace the default configuration values.
+func (suite *IngestionAPITestSuite) createServer(driver clickhouse.Driver, overrides ...map[configura.Variable[int64]]int64) *httptest.Server {
+	return suite.createServerWithSpool(driver, "", overrides...)
+}
+
+// createServerWithSpool starts a test server like createServer, spooling failed batches to spoolDir.
+func (suite *IngestionAPITestSuite) createServerWithSpool(driver clickhouse.Driver, spoolDir string, overrides ...map[configura.Variable[int64]]int64) *httptest.Server {
+	return suite.startServer(driver, suite.testConfig(nil, map[configura.Variable[string]]string{
+		ingestion.INGESTION_SPOOL_DIR: spoolDir,
+	}, overrides...))
+}
+
+// testConfig returns the configuration used by createServer, flags and settings replace the default boolean and string
+// values, overrides the numeric ones.
+func (suite *IngestionAPITestSuite) testConfig(flags map[configura.Variable[bool]]bool, settings map[configura.Variable[string]]string, overrides ...map[configura.Variable[int64]]int64) configura.Config {
 	cfg := configura.NewConfigImpl()
-	err := configura.WriteConfiguration(cfg, map[configura.Variable[bool]]bool{})
+	bools := map[configura.Variable[bool]]bool{
+		ingestion.INGESTION_API_TEST_FLAG:    false,
+		ingestion.INGESTION_BOTS_DROP:        false,
+		ingestion.INGESTION_PROJECTS_ENFORCE: false,
+	}
+	maps.Copy(bools, flags)
+	err := configura.WriteConfiguration(cfg, bools)
+	suite.NoError(err)
+
+	strs := map[configura.Variable[string]]string{
+		ingestion.INGESTION_SPOOL_DIR:            "",
+		ingestion.INGESTION_USERAGENT_RULES_FILE: "",
+		ingestion.INGESTION_BOTS_DENY_LIST:       "",
+		ingestion.INGESTION_TRUSTED_PROXIES:      "127.0.0.1",
+		ingestion.INGESTION_GEOIP_DATABASE:       "",
+		ingestion.INGESTION_IPREP_VPN_LISTS:      "",
+		ingestion.INGESTION_IPREP_PROXY_LISTS:    "",
+		ingestion.INGESTION_IPREP_TOR_LISTS:      "",
+	}
+	maps.Copy(strs, settings)
+	err = configura.WriteConfiguration(cfg, strs)
+	suite.NoError(err)
+
+	values := map[configura.Variable[int64]]int64{
+		ingestion.INGESTION_BUFFER_MAX_BATCH_SIZE:        100,
+		ingestion.INGESTION_BUFFER_MAX_EVENTS:            1000,
+		ingestion.INGESTION_BUFFER_FLUSH_INTERVAL_MS:     5,
+		ingestion.INGESTION_SPOOL_MAX_BYTES:              1024 * 1024,
+		ingestion.INGESTION_SPOOL_REPLAY_INTERVAL_MS:     5,
+		ingestion.INGESTION_RELOAD_INTERVAL_MS:           5,
+		ingestion.INGESTION_PROJECTS_REFRESH_INTERVAL_MS: 5,
+		session.SESSION_TIMEOUT_MS:                       session.DefaultTimeout.Milliseconds(),
+	}
+	for _, override := range overrides {
+		maps.Copy(values, override)
+	}
+	err = configura.WriteConfiguration(cfg, values)
 	suite.NoError(err)
 
-	_, driver := setupDB(suite.T())
+	return cfg
+}
+
+// startServer starts a test server with the ingestion API registered against the given driver and configuration, opts
+// are applied after the default test options.
+func (suite *IngestionAPITestSuite) startServer(driver clickhouse.Driver, cfg configura.Config, opts ...ingestion.Option) *httptest.Server {
+	opts = append([]ingestion.Option{
+		ingestion.WithClickhouseDriver(driver),
+		ingestion.WithSaltStore(fingerprint.NewMemoryStore()),
+		ingestion.WithSessionHistory(noHistory{}),
+	}, opts...)
 	srv, err := testserver.CreateServer(
 		testserver.WithConfig(cfg),
-		testserver.WithAPIBundle(ingestion.Register(ingestion.WithClickhouseDriver(driver))),
+		testserver.WithAPIBundle(ingestion.Register(opts...)),
 	)
 	suite.NoError(err)
+	return srv
+}
+
+// fingerprint returns the visitor fingerprint of a stored row, which depends on the random salt of the day.
+func (suite *IngestionAPITestSuite) fingerprint(row []any) string {
+	fp, ok := row[slices.Index(events.Columns, "visitor_fingerprint")].(string)
+	suite.True(ok)
+	suite.Len(fp, fingerprint.Length)
+	return fp
+}
+
+func (suite *IngestionAPITestSuite) TestPageviewEndpoint() {
+	var body struct {
+		Schema  string `json:"$schema"`
+		Message string `json:"message"`
+	}
+
+	width, height := uint16(1920), uint16(1080)
+	source := "newsletter"
+	userAgent := "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:126.0) Gecko/20100101 Firefox/126.0"
+	expected := events.Event{
+		ProjectID:                "project-1",
+		EventTimestamp:           time.Date(2025, 6, 16, 12, 0, 0, 0, time.UTC),
+		EventName:                "page_view",
+		Source:                   events.SourceClient,
+		URL:                      "https://example.com/pricing?utm_source=newsletter",
+		URLPath:                  "/pricing",
+		URLHost:                  "example.com",
+		URLQuery:                 "?utm_source=newsletter",
+		ReferrerURL:              "https://www.google.com/",
+		ReferrerHost:             "www.google.com",
+		UTMSource:                &source,
+		UserAgent:                userAgent,
+		BrowserName:              "Firefox",
+		BrowserVersion:           "126.0",
+		OSName:                   "Windows",
+		OSVersion:                "10",
+		DeviceType:               "desktop",
+		ScreenWidth:              &width,
+		ScreenHeight:             &height,
+		PageLoadTimeMs:           1200,
+		FirstContentfulPaintMs:   300,
+		LargestContentfulPaintMs: 900,
+		CustomProperties:         map[string]string{"plan": "pro"},
+	}
+
+	conn, driver := setupBatchDB(suite.T())
+	srv := suite.createServer(driver)
 	defer srv.Close()
 
-	resp, err := http.Get(srv.URL + "/api/ingestion/report/pageview")
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/ingestion/report/pageview", strings.NewReader(`{
+		"project_id": "project-1",
+		"url": "https://example.com/pricing?utm_source=newsletter",
+		"referrer": "https://www.google.com/",
+		"timestamp": "2025-06-16T12:00:00Z",
+		"screen_width": 1920,
+		"screen_height": 1080,
+		"web_vitals": {"page_load_time_ms": 1200, "first_contentful_paint_ms": 300, "largest_contentful_paint_ms": 900},
+		"custom_properties": {"plan": "pro"}
+	}`))
+	suite.NoError(err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+
+	resp, err := http.DefaultClient.Do(req)
 	suite.NoError(err)
 	defer resp.Body.Close()
-	suite.Equal(http.StatusOK, resp.StatusCode)
+	suite.Equal(http.StatusAccepted, resp.StatusCode)
 	suite.NoError(json.NewDecoder(resp.Body).Decode(&body))
 	suite.Contains(resp.Header.Get("Content-Type"), "application/json")
 	suite.NotEmpty(body.Schema)
-	suite.Equal("Pageview endpoint hit.", body.Message)
+	suite.Equal("Pageview accepted.", body.Message)
+
+	suite.Eventually(func() bool { return len(conn.rows()) == 1 }, time.Second, time.Millisecond)
+	row := conn.rows()[0]
+	expected.VisitorFingerprint = suite.fingerprint(row)
+	expected.SessionID = session.ID(expected.ProjectID, expected.VisitorFingerprint, expected.EventTimestamp)
+	suite.Equal(expected.Values(), row)
+}
+
+func (suite *IngestionAPITestSuite) TestPageviewEndpointBots() {
+	testCases := []struct {
+		name     string
+		drop     bool
+		expected int
+	}{
+		{name: "Flagged", drop: false, expected: 1},
+		{name: "Dropped", drop: true, expected: 0},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			conn, driver := setupBatchDB(suite.T())
+			history := &countingHistory{}
+			srv := suite.startServer(driver, suite.testConfig(map[configura.Variable[bool]]bool{
+				ingestion.INGESTION_BOTS_DROP: tc.drop,
+			}, nil), ingestion.WithSessionHistory(history))
+			defer srv.Close()
+
+			req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/ingestion/report/pageview", strings.NewReader(`{"project_id": "project-1", "url": "https://example.com/"}`))
+			suite.NoError(err)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)")
+
+			resp, err := http.DefaultClient.Do(req)
+			suite.NoError(err)
+			resp.Body.Close()
+			suite.Equal(http.StatusAccepted, resp.StatusCode, "bots should not learn that their traffic is dropped")
+
+			if tc.expected == 0 {
+				time.Sleep(20 * time.Millisecond)
+				suite.Empty(conn.rows())
+				suite.Zero(history.lookups.Load(), "dropped events should not be assigned a session")
+				return
+			}
+
+			suite.Eventually(func() bool { return len(conn.rows()) == tc.expected }, time.Second, time.Millisecond)
+			row := conn.rows()[0]
+			suite.Equal(uint8(1), row[slices.Index(events.Columns, "is_bot")])
+			suite.Equal("Googlebot", *row[slices.Index(events.Columns, "bot_name")].(*string))
+			suite.Equal(int64(1), history.lookups.Load())
+		})
+	}
+}
+
+func (suite *IngestionAPITestSuite) TestPageviewEndpointGeoIP() {
+	geoipPath := filepath.Join(suite.T().TempDir(), "City.mmdb")
+	suite.Require().NoError(geoiptest.WriteDatabase(geoipPath, map[string]geoip.Location{
+		"81.2.69.0/24": {CountryCode: "GB", RegionName: "England", CityName: "London"},
+	}))
+
+	conn, driver := setupBatchDB(suite.T())
+	srv := suite.startServer(driver, suite.testConfig(nil, map[configura.Variable[string]]string{
+		ingestion.INGESTION_GEOIP_DATABASE: geoipPath,
+	}))
+	defer srv.Close()
+
+	// The test client connects from 127.0.0.1, a trusted proxy, so the forwarded address is the client.
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/ingestion/report/pageview", strings.NewReader(`{"project_id": "project-1", "url": "https://example.com/"}`))
+	suite.NoError(err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Add("X-Forwarded-For", "192.0.2.1, 81.2.69.160")
+
+	resp, err := http.DefaultClient.Do(req)
+	suite.NoError(err)
+	resp.Body.Close()
+	suite.Equal(http.StatusAccepted, resp.StatusCode)
+
+	suite.Eventually(func() bool { return len(conn.rows()) == 1 }, time.Second, time.Millisecond)
+	row := conn.rows()[0]
+	suite.Equal("GB", row[slices.Index(events.Columns, "country_code")])
+	suite.Equal("England", row[slices.Index(events.Columns, "region_name")])
+	suite.Equal("London", row[slices.Index(events.Columns, "city_name")])
+	for _, value := range row {
+		suite.NotEqual("81.2.69.160", value, "the client IP should never be stored")
+	}
+}
+
+func (suite *IngestionAPITestSuite) TestPageviewEndpointReputation() {
+	dir := suite.T().TempDir()
+	vpnPath := filepath.Join(dir, "vpn.txt")
+	suite.Require().NoError(os.WriteFile(vpnPath, []byte("# VPN exit servers\n185.65.134.0/24 Mullvad\n"), 0o600))
+	torPath := filepath.Join(dir, "tor-exits.txt")
+	suite.Require().NoError(os.WriteFile(torPath, []byte("185.65.134.77\n"), 0o600))
+
+	conn, driver := setupBatchDB(suite.T())
+	srv := suite.startServer(driver, suite.testConfig(nil, map[configura.Variable[string]]string{
+		ingestion.INGESTION_IPREP_VPN_LISTS: vpnPath,
+		ingestion.INGESTION_IPREP_TOR_LISTS: torPath,
+	}))
+	defer srv.Close()
+
+	for _, addr := range []string{"185.65.134.77", "81.2.69.160"} {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/ingestion/report/pageview", strings.NewReader(`{"project_id": "project-1", "url": "https://example.com/"}`))
+		suite.NoError(err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", addr)
+
+		resp, err := http.DefaultClient.Do(req)
+		suite.NoError(err)
+		resp.Body.Close()
+		suite.Equal(http.StatusAccepted, resp.StatusCode)
+	}
+
+	suite.Eventually(func() bool { return len(conn.rows()) == 2 }, time.Second, time.Millisecond)
+	rows := conn.rows()
+	suite.Equal(uint8(1), rows[0][slices.Index(events.Columns, "is_vpn")])
+	suite.Equal("Mullvad", *rows[0][slices.Index(events.Columns, "vpn_provider")].(*string))
+	suite.Equal(uint8(1), rows[0][slices.Index(events.Columns, "is_tor_node")])
+	suite.Equal(uint8(0), rows[0][slices.Index(events.Columns, "is_proxy")])
+	suite.Equal(uint8(0), rows[1][slices.Index(events.Columns, "is_vpn")])
+	suite.Nil(rows[1][slices.Index(events.Columns, "vpn_provider")])
+	suite.Equal(uint8(0), rows[1][slices.Index(events.Columns, "is_tor_node")])
+}
+
+func (suite *IngestionAPITestSuite) TestPageviewEndpointFingerprint() {
+	conn, driver := setupBatchDB(suite.T())
+	srv := suite.startServer(driver, suite.testConfig(nil, nil))
+	defer srv.Close()
+
+	firefox := "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:126.0) Gecko/20100101 Firefox/126.0"
+	chrome := "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/125.0.0.0 Safari/537.36"
+	visits := []struct {
+		projectID string
+		addr      string
+		userAgent string
+	}{
+		{projectID: "project-1", addr: "81.2.69.160", userAgent: firefox},
+		{projectID: "project-1", addr: "81.2.69.160", userAgent: firefox},
+		{projectID: "project-1", addr: "81.2.69.161", userAgent: firefox},
+		{projectID: "project-1", addr: "81.2.69.160", userAgent: chrome},
+		{projectID: "project-2", addr: "81.2.69.160", userAgent: firefox},
+	}
+
+	for _, visit := range visits {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/ingestion/report/pageview", strings.NewReader(`{"project_id": "`+visit.projectID+`", "url": "https://example.com/"}`))
+		suite.NoError(err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", visit.addr)
+		req.Header.Set("User-Agent", visit.userAgent)
+		req.Header.Set("Accept-Language", "en")
+
+		resp, err := http.DefaultClient.Do(req)
+		suite.NoError(err)
+		resp.Body.Close()
+		suite.Equal(http.StatusAccepted, resp.StatusCode)
+	}
+
+	suite.Eventually(func() bool { return len(conn.rows()) == len(visits) }, time.Second, time.Millisecond)
+	fingerprints := make([]string, 0, len(visits))
+	for _, row := range conn.rows() {
+		fingerprints = append(fingerprints, suite.fingerprint(row))
+	}
+	suite.Equal(fingerprints[0], fingerprints[1], "the same visitor should get the same fingerprint")
+	suite.Len(slices.Compact(fingerprints), 4, "another address, user agent or project should be another visitor")
+}
+
+func (suite *IngestionAPITestSuite) TestPageviewEndpointSessions() {
+	conn, driver := setupBatchDB(suite.T())
+	srv := suite.startServer(driver, suite.testConfig(nil, nil, map[configura.Variable[int64]]int64{
+		session.SESSION_TIMEOUT_MS: (10 * time.Minute).Milliseconds(),
+	}))
+	defer srv.Close()
+
+	// The second pageview continues the session of the first, the third follows a gap longer than the timeout.
+	for _, timestamp := range []string{"2025-06-16T12:00:00Z", "2025-06-16T12:09:00Z", "2025-06-16T12:20:00Z"} {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/ingestion/report/pageview", strings.NewReader(`{"project_id": "project-1", "url": "https://example.com/", "timestamp": "`+timestamp+`"}`))
+		suite.NoError(err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:126.0) Gecko/20100101 Firefox/126.0")
+		req.Header.Set("Accept-Language", "en")
+
+		resp, err := http.DefaultClient.Do(req)
+		suite.NoError(err)
+		resp.Body.Close()
+		suite.Equal(http.StatusAccepted, resp.StatusCode)
+	}
+
+	suite.Eventually(func() bool { return len(conn.rows()) == 3 }, time.Second, time.Millisecond)
+	rows := conn.rows()
+	fp := suite.fingerprint(rows[0])
+	column := slices.Index(events.Columns, "session_id")
+	suite.Equal(session.ID("project-1", fp, time.Date(2025, 6, 16, 12, 0, 0, 0, time.UTC)), rows[0][column])
+	suite.Equal(rows[0][column], rows[1][column])
+	suite.Equal(session.ID("project-1", fp, time.Date(2025, 6, 16, 12, 20, 0, 0, time.UTC)), rows[2][column])
+}
+
+func (suite *IngestionAPITestSuite) TestPageviewEndpointValidation() {
+	testCases := []struct {
+		name     string
+		body     string
+		location string
+	}{
+		{
+			name:     "Missing project",
+			body:     `{"url": "https://example.com/"}`,
+			location: "body",
+		},
+		{
+			name:     "Relative URL",
+			body:     `{"project_id": "project-1", "url": "/pricing"}`,
+			location: "body.url",
+		},
+		{
+			name:     "Invalid referrer",
+			body:     `{"project_id": "project-1", "url": "https://example.com/", "referrer": "http://[::1"}`,
+			location: "body.referrer",
+		},
+		{
+			name:     "Timestamp in the future",
+			body:     `{"project_id": "project-1", "url": "https://example.com/", "timestamp": "2999-01-01T00:00:00Z"}`,
+			location: "body.timestamp",
+		},
+	}
+
+	conn, driver := setupBatchDB(suite.T())
+	srv := suite.createServer(driver)
+	defer srv.Close()
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			var body struct {
+				Errors []struct {
+					Location string `json:"location"`
+				} `json:"errors"`
+			}
+
+			resp, err := postJSON(srv.URL+"/api/ingestion/report/pageview", tc.body)
+			suite.NoError(err)
+			defer resp.Body.Close()
+			suite.Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+			suite.NoError(json.NewDecoder(resp.Body).Decode(&body))
+			suite.Require().NotEmpty(body.Errors)
+			suite.Equal(tc.location, body.Errors[0].Location)
+		})
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	suite.Empty(conn.rows(), "rejected pageviews should never be stored")
+}
+
+func (suite *IngestionAPITestSuite) TestPageviewEndpointBufferFull() {
+	conn, driver := setupBatchDB(suite.T())
+	conn.failSend(errors.New("connection refused"))
+	srv := suite.createServer(driver, map[configura.Variable[int64]]int64{
+		ingestion.INGESTION_BUFFER_MAX_EVENTS: 1,
+	})
+	defer srv.Close()
+
+	resp, err := postJSON(srv.URL+"/api/ingestion/report/pageview", `{"project_id": "project-1", "url": "https://example.com/"}`)
+	suite.NoError(err)
+	resp.Body.Close()
+	suite.Equal(http.StatusAccepted, resp.StatusCode)
+
+	// ClickHouse is unavailable, so the first pageview is still buffered and fills the buffer.
+	resp, err = postJSON(srv.URL+"/api/ingestion/report/pageview", `{"project_id": "project-1", "url": "https://example.com/"}`)
+	suite.NoError(err)
+	resp.Body.Close()
+	suite.Equal(http.StatusServiceUnavailable, resp.StatusCode)
+
+	// Once ClickHouse is back, the buffered pageview is flushed and room is made for new ones.
+	conn.failSend(nil)
+	suite.Eventually(func() bool { return len(conn.rows()) == 1 }, time.Second, time.Millisecond)
+
+	resp, err = postJSON(srv.URL+"/api/ingestion/report/pageview", `{"project_id": "project-1", "url": "https://example.com/"}`)
+	suite.NoError(err)
+	resp.Body.Close()
+	suite.Equal(http.StatusAccepted, resp.StatusCode)
+}
+
+func (suite *IngestionAPITestSuite) TestPageviewEndpointSpool() {
+	conn, driver := setupBatchDB(suite.T())
+	conn.failSend(errors.New("connection refused"))
+	spoolDir := suite.T().TempDir()
+	srv := suite.createServerWithSpool(driver, spoolDir, map[configura.Variable[int64]]int64{
+		ingestion.INGESTION_BUFFER_MAX_EVENTS: 1,
+	})
+	defer srv.Close()
+
+	// ClickHouse is unavailable, the pageviews are spooled to disk instead of filling up the buffer.
+	for _, path := range []string{"/first", "/second", "/third"} {
+		suite.Eventually(func() bool {
+			resp, err := postJSON(srv.URL+"/api/ingestion/report/pageview", `{"project_id": "project-1", "url": "https://example.com`+path+`"}`)
+			suite.NoError(err)
+			resp.Body.Close()
+			return resp.StatusCode == http.StatusAccepted
+		}, time.Second, time.Millisecond)
+	}
+
+	suite.Eventually(func() bool {
+		segments, _ := filepath.Glob(filepath.Join(spoolDir, "*.seg"))
+		return len(segments) == 3
+	}, time.Second, time.Millisecond)
+	suite.Empty(conn.rows())
+
+	// Once ClickHouse is back, the spooled pageviews are replayed in the order they were received.
+	conn.failSend(nil)
+	suite.Eventually(func() bool { return len(conn.rows()) == 3 }, time.Second, time.Millisecond)
+
+	rows := conn.rows()
+	urlColumn := slices.Index(events.Columns, "url")
+	for i, path := range []string{"/first", "/second", "/third"} {
+		suite.Equal("https://example.com"+path, rows[i][urlColumn])
+	}
+
+	segments, err := filepath.Glob(filepath.Join(spoolDir, "*.seg"))
+	suite.NoError(err)
+	suite.Empty(segments)
+}
+
+func (suite *IngestionAPITestSuite) TestIntervalValidation() {
+	_, driver := setupBatchDB(suite.T())
+	for _, interval := range []configura.Variable[int64]{
+		ingestion.INGESTION_SPOOL_REPLAY_INTERVAL_MS,
+		ingestion.INGESTION_RELOAD_INTERVAL_MS,
+	} {
+		cfg := suite.testConfig(nil, map[configura.Variable[string]]string{
+			ingestion.INGESTION_SPOOL_DIR: suite.T().TempDir(),
+		}, map[configura.Variable[int64]]int64{
+			interval: 0,
+		})
+
+		err := ingestion.Register(
+			ingestion.WithClickhouseDriver(driver),
+		)(cfg, humachi.New(chi.NewRouter(), huma.DefaultConfig("", "")))
+		suite.ErrorIs(err, ingestion.ErrInvalidInterval, interval)
+	}
 }
 
 func TestIngestionAPITestSuite(t *testing.T) {
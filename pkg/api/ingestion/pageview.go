@@ -0,0 +1,109 @@
+package ingestion
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/ponrove/ponrove-backend/internal/events"
+)
+
+// WebVitals holds the performance metrics reported by the client for a single page load.
+type WebVitals struct {
+	PageLoadTimeMs           uint32 `json:"page_load_time_ms,omitempty" maximum:"3600000" doc:"Total time taken for the page to load in milliseconds."`
+	FirstContentfulPaintMs   uint32 `json:"first_contentful_paint_ms,omitempty" maximum:"3600000" doc:"First Contentful Paint (FCP) in milliseconds."`
+	LargestContentfulPaintMs uint32 `json:"largest_contentful_paint_ms,omitempty" maximum:"3600000" doc:"Largest Contentful Paint (LCP) in milliseconds."`
+}
+
+// PageviewPayload is the body of a pageview report.
+type PageviewPayload struct {
+	ProjectID        string            `json:"project_id" minLength:"1" maxLength:"128" doc:"Identifier of the project the pageview belongs to."`
+	URL              string            `json:"url" minLength:"1" maxLength:"4096" doc:"Absolute http(s) URL of the viewed page."`
+	Referrer         string            `json:"referrer,omitempty" maxLength:"4096" doc:"Full URL of the referring page, empty for direct traffic."`
+	Timestamp        time.Time         `json:"timestamp,omitempty" doc:"When the pageview occurred, defaults to the time the pageview is received."`
+	ScreenWidth      *uint16           `json:"screen_width,omitempty" minimum:"1" doc:"Width of the device screen in pixels."`
+	ScreenHeight     *uint16           `json:"screen_height,omitempty" minimum:"1" doc:"Height of the device screen in pixels."`
+	WebVitals        *WebVitals        `json:"web_vitals,omitempty" doc:"Performance metrics of the page load."`
+	CustomProperties map[string]string `json:"custom_properties,omitempty" maxProperties:"32" doc:"Custom key-value data attached to the pageview, at most 32 properties with keys up to 64 and values up to 512 characters."`
+	UserAgent        string            `json:"user_agent,omitempty" maxLength:"1024" doc:"User agent of the visitor, for pageviews reported by a server on behalf of the visitor. Marks the pageview as server-side, and takes precedence over the User-Agent header."`
+	VisitorIP        string            `json:"visitor_ip,omitempty" maxLength:"64" doc:"IP address of the visitor, for pageviews reported by a server on behalf of the visitor along with their user agent. Only derives the visitor fingerprint, and is never stored. Server-side pageviews without it have no visitor nor session."`
+}
+
+// PageviewRequest is the request of the pageview endpoint.
+type PageviewRequest struct {
+	ClientHeaders
+	Body PageviewPayload
+}
+
+// RegisterPageviewEndpoint registers the endpoint used by clients to report a pageview.
+func (a *server) RegisterPageviewEndpoint(api huma.API) {
+	huma.Register(api, huma.Operation{
+		OperationID:   "Report Pageview",
+		Method:        http.MethodPost,
+		Path:          "/report/pageview",
+		Tags:          []string{"Ingestion"},
+		DefaultStatus: http.StatusAccepted,
+		Parameters:    ingestionKeyParams(),
+		Middlewares:   a.projectMiddlewares(api),
+	}, func(ctx context.Context, i *PageviewRequest) (*IngestionEndpointResponse, error) {
+		event, err := i.Body.event("body", time.Now())
+		if err != nil {
+			return nil, err
+		}
+		err = a.authorize(ctx, "body", event)
+		if err != nil {
+			return nil, err
+		}
+		if a.enrich(ctx, &event, i.ClientHeaders) {
+			err = a.store(ctx, event)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return accepted("Pageview accepted."), nil
+	})
+}
+
+// event converts the payload into a raw event, deriving the URL and referrer columns. The returned error is a huma
+// status error pointing at the offending field, relative to location.
+func (p *PageviewPayload) event(location string, now time.Time) (events.Event, error) {
+	timestamp, err := resolveTimestamp(location+".timestamp", p.Timestamp, now)
+	if err != nil {
+		return events.Event{}, err
+	}
+
+	err = validateCustomProperties(location+".custom_properties", p.CustomProperties)
+	if err != nil {
+		return events.Event{}, err
+	}
+
+	event := events.Event{
+		ProjectID:        p.ProjectID,
+		EventTimestamp:   timestamp,
+		EventName:        events.EventNamePageview,
+		Source:           events.SourceClient,
+		ScreenWidth:      p.ScreenWidth,
+		ScreenHeight:     p.ScreenHeight,
+		CustomProperties: p.CustomProperties,
+	}
+
+	err = applyServerSide(&event, location, p.UserAgent, p.VisitorIP)
+	if err != nil {
+		return events.Event{}, err
+	}
+
+	err = applyPageContext(&event, location, p.URL, p.Referrer, false)
+	if err != nil {
+		return events.Event{}, err
+	}
+
+	if p.WebVitals != nil {
+		event.PageLoadTimeMs = p.WebVitals.PageLoadTimeMs
+		event.FirstContentfulPaintMs = p.WebVitals.FirstContentfulPaintMs
+		event.LargestContentfulPaintMs = p.WebVitals.LargestContentfulPaintMs
+	}
+
+	return event, nil
+}
@@ -0,0 +1,109 @@
+package ingestion
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/ponrove/ponrove-backend/internal/events"
+	"github.com/ponrove/ponrove-backend/internal/projects"
+)
+
+const (
+	// ingestionKeyHeader carries the ingestion key of reports sent by servers.
+	ingestionKeyHeader = "X-Ponrove-Key"
+	// ingestionKeyQuery carries the ingestion key of reports sent by browsers, as navigator.sendBeacon can't set
+	// headers.
+	ingestionKeyQuery = "key"
+)
+
+// ingestionKeyParams document the ingestion key of the report endpoints, which is checked by a middleware rather than
+// read from the input of the handlers.
+func ingestionKeyParams() []*huma.Param {
+	return []*huma.Param{
+		{
+			Name:        ingestionKeyHeader,
+			In:          "header",
+			Description: "Ingestion key of the project, for reports sent by servers.",
+			Schema:      &huma.Schema{Type: huma.TypeString},
+		},
+		{
+			Name:        ingestionKeyQuery,
+			In:          "query",
+			Description: "Ingestion key of the project, for reports sent by browsers.",
+			Schema:      &huma.Schema{Type: huma.TypeString},
+		},
+	}
+}
+
+// projectContextKey is the context key of the project whose ingestion key authorized the request.
+type projectContextKey struct{}
+
+// projectMiddlewares returns the middlewares of the report endpoints, which reject requests without the ingestion key
+// of a registered project, or sent from a page outside the allowed hosts of the project. Without a registry, projects
+// aren't enforced and no middleware is returned.
+func (a *server) projectMiddlewares(api huma.API) huma.Middlewares {
+	if a.registry == nil {
+		return nil
+	}
+
+	return huma.Middlewares{func(ctx huma.Context, next func(huma.Context)) {
+		key := ctx.Header(ingestionKeyHeader)
+		if key == "" {
+			key = ctx.Query(ingestionKeyQuery)
+		}
+		if key == "" {
+			_ = huma.WriteErr(api, ctx, http.StatusUnauthorized, "missing ingestion key")
+			return
+		}
+
+		project, ok := a.registry.ByKey(key)
+		if !ok {
+			_ = huma.WriteErr(api, ctx, http.StatusUnauthorized, "invalid ingestion key")
+			return
+		}
+
+		// Browsers send the origin of the page with cross-origin requests, requests sent by servers have none.
+		if origin := ctx.Header("Origin"); origin != "" {
+			u, err := url.Parse(origin)
+			if err != nil || !project.AllowsHost(u.Host) {
+				_ = huma.WriteErr(api, ctx, http.StatusForbidden, "origin not allowed for the project", &huma.ErrorDetail{
+					Location: "header.Origin",
+					Message:  "origin is not an allowed host of the project",
+					Value:    origin,
+				})
+				return
+			}
+		}
+
+		next(huma.WithValue(ctx, projectContextKey{}, project))
+	}}
+}
+
+// authorize checks that an event belongs to the project of the ingestion key, and occurred on one of its allowed hosts.
+// The returned error is a huma status error pointing at the offending field, relative to location.
+func (a *server) authorize(ctx context.Context, location string, event events.Event) error {
+	project, ok := ctx.Value(projectContextKey{}).(projects.Project)
+	if !ok {
+		return nil
+	}
+
+	if event.ProjectID != project.ID {
+		return huma.Error403Forbidden("event not allowed for the project", &huma.ErrorDetail{
+			Location: location + ".project_id",
+			Message:  "project does not match the ingestion key",
+			Value:    event.ProjectID,
+		})
+	}
+
+	if event.URLHost != "" && !project.AllowsHost(event.URLHost) {
+		return huma.Error403Forbidden("event not allowed for the project", &huma.ErrorDetail{
+			Location: location + ".url",
+			Message:  "host is not an allowed host of the project",
+			Value:    event.URL,
+		})
+	}
+
+	return nil
+}
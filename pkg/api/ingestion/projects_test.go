@@ -0,0 +1,145 @@
+package ingestion_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/ponrove/configura"
+	"github.com/ponrove/octobe/driver/clickhouse"
+	"github.com/ponrove/ponrove-backend/internal/projects"
+	"github.com/ponrove/ponrove-backend/pkg/api/ingestion"
+)
+
+// createEnforcingServer starts a test server like createServer, accepting reports of the registered projects only.
+func (suite *IngestionAPITestSuite) createEnforcingServer(driver clickhouse.Driver) *httptest.Server {
+	cfg := suite.testConfig(map[configura.Variable[bool]]bool{ingestion.INGESTION_PROJECTS_ENFORCE: true}, nil)
+	return suite.startServer(driver, cfg, ingestion.WithProjectStore(projects.NewMemoryStore(
+		projects.Project{ID: "project-1", IngestionKey: "pk_1", AllowedHosts: []string{"example.com", "*.example.com"}},
+		projects.Project{ID: "project-2", IngestionKey: "pk_2"},
+	)))
+}
+
+// postReport posts the body as JSON to the ingestion endpoint at path, with the given headers.
+func (suite *IngestionAPITestSuite) postReport(srvURL, path, body string, headers map[string]string) (*http.Response, []string) {
+	req, err := http.NewRequest(http.MethodPost, srvURL+"/api/ingestion"+path, strings.NewReader(body))
+	suite.Require().NoError(err)
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	suite.Require().NoError(err)
+	defer resp.Body.Close()
+
+	var decoded struct {
+		Errors []struct {
+			Location string `json:"location"`
+		} `json:"errors"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&decoded)
+	locations := make([]string, 0, len(decoded.Errors))
+	for _, e := range decoded.Errors {
+		locations = append(locations, e.Location)
+	}
+	return resp, locations
+}
+
+func (suite *IngestionAPITestSuite) TestProjectEnforcement() {
+	conn, driver := setupBatchDB(suite.T())
+	srv := suite.createEnforcingServer(driver)
+	defer srv.Close()
+
+	pageview := `{"project_id": "project-1", "url": "https://www.example.com/pricing"}`
+	testCases := []struct {
+		name     string
+		path     string
+		body     string
+		headers  map[string]string
+		status   int
+		location string
+	}{
+		{name: "Missing key", path: "/report/pageview", body: pageview, status: http.StatusUnauthorized},
+		{name: "Unknown key", path: "/report/pageview", body: pageview, headers: map[string]string{"X-Ponrove-Key": "pk_unknown"}, status: http.StatusUnauthorized},
+		{name: "Key in header", path: "/report/pageview", body: pageview, headers: map[string]string{"X-Ponrove-Key": "pk_1"}, status: http.StatusAccepted},
+		{name: "Key in query", path: "/report/pageview?key=pk_1", body: pageview, status: http.StatusAccepted},
+		{name: "Allowed origin", path: "/report/pageview?key=pk_1", body: pageview, headers: map[string]string{"Origin": "https://shop.example.com"}, status: http.StatusAccepted},
+		{
+			name:     "Origin not allowed",
+			path:     "/report/pageview?key=pk_1",
+			body:     pageview,
+			headers:  map[string]string{"Origin": "https://evil.net"},
+			status:   http.StatusForbidden,
+			location: "header.Origin",
+		},
+		{
+			name:     "Project of another key",
+			path:     "/report/pageview?key=pk_2",
+			body:     pageview,
+			status:   http.StatusForbidden,
+			location: "body.project_id",
+		},
+		{
+			name:     "URL host not allowed",
+			path:     "/report/event?key=pk_1",
+			body:     `{"project_id": "project-1", "event_name": "click", "url": "https://evil.net/"}`,
+			status:   http.StatusForbidden,
+			location: "body.url",
+		},
+		{
+			name:   "Event without URL",
+			path:   "/report/event?key=pk_1",
+			body:   `{"project_id": "project-1", "event_name": "signup", "user_agent": "Mozilla/5.0"}`,
+			status: http.StatusAccepted,
+		},
+		{
+			name:   "Project without allowed hosts",
+			path:   "/report/vitals?key=pk_2",
+			body:   `{"project_id": "project-2", "url": "https://anywhere.net/", "page_load_time_ms": 900}`,
+			status: http.StatusAccepted,
+		},
+	}
+
+	accepted := 0
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			resp, locations := suite.postReport(srv.URL, tc.path, tc.body, tc.headers)
+			suite.Equal(tc.status, resp.StatusCode)
+			if tc.location != "" {
+				suite.Equal([]string{tc.location}, locations)
+			}
+			if tc.status == http.StatusAccepted {
+				accepted++
+			}
+		})
+	}
+
+	suite.Eventually(func() bool { return len(conn.rows()) == accepted }, time.Second, time.Millisecond)
+}
+
+func (suite *IngestionAPITestSuite) TestProjectEnforcementBatch() {
+	conn, driver := setupBatchDB(suite.T())
+	srv := suite.createEnforcingServer(driver)
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/api/ingestion/report/batch?key=pk_1", "text/plain;charset=UTF-8", strings.NewReader(`[
+		{"pageview": {"project_id": "project-1", "url": "https://example.com/"}},
+		{"event": {"project_id": "project-2", "event_name": "click"}},
+		{"vitals": {"project_id": "project-1", "url": "https://evil.net/", "page_load_time_ms": 900}}
+	]`))
+	suite.Require().NoError(err)
+	defer resp.Body.Close()
+
+	var body batchResponseBody
+	suite.NoError(json.NewDecoder(resp.Body).Decode(&body))
+	suite.Equal(http.StatusAccepted, resp.StatusCode)
+	suite.Equal(1, body.Accepted)
+	suite.Equal(2, body.Rejected)
+	suite.Equal("body[1].event.project_id", body.Results[1].Errors[0].Location)
+	suite.Equal("body[2].vitals.url", body.Results[2].Errors[0].Location)
+
+	suite.Eventually(func() bool { return len(conn.rows()) == 1 }, time.Second, time.Millisecond)
+}
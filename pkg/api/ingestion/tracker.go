@@ -0,0 +1,118 @@
+package ingestion
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"text/template"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/conditional"
+)
+
+// trackerCacheControl lets browsers and CDNs cache the tracker for an hour, and serve a stale copy for a day while
+// revalidating it with its ETag.
+const trackerCacheControl = "public, max-age=3600, stale-while-revalidate=86400"
+
+// trackerSource is the tracker script, rendered with the configuration of the project.
+//
+//go:embed tracker.js
+var trackerSource string
+
+var trackerTemplate = template.Must(template.New("tracker.js").Parse(trackerSource))
+
+// TrackerConfig is the configuration rendered into the tracker script.
+type TrackerConfig struct {
+	ProjectID string `json:"project_id"`
+	Key       string `json:"key,omitempty"`
+	SPA       bool   `json:"spa"`
+	Outbound  bool   `json:"outbound"`
+	Vitals    bool   `json:"vitals"`
+	Hash      bool   `json:"hash"`
+}
+
+// ScriptRequest is the request of the tracker script, the query parameters configure the script.
+type ScriptRequest struct {
+	conditional.Params
+	ProjectID string `query:"project_id" required:"true" minLength:"1" maxLength:"128" doc:"Identifier of the project reported by the script."`
+	Key       string `query:"key" maxLength:"128" doc:"Ingestion key of the project, sent with every report."`
+	SPA       bool   `query:"spa" default:"true" doc:"Report a pageview on every history navigation of single page applications."`
+	Outbound  bool   `query:"outbound" default:"true" doc:"Report clicks on links to other sites as outbound_link events."`
+	Vitals    bool   `query:"vitals" default:"true" doc:"Report the web vitals of page loads as web_vitals events."`
+	Hash      bool   `query:"hash" default:"false" doc:"Keep the fragment in reported URLs, and report a pageview on every fragment change."`
+}
+
+// ScriptResponse is the tracker script.
+type ScriptResponse struct {
+	ContentType  string `header:"Content-Type"`
+	CacheControl string `header:"Cache-Control"`
+	ETag         string `header:"ETag"`
+	Body         []byte
+}
+
+// RegisterScriptEndpoint registers the endpoint serving the tracker script, which reports pageviews, single page
+// application navigations, outbound links, web vitals and time on page to the batch endpoint.
+func (a *server) RegisterScriptEndpoint(api huma.API) {
+	huma.Register(api, huma.Operation{
+		OperationID: "Get Tracker Script",
+		Method:      http.MethodGet,
+		Path:        "/script.js",
+		Tags:        []string{"Ingestion"},
+		Description: `Include the script on every page with <script defer src=".../api/ingestion/script.js?project_id=...&key=..."></script>.`,
+		Responses: map[string]*huma.Response{
+			"200": {
+				Description: "The tracker script.",
+				Content:     map[string]*huma.MediaType{"text/javascript": {}},
+			},
+		},
+	}, func(ctx context.Context, i *ScriptRequest) (*ScriptResponse, error) {
+		script, err := renderTracker(TrackerConfig{
+			ProjectID: i.ProjectID,
+			Key:       i.Key,
+			SPA:       i.SPA,
+			Outbound:  i.Outbound,
+			Vitals:    i.Vitals,
+			Hash:      i.Hash,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		sum := sha256.Sum256(script)
+		etag := hex.EncodeToString(sum[:16])
+		if i.HasConditionalParams() {
+			if err := i.PreconditionFailed(etag, time.Time{}); err != nil {
+				return nil, err
+			}
+		}
+
+		return &ScriptResponse{
+			ContentType:  "text/javascript; charset=utf-8",
+			CacheControl: trackerCacheControl,
+			ETag:         `"` + etag + `"`,
+			Body:         script,
+		}, nil
+	})
+}
+
+// renderTracker renders the tracker script with the configuration. The configuration is rendered as JSON, which escapes
+// any markup in the project ID.
+func renderTracker(cfg TrackerConfig) ([]byte, error) {
+	config, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var script bytes.Buffer
+	err = trackerTemplate.Execute(&script, string(config))
+	if err != nil {
+		return nil, err
+	}
+
+	return script.Bytes(), nil
+}
@@ -0,0 +1,172 @@
+/* Ponrove tracker, served by the ingestion service. Configuration is rendered per project from the query parameters of
+ * the script URL. Reports are sent with navigator.sendBeacon as text/plain, which browsers send cross-origin without a
+ * preflight request, and fall back to fetch with keepalive. */
+(function (window, document, config) {
+  "use strict";
+
+  var navigator = window.navigator;
+  var location = window.location;
+  var performance = window.performance;
+
+  // Automated browsers and pages opened from disk are not tracked.
+  if (!config.project_id || navigator.webdriver || location.protocol === "file:") {
+    return;
+  }
+
+  var script = document.currentScript;
+  var endpoint = new URL(script && script.src ? script.src : "/", location.href).origin + "/api/ingestion/report/batch";
+  if (config.key) {
+    endpoint += "?key=" + encodeURIComponent(config.key);
+  }
+
+  function send(items) {
+    var body = JSON.stringify(items);
+    var blob = new Blob([body], { type: "text/plain;charset=UTF-8" });
+    if (navigator.sendBeacon && navigator.sendBeacon(endpoint, blob)) {
+      return;
+    }
+    if (window.fetch) {
+      window.fetch(endpoint, { method: "POST", body: body, keepalive: true, mode: "no-cors", credentials: "omit" });
+    }
+  }
+
+  function currentURL() {
+    return config.hash ? location.href : location.href.split("#")[0];
+  }
+
+  // Web vitals of the initial page load, SPA navigations have none. The largest contentful paint may change until the
+  // visitor interacts with the page, the vitals are reported once when the page is first hidden.
+  var vitals = {};
+  var vitalsURL = currentURL();
+  var vitalsReported = !config.vitals;
+  function observe(type, callback) {
+    try {
+      new PerformanceObserver(function (list) {
+        list.getEntries().forEach(callback);
+      }).observe({ type: type, buffered: true });
+    } catch (e) {
+      // The entry type is not supported by the browser.
+    }
+  }
+  if (config.vitals && window.PerformanceObserver) {
+    observe("paint", function (entry) {
+      if (entry.name === "first-contentful-paint") {
+        vitals.first_contentful_paint_ms = Math.round(entry.startTime);
+      }
+    });
+    observe("largest-contentful-paint", function (entry) {
+      vitals.largest_contentful_paint_ms = Math.round(entry.startTime);
+    });
+  }
+
+  function pageLoadTime() {
+    var entries = performance && performance.getEntriesByType ? performance.getEntriesByType("navigation") : [];
+    if (entries.length > 0 && entries[0].loadEventEnd > 0) {
+      return Math.round(entries[0].loadEventEnd);
+    }
+  }
+
+  function reportVitals() {
+    if (vitalsReported) {
+      return;
+    }
+    vitalsReported = true;
+    var report = {
+      project_id: config.project_id,
+      url: vitalsURL,
+      page_load_time_ms: pageLoadTime(),
+      first_contentful_paint_ms: vitals.first_contentful_paint_ms,
+      largest_contentful_paint_ms: vitals.largest_contentful_paint_ms
+    };
+    if (report.page_load_time_ms || report.first_contentful_paint_ms || report.largest_contentful_paint_ms) {
+      send([{ vitals: report }]);
+    }
+  }
+
+  // Time on page counts the time the page is visible, reported whenever the page is hidden or left.
+  var pageURL = currentURL();
+  var visibleSince = document.visibilityState === "visible" ? Date.now() : 0;
+  var visibleMs = 0;
+
+  function reportEngagement() {
+    if (visibleSince) {
+      visibleMs += Date.now() - visibleSince;
+      visibleSince = 0;
+    }
+    var seconds = Math.min(Math.round(visibleMs / 1000), 65535);
+    if (seconds > 0) {
+      send([{ event: { project_id: config.project_id, event_name: "page_engagement", url: pageURL, time_on_page_s: seconds } }]);
+    }
+    visibleMs = 0;
+  }
+
+  function reportPageview(referrer) {
+    var pageview = {
+      project_id: config.project_id,
+      url: pageURL,
+      screen_width: window.screen.width,
+      screen_height: window.screen.height
+    };
+    if (referrer) {
+      pageview.referrer = referrer;
+    }
+    send([{ pageview: pageview }]);
+  }
+
+  document.addEventListener("visibilitychange", function () {
+    if (document.visibilityState === "visible") {
+      visibleSince = Date.now();
+    } else {
+      reportVitals();
+      reportEngagement();
+    }
+  });
+  window.addEventListener("pagehide", function () {
+    reportVitals();
+    reportEngagement();
+  });
+
+  // SPA navigations report the engagement of the previous page, then a pageview of the new one.
+  function navigated() {
+    var url = currentURL();
+    if (url === pageURL) {
+      return;
+    }
+    reportVitals();
+    reportEngagement();
+    var referrer = pageURL;
+    pageURL = url;
+    if (document.visibilityState === "visible") {
+      visibleSince = Date.now();
+    }
+    reportPageview(referrer);
+  }
+  if (config.spa && window.history && window.history.pushState) {
+    ["pushState", "replaceState"].forEach(function (method) {
+      var original = window.history[method];
+      window.history[method] = function () {
+        var result = original.apply(this, arguments);
+        navigated();
+        return result;
+      };
+    });
+    window.addEventListener("popstate", navigated);
+    if (config.hash) {
+      window.addEventListener("hashchange", navigated);
+    }
+  }
+
+  // Clicks on links leaving the site are reported as outbound link events.
+  if (config.outbound) {
+    document.addEventListener("click", function (e) {
+      var link = e.target && e.target.closest ? e.target.closest("a[href]") : null;
+      if (!link || link.host === location.host || !/^https?:$/.test(link.protocol)) {
+        return;
+      }
+      send([{ event: { project_id: config.project_id, event_name: "outbound_link", url: pageURL, custom_properties: { href: link.href.slice(0, 512) } } }]);
+    }, true);
+  }
+
+  // The tracker is included with defer, so the document is parsed and the initial pageview can be reported right away.
+  reportPageview(document.referrer);
+})(window, document, {{.}});
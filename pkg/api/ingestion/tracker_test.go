@@ -0,0 +1,78 @@
+package ingestion_test
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+func (suite *IngestionAPITestSuite) getScript(srvURL string, query url.Values, etag string) (*http.Response, string) {
+	req, err := http.NewRequest(http.MethodGet, srvURL+"/api/ingestion/script.js?"+query.Encode(), nil)
+	suite.Require().NoError(err)
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	suite.Require().NoError(err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	suite.Require().NoError(err)
+	return resp, string(body)
+}
+
+func (suite *IngestionAPITestSuite) TestScriptEndpoint() {
+	_, driver := setupBatchDB(suite.T())
+	srv := suite.createServer(driver)
+	defer srv.Close()
+
+	resp, body := suite.getScript(srv.URL, url.Values{"project_id": {"project-1"}}, "")
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	suite.Equal("text/javascript; charset=utf-8", resp.Header.Get("Content-Type"))
+	suite.Equal("public, max-age=3600, stale-while-revalidate=86400", resp.Header.Get("Cache-Control"))
+	suite.Contains(body, `{"project_id":"project-1","spa":true,"outbound":true,"vitals":true,"hash":false}`)
+	suite.Contains(body, "/api/ingestion/report/batch")
+	etag := resp.Header.Get("ETag")
+	suite.Regexp(`^"[0-9a-f]{32}"$`, etag)
+
+	// The ETag is derived from the rendered script, a cached copy is revalidated without a body.
+	resp, body = suite.getScript(srv.URL, url.Values{"project_id": {"project-1"}}, etag)
+	suite.Equal(http.StatusNotModified, resp.StatusCode)
+	suite.Empty(body)
+
+	resp, body = suite.getScript(srv.URL, url.Values{"project_id": {"project-1"}, "key": {"pk_1"}, "spa": {"false"}, "hash": {"true"}}, etag)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	suite.NotEqual(etag, resp.Header.Get("ETag"))
+	suite.Contains(body, `{"project_id":"project-1","key":"pk_1","spa":false,"outbound":true,"vitals":true,"hash":true}`)
+}
+
+func (suite *IngestionAPITestSuite) TestScriptEndpointValidation() {
+	_, driver := setupBatchDB(suite.T())
+	srv := suite.createServer(driver)
+	defer srv.Close()
+
+	resp, _ := suite.getScript(srv.URL, url.Values{}, "")
+	suite.Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+
+	// Markup in the project ID can not break out of the script element the tracker is included with.
+	resp, body := suite.getScript(srv.URL, url.Values{"project_id": {"</script><script>alert(1)"}}, "")
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	suite.NotContains(body, "</script>")
+	suite.Contains(body, `"project_id":"\u003c/script\u003e\u003cscript\u003ealert(1)"`)
+}
+
+func (suite *IngestionAPITestSuite) TestBatchEndpointBeacon() {
+	conn, driver := setupBatchDB(suite.T())
+	srv := suite.createServer(driver)
+	defer srv.Close()
+
+	// The tracker sends its reports as text/plain, which browsers send cross-origin without a preflight request.
+	resp, body := suite.postBatch(srv.URL, "text/plain;charset=UTF-8", `[
+		{"event": {"project_id": "project-1", "event_name": "page_engagement", "url": "https://example.com/", "time_on_page_s": 42}}
+	]`)
+	suite.Equal(http.StatusAccepted, resp.StatusCode)
+	suite.Equal(1, body.Accepted)
+
+	suite.Eventually(func() bool { return len(conn.rows()) == 1 }, time.Second, time.Millisecond)
+	suite.Contains(conn.rows()[0], uint16(42))
+}
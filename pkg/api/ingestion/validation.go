@@ -0,0 +1,126 @@
+package ingestion
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/ponrove/ponrove-backend/internal/events"
+)
+
+const (
+	// maxClockSkew is how far into the future a client supplied timestamp may be, before the event is rejected.
+	maxClockSkew = 5 * time.Minute
+
+	// Limits applied to the custom properties of an event, to keep the custom_properties map of raw_events bounded.
+	maxCustomProperties          = 32
+	maxCustomPropertyKeyLength   = 64
+	maxCustomPropertyValueLength = 512
+)
+
+// validationError returns a 422 Unprocessable Entity error pointing at the offending field.
+func validationError(location, message string, value any) error {
+	return huma.Error422UnprocessableEntity("validation failed", &huma.ErrorDetail{
+		Location: location,
+		Message:  message,
+		Value:    value,
+	})
+}
+
+// resolveTimestamp returns the timestamp of an event, defaulting to now when the client did not supply one. Timestamps
+// further into the future than maxClockSkew are rejected.
+func resolveTimestamp(location string, timestamp, now time.Time) (time.Time, error) {
+	if timestamp.IsZero() {
+		return now, nil
+	}
+
+	if timestamp.After(now.Add(maxClockSkew)) {
+		return time.Time{}, validationError(location, "timestamp is too far in the future", timestamp)
+	}
+
+	return timestamp, nil
+}
+
+// applyPageContext sets the URL and referrer columns of the event, an empty URL is allowed when optional is true.
+func applyPageContext(event *events.Event, location, url, referrer string, optional bool) error {
+	if url != "" || !optional {
+		if err := event.SetURL(url); err != nil {
+			return validationError(location+".url", err.Error(), url)
+		}
+	}
+
+	if err := event.SetReferrer(referrer); err != nil {
+		return validationError(location+".referrer", err.Error(), referrer)
+	}
+
+	return nil
+}
+
+// applyServerSide marks the event as reported by a server on behalf of the visitor when the payload supplied the user
+// agent of the visitor, along with the IP address keying their fingerprint.
+func applyServerSide(event *events.Event, location, userAgent, visitorIP string) error {
+	if userAgent == "" {
+		if visitorIP != "" {
+			return validationError(location+".visitor_ip", "expected the user agent of the visitor along with their IP address", visitorIP)
+		}
+		return nil
+	}
+
+	event.Source = events.SourceServer
+	event.UserAgent = userAgent
+	if visitorIP != "" {
+		addr, err := netip.ParseAddr(visitorIP)
+		if err != nil {
+			return validationError(location+".visitor_ip", "invalid IP address", visitorIP)
+		}
+		event.VisitorIP = addr.Unmap()
+	}
+
+	return nil
+}
+
+// validateCustomProperties enforces the count and length limits of custom properties. All violations are reported at
+// once, in key order, so clients can fix their payload in a single round trip.
+func validateCustomProperties(location string, properties map[string]string) error {
+	if len(properties) > maxCustomProperties {
+		return validationError(location, fmt.Sprintf("expected at most %d custom properties", maxCustomProperties), len(properties))
+	}
+
+	keys := make([]string, 0, len(properties))
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var details []error
+	for _, key := range keys {
+		keyLocation := location + "." + key
+		switch {
+		case key == "":
+			details = append(details, &huma.ErrorDetail{Location: location, Message: "custom property keys must not be empty", Value: key})
+		case utf8.RuneCountInString(key) > maxCustomPropertyKeyLength:
+			details = append(details, &huma.ErrorDetail{
+				Location: keyLocation,
+				Message:  fmt.Sprintf("expected custom property key length <= %d", maxCustomPropertyKeyLength),
+				Value:    key,
+			})
+		}
+
+		if utf8.RuneCountInString(properties[key]) > maxCustomPropertyValueLength {
+			details = append(details, &huma.ErrorDetail{
+				Location: keyLocation,
+				Message:  fmt.Sprintf("expected custom property value length <= %d", maxCustomPropertyValueLength),
+				Value:    properties[key],
+			})
+		}
+	}
+
+	if len(details) > 0 {
+		return huma.Error422UnprocessableEntity("validation failed", details...)
+	}
+
+	return nil
+}
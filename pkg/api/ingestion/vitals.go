@@ -0,0 +1,87 @@
+package ingestion
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/ponrove/ponrove-backend/internal/events"
+)
+
+// VitalsPayload is the body of a web vitals report. Unlike the web vitals of a pageview, which are sent as the page
+// loads, the report is sent once the metrics are final, e.g. when the page is hidden.
+type VitalsPayload struct {
+	ProjectID                string    `json:"project_id" minLength:"1" maxLength:"128" doc:"Identifier of the project the page belongs to."`
+	URL                      string    `json:"url" minLength:"1" maxLength:"4096" doc:"Absolute http(s) URL of the measured page."`
+	Timestamp                time.Time `json:"timestamp,omitempty" doc:"When the page was loaded, defaults to the time the report is received."`
+	PageLoadTimeMs           uint32    `json:"page_load_time_ms,omitempty" maximum:"3600000" doc:"Total time taken for the page to load in milliseconds."`
+	FirstContentfulPaintMs   uint32    `json:"first_contentful_paint_ms,omitempty" maximum:"3600000" doc:"First Contentful Paint (FCP) in milliseconds."`
+	LargestContentfulPaintMs uint32    `json:"largest_contentful_paint_ms,omitempty" maximum:"3600000" doc:"Largest Contentful Paint (LCP) in milliseconds."`
+}
+
+// VitalsRequest is the request of the web vitals endpoint.
+type VitalsRequest struct {
+	ClientHeaders
+	Body VitalsPayload
+}
+
+// RegisterVitalsEndpoint registers the endpoint used by clients to report the web vitals of a page load.
+func (a *server) RegisterVitalsEndpoint(api huma.API) {
+	huma.Register(api, huma.Operation{
+		OperationID:   "Report Web Vitals",
+		Method:        http.MethodPost,
+		Path:          "/report/vitals",
+		Tags:          []string{"Ingestion"},
+		DefaultStatus: http.StatusAccepted,
+		Parameters:    ingestionKeyParams(),
+		Middlewares:   a.projectMiddlewares(api),
+	}, func(ctx context.Context, i *VitalsRequest) (*IngestionEndpointResponse, error) {
+		event, err := i.Body.event("body", time.Now())
+		if err != nil {
+			return nil, err
+		}
+		err = a.authorize(ctx, "body", event)
+		if err != nil {
+			return nil, err
+		}
+		if a.enrich(ctx, &event, i.ClientHeaders) {
+			err = a.store(ctx, event)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return accepted("Web vitals accepted."), nil
+	})
+}
+
+// event converts the payload into a raw web_vitals event. The returned error is a huma status error pointing at the
+// offending field, relative to location.
+func (p *VitalsPayload) event(location string, now time.Time) (events.Event, error) {
+	if p.PageLoadTimeMs == 0 && p.FirstContentfulPaintMs == 0 && p.LargestContentfulPaintMs == 0 {
+		return events.Event{}, validationError(location, "expected at least one metric", nil)
+	}
+
+	timestamp, err := resolveTimestamp(location+".timestamp", p.Timestamp, now)
+	if err != nil {
+		return events.Event{}, err
+	}
+
+	event := events.Event{
+		ProjectID:                p.ProjectID,
+		EventTimestamp:           timestamp,
+		EventName:                events.EventNameWebVitals,
+		Source:                   events.SourceClient,
+		PageLoadTimeMs:           p.PageLoadTimeMs,
+		FirstContentfulPaintMs:   p.FirstContentfulPaintMs,
+		LargestContentfulPaintMs: p.LargestContentfulPaintMs,
+	}
+
+	err = applyPageContext(&event, location, p.URL, "", false)
+	if err != nil {
+		return events.Event{}, err
+	}
+
+	return event, nil
+}
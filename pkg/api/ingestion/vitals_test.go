@@ -0,0 +1,129 @@
+package ingestion_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/ponrove/ponrove-backend/internal/events"
+	"github.com/ponrove/ponrove-backend/internal/session"
+)
+
+func (suite *IngestionAPITestSuite) TestVitalsEndpoint() {
+	var body struct {
+		Message string `json:"message"`
+	}
+
+	botName := "Go HTTP client"
+	expected := events.Event{
+		ProjectID:                "project-1",
+		EventTimestamp:           time.Date(2025, 6, 16, 12, 0, 0, 0, time.UTC),
+		EventName:                events.EventNameWebVitals,
+		Source:                   events.SourceClient,
+		URL:                      "https://example.com/pricing",
+		URLPath:                  "/pricing",
+		URLHost:                  "example.com",
+		IsBot:                    true,
+		BotName:                  &botName,
+		UserAgent:                "Go-http-client/1.1",
+		PageLoadTimeMs:           1830,
+		FirstContentfulPaintMs:   640,
+		LargestContentfulPaintMs: 1210,
+	}
+
+	conn, driver := setupBatchDB(suite.T())
+	srv := suite.createServer(driver)
+	defer srv.Close()
+
+	resp, err := postJSON(srv.URL+"/api/ingestion/report/vitals", `{
+		"project_id": "project-1",
+		"url": "https://example.com/pricing",
+		"timestamp": "2025-06-16T12:00:00Z",
+		"page_load_time_ms": 1830,
+		"first_contentful_paint_ms": 640,
+		"largest_contentful_paint_ms": 1210
+	}`)
+	suite.NoError(err)
+	defer resp.Body.Close()
+	suite.Equal(http.StatusAccepted, resp.StatusCode)
+	suite.NoError(json.NewDecoder(resp.Body).Decode(&body))
+	suite.Equal("Web vitals accepted.", body.Message)
+
+	suite.Eventually(func() bool { return len(conn.rows()) == 1 }, time.Second, time.Millisecond)
+	row := conn.rows()[0]
+	expected.VisitorFingerprint = suite.fingerprint(row)
+	expected.SessionID = session.ID(expected.ProjectID, expected.VisitorFingerprint, expected.EventTimestamp)
+	suite.Equal(expected.Values(), row)
+}
+
+func (suite *IngestionAPITestSuite) TestVitalsEndpointValidation() {
+	testCases := []struct {
+		name     string
+		body     string
+		location string
+	}{
+		{
+			name:     "No metrics",
+			body:     `{"project_id": "project-1", "url": "https://example.com/"}`,
+			location: "body",
+		},
+		{
+			name:     "Missing URL",
+			body:     `{"project_id": "project-1", "page_load_time_ms": 1830}`,
+			location: "body",
+		},
+		{
+			name:     "Invalid URL",
+			body:     `{"project_id": "project-1", "url": "example.com", "page_load_time_ms": 1830}`,
+			location: "body.url",
+		},
+		{
+			name:     "Metric out of range",
+			body:     `{"project_id": "project-1", "url": "https://example.com/", "page_load_time_ms": 3600001}`,
+			location: "body.page_load_time_ms",
+		},
+	}
+
+	conn, driver := setupBatchDB(suite.T())
+	srv := suite.createServer(driver)
+	defer srv.Close()
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			var body struct {
+				Errors []struct {
+					Location string `json:"location"`
+				} `json:"errors"`
+			}
+
+			resp, err := postJSON(srv.URL+"/api/ingestion/report/vitals", tc.body)
+			suite.NoError(err)
+			defer resp.Body.Close()
+			suite.Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+			suite.NoError(json.NewDecoder(resp.Body).Decode(&body))
+			suite.Require().NotEmpty(body.Errors)
+			suite.Equal(tc.location, body.Errors[0].Location)
+		})
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	suite.Empty(conn.rows())
+}
+
+func (suite *IngestionAPITestSuite) TestBatchEndpointVitals() {
+	conn, driver := setupBatchDB(suite.T())
+	srv := suite.createServer(driver)
+	defer srv.Close()
+
+	resp, body := suite.postBatch(srv.URL, "text/plain;charset=UTF-8", `[
+		{"vitals": {"project_id": "project-1", "url": "https://example.com/", "largest_contentful_paint_ms": 2400}},
+		{"vitals": {"project_id": "project-1", "url": "https://example.com/", "largest_contentful_paint_ms": 2400}, "event": {"project_id": "project-1", "event_name": "click"}}
+	]`)
+	suite.Equal(http.StatusAccepted, resp.StatusCode)
+	suite.Equal(1, body.Accepted)
+	suite.Equal(1, body.Rejected)
+	suite.Equal("body[1]", body.Results[1].Errors[0].Location)
+
+	suite.Eventually(func() bool { return len(conn.rows()) == 1 }, time.Second, time.Millisecond)
+	suite.Equal(events.EventNameWebVitals, conn.rows()[0][2])
+}
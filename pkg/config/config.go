@@ -3,6 +3,7 @@ package config
 import (
 	"github.com/ponrove/configura"
 	"github.com/ponrove/ponrove-backend/internal/database"
+	"github.com/ponrove/ponrove-backend/internal/session"
 	"github.com/ponrove/ponrove-backend/pkg/api/hub"
 	"github.com/ponrove/ponrove-backend/pkg/api/ingestion"
 	"github.com/ponrove/ponrunner"
@@ -34,6 +35,8 @@ func New() configura.Config {
 		configura.LoadEnvironment(serverConfigInstance, ponrunner.SERVER_LOG_FORMAT, "json")
 		/* Clickhouse */
 		configura.LoadEnvironment(serverConfigInstance, database.CLICKHOUSE_DSN, "")
+		/* Sessions */
+		configura.LoadEnvironment(serverConfigInstance, session.SESSION_TIMEOUT_MS, session.DefaultTimeout.Milliseconds())
 		/* Open Telemetry */
 		configura.LoadEnvironment(serverConfigInstance, ponrunner.OTEL_ENABLED, false)
 		configura.LoadEnvironment(serverConfigInstance, ponrunner.OTEL_LOGS_ENABLED, false)
@@ -58,8 +61,29 @@ func New() configura.Config {
 		configura.LoadEnvironment(serverConfigInstance, ponrunner.OTEL_EXPORTER_OTLP_LOGS_PROTOCOL, "grpc")
 		/* Ingestion API configuration */
 		configura.LoadEnvironment(serverConfigInstance, ingestion.INGESTION_API_TEST_FLAG, false)
+		configura.LoadEnvironment(serverConfigInstance, ingestion.INGESTION_BUFFER_MAX_BATCH_SIZE, int64(1000))
+		configura.LoadEnvironment(serverConfigInstance, ingestion.INGESTION_BUFFER_MAX_EVENTS, int64(100000))
+		configura.LoadEnvironment(serverConfigInstance, ingestion.INGESTION_BUFFER_FLUSH_INTERVAL_MS, int64(1000))
+		configura.LoadEnvironment(serverConfigInstance, ingestion.INGESTION_SPOOL_DIR, "")
+		configura.LoadEnvironment(serverConfigInstance, ingestion.INGESTION_SPOOL_MAX_BYTES, int64(1024*1024*1024))
+		configura.LoadEnvironment(serverConfigInstance, ingestion.INGESTION_SPOOL_REPLAY_INTERVAL_MS, int64(5000))
+		configura.LoadEnvironment(serverConfigInstance, ingestion.INGESTION_USERAGENT_RULES_FILE, "")
+		configura.LoadEnvironment(serverConfigInstance, ingestion.INGESTION_BOTS_DENY_LIST, "")
+		configura.LoadEnvironment(serverConfigInstance, ingestion.INGESTION_BOTS_DROP, false)
+		configura.LoadEnvironment(serverConfigInstance, ingestion.INGESTION_TRUSTED_PROXIES, "")
+		configura.LoadEnvironment(serverConfigInstance, ingestion.INGESTION_GEOIP_DATABASE, "")
+		configura.LoadEnvironment(serverConfigInstance, ingestion.INGESTION_RELOAD_INTERVAL_MS, int64(60000))
+		configura.LoadEnvironment(serverConfigInstance, ingestion.INGESTION_IPREP_VPN_LISTS, "")
+		configura.LoadEnvironment(serverConfigInstance, ingestion.INGESTION_IPREP_PROXY_LISTS, "")
+		configura.LoadEnvironment(serverConfigInstance, ingestion.INGESTION_IPREP_TOR_LISTS, "")
+		configura.LoadEnvironment(serverConfigInstance, ingestion.INGESTION_PROJECTS_ENFORCE, false)
+		configura.LoadEnvironment(serverConfigInstance, ingestion.INGESTION_PROJECTS_REFRESH_INTERVAL_MS, int64(30000))
 		/* Hub API configuration */
 		configura.LoadEnvironment(serverConfigInstance, hub.HUB_API_TEST_FLAG, false)
+		configura.LoadEnvironment(serverConfigInstance, hub.HUB_RETENTION_MIN_DAYS, int64(1))
+		configura.LoadEnvironment(serverConfigInstance, hub.HUB_RETENTION_MAX_DAYS, int64(3650))
+		configura.LoadEnvironment(serverConfigInstance, hub.HUB_REVENUE_CURRENCY, "USD")
+		configura.LoadEnvironment(serverConfigInstance, hub.HUB_REVENUE_RATES, "")
 	}
 
 	return serverConfigInstance